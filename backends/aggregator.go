@@ -1,6 +1,12 @@
 package backends
 
 import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/ghetzel/go-stockutil/stringutil"
 	"github.com/sniperkit/pivot/dal"
 	"github.com/sniperkit/pivot/filter"
 )
@@ -15,3 +21,249 @@ type Aggregator interface {
 	Average(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error)
 	GroupBy(collection *dal.Collection, fields []string, aggregates []filter.Aggregate, f ...*filter.Filter) (*dal.RecordSet, error)
 }
+
+// accumulates the values needed to calculate any supported aggregation for a single field
+type aggregateValue struct {
+	count uint64
+	sum   float64
+	min   float64
+	max   float64
+	first interface{}
+	last  interface{}
+}
+
+func (self *aggregateValue) Add(value interface{}) {
+	if value == nil {
+		return
+	}
+
+	if self.count == 0 {
+		self.first = value
+		self.min = math.MaxFloat64
+		self.max = -math.MaxFloat64
+	}
+
+	self.last = value
+	self.count += 1
+
+	if v, err := stringutil.ConvertToFloat(value); err == nil {
+		self.sum += v
+		self.min = math.Min(self.min, v)
+		self.max = math.Max(self.max, v)
+	}
+}
+
+func (self *aggregateValue) Value(aggregation filter.Aggregation) interface{} {
+	switch aggregation {
+	case filter.First:
+		return self.first
+	case filter.Last:
+		return self.last
+	case filter.Count:
+		return self.count
+	}
+
+	if self.count == 0 {
+		return float64(0)
+	}
+
+	switch aggregation {
+	case filter.Minimum:
+		return self.min
+	case filter.Maximum:
+		return self.max
+	case filter.Sum:
+		return self.sum
+	case filter.Average:
+		return (self.sum / float64(self.count))
+	default:
+		return nil
+	}
+}
+
+// a single group of results, identified by the values of the fields being grouped by
+type aggregateGroup struct {
+	keys   map[string]interface{}
+	values map[string]*aggregateValue
+	count  uint64
+}
+
+// accumulates records one at a time into one group per distinct combination of grouped values
+type groupAccumulator struct {
+	collection  *dal.Collection
+	getValue    func(collection *dal.Collection, record *dal.Record, field string) interface{}
+	groupBy     []string
+	aggregates  []filter.Aggregate
+	groups      []*aggregateGroup
+	groupsByKey map[string]*aggregateGroup
+}
+
+// Values are read from each record with the given function (e.g. memoryRecordValue), since backends
+// differ in how they store nested and identity values.
+func newGroupAccumulator(collection *dal.Collection, groupBy []string, aggregates []filter.Aggregate, getValue func(collection *dal.Collection, record *dal.Record, field string) interface{}) *groupAccumulator {
+	return &groupAccumulator{
+		collection:  collection,
+		getValue:    getValue,
+		groupBy:     groupBy,
+		aggregates:  aggregates,
+		groups:      make([]*aggregateGroup, 0),
+		groupsByKey: make(map[string]*aggregateGroup),
+	}
+}
+
+// Adds the grouped and aggregated values of the given record to the group it belongs to.
+func (self *groupAccumulator) Add(record *dal.Record) error {
+	keys := make(map[string]interface{})
+	keyParts := make([]string, len(self.groupBy))
+
+	for i, field := range self.groupBy {
+		value := self.getValue(self.collection, record, field)
+		keys[field] = value
+		keyParts[i] = fmt.Sprintf("%v", value)
+	}
+
+	groupKey := strings.Join(keyParts, "\x00")
+	group, ok := self.groupsByKey[groupKey]
+
+	if !ok {
+		if len(self.groups) >= MaxFacetCardinality {
+			return fmt.Errorf("GroupBy exceeds the maximum of %d groups", MaxFacetCardinality)
+		}
+
+		group = &aggregateGroup{
+			keys:   keys,
+			values: make(map[string]*aggregateValue),
+		}
+
+		self.groupsByKey[groupKey] = group
+		self.groups = append(self.groups, group)
+	}
+
+	group.count += 1
+
+	// several aggregates on the same field share the values accumulated for it
+	added := make(map[string]bool)
+
+	for _, aggregate := range self.aggregates {
+		if added[aggregate.Field] {
+			continue
+		}
+
+		value, ok := group.values[aggregate.Field]
+
+		if !ok {
+			value = new(aggregateValue)
+			group.values[aggregate.Field] = value
+		}
+
+		value.Add(self.getValue(self.collection, record, aggregate.Field))
+		added[aggregate.Field] = true
+	}
+
+	return nil
+}
+
+// Builds a recordset from aggregated groups, with one record per group containing the grouped values
+// and the value of each aggregate (see filter.AggregateResultName).  Groups that don't match the
+// Having criteria of the given filter are omitted.
+func groupedRecordSet(collection *dal.Collection, groups []*aggregateGroup, aggregates []filter.Aggregate, f *filter.Filter) *dal.RecordSet {
+	recordset := dal.NewRecordSet()
+	having := f.HavingFilter(aggregates...)
+
+	for _, group := range groups {
+		record := dal.NewRecord(nil)
+
+		for field, value := range group.keys {
+			if field == collection.IdentityField {
+				record.ID = collection.ConvertValue(field, value)
+			} else {
+				record.Set(field, collection.ConvertValue(field, value))
+			}
+		}
+
+		for _, aggregate := range aggregates {
+			var value interface{}
+
+			name := filter.AggregateResultName(aggregates, aggregate)
+
+			if v, ok := group.values[aggregate.Field]; ok {
+				value = collection.ConvertValue(aggregate.Field, v.Value(aggregate.Aggregation))
+			}
+
+			if name == collection.IdentityField {
+				record.ID = value
+			} else {
+				record.Set(name, value)
+			}
+		}
+
+		if having.MatchesRecord(record) {
+			recordset.Push(record)
+		}
+	}
+
+	return recordset
+}
+
+// Sorts the records in a recordset by the sort fields of the given filter, using get to retrieve the
+// value of a field from a record.  Numeric values are compared as numbers, all others as strings.
+// Sort fields referring to any of the given aggregates sort by the aggregated value.
+func sortRecords(recordset *dal.RecordSet, f *filter.Filter, aggregates []filter.Aggregate, get func(record *dal.Record, field string) interface{}) {
+	sortBy := f.GetSort()
+
+	if len(sortBy) == 0 {
+		return
+	}
+
+	for i, s := range sortBy {
+		sortBy[i].Field = filter.ResultFieldName(aggregates, s.Field)
+	}
+
+	sort.SliceStable(recordset.Records, func(i int, j int) bool {
+		for _, s := range sortBy {
+			a := get(recordset.Records[i], s.Field)
+			b := get(recordset.Records[j], s.Field)
+
+			var less, greater bool
+
+			if aF, err := stringutil.ConvertToFloat(a); err == nil {
+				if bF, err := stringutil.ConvertToFloat(b); err == nil {
+					less = (aF < bF)
+					greater = (aF > bF)
+				}
+			}
+
+			if !less && !greater {
+				aS := fmt.Sprintf("%v", a)
+				bS := fmt.Sprintf("%v", b)
+				less = (aS < bS)
+				greater = (aS > bS)
+			}
+
+			if less {
+				return !s.Descending
+			} else if greater {
+				return s.Descending
+			}
+		}
+
+		return false
+	})
+}
+
+// applies the offset and limit of the given filter to an already-populated recordset
+func limitRecords(recordset *dal.RecordSet, f *filter.Filter) {
+	if f.Offset > 0 {
+		if f.Offset < len(recordset.Records) {
+			recordset.Records = recordset.Records[f.Offset:]
+		} else {
+			recordset.Records = make([]*dal.Record, 0)
+		}
+	}
+
+	if f.Limit > 0 && len(recordset.Records) > f.Limit {
+		recordset.Records = recordset.Records[:f.Limit]
+	}
+
+	recordset.ResultCount = int64(len(recordset.Records))
+}
@@ -0,0 +1,62 @@
+package backends
+
+import (
+	"testing"
+
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupedRecordSetMultipleAggregates(t *testing.T) {
+	assert := require.New(t)
+
+	collection := dal.NewCollection(`orders`).
+		AddFields(dal.Field{
+			Name: `state`,
+			Type: dal.StringType,
+		}, dal.Field{
+			Name: `amount`,
+			Type: dal.IntType,
+		})
+
+	aggregates := []filter.Aggregate{
+		{Aggregation: filter.Sum, Field: `amount`},
+		{Aggregation: filter.Count, Field: `amount`},
+	}
+
+	accumulator := newGroupAccumulator(collection, []string{`state`}, aggregates, memoryRecordValue)
+
+	for i, order := range []map[string]interface{}{
+		{`state`: `ca`, `amount`: 600},
+		{`state`: `ca`, `amount`: 700},
+		{`state`: `ny`, `amount`: 100},
+		{`state`: `ny`, `amount`: 200},
+		{`state`: `ny`, `amount`: 300},
+		{`state`: `tx`, `amount`: 1100},
+	} {
+		assert.NoError(accumulator.Add(dal.NewRecord(i + 1).SetFields(order)))
+	}
+
+	f := filter.All()
+	f.Sort = []string{`-count:amount`}
+	f.AddHaving(filter.Criterion{
+		Field:    `sum:amount`,
+		Operator: `gt`,
+		Values:   []interface{}{1000},
+	})
+
+	recordset := groupedRecordSet(collection, accumulator.groups, aggregates, f)
+
+	sortRecords(recordset, f, aggregates, func(record *dal.Record, field string) interface{} {
+		return record.Get(field)
+	})
+
+	assert.Len(recordset.Records, 2)
+	assert.Equal(`ca`, recordset.Records[0].Get(`state`))
+	assert.EqualValues(1300, recordset.Records[0].Get(`sum:amount`))
+	assert.EqualValues(2, recordset.Records[0].Get(`count:amount`))
+	assert.Equal(`tx`, recordset.Records[1].Get(`state`))
+	assert.EqualValues(1100, recordset.Records[1].Get(`sum:amount`))
+	assert.EqualValues(1, recordset.Records[1].Get(`count:amount`))
+}
@@ -35,19 +35,27 @@ type Backend interface {
 }
 
 var NotImplementedError = fmt.Errorf("Not Implemented")
+var ReadOnlyError = fmt.Errorf("Backend is read-only")
 
 type BackendFunc func(dal.ConnectionString) Backend
 
 var backendMap = map[string]BackendFunc{
+	`bolt`:       NewBoltBackend,
 	`dynamodb`:   NewDynamoBackend,
 	`file`:       NewFilesystemBackend,
 	`fs`:         NewFilesystemBackend,
+	`memory`:     NewMemoryBackend,
 	`mongodb`:    NewMongoBackend,
 	`mysql`:      NewSqlBackend,
+	`parquet`:    NewParquetBackend,
+	`pivot`:      NewRemoteBackend,
+	`plugin`:     NewPluginBackend,
 	`postgres`:   NewSqlBackend,
 	`postgresql`: NewSqlBackend,
 	`psql`:       NewSqlBackend,
+	`redis`:      NewRedisBackend,
 	`sqlite`:     NewSqlBackend,
+	`tabular`:    NewTabularBackend,
 }
 
 func RegisterBackend(name string, fn BackendFunc) {
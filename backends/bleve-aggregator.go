@@ -0,0 +1,473 @@
+package backends
+
+// this file satifies the Aggregator interface for BleveIndexer
+
+import (
+	"fmt"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search"
+	"github.com/blevesearch/bleve/search/query"
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/ghetzel/go-stockutil/typeutil"
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter"
+)
+
+func (self *BleveIndexer) Sum(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	return self.aggregateFloat(collection, filter.Sum, field, f)
+}
+
+func (self *BleveIndexer) Count(collection *dal.Collection, flt ...*filter.Filter) (uint64, error) {
+	var f *filter.Filter
+
+	if len(flt) > 0 {
+		f = flt[0]
+	} else {
+		f = filter.All()
+	}
+
+	if index, err := self.getIndexForCollection(collection); err == nil {
+		if bq, err := self.filterToBleveQuery(index, f); err == nil {
+			if results, err := index.Search(bleve.NewSearchRequestOptions(bq, 0, 0, false)); err == nil {
+				return results.Total, nil
+			} else {
+				return 0, err
+			}
+		} else {
+			return 0, err
+		}
+	} else {
+		return 0, err
+	}
+}
+
+func (self *BleveIndexer) Minimum(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	return self.aggregateFloat(collection, filter.Minimum, field, f)
+}
+
+func (self *BleveIndexer) Maximum(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	return self.aggregateFloat(collection, filter.Maximum, field, f)
+}
+
+func (self *BleveIndexer) Average(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	return self.aggregateFloat(collection, filter.Average, field, f)
+}
+
+func (self *BleveIndexer) GroupBy(collection *dal.Collection, groupBy []string, aggregates []filter.Aggregate, flt ...*filter.Filter) (*dal.RecordSet, error) {
+	var f *filter.Filter
+
+	if len(flt) > 0 {
+		f = flt[0]
+	} else {
+		f = filter.All()
+	}
+
+	if groups, err := self.aggregate(collection, groupBy, aggregates, f); err == nil {
+		recordset := groupedRecordSet(collection, groups, aggregates, f)
+
+		sortRecords(recordset, f, aggregates, func(record *dal.Record, field string) interface{} {
+			if field == collection.IdentityField || field == BleveIdentityField {
+				return record.ID
+			}
+
+			return record.Get(field)
+		})
+
+		limitRecords(recordset, f)
+
+		return recordset, nil
+	} else {
+		return nil, err
+	}
+}
+
+func (self *BleveIndexer) AggregatorConnectionString() *dal.ConnectionString {
+	return self.conn
+}
+
+func (self *BleveIndexer) AggregatorInitialize(parent Backend) error {
+	return nil
+}
+
+func (self *BleveIndexer) aggregateFloat(collection *dal.Collection, aggregation filter.Aggregation, field string, flt []*filter.Filter) (float64, error) {
+	var f *filter.Filter
+
+	if len(flt) > 0 {
+		f = flt[0]
+	} else {
+		f = filter.All()
+	}
+
+	if groups, err := self.aggregate(collection, nil, []filter.Aggregate{
+		{
+			Aggregation: aggregation,
+			Field:       field,
+		},
+	}, f); err == nil {
+		if len(groups) > 0 {
+			if v, ok := groups[0].values[field]; ok {
+				return stringutil.ConvertToFloat(v.Value(aggregation))
+			}
+		}
+
+		return 0, nil
+	} else {
+		return 0, err
+	}
+}
+
+// a group of documents found using facets, along with the query that matches them
+type bleveFacetGroup struct {
+	keys  map[string]interface{}
+	query query.Query
+	total uint64
+}
+
+// Groups the documents matching the given filter by the values of the groupBy fields and calculates
+// the aggregated values of each group.  Groups are found using terms facets, and aggregated values
+// are calculated from the index where possible (see facetGroupValues).  Grouping by fields that
+// facets can't group by (anything other than string fields), or by fields that some documents have
+// no value for, falls back to reading every matching document.
+func (self *BleveIndexer) aggregate(collection *dal.Collection, groupBy []string, aggregates []filter.Aggregate, f *filter.Filter) ([]*aggregateGroup, error) {
+	if !self.canFacet(collection, groupBy) {
+		return self.scanAggregate(collection, groupBy, aggregates, f)
+	}
+
+	index, err := self.getIndexForCollection(collection)
+
+	if err != nil {
+		return nil, err
+	}
+
+	query := filter.Copy(f)
+
+	if query.IdentityField == `` {
+		query.IdentityField = BleveIdentityField
+	}
+
+	bq, err := self.filterToBleveQuery(index, &query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	facetGroups := make([]*bleveFacetGroup, 0)
+
+	if ok, err := self.facetGroups(index, bq, groupBy, 0, &facetGroups); err != nil {
+		return nil, err
+	} else if !ok {
+		return self.scanAggregate(collection, groupBy, aggregates, f)
+	}
+
+	groups := make([]*aggregateGroup, len(facetGroups))
+
+	for i, facetGroup := range facetGroups {
+		if group, err := self.facetGroupValues(index, collection, facetGroup, aggregates); err == nil {
+			groups[i] = group
+		} else {
+			return nil, err
+		}
+	}
+
+	return groups, nil
+}
+
+// Returns whether the given fields can be grouped by using terms facets, which requires them to be
+// string fields whose whole value is a single term.
+func (self *BleveIndexer) canFacet(collection *dal.Collection, groupBy []string) bool {
+	for _, name := range groupBy {
+		if field, ok := collection.GetField(name); ok && !field.Identity && name != collection.IdentityField {
+			switch field.Type {
+			case dal.StringType, dal.EnumType, dal.UUIDType:
+				continue
+			}
+		}
+
+		return false
+	}
+
+	return true
+}
+
+// Finds the groups of documents matching the given query that share each distinct combination of
+// values of the groupBy fields, using a terms facet on each field in turn to find the groups within
+// each group of the fields before it.  Returns false if some documents have no value for one of the
+// fields, since facets don't find those.
+func (self *BleveIndexer) facetGroups(index bleve.Index, bq query.Query, groupBy []string, depth int, groups *[]*bleveFacetGroup) (bool, error) {
+	request := bleve.NewSearchRequestOptions(bq, 1, 0, false)
+	request.Fields = groupBy
+
+	if depth < len(groupBy) {
+		request.AddFacet(groupBy[depth], bleve.NewFacetRequest(groupBy[depth], MaxFacetCardinality))
+	}
+
+	results, err := index.Search(request)
+
+	if err != nil {
+		return false, err
+	} else if results.Total == 0 {
+		return true, nil
+	}
+
+	// all documents in this group share the grouped values, which are read from any one of them
+	// since the facet terms have been through the field's analyzer
+	if depth == len(groupBy) {
+		if len(*groups) >= MaxFacetCardinality {
+			return false, fmt.Errorf("GroupBy exceeds the maximum of %d groups", MaxFacetCardinality)
+		}
+
+		group := &bleveFacetGroup{
+			keys:  make(map[string]interface{}),
+			query: bq,
+			total: results.Total,
+		}
+
+		for _, field := range groupBy {
+			if value := results.Hits[0].Fields[field]; typeutil.IsArray(value) {
+				group.keys[field] = sliceutil.First(value)
+			} else {
+				group.keys[field] = value
+			}
+		}
+
+		*groups = append(*groups, group)
+		return true, nil
+	}
+
+	facet, ok := results.Facets[groupBy[depth]]
+
+	if !ok || facet.Missing > 0 {
+		return false, nil
+	} else if facet.Other > 0 {
+		return false, fmt.Errorf("GroupBy exceeds the maximum of %d groups", MaxFacetCardinality)
+	}
+
+	for _, term := range facet.Terms {
+		termQuery := bleve.NewTermQuery(term.Term)
+		termQuery.SetField(groupBy[depth])
+
+		if ok, err := self.facetGroups(index, bleve.NewConjunctionQuery(bq, termQuery), groupBy, depth+1, groups); err != nil || !ok {
+			return ok, err
+		}
+	}
+
+	return true, nil
+}
+
+// Calculates the aggregated values of a group of documents.  Counts are taken from facets (which
+// count the documents that have no value for a field), and the minimum and maximum of numeric
+// fields by sorting on them.  Other aggregations are calculated from the field's stored values,
+// which are the only values read from each document.
+func (self *BleveIndexer) facetGroupValues(index bleve.Index, collection *dal.Collection, facetGroup *bleveFacetGroup, aggregates []filter.Aggregate) (*aggregateGroup, error) {
+	group := &aggregateGroup{
+		keys:   facetGroup.keys,
+		values: make(map[string]*aggregateValue),
+		count:  facetGroup.total,
+	}
+
+	aggregations := make(map[string][]filter.Aggregation)
+	fields := make([]string, 0)
+
+	for _, aggregate := range aggregates {
+		if _, ok := aggregations[aggregate.Field]; !ok {
+			fields = append(fields, aggregate.Field)
+		}
+
+		aggregations[aggregate.Field] = append(aggregations[aggregate.Field], aggregate.Aggregation)
+	}
+
+	for _, field := range fields {
+		value := new(aggregateValue)
+		group.values[field] = value
+
+		if self.needsStoredValues(collection, field, aggregations[field]) {
+			if err := self.storedValues(index, collection, facetGroup.query, field, value.Add); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		if field == collection.IdentityField || field == BleveIdentityField {
+			value.count = facetGroup.total
+			continue
+		}
+
+		request := bleve.NewSearchRequestOptions(facetGroup.query, 0, 0, false)
+		request.AddFacet(field, bleve.NewFacetRequest(field, 1))
+
+		if results, err := index.Search(request); err == nil {
+			if facet, ok := results.Facets[field]; ok {
+				value.count = results.Total - uint64(facet.Missing)
+			}
+		} else {
+			return nil, err
+		}
+
+		if value.count > 0 {
+			for _, aggregation := range aggregations[field] {
+				switch aggregation {
+				case filter.Minimum:
+					if v, err := self.sortedValue(index, facetGroup.query, field, false); err == nil {
+						value.min = v
+					} else {
+						return nil, err
+					}
+
+				case filter.Maximum:
+					if v, err := self.sortedValue(index, facetGroup.query, field, true); err == nil {
+						value.max = v
+					} else {
+						return nil, err
+					}
+				}
+			}
+		}
+	}
+
+	return group, nil
+}
+
+// Returns whether any of the given aggregations of a field can only be calculated by reading all of
+// its values.
+func (self *BleveIndexer) needsStoredValues(collection *dal.Collection, name string, aggregations []filter.Aggregation) bool {
+	var numeric bool
+
+	if field, ok := collection.GetField(name); ok {
+		numeric = (field.Type == dal.IntType || field.Type == dal.FloatType)
+	}
+
+	for _, aggregation := range aggregations {
+		switch aggregation {
+		case filter.Count:
+			continue
+		case filter.Minimum, filter.Maximum:
+			if numeric {
+				continue
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// Returns the smallest (or largest) value of a numeric field in the documents matching the given
+// query, which have at least one value for the field.
+func (self *BleveIndexer) sortedValue(index bleve.Index, bq query.Query, field string, descending bool) (float64, error) {
+	request := bleve.NewSearchRequestOptions(bq, 1, 0, false)
+	request.Fields = []string{field}
+	request.SortByCustom(search.SortOrder{
+		&search.SortField{
+			Field:   field,
+			Desc:    descending,
+			Type:    search.SortFieldAsNumber,
+			Missing: search.SortFieldMissingLast,
+		},
+	})
+
+	if results, err := index.Search(request); err == nil {
+		if len(results.Hits) > 0 {
+			value := results.Hits[0].Fields[field]
+
+			if typeutil.IsArray(value) {
+				value = sliceutil.First(value)
+			}
+
+			return stringutil.ConvertToFloat(value)
+		}
+
+		return 0, nil
+	} else {
+		return 0, err
+	}
+}
+
+// Calls fn with the stored value of the given field for each document matching the given query.
+func (self *BleveIndexer) storedValues(index bleve.Index, collection *dal.Collection, bq query.Query, field string, fn func(value interface{})) error {
+	identity := (field == collection.IdentityField || field == BleveIdentityField)
+	offset := 0
+
+	for {
+		request := bleve.NewSearchRequestOptions(bq, IndexerPageSize, offset, false)
+
+		if !identity {
+			request.Fields = []string{field}
+		}
+
+		if results, err := index.Search(request); err == nil {
+			for _, hit := range results.Hits {
+				if identity {
+					fn(stringutil.Autotype(hit.ID))
+				} else if value := hit.Fields[field]; typeutil.IsArray(value) {
+					fn(sliceutil.First(value))
+				} else {
+					fn(value)
+				}
+			}
+
+			offset += len(results.Hits)
+
+			if len(results.Hits) == 0 || uint64(offset) >= results.Total {
+				return nil
+			}
+		} else {
+			return err
+		}
+	}
+}
+
+// Reads the stored values of all fields being grouped and aggregated from every document matching
+// the given filter, accumulating them into one group per distinct combination of grouped values.
+func (self *BleveIndexer) scanAggregate(collection *dal.Collection, groupBy []string, aggregates []filter.Aggregate, f *filter.Filter) ([]*aggregateGroup, error) {
+	accumulator := newGroupAccumulator(collection, groupBy, aggregates, self.getRecordValue)
+
+	// only retrieve the fields we need, and process every matching document
+	query := filter.Copy(f)
+	query.Fields = sliceutil.Stringify(sliceutil.Unique(
+		append(append([]string{}, groupBy...), self.aggregateFieldNames(collection, aggregates)...),
+	))
+	query.Sort = nil
+	query.Limit = 0
+	query.Offset = 0
+
+	if err := self.QueryFunc(collection, &query, func(record *dal.Record, err error, _ IndexPage) error {
+		if err != nil {
+			return err
+		}
+
+		return accumulator.Add(record)
+	}); err != nil {
+		return nil, err
+	}
+
+	return accumulator.groups, nil
+}
+
+func (self *BleveIndexer) aggregateFieldNames(collection *dal.Collection, aggregates []filter.Aggregate) []string {
+	fields := make([]string, 0)
+
+	for _, aggregate := range aggregates {
+		if aggregate.Field != collection.IdentityField && aggregate.Field != BleveIdentityField {
+			fields = append(fields, aggregate.Field)
+		}
+	}
+
+	return fields
+}
+
+func (self *BleveIndexer) getRecordValue(collection *dal.Collection, record *dal.Record, field string) interface{} {
+	if field == collection.IdentityField || field == BleveIdentityField {
+		return stringutil.Autotype(record.ID)
+	}
+
+	// multi-valued fields are returned by Bleve as arrays; use the first value
+	if value := record.Get(field); typeutil.IsArray(value) {
+		return sliceutil.First(value)
+	} else {
+		return value
+	}
+}
@@ -0,0 +1,165 @@
+package backends
+
+import (
+	"testing"
+
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBleveAggregator(t *testing.T) {
+	assert := require.New(t)
+
+	conn, err := dal.ParseConnectionString(`bleve:///memory`)
+	assert.NoError(err)
+
+	indexer := NewBleveIndexer(conn)
+	assert.NoError(indexer.IndexInitialize(nil))
+
+	collection := dal.NewCollection(`TestBleveAggregator`).
+		AddFields(dal.Field{
+			Name: `color`,
+			Type: dal.StringType,
+		}, dal.Field{
+			Name:     `inventory`,
+			Type:     dal.IntType,
+			Required: true,
+		}, dal.Field{
+			Name: `factor`,
+			Type: dal.FloatType,
+		})
+
+	assert.NoError(indexer.Index(collection, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`color`, `red`).Set(`inventory`, 34).Set(`factor`, float64(2.7)),
+		dal.NewRecord(2).Set(`color`, `green`).Set(`inventory`, 92).Set(`factor`, float64(9.8)),
+		dal.NewRecord(3).Set(`color`, `blue`).Set(`inventory`, 0).Set(`factor`, float64(5.6)),
+		dal.NewRecord(4).Set(`color`, `red`).Set(`inventory`, 54).Set(`factor`, float64(0)),
+		dal.NewRecord(5).Set(`color`, `green`).Set(`inventory`, 123).Set(`factor`, float64(3.14)),
+		dal.NewRecord(6).Set(`color`, `red`).Set(`inventory`, 19).Set(`factor`, float64(4.67)),
+	)))
+
+	assert.NoError(indexer.FlushIndex())
+
+	vui, err := indexer.Count(collection, filter.All())
+	assert.NoError(err)
+	assert.Equal(uint64(6), vui)
+
+	vui, err = indexer.Count(collection, filter.MustParse(`color/red`))
+	assert.NoError(err)
+	assert.Equal(uint64(3), vui)
+
+	vf, err := indexer.Sum(collection, `inventory`, filter.All())
+	assert.NoError(err)
+	assert.Equal(float64(322), vf)
+
+	vf, err = indexer.Sum(collection, `inventory`, filter.MustParse(`color/red`))
+	assert.NoError(err)
+	assert.Equal(float64(107), vf)
+
+	vf, err = indexer.Minimum(collection, `inventory`, filter.All())
+	assert.NoError(err)
+	assert.Equal(float64(0), vf)
+
+	vf, err = indexer.Maximum(collection, `factor`, filter.All())
+	assert.NoError(err)
+	assert.Equal(float64(9.8), vf)
+
+	vf, err = indexer.Average(collection, `inventory`, filter.MustParse(`color/green`))
+	assert.NoError(err)
+	assert.Equal(float64(107.5), vf)
+
+	f := filter.All()
+	f.Sort = []string{`-inventory`}
+
+	recordset, err := indexer.GroupBy(collection, []string{`color`}, []filter.Aggregate{
+		{
+			Aggregation: filter.Sum,
+			Field:       `inventory`,
+		}, {
+			Aggregation: filter.Count,
+			Field:       `id`,
+		},
+	}, f)
+
+	assert.NoError(err)
+	assert.Len(recordset.Records, 3)
+
+	assert.Equal(int64(2), recordset.Records[0].ID)
+	assert.Equal(map[string]interface{}{
+		`color`:     `green`,
+		`inventory`: int64(215),
+	}, recordset.Records[0].Fields)
+
+	assert.Equal(int64(3), recordset.Records[1].ID)
+	assert.Equal(map[string]interface{}{
+		`color`:     `red`,
+		`inventory`: int64(107),
+	}, recordset.Records[1].Fields)
+
+	assert.Equal(int64(1), recordset.Records[2].ID)
+	assert.Equal(map[string]interface{}{
+		`color`:     `blue`,
+		`inventory`: int64(0),
+	}, recordset.Records[2].Fields)
+
+	// filter and bound the grouped results
+	f.AddHaving(filter.Criterion{
+		Field:    `inventory`,
+		Operator: `lt`,
+		Values:   []interface{}{200},
+	})
+
+	f.Limit = 1
+
+	recordset, err = indexer.GroupBy(collection, []string{`color`}, []filter.Aggregate{
+		{
+			Aggregation: filter.Sum,
+			Field:       `inventory`,
+		},
+	}, f)
+
+	assert.NoError(err)
+	assert.Len(recordset.Records, 1)
+	assert.Equal(`red`, recordset.Records[0].Get(`color`))
+
+	// several aggregates on the same field
+	f = filter.All()
+	f.Sort = []string{`color`}
+
+	recordset, err = indexer.GroupBy(collection, []string{`color`}, []filter.Aggregate{
+		{
+			Aggregation: filter.Minimum,
+			Field:       `inventory`,
+		}, {
+			Aggregation: filter.Maximum,
+			Field:       `inventory`,
+		}, {
+			Aggregation: filter.Sum,
+			Field:       `inventory`,
+		},
+	}, f)
+
+	assert.NoError(err)
+	assert.Len(recordset.Records, 3)
+	assert.Equal(`red`, recordset.Records[2].Get(`color`))
+	assert.EqualValues(19, recordset.Records[2].Get(`min:inventory`))
+	assert.EqualValues(54, recordset.Records[2].Get(`max:inventory`))
+	assert.EqualValues(107, recordset.Records[2].Get(`sum:inventory`))
+
+	// fields that facets can't group by are grouped by reading each document
+	f = filter.MustParse(`color/red`)
+	f.Sort = []string{`inventory`}
+
+	recordset, err = indexer.GroupBy(collection, []string{`inventory`}, []filter.Aggregate{
+		{
+			Aggregation: filter.Count,
+			Field:       `id`,
+		},
+	}, f)
+
+	assert.NoError(err)
+	assert.Len(recordset.Records, 3)
+	assert.EqualValues(19, recordset.Records[0].Get(`inventory`))
+	assert.EqualValues(54, recordset.Records[2].Get(`inventory`))
+}
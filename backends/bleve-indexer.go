@@ -10,6 +10,7 @@ import (
 
 	"github.com/blevesearch/bleve"
 	"github.com/blevesearch/bleve/analysis/analyzer/custom"
+	"github.com/blevesearch/bleve/analysis/analyzer/keyword"
 	"github.com/blevesearch/bleve/analysis/char/regexp"
 	"github.com/blevesearch/bleve/analysis/token/lowercase"
 	"github.com/blevesearch/bleve/analysis/tokenizer/single"
@@ -376,6 +377,7 @@ func (self *BleveIndexer) getIndexForCollection(collection *dal.Collection) (ble
 
 		// setup the mapping and text analysis settings for this index
 		self.useFilterMapping(mapping)
+		self.useFieldMappings(mapping, collection)
 
 		switch self.conn.Dataset() {
 		case `memory`:
@@ -613,3 +615,22 @@ func (self *BleveIndexer) useFilterMapping(mappingImpl *mapping.IndexMappingImpl
 
 	mappingImpl.DefaultAnalyzer = `pivot_filter`
 }
+
+// Adds mappings for fields whose types would not be indexed correctly if they were mapped
+// dynamically.
+func (self *BleveIndexer) useFieldMappings(mappingImpl *mapping.IndexMappingImpl, collection *dal.Collection) {
+	for _, field := range collection.Fields {
+		switch field.Type {
+		case dal.EnumType, dal.UUIDType:
+			// these are matched exactly, and enum values are case-sensitive
+			fieldMapping := bleve.NewTextFieldMapping()
+			fieldMapping.Analyzer = keyword.Name
+
+			mappingImpl.DefaultMapping.AddFieldMappingsAt(field.Name, fieldMapping)
+
+		case dal.BytesType:
+			// binary data would otherwise be indexed as an array of numbers
+			mappingImpl.DefaultMapping.AddSubDocumentMapping(field.Name, bleve.NewDocumentDisabledMapping())
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package backends
+
+// this file satifies the Indexer interface for BoltBackend
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter"
+	bolt "go.etcd.io/bbolt"
+)
+
+func (self *BoltBackend) IndexConnectionString() *dal.ConnectionString {
+	return &self.conn
+}
+
+func (self *BoltBackend) IndexInitialize(_ Backend) error {
+	return nil
+}
+
+func (self *BoltBackend) GetBackend() Backend {
+	return self
+}
+
+func (self *BoltBackend) IndexExists(collection *dal.Collection, id interface{}) bool {
+	return self.Exists(collection.GetIndexName(), id)
+}
+
+func (self *BoltBackend) IndexRetrieve(collection *dal.Collection, id interface{}) (*dal.Record, error) {
+	return self.Retrieve(collection.GetIndexName(), id)
+}
+
+// secondary indexes are maintained as records are written, so there is nothing to do here
+func (self *BoltBackend) IndexRemove(collection *dal.Collection, ids []interface{}) error {
+	return nil
+}
+
+func (self *BoltBackend) Index(collection *dal.Collection, records *dal.RecordSet) error {
+	return nil
+}
+
+func (self *BoltBackend) QueryFunc(collection *dal.Collection, f *filter.Filter, resultFn IndexResultFunc) error {
+	if f == nil {
+		f = filter.All()
+	}
+
+	querylog.Debugf("[%T] Query using filter %q", self, f.String())
+
+	if records, err := self.matchingRecords(collection, f); err == nil {
+		return emitRecordPage(records, f, resultFn)
+	} else {
+		return err
+	}
+}
+
+func (self *BoltBackend) Query(collection *dal.Collection, f *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error) {
+	if f == nil {
+		f = filter.All()
+	}
+
+	return DefaultQueryImplementation(self, collection, f, resultFns...)
+}
+
+func (self *BoltBackend) ListValues(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]interface{}, error) {
+	values := make(map[string][]interface{})
+
+	if err := self.QueryFunc(collection, f, func(record *dal.Record, err error, page IndexPage) error {
+		if err != nil {
+			return err
+		}
+
+		for _, field := range fields {
+			values[field] = sliceutil.Unique(append(values[field], memoryRecordValue(collection, record, field)))
+		}
+
+		return nil
+	}); err == nil {
+		return values, nil
+	} else {
+		return values, err
+	}
+}
+
+func (self *BoltBackend) DeleteQuery(collection *dal.Collection, f *filter.Filter) error {
+	ids := make([]interface{}, 0)
+
+	if err := self.QueryFunc(collection, f, func(record *dal.Record, err error, page IndexPage) error {
+		if err == nil {
+			ids = append(ids, record.ID)
+		}
+
+		return err
+	}); err == nil {
+		return self.Delete(collection.Name, ids...)
+	} else {
+		return err
+	}
+}
+
+func (self *BoltBackend) FlushIndex() error {
+	return nil
+}
+
+// Returns all records in the collection that match the given filter, sorted according to the
+// filter's sort fields (or in key order if none are given).  If the filter tests any ID or indexed
+// field for equality, only the records found in those indexes are considered; otherwise every record
+// in the collection is checked.
+func (self *BoltBackend) matchingRecords(collection *dal.Collection, f *filter.Filter) ([]*dal.Record, error) {
+	definition, err := self.GetCollection(collection.Name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]*dal.Record, 0)
+
+	err = self.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(definition.Name))
+
+		if bucket == nil {
+			return nil
+		}
+
+		check := func(data []byte) error {
+			if record, err := decodeBoltRecord(definition, data); err == nil {
+				if f.MatchesRecord(record) {
+					matches = append(matches, record)
+				}
+
+				return nil
+			} else {
+				return err
+			}
+		}
+
+		if keys, ok := boltCandidateKeys(tx, definition, f); ok {
+			for _, key := range keys {
+				if data := bucket.Get(key); data != nil {
+					if err := check(data); err != nil {
+						return err
+					}
+				}
+			}
+
+			return nil
+		} else {
+			return bucket.ForEach(func(key []byte, data []byte) error {
+				return check(data)
+			})
+		}
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	memorySortRecords(definition, matches, f)
+
+	return matches, nil
+}
+
+// Returns the keys of the records that could match the filter according to the ID and secondary
+// indexes, in key order, and whether any index could be used at all.  The records still need to be
+// checked against the whole filter.
+func boltCandidateKeys(tx *bolt.Tx, collection *dal.Collection, f *filter.Filter) ([][]byte, bool) {
+	if f.IsMatchAll() {
+		return nil, false
+	}
+
+	indexed := secondaryIndexFields(collection)
+	var candidates map[string][]byte
+
+	for _, criterion := range f.Criteria {
+		switch criterion.Operator {
+		case ``, `is`:
+		default:
+			continue
+		}
+
+		isIdentity := (criterion.Field == f.IdentityField || criterion.Field == collection.IdentityField)
+
+		if !isIdentity {
+			var isIndexed bool
+
+			for _, field := range indexed {
+				if field.Name == criterion.Field {
+					isIndexed = true
+					break
+				}
+			}
+
+			if !isIndexed {
+				continue
+			}
+		}
+
+		keys := make(map[string][]byte)
+		usable := true
+
+		for _, value := range criterion.Values {
+			if isIdentity {
+				key := boltKey(collection, value)
+				keys[string(key)] = key
+			} else if _, ok := boltIndexValue(value); ok {
+				for _, key := range boltIndexLookup(tx, collection, criterion.Field, value) {
+					keys[string(key)] = key
+				}
+			} else {
+				// null values aren't indexed
+				usable = false
+				break
+			}
+		}
+
+		if !usable {
+			continue
+		}
+
+		if candidates == nil {
+			candidates = keys
+		} else {
+			for k := range candidates {
+				if _, ok := keys[k]; !ok {
+					delete(candidates, k)
+				}
+			}
+		}
+	}
+
+	if candidates == nil {
+		return nil, false
+	}
+
+	output := make([][]byte, 0, len(candidates))
+
+	for _, key := range candidates {
+		output = append(output, key)
+	}
+
+	sort.Slice(output, func(i int, j int) bool {
+		return bytes.Compare(output[i], output[j]) < 0
+	})
+
+	return output, true
+}
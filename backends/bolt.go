@@ -0,0 +1,759 @@
+package backends
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/pathutil"
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter"
+	"github.com/tinylib/msgp/msgp"
+	bolt "go.etcd.io/bbolt"
+)
+
+var BoltOpenTimeout = 5 * time.Second
+var BoltSchemaBucket = `_pivot_schema`
+var BoltIndexBucketPrefix = `_pivot_index:`
+var BoltUniqueBucketSuffix = `:unique`
+
+const FormatMsgpack SerializationFormat = `msgpack`
+
+// A backend that stores records in a single bbolt database file.  Each collection is stored in its
+// own bucket, keyed on record ID, and collection definitions are stored in a metadata bucket so that
+// they persist along with the data.
+//
+// Records are encoded as JSON by default, or as msgpack if the connection string specifies it (e.g.:
+// bolt+msgpack:///path/to/db).  Records written in either format can always be read back.
+//
+// Fields that are marked as indexed, unique, or key have their values written to a secondary index
+// bucket, which is used to answer equality queries without scanning the whole collection.  Unique
+// fields are also checked for duplicate values when records are saved.
+type BoltBackend struct {
+	conn        dal.ConnectionString
+	db          *bolt.DB
+	path        string
+	format      SerializationFormat
+	indexer     Indexer
+	aggregator  map[string]Aggregator
+	collections map[string]*dal.Collection
+	lock        sync.RWMutex
+}
+
+func NewBoltBackend(connection dal.ConnectionString) Backend {
+	return &BoltBackend{
+		conn:        connection,
+		format:      FormatJSON,
+		aggregator:  make(map[string]Aggregator),
+		collections: make(map[string]*dal.Collection),
+	}
+}
+
+func (self *BoltBackend) GetConnectionString() *dal.ConnectionString {
+	return &self.conn
+}
+
+func (self *BoltBackend) Ping(timeout time.Duration) error {
+	if self.db == nil {
+		return fmt.Errorf("Backend not initialized")
+	}
+
+	return nil
+}
+
+func (self *BoltBackend) RegisterCollection(definition *dal.Collection) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.collections[definition.Name] = definition
+}
+
+func (self *BoltBackend) SetIndexer(indexConnString dal.ConnectionString) error {
+	if indexer, err := MakeIndexer(indexConnString); err == nil {
+		self.indexer = indexer
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *BoltBackend) Initialize() error {
+	switch self.conn.Protocol() {
+	case ``, `json`:
+		self.format = FormatJSON
+	case `msgpack`:
+		self.format = FormatMsgpack
+	default:
+		return fmt.Errorf("Unknown serialization format %q", self.conn.Protocol())
+	}
+
+	if path, err := boltPath(&self.conn); err == nil {
+		self.path = path
+	} else {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(self.path), 0700); err != nil {
+		return err
+	}
+
+	if db, err := bolt.Open(self.path, 0600, &bolt.Options{
+		Timeout: BoltOpenTimeout,
+	}); err == nil {
+		self.db = db
+	} else {
+		return err
+	}
+
+	// load the definitions of all collections stored in the database, unless they were already
+	// registered explicitly
+	if err := self.db.Update(func(tx *bolt.Tx) error {
+		if schema, err := tx.CreateBucketIfNotExists([]byte(BoltSchemaBucket)); err == nil {
+			return schema.ForEach(func(name []byte, data []byte) error {
+				definition := new(dal.Collection)
+
+				if err := json.Unmarshal(data, definition); err != nil {
+					return fmt.Errorf("invalid definition for collection %q: %v", string(name), err)
+				}
+
+				self.lock.Lock()
+				defer self.lock.Unlock()
+
+				if _, ok := self.collections[definition.Name]; !ok {
+					self.collections[definition.Name] = definition
+				}
+
+				return nil
+			})
+		} else {
+			return err
+		}
+	}); err != nil {
+		return err
+	}
+
+	if self.indexer == nil {
+		self.indexer = self
+	}
+
+	if err := self.indexer.IndexInitialize(self); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Closes the underlying database file, releasing the lock held on it.
+func (self *BoltBackend) Close() error {
+	if self.db != nil {
+		return self.db.Close()
+	}
+
+	return nil
+}
+
+func (self *BoltBackend) Insert(name string, recordset *dal.RecordSet) error {
+	return self.save(name, recordset, true)
+}
+
+func (self *BoltBackend) Exists(name string, id interface{}) bool {
+	if definition, err := self.GetCollection(name); err == nil {
+		var exists bool
+
+		self.db.View(func(tx *bolt.Tx) error {
+			if bucket := tx.Bucket([]byte(name)); bucket != nil {
+				exists = (bucket.Get(boltKey(definition, id)) != nil)
+			}
+
+			return nil
+		})
+
+		return exists
+	}
+
+	return false
+}
+
+func (self *BoltBackend) Retrieve(name string, id interface{}, fields ...string) (*dal.Record, error) {
+	if definition, err := self.GetCollection(name); err == nil {
+		var record *dal.Record
+
+		if err := self.db.View(func(tx *bolt.Tx) error {
+			if bucket := tx.Bucket([]byte(name)); bucket != nil {
+				if data := bucket.Get(boltKey(definition, id)); data != nil {
+					if r, err := decodeBoltRecord(definition, data); err == nil {
+						record = r
+						return nil
+					} else {
+						return err
+					}
+				}
+			}
+
+			return fmt.Errorf("Record %q does not exist", id)
+		}); err != nil {
+			return nil, err
+		}
+
+		if len(fields) > 0 {
+			for key := range record.Fields {
+				if !sliceutil.ContainsString(fields, key) {
+					delete(record.Fields, key)
+				}
+			}
+		}
+
+		return record, nil
+	} else {
+		return nil, err
+	}
+}
+
+// Records that already exist are updated with the fields given in the new record, and all others are
+// inserted.
+func (self *BoltBackend) Update(name string, recordset *dal.RecordSet, target ...string) error {
+	return self.save(name, recordset, false)
+}
+
+func (self *BoltBackend) Delete(name string, ids ...interface{}) error {
+	if definition, err := self.GetCollection(name); err == nil {
+		if err := self.db.Update(func(tx *bolt.Tx) error {
+			bucket := tx.Bucket([]byte(name))
+
+			if bucket == nil {
+				return nil
+			}
+
+			for _, id := range ids {
+				key := boltKey(definition, id)
+
+				if data := bucket.Get(key); data != nil {
+					if existing, err := decodeBoltRecord(definition, data); err == nil {
+						if err := unindexBoltRecord(tx, definition, key, existing); err != nil {
+							return err
+						}
+					} else {
+						return err
+					}
+
+					if err := bucket.Delete(key); err != nil {
+						return err
+					}
+				}
+			}
+
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		// remove documents from index
+		if search := self.WithSearch(definition); search != nil {
+			if err := search.IndexRemove(definition, ids); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *BoltBackend) WithSearch(collection *dal.Collection, filters ...*filter.Filter) Indexer {
+	return self.indexer
+}
+
+func (self *BoltBackend) WithAggregator(collection *dal.Collection) Aggregator {
+	if aggregator, ok := self.aggregator[collection.GetAggregatorName()]; ok {
+		return aggregator
+	}
+
+	// use the indexer to perform aggregations if it supports them
+	if self.indexer != nil && self.indexer != Indexer(self) {
+		if aggregator, ok := self.indexer.(Aggregator); ok {
+			return aggregator
+		}
+	}
+
+	return nil
+}
+
+func (self *BoltBackend) ListCollections() ([]string, error) {
+	names := make([]string, 0)
+
+	err := self.db.View(func(tx *bolt.Tx) error {
+		if schema := tx.Bucket([]byte(BoltSchemaBucket)); schema != nil {
+			return schema.ForEach(func(name []byte, _ []byte) error {
+				names = append(names, string(name))
+				return nil
+			})
+		}
+
+		return nil
+	})
+
+	return names, err
+}
+
+func (self *BoltBackend) CreateCollection(definition *dal.Collection) error {
+	if strings.HasPrefix(definition.Name, `_pivot_`) {
+		return fmt.Errorf("Collection name %q is reserved", definition.Name)
+	}
+
+	if err := self.db.Update(func(tx *bolt.Tx) error {
+		schema := tx.Bucket([]byte(BoltSchemaBucket))
+
+		if schema.Get([]byte(definition.Name)) != nil {
+			return fmt.Errorf("Collection %q already exists", definition.Name)
+		}
+
+		if data, err := json.Marshal(definition); err == nil {
+			if err := schema.Put([]byte(definition.Name), data); err != nil {
+				return err
+			}
+		} else {
+			return err
+		}
+
+		_, err := tx.CreateBucketIfNotExists([]byte(definition.Name))
+		return err
+	}); err != nil {
+		return err
+	}
+
+	self.RegisterCollection(definition)
+	return nil
+}
+
+func (self *BoltBackend) DeleteCollection(name string) error {
+	if err := self.db.Update(func(tx *bolt.Tx) error {
+		schema := tx.Bucket([]byte(BoltSchemaBucket))
+
+		if schema.Get([]byte(name)) == nil && tx.Bucket([]byte(name)) == nil {
+			return dal.CollectionNotFound
+		}
+
+		if err := schema.Delete([]byte(name)); err != nil {
+			return err
+		}
+
+		for _, bucket := range []string{name, BoltIndexBucketPrefix + name} {
+			if err := tx.DeleteBucket([]byte(bucket)); err != nil && err != bolt.ErrBucketNotFound {
+				return err
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	self.lock.Lock()
+	delete(self.collections, name)
+	self.lock.Unlock()
+
+	return nil
+}
+
+func (self *BoltBackend) GetCollection(name string) (*dal.Collection, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	if definition, ok := self.collections[name]; ok {
+		return definition, nil
+	} else {
+		return nil, dal.CollectionNotFound
+	}
+}
+
+func (self *BoltBackend) Flush() error {
+	if self.indexer != nil && self.indexer != Indexer(self) {
+		return self.indexer.FlushIndex()
+	}
+
+	return nil
+}
+
+// Formats, validates, and stores each record in the given recordset in a single transaction, so
+// either all of the records are saved or none of them are.  Records without an ID are assigned the
+// next one in sequence if the collection's identity field is an integer, and the IDs of the records
+// in the recordset are updated to match what was stored.
+func (self *BoltBackend) save(name string, recordset *dal.RecordSet, insert bool) error {
+	if definition, err := self.GetCollection(name); err == nil {
+		ids := make([]interface{}, len(recordset.Records))
+
+		if err := self.db.Update(func(tx *bolt.Tx) error {
+			bucket, err := tx.CreateBucketIfNotExists([]byte(name))
+
+			if err != nil {
+				return err
+			}
+
+			for i, record := range recordset.Records {
+				var stored *dal.Record
+
+				if !insert && record.ID != nil {
+					if data := bucket.Get(boltKey(definition, record.ID)); data != nil {
+						if stored, err = decodeBoltRecord(definition, data); err != nil {
+							return err
+						}
+					}
+				}
+
+				r, err := memoryPrepareRecord(definition, record, insert, stored)
+
+				if err != nil {
+					return err
+				}
+
+				if r.ID == nil && definition.IdentityFieldType == dal.IntType {
+					if seq, err := bucket.NextSequence(); err == nil {
+						r.ID = int64(seq)
+					} else {
+						return err
+					}
+				}
+
+				if r.ID == nil {
+					return fmt.Errorf("Cannot save a record without an ID to collection %q", name)
+				}
+
+				key := boltKey(definition, r.ID)
+
+				// merge the new fields into the existing record, and remove the existing record's
+				// values from the secondary indexes
+				if data := bucket.Get(key); data != nil {
+					if insert {
+						return fmt.Errorf("Record %q already exists", r.ID)
+					}
+
+					if existing, err := decodeBoltRecord(definition, data); err == nil {
+						if err := unindexBoltRecord(tx, definition, key, existing); err != nil {
+							return err
+						}
+
+						for k, v := range r.Fields {
+							existing.Fields[k] = v
+						}
+
+						if len(r.Data) == 0 {
+							r.Data = existing.Data
+						}
+
+						r.Fields = existing.Fields
+					} else {
+						return err
+					}
+				}
+
+				if err := indexBoltRecord(tx, definition, key, r); err != nil {
+					return err
+				}
+
+				if data, err := encodeBoltRecord(self.format, r); err == nil {
+					if err := bucket.Put(key, data); err != nil {
+						return err
+					}
+				} else {
+					return err
+				}
+
+				// keep the sequence ahead of any explicitly-given integer IDs
+				if id, ok := r.ID.(int64); ok && id > 0 && uint64(id) > bucket.Sequence() {
+					if err := bucket.SetSequence(uint64(id)); err != nil {
+						return err
+					}
+				}
+
+				ids[i] = r.ID
+			}
+
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for i, record := range recordset.Records {
+			record.ID = ids[i]
+		}
+
+		if search := self.WithSearch(definition); search != nil {
+			if err := search.Index(definition, recordset); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+// Connection strings of the form bolt://path/to/db are relative to the current directory, and those
+// of the form bolt:///path/to/db are absolute.
+func boltPath(conn *dal.ConnectionString) (string, error) {
+	path := `/` + conn.Dataset()
+
+	if host := conn.Host(); host != `` {
+		path = host + path
+	}
+
+	if strings.HasPrefix(path, `~`) {
+		if v, err := pathutil.ExpandUser(path); err == nil {
+			path = v
+		} else {
+			return ``, err
+		}
+	}
+
+	return filepath.Abs(path)
+}
+
+// Integer IDs are stored as big-endian uint64s so that records are kept in numeric order; all
+// other IDs are stored as strings.
+func boltKey(collection *dal.Collection, id interface{}) []byte {
+	id = memoryIdentity(collection, id)
+
+	if v, ok := id.(int64); ok && v >= 0 {
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, uint64(v))
+		return key
+	}
+
+	return []byte(memoryKey(id))
+}
+
+func encodeBoltRecord(format SerializationFormat, record *dal.Record) ([]byte, error) {
+	document := map[string]interface{}{
+		`id`:     record.ID,
+		`fields`: record.Fields,
+	}
+
+	if len(record.Data) > 0 {
+		document[`data`] = record.Data
+	}
+
+	switch format {
+	case FormatMsgpack:
+		return msgp.AppendIntf(nil, document)
+	default:
+		return json.Marshal(document)
+	}
+}
+
+// Decodes a stored record, converting its values to the types declared in the collection's schema.
+// JSON-encoded records always start with an opening brace, and anything else is treated as msgpack.
+func decodeBoltRecord(collection *dal.Collection, data []byte) (*dal.Record, error) {
+	record := dal.NewRecord(nil)
+
+	if bytes.HasPrefix(data, []byte(`{`)) {
+		if err := json.Unmarshal(data, record); err != nil {
+			return nil, err
+		} else if err := collection.DecodeJSONRecord(record); err != nil {
+			return nil, err
+		}
+	} else if value, _, err := msgp.ReadIntfBytes(data); err == nil {
+		if document, ok := value.(map[string]interface{}); ok {
+			record.ID = document[`id`]
+
+			if fields, ok := document[`fields`].(map[string]interface{}); ok {
+				record.Fields = fields
+			}
+
+			if data, ok := document[`data`].([]byte); ok {
+				record.Data = data
+			}
+		} else {
+			return nil, fmt.Errorf("invalid record: expected a map, got %T", value)
+		}
+	} else {
+		return nil, err
+	}
+
+	if record.Fields == nil {
+		record.Fields = make(map[string]interface{})
+	}
+
+	record.ID = memoryIdentity(collection, record.ID)
+
+	for key, value := range record.Fields {
+		record.Fields[key] = memoryFieldValue(collection, key, value)
+	}
+
+	return record, nil
+}
+
+// Returns the normalized form of a value as stored in a secondary index, and whether the value can
+// be indexed at all.  Values are normalized so that any two values considered equal by
+// Filter.MatchesRecord have the same index value.
+func boltIndexValue(value interface{}) (string, bool) {
+	if value == nil {
+		return ``, false
+	}
+
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		return ``, false
+	}
+
+	if t, ok := value.(time.Time); ok {
+		return t.UTC().Format(time.RFC3339Nano), true
+	}
+
+	str := fmt.Sprintf("%v", value)
+
+	switch {
+	case str == ``:
+		return ``, false
+	case stringutil.IsNumeric(str):
+		if v, err := stringutil.ConvertToFloat(str); err == nil {
+			return fmt.Sprintf("%g", v), true
+		}
+	case stringutil.IsBooleanTrue(str):
+		return `true`, true
+	case stringutil.IsBooleanFalse(str):
+		return `false`, true
+	case stringutil.IsTime(str):
+		if t, err := stringutil.ConvertToTime(str); err == nil {
+			return t.UTC().Format(time.RFC3339Nano), true
+		}
+	}
+
+	return str, true
+}
+
+// Returns the value of a unique field as stored in its unique index, and whether the value can be
+// indexed at all.  Unlike boltIndexValue, values are converted to the field's declared type before
+// comparison, so two strings like "007" and "7" are distinct values of a string field but the same
+// value of an integer field.
+func boltUniqueValue(field dal.Field, value interface{}) (string, bool) {
+	if value == nil {
+		return ``, false
+	}
+
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		return ``, false
+	}
+
+	if typed, err := field.ConvertValue(value); err == nil {
+		value = typed
+	}
+
+	if t, ok := value.(time.Time); ok {
+		return t.UTC().Format(time.RFC3339Nano), true
+	} else if str := fmt.Sprintf("%v", value); str != `` {
+		return str, true
+	}
+
+	return ``, false
+}
+
+// index entries are keyed on the normalized value followed by the record key, so that all records
+// with a given value can be found with a prefix scan
+func boltIndexEntry(value string, key []byte) []byte {
+	return append([]byte(value+"\x00"), key...)
+}
+
+func boltIndexBucket(tx *bolt.Tx, collection *dal.Collection, field string, create bool) (*bolt.Bucket, error) {
+	name := []byte(BoltIndexBucketPrefix + collection.Name)
+
+	if create {
+		if indexes, err := tx.CreateBucketIfNotExists(name); err == nil {
+			return indexes.CreateBucketIfNotExists([]byte(field))
+		} else {
+			return nil, err
+		}
+	} else if indexes := tx.Bucket(name); indexes != nil {
+		return indexes.Bucket([]byte(field)), nil
+	}
+
+	return nil, nil
+}
+
+// returns the keys of all records whose indexed field has the given value
+func boltIndexLookup(tx *bolt.Tx, collection *dal.Collection, field string, value interface{}) [][]byte {
+	keys := make([][]byte, 0)
+
+	if v, ok := boltIndexValue(value); ok {
+		if index, _ := boltIndexBucket(tx, collection, field, false); index != nil {
+			prefix := []byte(v + "\x00")
+			cursor := index.Cursor()
+
+			for k, key := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, key = cursor.Next() {
+				keys = append(keys, append([]byte{}, key...))
+			}
+		}
+	}
+
+	return keys
+}
+
+func indexBoltRecord(tx *bolt.Tx, collection *dal.Collection, key []byte, record *dal.Record) error {
+	for _, field := range secondaryIndexFields(collection) {
+		value := record.Get(field.Name)
+
+		if field.Unique {
+			if v, ok := boltUniqueValue(field, value); ok {
+				if index, err := boltIndexBucket(tx, collection, field.Name+BoltUniqueBucketSuffix, true); err == nil {
+					if other := index.Get([]byte(v)); other != nil && !bytes.Equal(other, key) {
+						return fmt.Errorf("Value %v for field %q already exists in collection %q", value, field.Name, collection.Name)
+					} else if err := index.Put([]byte(v), key); err != nil {
+						return err
+					}
+				} else {
+					return err
+				}
+			}
+		}
+
+		if v, ok := boltIndexValue(value); ok {
+			if index, err := boltIndexBucket(tx, collection, field.Name, true); err == nil {
+				if err := index.Put(boltIndexEntry(v, key), key); err != nil {
+					return err
+				}
+			} else {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func unindexBoltRecord(tx *bolt.Tx, collection *dal.Collection, key []byte, record *dal.Record) error {
+	for _, field := range secondaryIndexFields(collection) {
+		value := record.Get(field.Name)
+
+		if field.Unique {
+			if v, ok := boltUniqueValue(field, value); ok {
+				if index, _ := boltIndexBucket(tx, collection, field.Name+BoltUniqueBucketSuffix, false); index != nil {
+					if bytes.Equal(index.Get([]byte(v)), key) {
+						if err := index.Delete([]byte(v)); err != nil {
+							return err
+						}
+					}
+				}
+			}
+		}
+
+		if v, ok := boltIndexValue(value); ok {
+			if index, _ := boltIndexBucket(tx, collection, field.Name, false); index != nil {
+				if err := index.Delete(boltIndexEntry(v, key)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
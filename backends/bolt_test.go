@@ -0,0 +1,230 @@
+package backends
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestBoltBackendIndexes(t *testing.T) {
+	assert := require.New(t)
+
+	root, err := ioutil.TempDir(``, `pivot-backend-bolt-`)
+	assert.NoError(err)
+	defer os.RemoveAll(root)
+
+	cs, err := dal.ParseConnectionString(`bolt://` + filepath.Join(root, `test.db`))
+	assert.NoError(err)
+
+	backend := NewBoltBackend(cs).(*BoltBackend)
+	assert.NoError(backend.Initialize())
+	defer backend.Close()
+
+	collection := dal.NewCollection(`TestBoltBackendIndexes`).
+		AddFields(dal.Field{
+			Name:    `name`,
+			Type:    dal.StringType,
+			Indexed: true,
+		}, dal.Field{
+			Name: `size`,
+			Type: dal.IntType,
+		}, dal.Field{
+			Name:   `sku`,
+			Type:   dal.StringType,
+			Unique: true,
+		})
+
+	assert.NoError(backend.CreateCollection(collection))
+	assert.NoError(backend.Insert(collection.Name, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `one`).Set(`size`, 1).Set(`sku`, `a1`),
+		dal.NewRecord(2).Set(`name`, `two`).Set(`size`, 2).Set(`sku`, `a2`),
+		dal.NewRecord(3).Set(`name`, `one`).Set(`size`, 3).Set(`sku`, `a3`),
+	)))
+
+	// nothing in a batch is saved if any record in it fails
+	assert.Error(backend.Insert(collection.Name, dal.NewRecordSet(
+		dal.NewRecord(4).Set(`sku`, `a4`),
+		dal.NewRecord(5).Set(`sku`, `a1`),
+	)))
+	assert.False(backend.Exists(collection.Name, 4))
+
+	// only equality criteria on the ID and indexed fields use the indexes
+	assert.NoError(backend.db.View(func(tx *bolt.Tx) error {
+		keys, ok := boltCandidateKeys(tx, collection, filter.MustParse(`name/one/size/gt:2`))
+		assert.True(ok)
+		assert.Len(keys, 2)
+
+		_, ok = boltCandidateKeys(tx, collection, filter.MustParse(`size/2`))
+		assert.False(ok)
+
+		return nil
+	}))
+
+	// updates and deletes keep the indexes current, and free up unique values
+	assert.NoError(backend.Update(collection.Name, dal.NewRecordSet(dal.NewRecord(1).Set(`name`, `uno`))))
+
+	recordset, err := backend.Query(collection, filter.MustParse(`name/one`))
+	assert.NoError(err)
+	assert.Len(recordset.Records, 1)
+	assert.Equal(int64(3), recordset.Records[0].ID)
+
+	assert.NoError(backend.Delete(collection.Name, 2))
+	assert.NoError(backend.Insert(collection.Name, dal.NewRecordSet(dal.NewRecord(6).Set(`sku`, `a2`))))
+}
+
+func TestBoltBackendUniqueValues(t *testing.T) {
+	assert := require.New(t)
+
+	root, err := ioutil.TempDir(``, `pivot-backend-bolt-`)
+	assert.NoError(err)
+	defer os.RemoveAll(root)
+
+	cs, err := dal.ParseConnectionString(`bolt://` + filepath.Join(root, `test.db`))
+	assert.NoError(err)
+
+	backend := NewBoltBackend(cs).(*BoltBackend)
+	assert.NoError(backend.Initialize())
+	defer backend.Close()
+
+	collection := dal.NewCollection(`TestBoltBackendUniqueValues`).
+		AddFields(dal.Field{
+			Name:   `code`,
+			Type:   dal.StringType,
+			Unique: true,
+		}, dal.Field{
+			Name:   `number`,
+			Type:   dal.IntType,
+			Unique: true,
+		})
+
+	assert.NoError(backend.CreateCollection(collection))
+
+	// uniqueness is decided on the field's declared type, so these are all distinct strings
+	for i, code := range []string{`007`, `7`, `yes`, `true`, `1e3`, `1000`} {
+		assert.NoError(backend.Insert(collection.Name, dal.NewRecordSet(
+			dal.NewRecord(i+1).Set(`code`, code),
+		)), code)
+	}
+
+	assert.Error(backend.Insert(collection.Name, dal.NewRecordSet(dal.NewRecord(10).Set(`code`, `007`))))
+
+	// ...but the same integer
+	assert.NoError(backend.Insert(collection.Name, dal.NewRecordSet(dal.NewRecord(20).Set(`number`, `007`))))
+	assert.Error(backend.Insert(collection.Name, dal.NewRecordSet(dal.NewRecord(21).Set(`number`, 7))))
+}
+
+func TestBoltBackendPersistence(t *testing.T) {
+	assert := require.New(t)
+
+	root, err := ioutil.TempDir(``, `pivot-backend-bolt-`)
+	assert.NoError(err)
+	defer os.RemoveAll(root)
+
+	open := func() *BoltBackend {
+		cs, err := dal.ParseConnectionString(`bolt+msgpack://` + filepath.Join(root, `test.db`))
+		assert.NoError(err)
+
+		backend := NewBoltBackend(cs)
+		assert.NoError(backend.Initialize())
+
+		return backend.(*BoltBackend)
+	}
+
+	backend := open()
+
+	collection := dal.NewCollection(`TestBoltBackendPersistence`).
+		AddFields(dal.Field{
+			Name: `name`,
+			Type: dal.StringType,
+		}, dal.Field{
+			Name: `created_at`,
+			Type: dal.TimeType,
+		})
+
+	created := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	assert.NoError(backend.CreateCollection(collection))
+	assert.NoError(backend.Insert(collection.Name, dal.NewRecordSet(
+		dal.NewRecord(10).Set(`name`, `first`).Set(`created_at`, created),
+	)))
+	assert.NoError(backend.Close())
+
+	// collections and records are restored when the database is reopened
+	restored := open()
+	defer restored.Close()
+
+	names, err := restored.ListCollections()
+	assert.NoError(err)
+	assert.Equal([]string{collection.Name}, names)
+
+	definition, err := restored.GetCollection(collection.Name)
+	assert.NoError(err)
+	assert.EqualValues(dal.TimeType, definition.Fields[1].Type)
+
+	record, err := restored.Retrieve(collection.Name, 10)
+	assert.NoError(err)
+	assert.Equal(int64(10), record.ID)
+	assert.Equal(`first`, record.Get(`name`))
+	assert.True(created.Equal(record.Get(`created_at`).(time.Time)))
+
+	// new records continue the sequence after the highest ID
+	recordset := dal.NewRecordSet(dal.NewRecord(nil).Set(`name`, `second`))
+	assert.NoError(restored.Insert(collection.Name, recordset))
+	assert.Equal(int64(11), recordset.Records[0].ID)
+
+	assert.NoError(restored.DeleteCollection(collection.Name))
+	assert.Error(restored.DeleteCollection(collection.Name))
+
+	_, err = restored.GetCollection(collection.Name)
+	assert.Equal(dal.CollectionNotFound, err)
+}
+
+func TestBoltBackendWithBleve(t *testing.T) {
+	assert := require.New(t)
+
+	root, err := ioutil.TempDir(``, `pivot-backend-bolt-`)
+	assert.NoError(err)
+	defer os.RemoveAll(root)
+
+	cs, err := dal.ParseConnectionString(`bolt://` + filepath.Join(root, `test.db`))
+	assert.NoError(err)
+
+	ics, err := dal.ParseConnectionString(`bleve:///memory`)
+	assert.NoError(err)
+
+	backend := NewBoltBackend(cs)
+	assert.NoError(backend.SetIndexer(ics))
+	assert.NoError(backend.Initialize())
+	defer backend.(*BoltBackend).Close()
+
+	collection := dal.NewCollection(`TestBoltBackendWithBleve`).
+		AddFields(dal.Field{
+			Name: `description`,
+			Type: dal.StringType,
+		})
+
+	assert.NoError(backend.CreateCollection(collection))
+	assert.NoError(backend.Insert(collection.Name, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`description`, `a large red balloon`),
+		dal.NewRecord(2).Set(`description`, `a small green frog`),
+	)))
+	assert.NoError(backend.Flush())
+
+	search := backend.WithSearch(collection)
+	assert.NotNil(search)
+	assert.IsType(&BleveIndexer{}, search)
+
+	recordset, err := search.Query(collection, filter.MustParse(`description/contains:frog`))
+	assert.NoError(err)
+	assert.Len(recordset.Records, 1)
+	assert.Equal(`a small green frog`, recordset.Records[0].Get(`description`))
+
+	assert.NotNil(backend.WithAggregator(collection))
+}
@@ -421,6 +421,12 @@ func (self *DynamoBackend) getSingleRecordDelete(name string, id interface{}) (*
 
 func (self *DynamoBackend) upsertRecords(collection *dal.Collection, records *dal.RecordSet, isCreate bool) error {
 	for _, record := range records.Records {
+		if r, err := collection.MakeRecord(record); err == nil {
+			record = r
+		} else {
+			return err
+		}
+
 		item := make(map[string]interface{})
 
 		for k, v := range record.Fields {
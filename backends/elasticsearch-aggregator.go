@@ -6,19 +6,14 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/ghetzel/go-stockutil/maputil"
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/stringutil"
 	"github.com/sniperkit/pivot/dal"
 	"github.com/sniperkit/pivot/filter"
 	"github.com/sniperkit/pivot/filter/generators"
 )
 
-type esAggregationQuery struct {
-	Aggregations map[string]esAggregation `json:"aggs"`
-	Filter       map[string]interface{}   `json:"filter,omitempty"`
-	Size         int                      `json:"size"`
-}
-
-type esAggregation map[string]interface{}
-
 func (self *ElasticsearchIndexer) Sum(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
 	return self.aggregateFloat(collection, filter.Sum, field, f)
 }
@@ -28,6 +23,8 @@ func (self *ElasticsearchIndexer) Count(collection *dal.Collection, flt ...*filt
 
 	if len(flt) > 0 {
 		f = flt[0]
+	} else {
+		f = filter.All()
 	}
 
 	f.Limit = 1
@@ -91,72 +88,148 @@ func (self *ElasticsearchIndexer) aggregate(collection *dal.Collection, groupBy
 
 	if len(flt) > 0 {
 		f = flt[0]
+	} else {
+		f = filter.All()
 	}
 
-	if query, err := filter.Render(
-		generators.NewElasticsearchGenerator(),
-		collection.GetAggregatorName(),
-		f,
-	); err == nil {
-		var esFilter map[string]interface{}
+	queryGen := generators.NewElasticsearchGenerator()
+	queryGen.BucketSize = MaxFacetCardinality
 
-		if err := json.Unmarshal(query, &esFilter); err == nil {
-			aggs := esAggregationQuery{
-				Aggregations: make(map[string]esAggregation),
-			}
+	for _, field := range groupBy {
+		queryGen.GroupByField(field)
+	}
 
-			for _, aggregate := range aggregates {
-				var statsKey = aggregate.Field
-				var statsField esAggregation
+	for _, aggregate := range aggregates {
+		queryGen.AggregateByField(aggregate.Aggregation, aggregate.Field)
+	}
 
-				if s, ok := aggs.Aggregations[statsKey]; ok {
-					statsField = s
-				} else {
-					statsField = make(esAggregation)
+	if query, err := filter.Render(queryGen, collection.GetAggregatorName(), f); err == nil {
+		if results, err := self.searchAggregations(collection.GetAggregatorName(), string(query)); err == nil {
+			if single {
+				if len(aggregates) > 0 {
+					field := aggregates[0].Field
+
+					if v := maputil.DeepGet(results, []string{filter.AggregateResultName(aggregates, aggregates[0]), `value`}); v != nil {
+						if vF, err := stringutil.ConvertToFloat(v); err == nil {
+							return vF, nil
+						} else {
+							return float64(0), fmt.Errorf("'%v' aggregation not supported for field %v", aggregates[0].Aggregation, field)
+						}
+					}
 				}
 
-				statsField[`stats`] = map[string]interface{}{
-					`field`: aggregate.Field,
+				return float64(0), nil
+			} else {
+				recordset := dal.NewRecordSet()
+
+				if err := self.bucketsToRecords(collection, recordset, results, groupBy, aggregates, make(map[string]interface{})); err != nil {
+					return nil, err
 				}
 
-				aggs.Aggregations[statsKey] = statsField
+				// buckets are sorted and limited per-parent, so sort the flattened results as a whole
+				// before applying the overall bounds
+				sortRecords(recordset, f, aggregates, func(record *dal.Record, field string) interface{} {
+					if field == collection.IdentityField {
+						return record.ID
+					}
+
+					return record.Get(field)
+				})
+
+				limitRecords(recordset, f)
+
+				return recordset, nil
 			}
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, fmt.Errorf("filter error: %v", err)
+	}
+}
+
+// Recursively walks the nested terms aggregation buckets in an Elasticsearch response, emitting
+// one record per innermost bucket.  The record contains the key of every bucket along the path to
+// it (keyed by the field being grouped by), as well as the values of all sub-metrics.
+func (self *ElasticsearchIndexer) bucketsToRecords(collection *dal.Collection, recordset *dal.RecordSet, aggs map[string]interface{}, groupBy []string, aggregates []filter.Aggregate, keys map[string]interface{}) error {
+	if len(groupBy) == 0 {
+		record := dal.NewRecord(nil)
 
-			if len(esFilter) > 0 {
-				aggs.Filter = esFilter
-				aggs.Size = 0
+		for field, value := range keys {
+			if field == collection.IdentityField {
+				record.ID = value
+			} else {
+				record.Set(field, value)
 			}
+		}
 
-			if query, err := json.Marshal(aggs); err == nil {
-				if req, err := self.newRequest(`GET`, fmt.Sprintf("/%s/_search", collection.GetAggregatorName()), string(query)); err == nil {
-					// perform request, read response
-					if response, err := self.client.Do(req); err == nil {
-						if response.StatusCode < 400 {
-							output := make(map[string]interface{})
-
-							if err := json.NewDecoder(response.Body).Decode(&output); err == nil {
-								return output, nil
-							} else {
-								return nil, fmt.Errorf("response decode error: %v", err)
-							}
-						} else {
-							return nil, fmt.Errorf("Got HTTP %v", response.Status)
-						}
-					} else {
-						return nil, err
-					}
+		for _, aggregate := range aggregates {
+			name := filter.AggregateResultName(aggregates, aggregate)
+			value := collection.ConvertValue(aggregate.Field, maputil.DeepGet(aggs, []string{name, `value`}))
+
+			if name == collection.IdentityField {
+				record.ID = value
+			} else {
+				record.Set(name, value)
+			}
+		}
+
+		recordset.Push(record)
+		return nil
+	}
+
+	field := groupBy[0]
+
+	for _, bucket := range sliceutil.Sliceify(maputil.DeepGet(aggs, []string{field, `buckets`})) {
+		if bucketMap, ok := bucket.(map[string]interface{}); ok {
+			subkeys := make(map[string]interface{})
+
+			for k, v := range keys {
+				subkeys[k] = v
+			}
+
+			if v, ok := bucketMap[`key_as_string`]; ok {
+				subkeys[field] = collection.ConvertValue(field, v)
+			} else {
+				subkeys[field] = collection.ConvertValue(field, bucketMap[`key`])
+			}
 
+			if err := self.bucketsToRecords(collection, recordset, bucketMap, groupBy[1:], aggregates, subkeys); err != nil {
+				return err
+			}
+		} else {
+			return fmt.Errorf("invalid bucket for field %q: expected object, got %T", field, bucket)
+		}
+	}
+
+	return nil
+}
+
+// Performs a search request against the given index, returning the "aggregations" section of the response.
+func (self *ElasticsearchIndexer) searchAggregations(index string, query interface{}) (map[string]interface{}, error) {
+	if req, err := self.newRequest(`POST`, fmt.Sprintf("/%s/_search", index), query); err == nil {
+		// perform request, read response
+		if response, err := self.client.Do(req); err == nil {
+			defer response.Body.Close()
+
+			if response.StatusCode < 400 {
+				var output struct {
+					Aggregations map[string]interface{} `json:"aggregations"`
+				}
+
+				if err := json.NewDecoder(response.Body).Decode(&output); err == nil {
+					return output.Aggregations, nil
 				} else {
-					return nil, err
+					return nil, fmt.Errorf("response decode error: %v", err)
 				}
 			} else {
-				return nil, fmt.Errorf("filter encode error: %v", err)
+				return nil, fmt.Errorf("Got HTTP %v", response.Status)
 			}
 		} else {
-			return nil, fmt.Errorf("filter decode error: %v", err)
+			return nil, err
 		}
 	} else {
-		return nil, fmt.Errorf("filter error: %v", err)
+		return nil, err
 	}
 }
 
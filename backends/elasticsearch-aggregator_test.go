@@ -0,0 +1,330 @@
+package backends
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ghetzel/go-stockutil/maputil"
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter"
+	"github.com/stretchr/testify/require"
+)
+
+// replays canned Elasticsearch responses, keyed on the request path, and records the most recent
+// search request body.  Search responses can also be built from the request by a respond function.
+type esReplayServer struct {
+	*httptest.Server
+	responses map[string]string
+	respond   func(query map[string]interface{}) string
+	lastQuery map[string]interface{}
+}
+
+func newEsReplayServer(responses map[string]string) *esReplayServer {
+	replay := &esReplayServer{
+		responses: responses,
+	}
+
+	replay.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if strings.HasSuffix(req.URL.Path, `/_search`) {
+			replay.lastQuery = make(map[string]interface{})
+			json.NewDecoder(req.Body).Decode(&replay.lastQuery)
+		}
+
+		if replay.respond != nil && strings.HasSuffix(req.URL.Path, `/_search`) {
+			w.Header().Set(`Content-Type`, `application/json`)
+			w.Write([]byte(replay.respond(replay.lastQuery)))
+		} else if body, ok := replay.responses[req.URL.Path]; ok {
+			w.Header().Set(`Content-Type`, `application/json`)
+			w.Write([]byte(body))
+		} else {
+			http.Error(w, `not found`, http.StatusNotFound)
+		}
+	}))
+
+	return replay
+}
+
+func (self *esReplayServer) indexer(assert *require.Assertions) *ElasticsearchIndexer {
+	conn, err := dal.ParseConnectionString(fmt.Sprintf("elasticsearch://%s/", strings.TrimPrefix(self.URL, `http://`)))
+	assert.NoError(err)
+
+	return NewElasticsearchIndexer(conn)
+}
+
+func TestElasticsearchGroupBy(t *testing.T) {
+	assert := require.New(t)
+
+	server := newEsReplayServer(map[string]string{
+		`/orders/_search`: `{
+			"took": 1,
+			"aggregations": {
+				"state": {
+					"buckets": [{
+						"key": "NY",
+						"doc_count": 3,
+						"city": {
+							"buckets": [{
+								"key": "Albany",
+								"doc_count": 2,
+								"amount": {"value": 1500},
+								"id": {"value": 2}
+							}, {
+								"key": "Buffalo",
+								"doc_count": 1,
+								"amount": {"value": 200},
+								"id": {"value": 1}
+							}]
+						}
+					}, {
+						"key": "TX",
+						"doc_count": 1,
+						"city": {
+							"buckets": [{
+								"key": "Austin",
+								"doc_count": 1,
+								"amount": {"value": 50},
+								"id": {"value": 1}
+							}]
+						}
+					}]
+				}
+			}
+		}`,
+	})
+
+	defer server.Close()
+
+	collection := dal.NewCollection(`orders`)
+	collection.AddFields(dal.Field{
+		Name: `state`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `city`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `amount`,
+		Type: dal.IntType,
+	})
+
+	indexer := server.indexer(assert)
+
+	f := filter.All()
+	f.Sort = []string{`-amount`}
+
+	recordset, err := indexer.GroupBy(collection, []string{`state`, `city`}, []filter.Aggregate{
+		{
+			Aggregation: filter.Sum,
+			Field:       `amount`,
+		}, {
+			Aggregation: filter.Count,
+			Field:       `id`,
+		},
+	}, f)
+
+	assert.NoError(err)
+	assert.Equal(int64(3), recordset.ResultCount)
+	assert.Len(recordset.Records, 3)
+
+	assert.Equal(int64(2), recordset.Records[0].ID)
+	assert.Equal(map[string]interface{}{
+		`state`:  `NY`,
+		`city`:   `Albany`,
+		`amount`: int64(1500),
+	}, recordset.Records[0].Fields)
+
+	assert.Equal(int64(1), recordset.Records[1].ID)
+	assert.Equal(map[string]interface{}{
+		`state`:  `NY`,
+		`city`:   `Buffalo`,
+		`amount`: int64(200),
+	}, recordset.Records[1].Fields)
+
+	assert.Equal(int64(1), recordset.Records[2].ID)
+	assert.Equal(map[string]interface{}{
+		`state`:  `TX`,
+		`city`:   `Austin`,
+		`amount`: int64(50),
+	}, recordset.Records[2].Fields)
+
+	// verify the nested terms aggregations were requested
+	assert.Equal(float64(0), server.lastQuery[`size`])
+	assert.Equal(`state`, server.lastQuery[`aggs`].(map[string]interface{})[`state`].(map[string]interface{})[`terms`].(map[string]interface{})[`field`])
+	assert.Equal(float64(MaxFacetCardinality), server.lastQuery[`aggs`].(map[string]interface{})[`state`].(map[string]interface{})[`terms`].(map[string]interface{})[`size`])
+
+	// bounds are applied to the flattened results
+	f.Limit = 1
+	f.Offset = 1
+
+	recordset, err = indexer.GroupBy(collection, []string{`state`, `city`}, []filter.Aggregate{
+		{
+			Aggregation: filter.Sum,
+			Field:       `amount`,
+		},
+	}, f)
+
+	assert.NoError(err)
+	assert.Len(recordset.Records, 1)
+	assert.Equal(`Buffalo`, recordset.Records[0].Get(`city`))
+}
+
+func TestElasticsearchGroupByPaginated(t *testing.T) {
+	assert := require.New(t)
+
+	// amounts by state and city; each state has 15 cities whose amounts interleave with the other's
+	amounts := map[string][]int{}
+
+	for i := 0; i < 15; i++ {
+		amounts[`NY`] = append(amounts[`NY`], 1000-(i*20))
+		amounts[`TX`] = append(amounts[`TX`], 990-(i*20))
+	}
+
+	server := newEsReplayServer(nil)
+
+	// behaves like Elasticsearch: the city buckets of each state are sorted by amount (descending),
+	// and limited to the requested size
+	server.respond = func(query map[string]interface{}) string {
+		size := int(maputil.DeepGet(query, []string{`aggs`, `state`, `aggs`, `city`, `terms`, `size`}).(float64))
+		states := make([]string, 0)
+
+		for _, state := range []string{`NY`, `TX`} {
+			cities := make([]string, 0)
+
+			for i, amount := range amounts[state] {
+				if i < size {
+					cities = append(cities, fmt.Sprintf(
+						`{"key": "%s-%02d", "doc_count": 1, "amount": {"value": %d}}`, state, i, amount,
+					))
+				}
+			}
+
+			states = append(states, fmt.Sprintf(
+				`{"key": "%s", "doc_count": 15, "city": {"buckets": [%s]}}`, state, strings.Join(cities, `,`),
+			))
+		}
+
+		return fmt.Sprintf(`{"took": 1, "aggregations": {"state": {"buckets": [%s]}}}`, strings.Join(states, `,`))
+	}
+
+	defer server.Close()
+
+	collection := dal.NewCollection(`orders`)
+	collection.AddFields(dal.Field{
+		Name: `state`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `city`,
+		Type: dal.StringType,
+	}, dal.Field{
+		Name: `amount`,
+		Type: dal.IntType,
+	})
+
+	indexer := server.indexer(assert)
+
+	f := filter.All()
+	f.Sort = []string{`-amount`}
+	f.Offset = 10
+	f.Limit = 10
+
+	recordset, err := indexer.GroupBy(collection, []string{`state`, `city`}, []filter.Aggregate{
+		{
+			Aggregation: filter.Sum,
+			Field:       `amount`,
+		},
+	}, f)
+
+	assert.NoError(err)
+	assert.Len(recordset.Records, 10)
+
+	// the second page of all cities, sorted together
+	for i, record := range recordset.Records {
+		rank := f.Offset + i
+		state := []string{`NY`, `TX`}[rank%2]
+
+		assert.Equal(fmt.Sprintf("%s-%02d", state, rank/2), record.Get(`city`))
+		assert.Equal(state, record.Get(`state`))
+		assert.EqualValues(amounts[state][rank/2], record.Get(`amount`))
+	}
+
+	// each state was asked for enough buckets to fill the page
+	assert.Equal(float64(20), maputil.DeepGet(server.lastQuery, []string{`aggs`, `state`, `aggs`, `city`, `terms`, `size`}))
+}
+
+func TestElasticsearchSingleAggregate(t *testing.T) {
+	assert := require.New(t)
+
+	server := newEsReplayServer(map[string]string{
+		`/orders/_search`: `{
+			"took": 1,
+			"aggregations": {
+				"amount": {"value": 1750.5}
+			}
+		}`,
+	})
+
+	defer server.Close()
+
+	indexer := server.indexer(assert)
+	collection := dal.NewCollection(`orders`)
+
+	v, err := indexer.Sum(collection, `amount`)
+	assert.NoError(err)
+	assert.Equal(float64(1750.5), v)
+	assert.Equal(map[string]interface{}{
+		`amount`: map[string]interface{}{
+			`sum`: map[string]interface{}{
+				`field`: `amount`,
+			},
+		},
+	}, server.lastQuery[`aggs`])
+}
+
+func TestElasticsearchListValues(t *testing.T) {
+	assert := require.New(t)
+
+	server := newEsReplayServer(map[string]string{
+		`/orders`: `{"orders": {}}`,
+		`/orders/_search`: `{
+			"took": 1,
+			"aggregations": {
+				"state": {
+					"buckets": [
+						{"key": "NY", "doc_count": 3},
+						{"key": "TX", "doc_count": 1}
+					]
+				},
+				"amount": {
+					"buckets": [
+						{"key": 50, "doc_count": 1},
+						{"key": 200, "doc_count": 1}
+					]
+				}
+			}
+		}`,
+	})
+
+	defer server.Close()
+
+	indexer := server.indexer(assert)
+	collection := dal.NewCollection(`orders`)
+
+	values, err := indexer.ListValues(collection, []string{`state`, `amount`}, filter.All())
+	assert.NoError(err)
+	assert.Equal(map[string][]interface{}{
+		`state`:  {`NY`, `TX`},
+		`amount`: {float64(50), float64(200)},
+	}, values)
+
+	aggs := server.lastQuery[`aggs`].(map[string]interface{})
+	assert.Len(aggs, 2)
+	assert.Equal(map[string]interface{}{
+		`terms`: map[string]interface{}{
+			`field`: `state`,
+			`size`:  float64(MaxFacetCardinality),
+		},
+	}, aggs[`state`])
+}
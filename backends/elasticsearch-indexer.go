@@ -251,7 +251,14 @@ func (self *ElasticsearchIndexer) IndexExists(collection *dal.Collection, id int
 func (self *ElasticsearchIndexer) Index(collection *dal.Collection, records *dal.RecordSet) error {
 	defer stats.NewTiming().Send(`pivot.indexers.elasticsearch.index_time`)
 
-	if index, err := self.getIndexForCollection(collection); err == nil {
+	index, err := self.getIndexForCollection(collection)
+
+	// create the index (with mappings for the collection's fields) the first time it is written to
+	if _, ok := err.(elasticsearchIndexNotFound); ok {
+		index, err = self.createIndexForCollection(collection)
+	}
+
+	if err == nil {
 		for _, record := range records.Records {
 			querylog.Debugf("[%T] Adding %v to batch", self, record)
 
@@ -486,8 +493,71 @@ func (self *ElasticsearchIndexer) IndexRemove(collection *dal.Collection, ids []
 }
 
 func (self *ElasticsearchIndexer) ListValues(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]interface{}, error) {
-	if _, err := self.getIndexForCollection(collection); err == nil {
-		return nil, fmt.Errorf("Not Implemented")
+	if index, err := self.getIndexForCollection(collection); err == nil {
+		if f == nil {
+			f = filter.All()
+		}
+
+		if query, err := filter.Render(
+			generators.NewElasticsearchGenerator(),
+			index.Name,
+			f,
+		); err == nil {
+			var payload map[string]interface{}
+
+			if err := json.Unmarshal(query, &payload); err != nil {
+				return nil, fmt.Errorf("filter decode error: %v", err)
+			}
+
+			aggs := make(map[string]interface{})
+
+			// each field gets its own (non-nested) terms aggregation
+			for _, field := range fields {
+				qfield := field
+
+				if qfield == collection.IdentityField || qfield == `id` {
+					qfield = ElasticsearchIdentityField
+				}
+
+				aggs[field] = map[string]interface{}{
+					`terms`: map[string]interface{}{
+						`field`: qfield,
+						`size`:  MaxFacetCardinality,
+					},
+				}
+			}
+
+			payload[`aggs`] = aggs
+			payload[`size`] = 0
+			delete(payload, `from`)
+			delete(payload, `sort`)
+
+			if results, err := self.searchAggregations(index.Name, payload); err == nil {
+				rv := make(map[string][]interface{})
+
+				for _, field := range fields {
+					values := make([]interface{}, 0)
+
+					for _, bucket := range sliceutil.Sliceify(maputil.DeepGet(results, []string{field, `buckets`})) {
+						if bucketMap, ok := bucket.(map[string]interface{}); ok {
+							if v, ok := bucketMap[`key_as_string`]; ok {
+								values = append(values, v)
+							} else {
+								values = append(values, bucketMap[`key`])
+							}
+						}
+					}
+
+					rv[field] = values
+				}
+
+				return rv, nil
+			} else {
+				return nil, err
+			}
+		} else {
+			return nil, fmt.Errorf("filter error: %v", err)
+		}
 	} else {
 		return nil, err
 	}
@@ -549,6 +619,13 @@ func (self *ElasticsearchIndexer) newRequest(method string, urlpath string, body
 	}
 }
 
+// returned by getIndexForCollection when the server responds that the index does not exist
+type elasticsearchIndexNotFound string
+
+func (self elasticsearchIndexNotFound) Error() string {
+	return fmt.Sprintf("Index %v not found", string(self))
+}
+
 func (self *ElasticsearchIndexer) getIndexForCollection(collection *dal.Collection) (*elasticsearchIndex, error) {
 	defer stats.NewTiming().Send(`pivot.indexers.elasticsearch.retrieve_index`)
 	name := collection.GetIndexName()
@@ -558,6 +635,8 @@ func (self *ElasticsearchIndexer) getIndexForCollection(collection *dal.Collecti
 	} else {
 		if req, err := self.newRequest(`GET`, fmt.Sprintf("/%s", name), nil); err == nil {
 			if response, err := self.client.Do(req); err == nil {
+				defer response.Body.Close()
+
 				switch {
 				case response.StatusCode < 400:
 					var index elasticsearchIndex
@@ -572,10 +651,10 @@ func (self *ElasticsearchIndexer) getIndexForCollection(collection *dal.Collecti
 					}
 
 				case response.StatusCode == 404:
-					return nil, fmt.Errorf("Index %v not found", name)
+					return nil, elasticsearchIndexNotFound(name)
 
 				default:
-					return nil, err
+					return nil, fmt.Errorf("Failed to retrieve index %v: %v", name, response.Status)
 				}
 			} else {
 				return nil, err
@@ -586,6 +665,86 @@ func (self *ElasticsearchIndexer) getIndexForCollection(collection *dal.Collecti
 	}
 }
 
+func (self *ElasticsearchIndexer) createIndexForCollection(collection *dal.Collection) (*elasticsearchIndex, error) {
+	index := &elasticsearchIndex{
+		Name: collection.GetIndexName(),
+		Mappings: map[string]interface{}{
+			ElasticsearchDocumentType: map[string]interface{}{
+				`properties`: elasticsearchMappingFor(collection),
+			},
+		},
+	}
+
+	if req, err := self.newRequest(`PUT`, fmt.Sprintf("/%s", index.Name), map[string]interface{}{
+		`mappings`: index.Mappings,
+	}); err == nil {
+		if response, err := self.client.Do(req); err == nil {
+			defer response.Body.Close()
+
+			if response.StatusCode < 400 {
+				self.indexCache[index.Name] = index
+				return index, nil
+			} else {
+				return nil, fmt.Errorf("Failed to create index %v: %v", index.Name, response.Status)
+			}
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+}
+
+// Returns the Elasticsearch field mappings for the given collection.  Fields of unknown types are
+// left to be mapped dynamically.
+func elasticsearchMappingFor(collection *dal.Collection) map[string]interface{} {
+	properties := make(map[string]interface{})
+
+	for _, field := range collection.Fields {
+		t := field.Type
+
+		// lists are mapped by the type of their elements
+		if t == dal.ArrayType {
+			t = field.Subtype
+		}
+
+		if mapping := elasticsearchFieldMapping(t, field.Precision); mapping != nil {
+			properties[field.Name] = mapping
+		}
+	}
+
+	return properties
+}
+
+func elasticsearchFieldMapping(t dal.Type, precision int) map[string]interface{} {
+	switch t {
+	case dal.IntType:
+		return map[string]interface{}{`type`: `long`}
+	case dal.FloatType:
+		return map[string]interface{}{`type`: `double`}
+	case dal.BooleanType:
+		return map[string]interface{}{`type`: `boolean`}
+	case dal.TimeType:
+		return map[string]interface{}{`type`: `date`}
+	case dal.EnumType, dal.UUIDType:
+		return map[string]interface{}{`type`: `keyword`}
+	case dal.BytesType:
+		return map[string]interface{}{`type`: `binary`}
+	case dal.DecimalType:
+		// the exact value is kept in the document source, this only affects searching and sorting
+		if precision > 0 {
+			return map[string]interface{}{
+				`type`:           `scaled_float`,
+				`scaling_factor`: math.Pow10(precision),
+			}
+		}
+
+		return map[string]interface{}{`type`: `double`}
+	default:
+		return nil
+	}
+}
+
 func (self *ElasticsearchIndexer) useFilterMapping(index *elasticsearchIndex) {
 	// mappingImpl.AddCustomCharFilter(`remove_expression_tokens`, map[string]interface{}{
 	// 	`type`:   regexp.Name,
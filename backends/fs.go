@@ -162,7 +162,7 @@ func (self *FilesystemBackend) Insert(collectionName string, recordset *dal.Reco
 		}
 	}
 
-	return self.Update(collectionName, recordset)
+	return self.save(collectionName, recordset, true)
 }
 
 func (self *FilesystemBackend) Exists(name string, id interface{}) bool {
@@ -203,9 +203,26 @@ func (self *FilesystemBackend) Retrieve(name string, id interface{}, fields ...s
 }
 
 func (self *FilesystemBackend) Update(name string, recordset *dal.RecordSet, target ...string) error {
+	return self.save(name, recordset, false)
+}
+
+// Formats, validates, and writes each record in the given recordset.  Updated records replace the
+// existing record with the same ID (if any).
+func (self *FilesystemBackend) save(name string, recordset *dal.RecordSet, insert bool) error {
 	if collection, err := self.GetCollection(name); err == nil {
 		for _, record := range recordset.Records {
-			if r, err := collection.MakeRecord(record); err == nil {
+			var existing dal.Record
+			var stored *dal.Record
+
+			// the stored record is only used to keep unchanged hashed values as they are, and may be
+			// shared with the record cache, so it is left unmodified
+			if !insert && record.ID != nil {
+				if err := self.readObject(collection, fmt.Sprintf("%v", record.ID), true, &existing); err == nil {
+					stored = &existing
+				}
+			}
+
+			if r, err := collection.MakeRecord(record, stored); err == nil {
 				record = r
 			} else {
 				return err
@@ -262,6 +279,17 @@ func (self *FilesystemBackend) WithSearch(collection *dal.Collection, filters ..
 }
 
 func (self *FilesystemBackend) WithAggregator(collection *dal.Collection) Aggregator {
+	if aggregator, ok := self.aggregator[collection.GetAggregatorName()]; ok {
+		return aggregator
+	}
+
+	// use the indexer to perform aggregations if it supports them
+	if self.indexer != nil && self.indexer != Indexer(self) {
+		if aggregator, ok := self.indexer.(Aggregator); ok {
+			return aggregator
+		}
+	}
+
 	return nil
 }
 
@@ -570,6 +598,11 @@ func (self *FilesystemBackend) prepareIncomingRecord(collectionName string, reco
 			record.ID = stringutil.Autotype(record.ID)
 		}
 
+		// YAML is converted to and from JSON, so records in either format are decoded the same way
+		if err := collection.DecodeJSONRecord(record); err != nil {
+			return err
+		}
+
 		// do this AFTER populating the record's fields from the database
 		if err := record.Populate(record, collection); err != nil {
 			return err
@@ -52,6 +52,62 @@ func MakeIndexer(connection dal.ConnectionString) (Indexer, error) {
 	}
 }
 
+// Returns the fields in the collection that backends with their own secondary indexes should index:
+// those marked as indexed, unique, or key (other than the identity field).
+func secondaryIndexFields(collection *dal.Collection) []dal.Field {
+	fields := make([]dal.Field, 0)
+
+	for _, field := range collection.Fields {
+		if field.Identity || field.Name == collection.IdentityField {
+			continue
+		}
+
+		if field.Indexed || field.Unique || field.Key {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields
+}
+
+// Calls resultFn for each record in the page of results described by the filter's limit and offset,
+// for indexers that find all matching records before returning any of them.
+func emitRecordPage(records []*dal.Record, f *filter.Filter, resultFn IndexResultFunc) error {
+	total := len(records)
+	page := IndexPage{
+		Page:         1,
+		TotalPages:   1,
+		Limit:        f.Limit,
+		Offset:       f.Offset,
+		TotalResults: int64(total),
+	}
+
+	if f.Limit > 0 {
+		page.Page = (f.Offset / f.Limit) + 1
+		page.TotalPages = int(math.Ceil(float64(total) / float64(f.Limit)))
+	}
+
+	if f.Offset > 0 {
+		if f.Offset < len(records) {
+			records = records[f.Offset:]
+		} else {
+			records = nil
+		}
+	}
+
+	if f.Limit > 0 && len(records) > f.Limit {
+		records = records[:f.Limit]
+	}
+
+	for _, record := range records {
+		if err := resultFn(record, nil, page); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func PopulateRecordSetPageDetails(recordset *dal.RecordSet, f *filter.Filter, page IndexPage) {
 	// result count is whatever we were told it was for this query
 	if page.TotalResults >= 0 {
@@ -0,0 +1,110 @@
+package backends
+
+// this file satifies the Aggregator interface for MemoryBackend
+
+import (
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter"
+)
+
+func (self *MemoryBackend) Sum(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	return self.aggregateFloat(collection, filter.Sum, field, f)
+}
+
+func (self *MemoryBackend) Count(collection *dal.Collection, flt ...*filter.Filter) (uint64, error) {
+	if records, err := self.matchingRecords(collection, memoryAggregateFilter(flt)); err == nil {
+		return uint64(len(records)), nil
+	} else {
+		return 0, err
+	}
+}
+
+func (self *MemoryBackend) Minimum(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	return self.aggregateFloat(collection, filter.Minimum, field, f)
+}
+
+func (self *MemoryBackend) Maximum(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	return self.aggregateFloat(collection, filter.Maximum, field, f)
+}
+
+func (self *MemoryBackend) Average(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	return self.aggregateFloat(collection, filter.Average, field, f)
+}
+
+func (self *MemoryBackend) GroupBy(collection *dal.Collection, groupBy []string, aggregates []filter.Aggregate, flt ...*filter.Filter) (*dal.RecordSet, error) {
+	f := memoryAggregateFilter(flt)
+
+	if groups, err := self.aggregate(collection, groupBy, aggregates, f); err == nil {
+		recordset := groupedRecordSet(collection, groups, aggregates, f)
+
+		sortRecords(recordset, f, aggregates, func(record *dal.Record, field string) interface{} {
+			return memoryRecordValue(collection, record, field)
+		})
+
+		limitRecords(recordset, f)
+
+		return recordset, nil
+	} else {
+		return nil, err
+	}
+}
+
+func (self *MemoryBackend) AggregatorConnectionString() *dal.ConnectionString {
+	return &self.conn
+}
+
+func (self *MemoryBackend) AggregatorInitialize(parent Backend) error {
+	return nil
+}
+
+func (self *MemoryBackend) aggregateFloat(collection *dal.Collection, aggregation filter.Aggregation, field string, flt []*filter.Filter) (float64, error) {
+	if groups, err := self.aggregate(collection, nil, []filter.Aggregate{
+		{
+			Aggregation: aggregation,
+			Field:       field,
+		},
+	}, memoryAggregateFilter(flt)); err == nil {
+		if len(groups) > 0 {
+			if v, ok := groups[0].values[field]; ok {
+				return stringutil.ConvertToFloat(v.Value(aggregation))
+			}
+		}
+
+		return 0, nil
+	} else {
+		return 0, err
+	}
+}
+
+// accumulates the values of all aggregated fields from every record matching the given filter into
+// one group per distinct combination of grouped values
+func (self *MemoryBackend) aggregate(collection *dal.Collection, groupBy []string, aggregates []filter.Aggregate, f *filter.Filter) ([]*aggregateGroup, error) {
+	// grouping considers every matching record, regardless of sorting and pagination
+	query := filter.Copy(f)
+	query.Sort = nil
+
+	records, err := self.matchingRecords(collection, &query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	accumulator := newGroupAccumulator(collection, groupBy, aggregates, memoryRecordValue)
+
+	for _, record := range records {
+		if err := accumulator.Add(record); err != nil {
+			return nil, err
+		}
+	}
+
+	return accumulator.groups, nil
+}
+
+func memoryAggregateFilter(flt []*filter.Filter) *filter.Filter {
+	if len(flt) > 0 && flt[0] != nil {
+		return flt[0]
+	}
+
+	return filter.All()
+}
@@ -0,0 +1,411 @@
+package backends
+
+// this file satifies the Indexer interface for MemoryBackend
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/ghetzel/go-stockutil/typeutil"
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter"
+)
+
+func (self *MemoryBackend) IndexConnectionString() *dal.ConnectionString {
+	return &self.conn
+}
+
+func (self *MemoryBackend) IndexInitialize(_ Backend) error {
+	return nil
+}
+
+func (self *MemoryBackend) GetBackend() Backend {
+	return self
+}
+
+func (self *MemoryBackend) IndexExists(collection *dal.Collection, id interface{}) bool {
+	return self.Exists(collection.GetIndexName(), id)
+}
+
+func (self *MemoryBackend) IndexRetrieve(collection *dal.Collection, id interface{}) (*dal.Record, error) {
+	return self.Retrieve(collection.GetIndexName(), id)
+}
+
+func (self *MemoryBackend) IndexRemove(collection *dal.Collection, ids []interface{}) error {
+	return nil
+}
+
+func (self *MemoryBackend) Index(collection *dal.Collection, records *dal.RecordSet) error {
+	return nil
+}
+
+func (self *MemoryBackend) QueryFunc(collection *dal.Collection, f *filter.Filter, resultFn IndexResultFunc) error {
+	if f == nil {
+		f = filter.All()
+	}
+
+	querylog.Debugf("[%T] Query using filter %q", self, f.String())
+
+	if records, err := self.matchingRecords(collection, f); err == nil {
+		return emitRecordPage(records, f, resultFn)
+	} else {
+		return err
+	}
+}
+
+func (self *MemoryBackend) Query(collection *dal.Collection, f *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error) {
+	if f == nil {
+		f = filter.All()
+	}
+
+	return DefaultQueryImplementation(self, collection, f, resultFns...)
+}
+
+func (self *MemoryBackend) ListValues(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]interface{}, error) {
+	values := make(map[string][]interface{})
+
+	if err := self.QueryFunc(collection, f, func(record *dal.Record, err error, page IndexPage) error {
+		if err != nil {
+			return err
+		}
+
+		for _, field := range fields {
+			values[field] = sliceutil.Unique(append(values[field], memoryRecordValue(collection, record, field)))
+		}
+
+		return nil
+	}); err == nil {
+		return values, nil
+	} else {
+		return values, err
+	}
+}
+
+func (self *MemoryBackend) DeleteQuery(collection *dal.Collection, f *filter.Filter) error {
+	ids := make([]interface{}, 0)
+
+	if err := self.QueryFunc(collection, f, func(record *dal.Record, err error, page IndexPage) error {
+		if err == nil {
+			ids = append(ids, record.ID)
+		}
+
+		return err
+	}); err == nil {
+		return self.Delete(collection.Name, ids...)
+	} else {
+		return err
+	}
+}
+
+func (self *MemoryBackend) FlushIndex() error {
+	return nil
+}
+
+// returns copies of all records in the collection that match the given filter, sorted according to
+// the filter's sort fields (or in insertion order if none are given)
+func (self *MemoryBackend) matchingRecords(collection *dal.Collection, f *filter.Filter) ([]*dal.Record, error) {
+	for _, criterion := range f.Criteria {
+		if !isMemoryOperator(criterion.Operator) {
+			return nil, fmt.Errorf("Unimplemented operator '%s'", criterion.Operator)
+		}
+	}
+
+	if records, err := self.listRecords(collection.Name); err == nil {
+		matches := make([]*dal.Record, 0)
+
+		for _, record := range records {
+			if memoryMatchesFilter(collection, f, record) {
+				matches = append(matches, record)
+			}
+		}
+
+		memorySortRecords(collection, matches, f)
+
+		return matches, nil
+	} else {
+		return nil, err
+	}
+}
+
+// sorts records according to the filter's sort fields, keeping the existing order of equal records
+func memorySortRecords(collection *dal.Collection, records []*dal.Record, f *filter.Filter) {
+	if sortBy := f.GetSort(); len(sortBy) > 0 {
+		sort.SliceStable(records, func(i int, j int) bool {
+			for _, s := range sortBy {
+				c := memoryCompareForSort(
+					memoryRecordValue(collection, records[i], s.Field),
+					memoryRecordValue(collection, records[j], s.Field),
+				)
+
+				if c != 0 {
+					return (c < 0) != s.Descending
+				}
+			}
+
+			return false
+		})
+	}
+}
+
+func isMemoryOperator(operator string) bool {
+	switch operator {
+	case ``, `is`, `not`, `like`, `unlike`, `prefix`, `suffix`, `contains`, `gt`, `gte`, `lt`, `lte`, `range`:
+		return true
+	}
+
+	return false
+}
+
+// Returns whether the given record satisfies every criterion in the filter.  Criteria with multiple
+// values match if any of the values match, except for "not" and "unlike", which match if none of them
+// do (i.e.: the same as an SQL IN() or NOT IN() statement).
+func memoryMatchesFilter(collection *dal.Collection, f *filter.Filter, record *dal.Record) bool {
+	if f.IsMatchAll() {
+		return true
+	}
+
+	normalizer := f.Normalizer
+
+	if normalizer == nil {
+		normalizer = filter.DefaultNormalizerFunc
+	}
+
+	for _, criterion := range f.Criteria {
+		value := memoryRecordValue(collection, record, criterion.Field)
+
+		if criterion.Field == f.IdentityField {
+			value = record.ID
+		}
+
+		values := make([]interface{}, len(criterion.Values))
+
+		for i, v := range criterion.Values {
+			values[i] = memoryCriterionValue(collection, criterion, v)
+		}
+
+		switch criterion.Operator {
+		case `not`, `unlike`:
+			for _, v := range values {
+				if v != nil && value == nil {
+					return false
+				} else if memoryMatchesValue(criterion.Operator, value, v, normalizer) {
+					return false
+				}
+			}
+
+		case `range`:
+			if len(values)%2 != 0 {
+				return false
+			}
+
+			var inRange bool
+
+			for i := 0; i < len(values); i += 2 {
+				if memoryMatchesValue(`gte`, value, values[i], normalizer) && memoryMatchesValue(`lt`, value, values[i+1], normalizer) {
+					inRange = true
+					break
+				}
+			}
+
+			if !inRange {
+				return false
+			}
+
+		default:
+			var matched bool
+
+			for _, v := range values {
+				if memoryMatchesValue(criterion.Operator, value, v, normalizer) {
+					matched = true
+					break
+				}
+			}
+
+			if !matched {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// Returns whether a single record value satisfies an operator for a single criterion value.  For the
+// inverting operators, this returns whether the value is equal (so the caller can invert it).  List
+// values match if any of their elements match.
+func memoryMatchesValue(operator string, value interface{}, criterionValue interface{}, normalizer filter.NormalizerFunc) bool {
+	if typeutil.IsArray(value) {
+		if _, isBytes := value.([]byte); !isBytes {
+			for _, item := range sliceutil.Sliceify(value) {
+				if memoryMatchesValue(operator, item, criterionValue, normalizer) {
+					return true
+				}
+			}
+
+			return false
+		}
+	}
+
+	// null comparisons only succeed for equality
+	if value == nil || criterionValue == nil {
+		switch operator {
+		case ``, `is`, `not`, `like`, `unlike`:
+			return (value == nil && criterionValue == nil)
+		default:
+			return false
+		}
+	}
+
+	switch operator {
+	case ``, `is`, `not`:
+		c, ok := memoryCompare(value, criterionValue)
+		return ok && c == 0
+
+	case `like`, `unlike`:
+		return normalizer(fmt.Sprintf("%v", value)) == normalizer(fmt.Sprintf("%v", criterionValue))
+
+	case `prefix`, `suffix`, `contains`:
+		haystack := strings.ToLower(normalizer(fmt.Sprintf("%v", value)))
+		needle := strings.ToLower(normalizer(fmt.Sprintf("%v", criterionValue)))
+
+		switch operator {
+		case `prefix`:
+			return strings.HasPrefix(haystack, needle)
+		case `suffix`:
+			return strings.HasSuffix(haystack, needle)
+		default:
+			return strings.Contains(haystack, needle)
+		}
+
+	case `gt`, `gte`, `lt`, `lte`:
+		if c, ok := memoryCompare(value, criterionValue); ok {
+			switch operator {
+			case `gt`:
+				return (c > 0)
+			case `gte`:
+				return (c >= 0)
+			case `lt`:
+				return (c < 0)
+			default:
+				return (c <= 0)
+			}
+		}
+	}
+
+	return false
+}
+
+// returns the value of the named field in a record, which may be the identity field or a nested field
+func memoryRecordValue(collection *dal.Collection, record *dal.Record, field string) interface{} {
+	if field == collection.IdentityField || field == dal.DefaultIdentityField {
+		return record.ID
+	}
+
+	return record.Get(field)
+}
+
+// Converts a criterion value (which is usually a string parsed from a filter) to the type of the
+// field it is compared against.  The literal value "null" and empty values are treated as nil.
+func memoryCriterionValue(collection *dal.Collection, criterion filter.Criterion, value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+
+	switch fmt.Sprintf("%v", value) {
+	case `null`, `NULL`, ``:
+		return nil
+	}
+
+	switch criterion.Operator {
+	case `like`, `unlike`, `prefix`, `suffix`, `contains`:
+		return value
+	}
+
+	if criterion.Type != `` && criterion.Type != dal.AutoType {
+		if v, err := (&dal.Field{Type: criterion.Type}).ConvertValue(value); err == nil {
+			return v
+		}
+	} else if field, ok := collection.GetField(criterion.Field); ok && field.Type != dal.ArrayType {
+		if v, err := field.ConvertValue(value); err == nil {
+			return v
+		}
+	}
+
+	return stringutil.Autotype(value)
+}
+
+// Compares two non-nil values, returning -1, 0, or 1 if a is less than, equal to, or greater than b
+// (respectively), and whether the two values could be compared at all.  Times are compared
+// chronologically and numbers numerically; everything else is compared as strings.
+func memoryCompare(a interface{}, b interface{}) (int, bool) {
+	if aT, ok := a.(time.Time); ok {
+		if bT, err := stringutil.ConvertToTime(b); err == nil {
+			return memoryCompareTimes(aT, bT), true
+		}
+
+		return 0, false
+	} else if bT, ok := b.(time.Time); ok {
+		if aT, err := stringutil.ConvertToTime(a); err == nil {
+			return memoryCompareTimes(aT, bT), true
+		}
+
+		return 0, false
+	}
+
+	if isMemoryNumber(a) || isMemoryNumber(b) {
+		if aF, err := stringutil.ConvertToFloat(a); err == nil {
+			if bF, err := stringutil.ConvertToFloat(b); err == nil {
+				switch {
+				case aF < bF:
+					return -1, true
+				case aF > bF:
+					return 1, true
+				default:
+					return 0, true
+				}
+			}
+		}
+	}
+
+	return strings.Compare(fmt.Sprintf("%v", a), fmt.Sprintf("%v", b)), true
+}
+
+// like memoryCompare, but orders nil values before all others
+func memoryCompareForSort(a interface{}, b interface{}) int {
+	if a == nil && b == nil {
+		return 0
+	} else if a == nil {
+		return -1
+	} else if b == nil {
+		return 1
+	}
+
+	c, _ := memoryCompare(a, b)
+	return c
+}
+
+func memoryCompareTimes(a time.Time, b time.Time) int {
+	if a.Before(b) {
+		return -1
+	} else if a.After(b) {
+		return 1
+	}
+
+	return 0
+}
+
+func isMemoryNumber(value interface{}) bool {
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,611 @@
+package backends
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/ghetzel/go-stockutil/typeutil"
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter"
+)
+
+// a single collection and the records in it, in the order they were first inserted
+type memoryCollection struct {
+	definition *dal.Collection
+	records    map[string]*dal.Record
+	order      []string
+	sequence   int64
+}
+
+// the on-disk representation of a MemoryBackend
+type memorySnapshot struct {
+	Collections []memorySnapshotCollection `json:"collections"`
+}
+
+type memorySnapshotCollection struct {
+	Schema  *dal.Collection `json:"schema"`
+	Records []*dal.Record   `json:"records"`
+}
+
+// A backend that keeps all collections and records in memory.  It implements the Indexer and
+// Aggregator interfaces itself, supporting all filter operators, sorting, and pagination.
+//
+// If a path is given in the connection string (e.g.: memory:///path/to/snapshot.json), the
+// contents of that file are loaded when the backend is initialized, and are written back to it
+// whenever the backend is flushed.  If the "autosave" option is true, the file is also written after
+// every change.
+type MemoryBackend struct {
+	conn         dal.ConnectionString
+	indexer      Indexer
+	aggregator   map[string]Aggregator
+	collections  map[string]*memoryCollection
+	snapshotPath string
+	autosave     bool
+	lock         sync.RWMutex
+}
+
+func NewMemoryBackend(connection dal.ConnectionString) Backend {
+	return &MemoryBackend{
+		conn:        connection,
+		aggregator:  make(map[string]Aggregator),
+		collections: make(map[string]*memoryCollection),
+	}
+}
+
+func (self *MemoryBackend) GetConnectionString() *dal.ConnectionString {
+	return &self.conn
+}
+
+func (self *MemoryBackend) Ping(timeout time.Duration) error {
+	return nil
+}
+
+func (self *MemoryBackend) RegisterCollection(definition *dal.Collection) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if collection, ok := self.collections[definition.Name]; ok {
+		collection.definition = definition
+	} else {
+		self.collections[definition.Name] = newMemoryCollection(definition)
+	}
+}
+
+func (self *MemoryBackend) SetIndexer(indexConnString dal.ConnectionString) error {
+	if indexer, err := MakeIndexer(indexConnString); err == nil {
+		self.indexer = indexer
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *MemoryBackend) Initialize() error {
+	if dataset := self.conn.Dataset(); dataset != `` {
+		self.snapshotPath = `/` + dataset
+	}
+
+	self.autosave = self.conn.OptBool(`autosave`, false)
+
+	if self.snapshotPath != `` {
+		if _, err := os.Stat(self.snapshotPath); err == nil {
+			if err := self.LoadSnapshot(self.snapshotPath); err != nil {
+				return err
+			}
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if self.indexer == nil {
+		self.indexer = self
+	}
+
+	if err := self.indexer.IndexInitialize(self); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (self *MemoryBackend) Insert(name string, recordset *dal.RecordSet) error {
+	return self.save(name, recordset, true)
+}
+
+func (self *MemoryBackend) Exists(name string, id interface{}) bool {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	if collection, ok := self.collections[name]; ok {
+		_, ok := collection.records[memoryKey(id)]
+		return ok
+	}
+
+	return false
+}
+
+func (self *MemoryBackend) Retrieve(name string, id interface{}, fields ...string) (*dal.Record, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	if collection, ok := self.collections[name]; ok {
+		if record, ok := collection.records[memoryKey(id)]; ok {
+			output := copyMemoryRecord(record)
+
+			if len(fields) > 0 {
+				for key := range output.Fields {
+					if !sliceutil.ContainsString(fields, key) {
+						delete(output.Fields, key)
+					}
+				}
+			}
+
+			return output, nil
+		} else {
+			return nil, fmt.Errorf("Record %q does not exist", id)
+		}
+	} else {
+		return nil, dal.CollectionNotFound
+	}
+}
+
+// Records that already exist are updated with the fields given in the new record, and all others are
+// inserted.
+func (self *MemoryBackend) Update(name string, recordset *dal.RecordSet, target ...string) error {
+	return self.save(name, recordset, false)
+}
+
+func (self *MemoryBackend) Delete(name string, ids ...interface{}) error {
+	if collection, err := self.getCollection(name); err == nil {
+		self.lock.Lock()
+
+		for _, id := range ids {
+			collection.remove(memoryKey(id))
+		}
+
+		self.lock.Unlock()
+
+		// remove documents from index
+		if search := self.WithSearch(collection.definition); search != nil {
+			if err := search.IndexRemove(collection.definition, ids); err != nil {
+				return err
+			}
+		}
+
+		return self.autosaveSnapshot()
+	} else {
+		return err
+	}
+}
+
+func (self *MemoryBackend) WithSearch(collection *dal.Collection, filters ...*filter.Filter) Indexer {
+	return self.indexer
+}
+
+func (self *MemoryBackend) WithAggregator(collection *dal.Collection) Aggregator {
+	if aggregator, ok := self.aggregator[collection.GetAggregatorName()]; ok {
+		return aggregator
+	}
+
+	// use the indexer to perform aggregations if it supports them
+	if self.indexer != nil && self.indexer != Indexer(self) {
+		if aggregator, ok := self.indexer.(Aggregator); ok {
+			return aggregator
+		}
+	}
+
+	return self
+}
+
+func (self *MemoryBackend) ListCollections() ([]string, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	names := make([]string, 0, len(self.collections))
+
+	for name := range self.collections {
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+func (self *MemoryBackend) CreateCollection(definition *dal.Collection) error {
+	self.lock.Lock()
+
+	if _, ok := self.collections[definition.Name]; ok {
+		self.lock.Unlock()
+		return fmt.Errorf("Collection %q already exists", definition.Name)
+	}
+
+	self.collections[definition.Name] = newMemoryCollection(definition)
+	self.lock.Unlock()
+
+	return self.autosaveSnapshot()
+}
+
+func (self *MemoryBackend) DeleteCollection(name string) error {
+	self.lock.Lock()
+
+	if _, ok := self.collections[name]; !ok {
+		self.lock.Unlock()
+		return dal.CollectionNotFound
+	}
+
+	delete(self.collections, name)
+	self.lock.Unlock()
+
+	return self.autosaveSnapshot()
+}
+
+func (self *MemoryBackend) GetCollection(name string) (*dal.Collection, error) {
+	if collection, err := self.getCollection(name); err == nil {
+		return collection.definition, nil
+	} else {
+		return nil, err
+	}
+}
+
+func (self *MemoryBackend) Flush() error {
+	if self.indexer != nil && self.indexer != Indexer(self) {
+		if err := self.indexer.FlushIndex(); err != nil {
+			return err
+		}
+	}
+
+	if self.snapshotPath != `` {
+		return self.SaveSnapshot(self.snapshotPath)
+	}
+
+	return nil
+}
+
+// Writes all collections and records to the given file as JSON.  The file is replaced atomically,
+// so a failed write will not corrupt an existing snapshot.
+func (self *MemoryBackend) SaveSnapshot(filename string) error {
+	self.lock.RLock()
+	snapshot := memorySnapshot{
+		Collections: make([]memorySnapshotCollection, 0, len(self.collections)),
+	}
+
+	for _, collection := range self.collections {
+		records := make([]*dal.Record, len(collection.order))
+
+		for i, key := range collection.order {
+			records[i] = collection.records[key]
+		}
+
+		snapshot.Collections = append(snapshot.Collections, memorySnapshotCollection{
+			Schema:  collection.definition,
+			Records: records,
+		})
+	}
+
+	data, err := json.MarshalIndent(&snapshot, ``, `  `)
+	self.lock.RUnlock()
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return err
+	}
+
+	if tmp, err := ioutil.TempFile(filepath.Dir(filename), `.`+filepath.Base(filename)+`-`); err == nil {
+		defer os.Remove(tmp.Name())
+
+		if _, err := tmp.Write(data); err != nil {
+			tmp.Close()
+			return err
+		}
+
+		if err := tmp.Close(); err != nil {
+			return err
+		}
+
+		return os.Rename(tmp.Name(), filename)
+	} else {
+		return err
+	}
+}
+
+// Replaces all collections and records with those in the given snapshot file.  Record values are
+// converted to the types declared in each collection's schema, since JSON cannot represent all of
+// them natively.
+func (self *MemoryBackend) LoadSnapshot(filename string) error {
+	var snapshot memorySnapshot
+
+	if data, err := ioutil.ReadFile(filename); err == nil {
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			return fmt.Errorf("invalid snapshot %v: %v", filename, err)
+		}
+	} else {
+		return err
+	}
+
+	collections := make(map[string]*memoryCollection)
+
+	for _, sc := range snapshot.Collections {
+		if sc.Schema == nil {
+			return fmt.Errorf("invalid snapshot %v: collection is missing a schema", filename)
+		}
+
+		collection := newMemoryCollection(sc.Schema)
+
+		for _, record := range sc.Records {
+			record.ID = memoryIdentity(sc.Schema, record.ID)
+
+			for key, value := range record.Fields {
+				record.Fields[key] = memoryJSONFieldValue(sc.Schema, key, value)
+			}
+
+			collection.put(record)
+		}
+
+		collections[sc.Schema.Name] = collection
+	}
+
+	self.lock.Lock()
+	self.collections = collections
+	self.lock.Unlock()
+
+	return nil
+}
+
+// Formats, validates, and stores each record in the given recordset.  Records without an ID are
+// assigned the next one in sequence if the collection's identity field is an integer, and the IDs of
+// the records in the recordset are updated to match what was stored.
+func (self *MemoryBackend) save(name string, recordset *dal.RecordSet, insert bool) error {
+	if collection, err := self.getCollection(name); err == nil {
+		definition := collection.definition
+
+		for _, record := range recordset.Records {
+			var existing *dal.Record
+
+			if !insert {
+				self.lock.RLock()
+				existing = collection.records[memoryKey(memoryIdentity(definition, record.ID))]
+				self.lock.RUnlock()
+			}
+
+			if r, err := memoryPrepareRecord(definition, record, insert, existing); err == nil {
+				self.lock.Lock()
+
+				if r.ID == nil && definition.IdentityFieldType == dal.IntType {
+					r.ID = collection.sequence + 1
+				}
+
+				if r.ID == nil {
+					self.lock.Unlock()
+					return fmt.Errorf("Cannot save a record without an ID to collection %q", name)
+				} else if _, ok := collection.records[memoryKey(r.ID)]; ok && insert {
+					self.lock.Unlock()
+					return fmt.Errorf("Record %q already exists", r.ID)
+				}
+
+				collection.put(r)
+				record.ID = r.ID
+				self.lock.Unlock()
+			} else {
+				return err
+			}
+		}
+
+		if search := self.WithSearch(definition); search != nil {
+			if err := search.Index(definition, recordset); err != nil {
+				return err
+			}
+		}
+
+		return self.autosaveSnapshot()
+	} else {
+		return err
+	}
+}
+
+func (self *MemoryBackend) autosaveSnapshot() error {
+	if self.autosave && self.snapshotPath != `` {
+		return self.SaveSnapshot(self.snapshotPath)
+	}
+
+	return nil
+}
+
+func (self *MemoryBackend) getCollection(name string) (*memoryCollection, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	if collection, ok := self.collections[name]; ok {
+		return collection, nil
+	} else {
+		return nil, dal.CollectionNotFound
+	}
+}
+
+// returns copies of all records in the collection, in insertion order
+func (self *MemoryBackend) listRecords(name string) ([]*dal.Record, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	if collection, ok := self.collections[name]; ok {
+		records := make([]*dal.Record, len(collection.order))
+
+		for i, key := range collection.order {
+			records[i] = copyMemoryRecord(collection.records[key])
+		}
+
+		return records, nil
+	} else {
+		return nil, dal.CollectionNotFound
+	}
+}
+
+func newMemoryCollection(definition *dal.Collection) *memoryCollection {
+	return &memoryCollection{
+		definition: definition,
+		records:    make(map[string]*dal.Record),
+		order:      make([]string, 0),
+	}
+}
+
+// stores a copy of the given record, merging its fields into those of any existing record with the
+// same ID
+func (self *memoryCollection) put(record *dal.Record) {
+	key := memoryKey(record.ID)
+	record = copyMemoryRecord(record)
+
+	if existing, ok := self.records[key]; ok {
+		for k, v := range record.Fields {
+			existing.Fields[k] = v
+		}
+
+		if len(record.Data) > 0 {
+			existing.Data = record.Data
+		}
+	} else {
+		self.records[key] = record
+		self.order = append(self.order, key)
+	}
+
+	if id, ok := record.ID.(int64); ok && id > self.sequence {
+		self.sequence = id
+	}
+}
+
+func (self *memoryCollection) remove(key string) {
+	if _, ok := self.records[key]; ok {
+		delete(self.records, key)
+
+		for i, k := range self.order {
+			if k == key {
+				self.order = append(self.order[:i], self.order[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+func memoryKey(id interface{}) string {
+	return fmt.Sprintf("%v", id)
+}
+
+// Formats and validates a record being inserted, or one being merged into an existing record (see
+// Collection.MakeRecordForUpdate).  Values are stored as the types declared in the schema, as other
+// backends return them.
+func memoryPrepareRecord(collection *dal.Collection, record *dal.Record, insert bool, existing *dal.Record) (*dal.Record, error) {
+	var r *dal.Record
+	var err error
+
+	if insert {
+		r, err = collection.MakeRecord(record)
+	} else {
+		r, err = collection.MakeRecordForUpdate(record, existing)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	r.ID = memoryIdentity(collection, r.ID)
+
+	for key, value := range r.Fields {
+		r.Fields[key] = memoryFieldValue(collection, key, value)
+	}
+
+	return r, nil
+}
+
+// IDs are stored as strings if the collection's identity field is a string, and as the most
+// appropriate native type otherwise
+func memoryIdentity(collection *dal.Collection, id interface{}) interface{} {
+	if id == nil || typeutil.IsZero(id) {
+		return nil
+	} else if collection.IdentityFieldType == dal.StringType {
+		return fmt.Sprintf("%v", id)
+	} else {
+		return stringutil.Autotype(id)
+	}
+}
+
+// Converts a value that was decoded from JSON to the type of the named field.  Values that can't be
+// decoded (see dal.Field.DecodeJSONValue) are converted as they are.
+func memoryJSONFieldValue(collection *dal.Collection, name string, value interface{}) interface{} {
+	if field, ok := collection.GetField(name); ok {
+		if v, err := field.DecodeJSONValue(value); err == nil {
+			value = v
+		}
+	}
+
+	return memoryFieldValue(collection, name, value)
+}
+
+// Converts a value to the type of the named field.  Unlike Collection.ConvertValue, zero values of
+// scalar fields are kept rather than being replaced with nil.
+func memoryFieldValue(collection *dal.Collection, name string, value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+
+	if field, ok := collection.GetField(name); ok {
+		if v, err := field.ConvertValue(value); err == nil {
+			if v != nil {
+				return v
+			}
+
+			switch field.Type {
+			case dal.StringType, dal.BooleanType, dal.IntType, dal.FloatType:
+				return field.GetTypeInstance()
+			}
+		}
+	}
+
+	return value
+}
+
+// records are copied on the way in and out so that callers cannot modify stored data
+func copyMemoryRecord(record *dal.Record) *dal.Record {
+	output := dal.NewRecord(record.ID)
+
+	for key, value := range record.Fields {
+		output.Fields[key] = copyMemoryValue(value)
+	}
+
+	if len(record.Data) > 0 {
+		output.Data = append([]byte{}, record.Data...)
+	}
+
+	return output
+}
+
+func copyMemoryValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		output := make(map[string]interface{}, len(v))
+
+		for key, item := range v {
+			output[key] = copyMemoryValue(item)
+		}
+
+		return output
+	case []interface{}:
+		output := make([]interface{}, len(v))
+
+		for i, item := range v {
+			output[i] = copyMemoryValue(item)
+		}
+
+		return output
+	case []byte:
+		return append([]byte{}, v...)
+	default:
+		return value
+	}
+}
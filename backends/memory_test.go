@@ -0,0 +1,112 @@
+package backends
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sniperkit/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryBackendCopies(t *testing.T) {
+	assert := require.New(t)
+
+	cs, err := dal.ParseConnectionString(`memory://`)
+	assert.NoError(err)
+
+	backend := NewMemoryBackend(cs)
+	assert.NoError(backend.Initialize())
+
+	collection := dal.NewCollection(`TestMemoryBackendCopies`).
+		AddFields(dal.Field{
+			Name: `color`,
+			Type: dal.StringType,
+		})
+
+	assert.NoError(backend.CreateCollection(collection))
+
+	records := dal.NewRecordSet(dal.NewRecord(nil).Set(`color`, `red`))
+	assert.NoError(backend.Insert(collection.Name, records))
+	assert.Equal(int64(1), records.Records[0].ID)
+
+	// stored records can't be changed by modifying the records that were saved or retrieved
+	records.Records[0].Set(`color`, `purple`)
+	record, err := backend.Retrieve(collection.Name, 1)
+	assert.NoError(err)
+	assert.Equal(`red`, record.Get(`color`))
+	record.Set(`color`, `purple`)
+	record, err = backend.Retrieve(collection.Name, `1`)
+	assert.NoError(err)
+	assert.Equal(`red`, record.Get(`color`))
+}
+
+func TestMemoryBackendSnapshot(t *testing.T) {
+	assert := require.New(t)
+
+	root, err := ioutil.TempDir(``, `pivot-backend-memory-`)
+	assert.NoError(err)
+	defer os.RemoveAll(root)
+
+	snapshot := filepath.Join(root, `snapshot.json`)
+	open := func(conn string) *MemoryBackend {
+		cs, err := dal.ParseConnectionString(conn)
+		assert.NoError(err)
+
+		backend := NewMemoryBackend(cs)
+		assert.NoError(backend.Initialize())
+
+		return backend.(*MemoryBackend)
+	}
+
+	backend := open(`memory://` + snapshot)
+
+	collection := dal.NewCollection(`TestMemoryBackendSnapshot`).
+		AddFields(dal.Field{
+			Name: `name`,
+			Type: dal.StringType,
+		}, dal.Field{
+			Name: `count`,
+			Type: dal.IntType,
+		}, dal.Field{
+			Name: `created_at`,
+			Type: dal.TimeType,
+		})
+
+	created := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	assert.NoError(backend.CreateCollection(collection))
+	assert.NoError(backend.Insert(collection.Name, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `first`).Set(`count`, 3).Set(`created_at`, created),
+	)))
+
+	// nothing is written until the backend is flushed
+	_, err = os.Stat(snapshot)
+	assert.True(os.IsNotExist(err))
+	assert.NoError(backend.Flush())
+
+	restored := open(`memory://` + snapshot)
+
+	names, err := restored.ListCollections()
+	assert.NoError(err)
+	assert.Equal([]string{collection.Name}, names)
+
+	record, err := restored.Retrieve(collection.Name, 1)
+	assert.NoError(err)
+	assert.Equal(int64(1), record.ID)
+	assert.Equal(`first`, record.Get(`name`))
+	assert.Equal(int64(3), record.Get(`count`))
+	assert.True(created.Equal(record.Get(`created_at`).(time.Time)))
+
+	// new records continue the restored sequence, and are saved immediately with autosave
+	autosaved := open(`memory://` + snapshot + `?autosave=true`)
+	recordset := dal.NewRecordSet(dal.NewRecord(nil).Set(`name`, `second`))
+
+	assert.NoError(autosaved.Insert(collection.Name, recordset))
+	assert.Equal(int64(2), recordset.Records[0].ID)
+
+	restored = open(`memory://` + snapshot)
+	assert.True(restored.Exists(collection.Name, 2))
+}
@@ -7,3 +7,9 @@ import (
 type Migratable interface {
 	Migrate(diff []dal.SchemaDelta) error
 }
+
+// Implemented by backends that can describe the native statements a migration would execute
+// without executing them.
+type MigrationPlanner interface {
+	MigrationStatements(diff []dal.SchemaDelta) ([]string, error)
+}
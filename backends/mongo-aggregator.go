@@ -4,7 +4,6 @@ package backends
 
 import (
 	"fmt"
-	"strings"
 
 	"github.com/ghetzel/go-stockutil/stringutil"
 	"github.com/sniperkit/pivot/dal"
@@ -74,14 +73,47 @@ func (self *MongoBackend) aggregate(collection *dal.Collection, groupBy []string
 
 	if len(flt) > 0 {
 		f = flt[0]
+	} else {
+		f = filter.All()
 	}
 
 	if query, err := self.filterToNative(collection, f); err == nil {
-		var aggGroups []bson.M
+		var finalQuery []bson.M
 		var firstKey string
+		var groupId interface{}
+
+		if len(query) > 0 {
+			finalQuery = append(finalQuery, bson.M{
+				`$match`: query,
+			})
+		}
+
+		// group by the given fields (or everything, if none were given)
+		if len(groupBy) > 0 {
+			groupFields := bson.M{}
+
+			for _, field := range groupBy {
+				groupFields[field] = fmt.Sprintf("$%s", self.toMongoField(field))
+			}
+
+			groupId = groupFields
+		}
+
+		group := bson.M{
+			`_id`: groupId,
+		}
+
+		project := bson.M{
+			`_id`: 0,
+		}
+
+		for _, field := range groupBy {
+			project[field] = fmt.Sprintf("$_id.%s", field)
+		}
 
 		for _, aggregate := range aggregates {
 			var mongoFn string
+			var mongoValue interface{} = fmt.Sprintf("$%s", self.toMongoField(aggregate.Field))
 
 			switch aggregate.Aggregation {
 			case filter.Sum:
@@ -96,32 +128,78 @@ func (self *MongoBackend) aggregate(collection *dal.Collection, groupBy []string
 				mongoFn = `$max`
 			case filter.Average:
 				mongoFn = `$avg`
+			case filter.Count:
+				mongoFn = `$sum`
+				mongoValue = 1
 			}
 
-			aggGroups = append(aggGroups, bson.M{
-				`$group`: bson.M{
-					`_id`: aggregate.Field,
-					strings.TrimPrefix(mongoFn, `$`): bson.M{
-						mongoFn: fmt.Sprintf("$%s", aggregate.Field),
-					},
-				},
-			})
+			name := filter.AggregateResultName(aggregates, aggregate)
+
+			group[name] = bson.M{
+				mongoFn: mongoValue,
+			}
+
+			project[name] = 1
 
 			if firstKey == `` {
-				firstKey = strings.TrimPrefix(mongoFn, `$`)
+				firstKey = name
 			}
 		}
 
-		var finalQuery []bson.M
+		finalQuery = append(finalQuery, bson.M{
+			`$group`: group,
+		}, bson.M{
+			`$project`: project,
+		})
 
-		if len(query) > 0 {
-			finalQuery = append(finalQuery, query)
+		// filter the grouped results
+		if len(f.Having) > 0 {
+			if having, err := self.filterToNative(collection, f.HavingFilter(aggregates...)); err == nil {
+				finalQuery = append(finalQuery, bson.M{
+					`$match`: having,
+				})
+			} else {
+				return nil, fmt.Errorf("aggregate filter error: %v", err)
+			}
 		}
 
-		finalQuery = append(finalQuery, aggGroups...)
+		// sort the grouped results
+		if sortBy := f.GetSort(); len(sortBy) > 0 {
+			sort := bson.D{}
+
+			for _, s := range sortBy {
+				direction := 1
+
+				if s.Descending {
+					direction = -1
+				}
+
+				sort = append(sort, bson.DocElem{
+					Name:  filter.ResultFieldName(aggregates, s.Field),
+					Value: direction,
+				})
+			}
+
+			finalQuery = append(finalQuery, bson.M{
+				`$sort`: sort,
+			})
+		}
+
+		if f.Offset > 0 {
+			finalQuery = append(finalQuery, bson.M{
+				`$skip`: f.Offset,
+			})
+		}
+
+		if f.Limit > 0 {
+			finalQuery = append(finalQuery, bson.M{
+				`$limit`: f.Limit,
+			})
+		}
 
 		q := self.db.C(collection.Name).Pipe(finalQuery)
 		iter := q.Iter()
+		recordset := dal.NewRecordSet()
 
 		var result map[string]interface{}
 
@@ -129,28 +207,54 @@ func (self *MongoBackend) aggregate(collection *dal.Collection, groupBy []string
 			if err := iter.Err(); err != nil {
 				return nil, err
 			} else if single {
-				_id, _ := result[`_id`]
-
 				if v, ok := result[firstKey]; ok {
 					if vF, err := stringutil.ConvertToFloat(v); err == nil {
 						return vF, nil
 					} else {
-						return 0, fmt.Errorf("'%s' aggregation not supported for field %v", firstKey, _id)
+						return 0, fmt.Errorf("aggregation not supported for field %v", firstKey)
 					}
 				} else {
 					return 0, fmt.Errorf("missing aggregation value '%s'", firstKey)
 				}
 			} else {
-				return nil, fmt.Errorf("Not implemented")
+				record := dal.NewRecord(nil)
+
+				for key, value := range result {
+					if key == collection.IdentityField {
+						record.ID = value
+					} else {
+						record.Set(key, value)
+					}
+				}
+
+				recordset.Push(record)
 			}
+
+			result = nil
+		}
+
+		if err := iter.Close(); err != nil {
+			return nil, err
 		}
 
-		return nil, nil
+		if single {
+			return float64(0), nil
+		} else {
+			return recordset, nil
+		}
 	} else {
 		return nil, fmt.Errorf("filter error: %v", err)
 	}
 }
 
+func (self *MongoBackend) toMongoField(field string) string {
+	if field == `id` {
+		return MongoIdentityField
+	}
+
+	return field
+}
+
 func (self *MongoBackend) AggregatorConnectionString() *dal.ConnectionString {
 	return self.GetConnectionString()
 }
@@ -1,7 +1,9 @@
 package backends
 
 import (
+	"encoding/hex"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -18,6 +20,9 @@ import (
 var DefaultConnectTimeout = 10 * time.Second
 var MongoIdentityField = `_id`
 
+// the BSON binary subtype used for UUIDs
+const mongoUUIDSubtype = 0x04
+
 type MongoBackend struct {
 	Backend
 	Indexer
@@ -158,7 +163,7 @@ func (self *MongoBackend) Insert(name string, records *dal.RecordSet) error {
 		for _, record := range records.Records {
 			if _, err := collection.MakeRecord(record); err == nil {
 				self.normalizeRecordValues(record)
-				data := self.prepareValuesForWrite(record.Fields)
+				data := self.prepareValuesForWrite(collection, record.Fields)
 
 				if record.ID != nil {
 					data[MongoIdentityField] = self.getId(record.ID)
@@ -183,14 +188,24 @@ func (self *MongoBackend) Insert(name string, records *dal.RecordSet) error {
 func (self *MongoBackend) Update(name string, records *dal.RecordSet, target ...string) error {
 	if collection, err := self.GetCollection(name); err == nil {
 		for _, record := range records.Records {
-			if _, err := collection.MakeRecord(record); err == nil {
+			var stored *dal.Record
+
+			if record.ID != nil {
+				stored, _ = self.Retrieve(name, record.ID)
+			}
+
+			if _, err := collection.MakeRecordForUpdate(record, stored); err == nil {
 				self.normalizeRecordValues(record)
-				data := self.prepareValuesForWrite(record.Fields)
+				data := self.prepareValuesForWrite(collection, record.Fields)
+				delete(data, MongoIdentityField)
 
 				if record.ID == nil {
 					return fmt.Errorf("Cannot update record without an ID")
 				} else {
-					if err := self.db.C(collection.Name).UpdateId(self.getId(record.ID), data); err != nil {
+					// only the fields being updated are replaced
+					if err := self.db.C(collection.Name).UpdateId(self.getId(record.ID), bson.M{
+						`$set`: data,
+					}); err != nil {
 						return err
 					}
 				}
@@ -282,15 +297,45 @@ func (self *MongoBackend) normalizeRecordValues(record *dal.Record) {
 		switch value.(type) {
 		case bson.ObjectId:
 			record.Fields[name] = value.(bson.ObjectId).Hex()
+		case bson.Decimal128:
+			record.Fields[name] = value.(bson.Decimal128).String()
+		case bson.Binary:
+			record.Fields[name] = value.(bson.Binary).Data
 		}
 	}
 }
 
-func (self *MongoBackend) prepareValuesForWrite(data map[string]interface{}) map[string]interface{} {
+func (self *MongoBackend) prepareValuesForWrite(collection *dal.Collection, data map[string]interface{}) map[string]interface{} {
 	output := make(map[string]interface{})
 
-	// ObjectId-ify any data that _looks_ like an ObjectId
 	for k, v := range data {
+		// use native BSON types for fields that have them
+		if field, ok := collection.GetField(k); ok && v != nil {
+			switch field.Type {
+			case dal.DecimalType:
+				if d, err := bson.ParseDecimal128(fmt.Sprintf("%v", v)); err == nil {
+					output[k] = d
+					continue
+				}
+			case dal.UUIDType:
+				if id, err := hex.DecodeString(strings.Replace(fmt.Sprintf("%v", v), `-`, ``, -1)); err == nil && len(id) == 16 {
+					output[k] = bson.Binary{
+						Kind: mongoUUIDSubtype,
+						Data: id,
+					}
+					continue
+				}
+			case dal.BytesType:
+				if b, ok := v.([]byte); ok {
+					output[k] = bson.Binary{
+						Data: b,
+					}
+					continue
+				}
+			}
+		}
+
+		// ObjectId-ify any data that _looks_ like an ObjectId
 		vS := fmt.Sprintf("%v", v)
 
 		if bson.IsObjectIdHex(vS) {
@@ -0,0 +1,130 @@
+package backends
+
+// this file satifies the Aggregator interface for ParquetBackend
+
+import (
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter"
+)
+
+func (self *ParquetBackend) Sum(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	return self.aggregateFloat(collection, filter.Sum, field, f)
+}
+
+// Counts the records matching the filter.  Counting every record only needs the file footers.
+func (self *ParquetBackend) Count(collection *dal.Collection, flt ...*filter.Filter) (uint64, error) {
+	f := memoryAggregateFilter(flt)
+
+	if f.IsMatchAll() {
+		if _, err := self.GetCollection(collection.Name); err != nil {
+			return 0, err
+		}
+
+		self.lock.RLock()
+		defer self.lock.RUnlock()
+
+		return uint64(self.rows[collection.Name]), nil
+	}
+
+	if groups, err := self.aggregate(collection, nil, nil, f); err == nil {
+		if len(groups) > 0 {
+			return groups[0].count, nil
+		}
+
+		return 0, nil
+	} else {
+		return 0, err
+	}
+}
+
+func (self *ParquetBackend) Minimum(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	return self.aggregateFloat(collection, filter.Minimum, field, f)
+}
+
+func (self *ParquetBackend) Maximum(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	return self.aggregateFloat(collection, filter.Maximum, field, f)
+}
+
+func (self *ParquetBackend) Average(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	return self.aggregateFloat(collection, filter.Average, field, f)
+}
+
+func (self *ParquetBackend) GroupBy(collection *dal.Collection, groupBy []string, aggregates []filter.Aggregate, flt ...*filter.Filter) (*dal.RecordSet, error) {
+	f := memoryAggregateFilter(flt)
+
+	if groups, err := self.aggregate(collection, groupBy, aggregates, f); err == nil {
+		recordset := groupedRecordSet(collection, groups, aggregates, f)
+
+		sortRecords(recordset, f, aggregates, func(record *dal.Record, field string) interface{} {
+			return memoryRecordValue(collection, record, field)
+		})
+
+		limitRecords(recordset, f)
+
+		return recordset, nil
+	} else {
+		return nil, err
+	}
+}
+
+func (self *ParquetBackend) AggregatorConnectionString() *dal.ConnectionString {
+	return &self.conn
+}
+
+func (self *ParquetBackend) AggregatorInitialize(parent Backend) error {
+	return nil
+}
+
+func (self *ParquetBackend) aggregateFloat(collection *dal.Collection, aggregation filter.Aggregation, field string, flt []*filter.Filter) (float64, error) {
+	if groups, err := self.aggregate(collection, nil, []filter.Aggregate{
+		{
+			Aggregation: aggregation,
+			Field:       field,
+		},
+	}, memoryAggregateFilter(flt)); err == nil {
+		if len(groups) > 0 {
+			if v, ok := groups[0].values[field]; ok {
+				return stringutil.ConvertToFloat(v.Value(aggregation))
+			}
+		}
+
+		return 0, nil
+	} else {
+		return 0, err
+	}
+}
+
+// Streams every record matching the given filter through the aggregated groups, one batch of rows at
+// a time, reading only the columns being filtered, grouped, and aggregated.
+func (self *ParquetBackend) aggregate(collection *dal.Collection, groupBy []string, aggregates []filter.Aggregate, f *filter.Filter) ([]*aggregateGroup, error) {
+	definition, err := self.GetCollection(collection.Name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// grouping considers every matching record, regardless of sorting and pagination
+	query := filter.Copy(f)
+	query.Sort = nil
+	query.Fields = append([]string{}, groupBy...)
+
+	for _, aggregate := range aggregates {
+		query.Fields = append(query.Fields, aggregate.Field)
+	}
+
+	// at least one column must be named for only the needed columns to be read
+	if len(query.Fields) == 0 {
+		query.Fields = []string{definition.IdentityField}
+	}
+
+	accumulator := newGroupAccumulator(definition, groupBy, aggregates, memoryRecordValue)
+
+	if err := self.scan(definition, &query, func(record *dal.Record) error {
+		return accumulator.Add(record)
+	}); err != nil {
+		return nil, err
+	}
+
+	return accumulator.groups, nil
+}
@@ -0,0 +1,170 @@
+package backends
+
+// this file satifies the Indexer interface for ParquetBackend
+
+import (
+	"fmt"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter"
+)
+
+func (self *ParquetBackend) IndexConnectionString() *dal.ConnectionString {
+	return &self.conn
+}
+
+func (self *ParquetBackend) IndexInitialize(_ Backend) error {
+	return nil
+}
+
+func (self *ParquetBackend) GetBackend() Backend {
+	return self
+}
+
+func (self *ParquetBackend) IndexExists(collection *dal.Collection, id interface{}) bool {
+	return self.Exists(collection.GetIndexName(), id)
+}
+
+func (self *ParquetBackend) IndexRetrieve(collection *dal.Collection, id interface{}) (*dal.Record, error) {
+	return self.Retrieve(collection.GetIndexName(), id)
+}
+
+func (self *ParquetBackend) IndexRemove(collection *dal.Collection, ids []interface{}) error {
+	return ReadOnlyError
+}
+
+func (self *ParquetBackend) Index(collection *dal.Collection, records *dal.RecordSet) error {
+	return ReadOnlyError
+}
+
+// Calls resultFn for each matching record.  Unsorted queries are streamed, stopping as soon as the
+// filter's limit is reached; sorted queries need to read every matching record before returning any
+// of them.
+func (self *ParquetBackend) QueryFunc(collection *dal.Collection, f *filter.Filter, resultFn IndexResultFunc) error {
+	if f == nil {
+		f = filter.All()
+	}
+
+	querylog.Debugf("[%T] Query using filter %q", self, f.String())
+
+	for _, criterion := range f.Criteria {
+		if !isMemoryOperator(criterion.Operator) {
+			return fmt.Errorf("Unimplemented operator '%s'", criterion.Operator)
+		}
+	}
+
+	definition, err := self.GetCollection(collection.Name)
+
+	if err != nil {
+		return err
+	}
+
+	if len(f.GetSort()) > 0 {
+		matches := make([]*dal.Record, 0)
+
+		if err := self.scan(definition, f, func(record *dal.Record) error {
+			matches = append(matches, record)
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		memorySortRecords(definition, matches, f)
+
+		return emitRecordPage(matches, f, resultFn)
+	}
+
+	page := IndexPage{
+		Page:         1,
+		TotalPages:   1,
+		Limit:        f.Limit,
+		Offset:       f.Offset,
+		TotalResults: -1,
+	}
+
+	// the total number of rows is known from the file footers when every row matches
+	if f.IsMatchAll() {
+		self.lock.RLock()
+		page.TotalResults = self.rows[definition.Name]
+		self.lock.RUnlock()
+	}
+
+	if f.Limit > 0 {
+		page.Page = (f.Offset / f.Limit) + 1
+	}
+
+	processed := 0
+
+	return self.scan(definition, f, func(record *dal.Record) error {
+		processed += 1
+
+		if processed <= f.Offset {
+			return nil
+		}
+
+		if err := resultFn(record, nil, page); err != nil {
+			return err
+		}
+
+		if f.Limit > 0 && processed >= (f.Offset+f.Limit) {
+			return IndexerResultsStop
+		}
+
+		return nil
+	})
+}
+
+func (self *ParquetBackend) Query(collection *dal.Collection, f *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error) {
+	if f == nil {
+		f = filter.All()
+	}
+
+	// use the record that comes back from the QueryFunc as-is instead of reading the file again
+	query := filter.Copy(f)
+	query.Options = make(map[string]interface{})
+
+	for k, v := range f.Options {
+		query.Options[k] = v
+	}
+
+	query.Options[`ForceIndexRecord`] = true
+
+	return DefaultQueryImplementation(self, collection, &query, resultFns...)
+}
+
+func (self *ParquetBackend) ListValues(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]interface{}, error) {
+	values := make(map[string][]interface{})
+
+	if f == nil {
+		f = filter.All()
+	}
+
+	// only read the columns whose values are being listed
+	query := filter.Copy(f)
+	query.Fields = fields
+
+	if err := self.QueryFunc(collection, &query, func(record *dal.Record, err error, page IndexPage) error {
+		if err != nil {
+			return err
+		}
+
+		for _, field := range fields {
+			values[field] = sliceutil.Unique(append(values[field], memoryRecordValue(collection, record, field)))
+		}
+
+		return nil
+	}); err == nil {
+		return values, nil
+	} else {
+		return values, err
+	}
+}
+
+func (self *ParquetBackend) DeleteQuery(collection *dal.Collection, f *filter.Filter) error {
+	return ReadOnlyError
+}
+
+func (self *ParquetBackend) FlushIndex() error {
+	return nil
+}
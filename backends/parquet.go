@@ -0,0 +1,884 @@
+package backends
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter"
+	"github.com/xitongsys/parquet-go/common"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/source"
+	"github.com/xitongsys/parquet-go/types"
+)
+
+var ParquetFileExtension = `.parquet`
+var ParquetReadBatchSize = 1024
+var ParquetReaderParallelism int64 = 4
+
+// A read-only backend for querying Parquet files (e.g.: parquet:///path/to/exports).  Each file in
+// the directory is a collection named after the file (exports/events.parquet is the "events"
+// collection), and each subdirectory containing Parquet files is a collection made up of all of the
+// part files within it, read in lexical order.
+//
+// Collection schemas are derived from the schema of the first file in each collection.  Top-level
+// primitive columns become fields; nested and repeated columns are not supported and are ignored.
+// If a collection has a column named for the identity field, its values are the record IDs;
+// otherwise each record is identified by its (1-based) row number across all of the collection's
+// files.
+//
+// Queries only read the columns they need, and skip any row groups whose column statistics show
+// that none of their rows can match the filter.
+type ParquetBackend struct {
+	conn        dal.ConnectionString
+	root        string
+	indexer     Indexer
+	collections map[string]*dal.Collection
+	files       map[string][]string
+	rows        map[string]int64
+	lock        sync.RWMutex
+}
+
+func NewParquetBackend(connection dal.ConnectionString) Backend {
+	return &ParquetBackend{
+		conn:        connection,
+		collections: make(map[string]*dal.Collection),
+		files:       make(map[string][]string),
+		rows:        make(map[string]int64),
+	}
+}
+
+func (self *ParquetBackend) GetConnectionString() *dal.ConnectionString {
+	return &self.conn
+}
+
+func (self *ParquetBackend) Ping(timeout time.Duration) error {
+	if self.root == `` {
+		return fmt.Errorf("Backend not initialized")
+	} else if _, err := os.Stat(self.root); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Registers a collection definition to use in place of the one derived from the Parquet schema
+// (e.g.: to give a field a more specific type).  The collection's files must already exist.
+func (self *ParquetBackend) RegisterCollection(definition *dal.Collection) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.collections[definition.Name] = definition
+}
+
+func (self *ParquetBackend) SetIndexer(indexConnString dal.ConnectionString) error {
+	if indexer, err := MakeIndexer(indexConnString); err == nil {
+		self.indexer = indexer
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *ParquetBackend) Initialize() error {
+	if root, err := boltPath(&self.conn); err == nil {
+		self.root = root
+	} else {
+		return err
+	}
+
+	entries, err := ioutil.ReadDir(self.root)
+
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		var name string
+		var files []string
+
+		if strings.HasPrefix(entry.Name(), `.`) || strings.HasPrefix(entry.Name(), `_`) {
+			continue
+		} else if entry.IsDir() {
+			name = entry.Name()
+
+			if f, err := parquetPartFiles(filepath.Join(self.root, name)); err == nil {
+				files = f
+			} else {
+				return err
+			}
+		} else if filepath.Ext(entry.Name()) == ParquetFileExtension {
+			name = strings.TrimSuffix(entry.Name(), ParquetFileExtension)
+			files = []string{filepath.Join(self.root, entry.Name())}
+		}
+
+		if len(files) == 0 {
+			continue
+		}
+
+		if err := self.loadCollection(name, files); err != nil {
+			return fmt.Errorf("Cannot load collection %q: %v", name, err)
+		}
+	}
+
+	if self.indexer == nil {
+		self.indexer = self
+	}
+
+	if err := self.indexer.IndexInitialize(self); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (self *ParquetBackend) Insert(collection string, records *dal.RecordSet) error {
+	return ReadOnlyError
+}
+
+func (self *ParquetBackend) Exists(name string, id interface{}) bool {
+	_, err := self.Retrieve(name, id)
+	return (err == nil)
+}
+
+func (self *ParquetBackend) Retrieve(name string, id interface{}, fields ...string) (*dal.Record, error) {
+	if collection, err := self.GetCollection(name); err == nil {
+		var found *dal.Record
+
+		f := filter.New()
+		f.Fields = fields
+		f.AddCriteria(filter.Criterion{
+			Field:  collection.IdentityField,
+			Values: []interface{}{id},
+		})
+
+		if err := self.scan(collection, f, func(record *dal.Record) error {
+			found = record
+			return IndexerResultsStop
+		}); err != nil {
+			return nil, err
+		}
+
+		if found == nil {
+			return nil, fmt.Errorf("Record %q does not exist", id)
+		}
+
+		return found, nil
+	} else {
+		return nil, err
+	}
+}
+
+func (self *ParquetBackend) Update(collection string, records *dal.RecordSet, target ...string) error {
+	return ReadOnlyError
+}
+
+func (self *ParquetBackend) Delete(collection string, ids ...interface{}) error {
+	return ReadOnlyError
+}
+
+func (self *ParquetBackend) WithSearch(collection *dal.Collection, filters ...*filter.Filter) Indexer {
+	return self.indexer
+}
+
+func (self *ParquetBackend) WithAggregator(collection *dal.Collection) Aggregator {
+	return self
+}
+
+func (self *ParquetBackend) ListCollections() ([]string, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	names := make([]string, 0, len(self.collections))
+
+	for name := range self.collections {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+func (self *ParquetBackend) CreateCollection(definition *dal.Collection) error {
+	return ReadOnlyError
+}
+
+func (self *ParquetBackend) DeleteCollection(collection string) error {
+	return ReadOnlyError
+}
+
+func (self *ParquetBackend) GetCollection(name string) (*dal.Collection, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	if definition, ok := self.collections[name]; ok {
+		return definition, nil
+	} else {
+		return nil, dal.CollectionNotFound
+	}
+}
+
+func (self *ParquetBackend) Flush() error {
+	return nil
+}
+
+// reads the footers of all of a collection's files to count its rows and derive its schema
+func (self *ParquetBackend) loadCollection(name string, files []string) error {
+	var rows int64
+	definition := dal.NewCollection(name)
+
+	for i, filename := range files {
+		if err := withParquetReader(filename, func(pr *reader.ParquetReader) error {
+			rows += pr.GetNumRows()
+
+			if i > 0 {
+				return nil
+			}
+
+			definition.IdentityFieldType = dal.IntType
+
+			for _, column := range parquetColumns(pr) {
+				if fieldType, ok := parquetFieldType(column.element); ok {
+					if column.name == definition.IdentityField {
+						definition.IdentityFieldType = fieldType
+						continue
+					}
+
+					field := dal.Field{
+						Name: column.name,
+						Type: fieldType,
+					}
+
+					if fieldType == dal.DecimalType {
+						field.Precision = int(parquetDecimalScale(column.element))
+					}
+
+					definition.AddFields(field)
+				} else {
+					querylog.Debugf("[%T] %v: skipping unsupported column %q", self, filename, column.name)
+				}
+			}
+
+			return nil
+		}); err != nil {
+			return fmt.Errorf("%v: %v", filename, err)
+		}
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if _, ok := self.collections[name]; !ok {
+		self.collections[name] = definition
+	}
+
+	self.files[name] = files
+	self.rows[name] = rows
+
+	return nil
+}
+
+// Calls fn with each record in the collection that matches the filter, in file order, until fn
+// returns an error (returning IndexerResultsStop stops reading without returning an error).  Only the
+// columns needed to check the filter and populate its fields are read, and row groups that cannot
+// contain any matching records are skipped.
+func (self *ParquetBackend) scan(collection *dal.Collection, f *filter.Filter, fn func(record *dal.Record) error) error {
+	self.lock.RLock()
+	files, ok := self.files[collection.Name]
+	self.lock.RUnlock()
+
+	if !ok {
+		return dal.CollectionNotFound
+	}
+
+	wanted := parquetWantedColumns(collection, f)
+	var offset int64
+
+	for _, filename := range files {
+		err := withParquetReader(filename, func(pr *reader.ParquetReader) error {
+			columns := parquetColumns(pr)
+			selected := make([]*parquetColumn, 0)
+			hasIdentity := false
+
+			for _, column := range columns {
+				if _, ok := parquetFieldType(column.element); !ok {
+					continue
+				} else if column.name == collection.IdentityField {
+					hasIdentity = true
+				} else if wanted != nil && !sliceutil.ContainsString(wanted, column.name) {
+					continue
+				}
+
+				selected = append(selected, column)
+			}
+
+			for _, rowGroup := range pr.Footer.RowGroups {
+				if !parquetRowGroupMatches(collection, f, columns, rowGroup, hasIdentity, offset) {
+					for _, column := range selected {
+						if err := pr.SkipRowsByPath(column.path, rowGroup.NumRows); err != nil {
+							return err
+						}
+					}
+
+					offset += rowGroup.NumRows
+					continue
+				}
+
+				for read := int64(0); read < rowGroup.NumRows; {
+					count := rowGroup.NumRows - read
+
+					if count > int64(ParquetReadBatchSize) {
+						count = int64(ParquetReadBatchSize)
+					}
+
+					records := make([]*dal.Record, count)
+
+					for i := range records {
+						records[i] = dal.NewRecord(offset + read + int64(i) + 1)
+					}
+
+					for _, column := range selected {
+						values, _, _, err := pr.ReadColumnByPath(column.path, count)
+
+						if err != nil {
+							return err
+						}
+
+						for i, value := range values {
+							if i >= len(records) {
+								break
+							} else if value = parquetValue(column.element, value); value == nil {
+								continue
+							}
+
+							if column.name == collection.IdentityField {
+								records[i].ID = memoryIdentity(collection, value)
+							} else {
+								records[i].Fields[column.name] = memoryFieldValue(collection, column.name, value)
+							}
+						}
+					}
+
+					for _, record := range records {
+						if memoryMatchesFilter(collection, f, record) {
+							if len(f.Fields) > 0 {
+								projectTabularRecord(record, f.Fields)
+							}
+
+							if err := fn(record); err != nil {
+								return err
+							}
+						}
+					}
+
+					read += count
+				}
+
+				offset += rowGroup.NumRows
+			}
+
+			return nil
+		})
+
+		if err == IndexerResultsStop {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%v: %v", filename, err)
+		}
+	}
+
+	return nil
+}
+
+// implements source.ParquetFile for local files
+type parquetFile struct {
+	*os.File
+}
+
+func (self *parquetFile) Open(name string) (source.ParquetFile, error) {
+	if name == `` {
+		name = self.Name()
+	}
+
+	if file, err := os.Open(name); err == nil {
+		return &parquetFile{file}, nil
+	} else {
+		return nil, err
+	}
+}
+
+func (self *parquetFile) Create(name string) (source.ParquetFile, error) {
+	if file, err := os.Create(name); err == nil {
+		return &parquetFile{file}, nil
+	} else {
+		return nil, err
+	}
+}
+
+// opens a column reader for the given file, which is closed once fn returns
+func withParquetReader(filename string, fn func(pr *reader.ParquetReader) error) error {
+	file, err := os.Open(filename)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	if pr, err := reader.NewParquetColumnReader(&parquetFile{file}, ParquetReaderParallelism); err == nil {
+		defer pr.ReadStop()
+		return fn(pr)
+	} else {
+		return err
+	}
+}
+
+// returns all Parquet files in or below the given directory, in lexical order
+func parquetPartFiles(dir string) ([]string, error) {
+	files := make([]string, 0)
+
+	if err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if name := info.Name(); path != dir && (strings.HasPrefix(name, `.`) || strings.HasPrefix(name, `_`)) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !info.IsDir() && filepath.Ext(path) == ParquetFileExtension {
+			files = append(files, path)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// Returns the names of the columns that need to be read to check the filter and populate the fields
+// it asks for, or nil if all columns should be read.
+func parquetWantedColumns(collection *dal.Collection, f *filter.Filter) []string {
+	if len(f.Fields) == 0 {
+		return nil
+	}
+
+	wanted := append([]string{collection.IdentityField}, f.Fields...)
+
+	for _, criterion := range f.Criteria {
+		wanted = append(wanted, criterion.Field)
+	}
+
+	for _, s := range f.GetSort() {
+		wanted = append(wanted, s.Field)
+	}
+
+	return sliceutil.Stringify(sliceutil.Unique(wanted))
+}
+
+// a top-level column in a Parquet file
+type parquetColumn struct {
+	name    string
+	path    string
+	inName  string
+	element *parquet.SchemaElement
+}
+
+// Returns the top-level columns of the file being read.  The reader renames the schema elements to
+// its own internal names, so the names the columns were written with are taken from its schema
+// handler.
+func parquetColumns(pr *reader.ParquetReader) []*parquetColumn {
+	columns := make([]*parquetColumn, 0)
+	schema := pr.Footer.Schema
+	infos := pr.SchemaHandler.Infos
+
+	if len(schema) == 0 || len(infos) != len(schema) {
+		return columns
+	}
+
+	// the schema is a depth-first list of elements, starting with the root
+	for i := 1; i < len(schema); {
+		columns = append(columns, &parquetColumn{
+			name:    infos[i].ExName,
+			path:    common.PathToStr([]string{infos[0].InName, infos[i].InName}),
+			inName:  infos[i].InName,
+			element: schema[i],
+		})
+
+		// skip over all of the descendants of group elements
+		remaining := int(schema[i].GetNumChildren())
+		i += 1
+
+		for remaining > 0 && i < len(schema) {
+			remaining += int(schema[i].GetNumChildren()) - 1
+			i += 1
+		}
+	}
+
+	return columns
+}
+
+func findParquetColumn(columns []*parquetColumn, name string) *parquetColumn {
+	for _, column := range columns {
+		if column.name == name {
+			return column
+		}
+	}
+
+	return nil
+}
+
+// Returns the field type that values of the given column are converted to, and whether the column
+// is supported at all.
+func parquetFieldType(element *parquet.SchemaElement) (dal.Type, bool) {
+	if element.GetNumChildren() > 0 || element.GetRepetitionType() == parquet.FieldRepetitionType_REPEATED || !element.IsSetType() {
+		return ``, false
+	}
+
+	logical := element.GetLogicalType()
+
+	if element.IsSetConvertedType() || logical != nil {
+		switch {
+		case element.GetConvertedType() == parquet.ConvertedType_DECIMAL || (logical != nil && logical.IsSetDECIMAL()):
+			return dal.DecimalType, true
+		case isParquetTime(element):
+			return dal.TimeType, true
+		case element.GetConvertedType() == parquet.ConvertedType_JSON || (logical != nil && logical.IsSetJSON()):
+			return dal.ObjectType, true
+		case logical != nil && logical.IsSetUUID():
+			return dal.UUIDType, true
+		case isParquetString(element):
+			return dal.StringType, true
+		}
+	}
+
+	switch element.GetType() {
+	case parquet.Type_BOOLEAN:
+		return dal.BooleanType, true
+	case parquet.Type_INT32, parquet.Type_INT64:
+		return dal.IntType, true
+	case parquet.Type_INT96:
+		return dal.TimeType, true
+	case parquet.Type_FLOAT, parquet.Type_DOUBLE:
+		return dal.FloatType, true
+	default:
+		return dal.BytesType, true
+	}
+}
+
+// Converts a value read from the given column to the type returned by parquetFieldType.
+func parquetValue(element *parquet.SchemaElement, value interface{}) interface{} {
+	if value == nil || element == nil {
+		return value
+	}
+
+	if fieldType, _ := parquetFieldType(element); fieldType == dal.DecimalType {
+		scale := parquetDecimalScale(element)
+		unscaled := new(big.Int)
+
+		switch v := value.(type) {
+		case int32:
+			unscaled.SetInt64(int64(v))
+		case int64:
+			unscaled.SetInt64(v)
+		case string:
+			// byte array decimals are big-endian two's complement integers
+			unscaled.SetBytes([]byte(v))
+
+			if len(v) > 0 && v[0]&0x80 != 0 {
+				unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(len(v)*8)))
+			}
+		}
+
+		if decimal, err := dal.ConvertToDecimal(
+			new(big.Rat).SetFrac(unscaled, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)),
+			int(scale),
+		); err == nil {
+			return decimal
+		}
+
+		return nil
+	}
+
+	switch v := value.(type) {
+	case int32:
+		if isParquetTime(element) {
+			// dates are stored as the number of days since the epoch
+			return time.Unix(int64(v)*86400, 0).UTC()
+		} else if element.GetConvertedType() == parquet.ConvertedType_UINT_32 {
+			return int64(uint32(v))
+		}
+
+		return int64(v)
+
+	case int64:
+		if isParquetTime(element) {
+			return parquetTimestamp(element, v)
+		} else if element.GetConvertedType() == parquet.ConvertedType_UINT_64 {
+			return uint64(v)
+		}
+
+		return v
+
+	case float32:
+		return float64(v)
+
+	case string:
+		if element.GetType() == parquet.Type_INT96 {
+			return types.INT96ToTime(v).UTC()
+		}
+
+		switch fieldType, _ := parquetFieldType(element); fieldType {
+		case dal.StringType:
+			return v
+		case dal.ObjectType:
+			var decoded interface{}
+
+			if err := json.Unmarshal([]byte(v), &decoded); err == nil {
+				return decoded
+			}
+
+			return v
+		case dal.UUIDType:
+			if id, err := dal.ConvertToUUID([]byte(v)); err == nil {
+				return id
+			}
+		}
+
+		return []byte(v)
+	}
+
+	return value
+}
+
+func parquetTimestamp(element *parquet.SchemaElement, value int64) time.Time {
+	if logical := element.GetLogicalType(); logical != nil && logical.IsSetTIMESTAMP() {
+		unit := logical.GetTIMESTAMP().GetUnit()
+
+		switch {
+		case unit.IsSetNANOS():
+			return time.Unix(0, value).UTC()
+		case unit.IsSetMICROS():
+			return time.Unix(0, value*int64(time.Microsecond)).UTC()
+		}
+	} else if element.GetConvertedType() == parquet.ConvertedType_TIMESTAMP_MICROS {
+		return time.Unix(0, value*int64(time.Microsecond)).UTC()
+	}
+
+	return time.Unix(0, value*int64(time.Millisecond)).UTC()
+}
+
+func parquetDecimalScale(element *parquet.SchemaElement) int32 {
+	if logical := element.GetLogicalType(); logical != nil && logical.IsSetDECIMAL() {
+		return logical.GetDECIMAL().GetScale()
+	}
+
+	return element.GetScale()
+}
+
+// dates and timestamps; times of day are left as integers
+func isParquetTime(element *parquet.SchemaElement) bool {
+	if logical := element.GetLogicalType(); logical != nil && (logical.IsSetDATE() || logical.IsSetTIMESTAMP()) {
+		return true
+	}
+
+	switch element.GetConvertedType() {
+	case parquet.ConvertedType_DATE, parquet.ConvertedType_TIMESTAMP_MILLIS, parquet.ConvertedType_TIMESTAMP_MICROS:
+		return element.IsSetConvertedType()
+	}
+
+	return false
+}
+
+func isParquetString(element *parquet.SchemaElement) bool {
+	if logical := element.GetLogicalType(); logical != nil && (logical.IsSetSTRING() || logical.IsSetENUM()) {
+		return true
+	}
+
+	switch element.GetConvertedType() {
+	case parquet.ConvertedType_UTF8, parquet.ConvertedType_ENUM:
+		return element.IsSetConvertedType()
+	}
+
+	return false
+}
+
+// Returns whether any of the rows in the given row group could match the filter, according to the
+// row numbers it contains and the minimum and maximum values of its columns.  Only integer, float,
+// and string columns with statistics are considered; criteria on all other columns could always match.
+func parquetRowGroupMatches(collection *dal.Collection, f *filter.Filter, columns []*parquetColumn, rowGroup *parquet.RowGroup, hasIdentity bool, offset int64) bool {
+	if f.IsMatchAll() {
+		return true
+	}
+
+	for _, criterion := range f.Criteria {
+		var min, max interface{}
+
+		if criterion.Field == collection.IdentityField && !hasIdentity {
+			min = offset + 1
+			max = offset + rowGroup.NumRows
+		} else if column := findParquetColumn(columns, criterion.Field); column != nil {
+			for _, chunk := range rowGroup.Columns {
+				if meta := chunk.GetMetaData(); meta != nil && len(meta.PathInSchema) == 1 && meta.PathInSchema[0] == column.inName {
+					min, max = parquetStatistics(column.element, meta.GetStatistics())
+					break
+				}
+			}
+		}
+
+		if min == nil || max == nil {
+			continue
+		}
+
+		values := make([]interface{}, len(criterion.Values))
+
+		for i, v := range criterion.Values {
+			values[i] = memoryCriterionValue(collection, criterion, v)
+		}
+
+		// numeric statistics can't rule anything out for values that aren't numbers
+		numeric := isMemoryNumber(min)
+		possible := false
+
+		switch criterion.Operator {
+		case ``, `is`, `gt`, `gte`, `lt`, `lte`:
+			for _, v := range values {
+				if v == nil || (numeric && !isMemoryNumber(v)) {
+					possible = true
+					break
+				}
+
+				lower := memoryCompareForSort(v, min)
+				upper := memoryCompareForSort(v, max)
+
+				switch criterion.Operator {
+				case `gt`:
+					possible = (upper < 0)
+				case `gte`:
+					possible = (upper <= 0)
+				case `lt`:
+					possible = (lower > 0)
+				case `lte`:
+					possible = (lower >= 0)
+				default:
+					possible = (lower >= 0 && upper <= 0)
+				}
+
+				if possible {
+					break
+				}
+			}
+
+		case `range`:
+			if len(values)%2 != 0 {
+				possible = true
+			}
+
+			for i := 0; i+1 < len(values); i += 2 {
+				if numeric && (!isMemoryNumber(values[i]) || !isMemoryNumber(values[i+1])) {
+					possible = true
+					break
+				} else if memoryCompareForSort(values[i], max) <= 0 && memoryCompareForSort(values[i+1], min) >= 0 {
+					possible = true
+					break
+				}
+			}
+
+		default:
+			possible = true
+		}
+
+		if !possible {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Decodes the minimum and maximum values from a column chunk's statistics, returning nils if they
+// aren't present or the column's values aren't compared in a way that is safe to use.
+func parquetStatistics(element *parquet.SchemaElement, stats *parquet.Statistics) (interface{}, interface{}) {
+	if stats == nil {
+		return nil, nil
+	}
+
+	min, max := stats.MinValue, stats.MaxValue
+	fieldType, _ := parquetFieldType(element)
+
+	switch fieldType {
+	case dal.IntType, dal.FloatType:
+		// the deprecated min and max are sorted as signed values, which is correct for numbers
+		if min == nil || max == nil {
+			min, max = stats.Min, stats.Max
+		}
+
+		switch element.GetConvertedType() {
+		case parquet.ConvertedType_UINT_8, parquet.ConvertedType_UINT_16, parquet.ConvertedType_UINT_32, parquet.ConvertedType_UINT_64:
+			if element.IsSetConvertedType() {
+				return nil, nil
+			}
+		}
+
+	case dal.StringType:
+		if min == nil || max == nil {
+			return nil, nil
+		}
+
+		return string(min), string(max)
+
+	default:
+		return nil, nil
+	}
+
+	if minValue, err := parquetPlainValue(element.GetType(), min); err == nil {
+		if maxValue, err := parquetPlainValue(element.GetType(), max); err == nil {
+			return minValue, maxValue
+		}
+	}
+
+	return nil, nil
+}
+
+// decodes a single plain-encoded numeric value
+func parquetPlainValue(physical parquet.Type, data []byte) (interface{}, error) {
+	switch physical {
+	case parquet.Type_INT32:
+		if len(data) == 4 {
+			return int64(int32(binary.LittleEndian.Uint32(data))), nil
+		}
+	case parquet.Type_INT64:
+		if len(data) == 8 {
+			return int64(binary.LittleEndian.Uint64(data)), nil
+		}
+	case parquet.Type_FLOAT:
+		if len(data) == 4 {
+			return float64(math.Float32frombits(binary.LittleEndian.Uint32(data))), nil
+		}
+	case parquet.Type_DOUBLE:
+		if len(data) == 8 {
+			return math.Float64frombits(binary.LittleEndian.Uint64(data)), nil
+		}
+	}
+
+	return nil, fmt.Errorf("cannot decode %d-byte %v value", len(data), physical)
+}
@@ -0,0 +1,352 @@
+package backends
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter"
+	"github.com/stretchr/testify/require"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+type parquetTestProduct struct {
+	Name      string   `parquet:"name=name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Color     *string  `parquet:"name=color, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	Inventory int32    `parquet:"name=inventory, type=INT32"`
+	Price     int64    `parquet:"name=price, type=INT64, convertedtype=DECIMAL, scale=2, precision=10"`
+	CreatedAt int64    `parquet:"name=created_at, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	Active    bool     `parquet:"name=active, type=BOOLEAN"`
+	Tags      []string `parquet:"name=tags, type=LIST, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8"`
+}
+
+type parquetTestEvent struct {
+	ID   int64  `parquet:"name=id, type=INT64"`
+	Kind string `parquet:"name=kind, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// writes the given rows to a Parquet file, starting a new row group every perGroup rows
+func writeParquetFixture(t *testing.T, filename string, obj interface{}, rows []interface{}, perGroup int) {
+	assert := require.New(t)
+
+	assert.NoError(os.MkdirAll(filepath.Dir(filename), 0700))
+
+	file, err := os.Create(filename)
+	assert.NoError(err)
+
+	pw, err := writer.NewParquetWriter(&parquetFile{file}, obj, 1)
+	assert.NoError(err)
+
+	for i, row := range rows {
+		assert.NoError(pw.Write(row))
+
+		if (i+1)%perGroup == 0 {
+			assert.NoError(pw.Flush(true))
+		}
+	}
+
+	assert.NoError(pw.WriteStop())
+	assert.NoError(file.Close())
+}
+
+func makeParquetBackend(t *testing.T) (*ParquetBackend, string) {
+	assert := require.New(t)
+
+	root, err := ioutil.TempDir(``, `pivot-backend-parquet-`)
+	assert.NoError(err)
+
+	base := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	products := make([]interface{}, 30)
+
+	for i := range products {
+		product := parquetTestProduct{
+			Name:      fmt.Sprintf("item-%02d", i+1),
+			Inventory: int32(i + 1),
+			Price:     int64(1000 + i),
+			CreatedAt: base.AddDate(0, 0, i).UnixNano() / int64(time.Millisecond),
+			Active:    (i < 10),
+			Tags:      []string{`a`},
+		}
+
+		if i%2 == 1 {
+			color := `red`
+			product.Color = &color
+		}
+
+		products[i] = &product
+	}
+
+	writeParquetFixture(t, filepath.Join(root, `products.parquet`), new(parquetTestProduct), products, 10)
+
+	for p, start := range []int64{100, 200} {
+		events := make([]interface{}, 5)
+
+		for i := range events {
+			events[i] = &parquetTestEvent{
+				ID:   start + int64(i),
+				Kind: fmt.Sprintf("kind-%d", i%2),
+			}
+		}
+
+		writeParquetFixture(t, filepath.Join(root, `events`, fmt.Sprintf("part-%04d.parquet", p)), new(parquetTestEvent), events, 5)
+	}
+
+	assert.NoError(ioutil.WriteFile(filepath.Join(root, `events`, `_SUCCESS`), nil, 0600))
+	assert.NoError(ioutil.WriteFile(filepath.Join(root, `notes.txt`), []byte(`ignored`), 0600))
+
+	cs, err := dal.ParseConnectionString(`parquet://` + root)
+	assert.NoError(err)
+
+	backend := NewParquetBackend(cs)
+	assert.NoError(backend.Initialize())
+
+	return backend.(*ParquetBackend), root
+}
+
+func TestParquetBackendSchema(t *testing.T) {
+	assert := require.New(t)
+
+	backend, root := makeParquetBackend(t)
+	defer os.RemoveAll(root)
+
+	names, err := backend.ListCollections()
+	assert.NoError(err)
+	assert.Equal([]string{`events`, `products`}, names)
+
+	products, err := backend.GetCollection(`products`)
+	assert.NoError(err)
+	assert.EqualValues(dal.IntType, products.IdentityFieldType)
+
+	// nested and repeated columns are skipped
+	names = make([]string, 0)
+
+	for _, field := range products.Fields {
+		names = append(names, field.Name)
+	}
+
+	assert.Equal([]string{`name`, `color`, `inventory`, `price`, `created_at`, `active`}, names)
+
+	for name, fieldType := range map[string]dal.Type{
+		`name`:       dal.StringType,
+		`color`:      dal.StringType,
+		`inventory`:  dal.IntType,
+		`price`:      dal.DecimalType,
+		`created_at`: dal.TimeType,
+		`active`:     dal.BooleanType,
+	} {
+		field, ok := products.GetField(name)
+		assert.True(ok, name)
+		assert.Equal(fieldType, field.Type, name)
+	}
+
+	events, err := backend.GetCollection(`events`)
+	assert.NoError(err)
+	assert.EqualValues(dal.IntType, events.IdentityFieldType)
+	assert.Len(events.Fields, 1)
+	assert.Equal(`kind`, events.Fields[0].Name)
+
+	// the backend is read-only
+	assert.Equal(ReadOnlyError, backend.Insert(`products`, dal.NewRecordSet(dal.NewRecord(nil).Set(`name`, `new`))))
+	assert.Equal(ReadOnlyError, backend.Update(`products`, dal.NewRecordSet(dal.NewRecord(1).Set(`name`, `new`))))
+	assert.Equal(ReadOnlyError, backend.Delete(`products`, 1))
+	assert.Equal(ReadOnlyError, backend.CreateCollection(dal.NewCollection(`other`)))
+	assert.Equal(ReadOnlyError, backend.DeleteCollection(`products`))
+	assert.Equal(ReadOnlyError, backend.DeleteQuery(products, filter.All()))
+}
+
+func TestParquetBackendQuery(t *testing.T) {
+	assert := require.New(t)
+
+	backend, root := makeParquetBackend(t)
+	defer os.RemoveAll(root)
+
+	products, err := backend.GetCollection(`products`)
+	assert.NoError(err)
+
+	// records without an ID column are identified by row number
+	record, err := backend.Retrieve(`products`, 4)
+	assert.NoError(err)
+	assert.Equal(int64(4), record.ID)
+	assert.Equal(`item-04`, record.Get(`name`))
+	assert.Equal(`red`, record.Get(`color`))
+	assert.Equal(int64(4), record.Get(`inventory`))
+	assert.Equal(`10.03`, record.Get(`price`))
+	assert.Equal(true, record.Get(`active`))
+	assert.True(time.Date(2018, 1, 4, 0, 0, 0, 0, time.UTC).Equal(record.Get(`created_at`).(time.Time)))
+
+	record, err = backend.Retrieve(`products`, 3)
+	assert.NoError(err)
+	assert.Nil(record.Get(`color`))
+
+	assert.False(backend.Exists(`products`, 31))
+
+	record, err = backend.Retrieve(`events`, `202`)
+	assert.NoError(err)
+	assert.Equal(int64(202), record.ID)
+	assert.Equal(`kind-0`, record.Get(`kind`))
+
+	for spec, expected := range map[string][]interface{}{
+		`inventory/gt:27`:                {int64(28), int64(29), int64(30)},
+		`inventory/range:9|12`:           {int64(9), int64(10), int64(11)},
+		`color/red/inventory/lte:6`:      {int64(2), int64(4), int64(6)},
+		`name/item-15`:                   {int64(15)},
+		`id/29|2`:                        {int64(2), int64(29)},
+		`color/null/active/true/id/lt:6`: {int64(1), int64(3), int64(5)},
+		`name/prefix:item-3`:             {int64(30)},
+	} {
+		recordset, err := backend.Query(products, filter.MustParse(spec))
+		assert.NoError(err, spec)
+
+		ids := make([]interface{}, 0)
+
+		for _, record := range recordset.Records {
+			ids = append(ids, record.ID)
+		}
+
+		assert.Equal(expected, ids, spec)
+	}
+
+	recordset, err := backend.Query(products, filter.MustParse(`inventory/gt:400`))
+	assert.NoError(err)
+	assert.Empty(recordset.Records)
+
+	// only the requested fields are returned
+	f := filter.MustParse(`inventory/gt:28`)
+	f.Fields = []string{`name`}
+
+	recordset, err = backend.Query(products, f)
+	assert.NoError(err)
+	assert.Len(recordset.Records, 2)
+	assert.Equal(map[string]interface{}{`name`: `item-29`}, recordset.Records[0].Fields)
+
+	// pagination, with the total known from the file footers
+	f = filter.All()
+	f.Limit = 5
+	f.Offset = 12
+
+	recordset, err = backend.Query(products, f)
+	assert.NoError(err)
+	assert.Len(recordset.Records, 5)
+	assert.Equal(int64(13), recordset.Records[0].ID)
+	assert.EqualValues(30, recordset.ResultCount)
+
+	f = filter.MustParse(`color/red`)
+	f.Sort = []string{`-inventory`}
+	f.Limit = 2
+
+	recordset, err = backend.Query(products, f)
+	assert.NoError(err)
+	assert.Len(recordset.Records, 2)
+	assert.Equal(int64(30), recordset.Records[0].ID)
+	assert.Equal(int64(28), recordset.Records[1].ID)
+	assert.EqualValues(15, recordset.ResultCount)
+
+	// part files are read in order
+	events, err := backend.GetCollection(`events`)
+	assert.NoError(err)
+
+	recordset, err = backend.Query(events, filter.MustParse(`kind/kind-1`))
+	assert.NoError(err)
+	assert.Len(recordset.Records, 4)
+	assert.Equal(int64(101), recordset.Records[0].ID)
+	assert.Equal(int64(203), recordset.Records[3].ID)
+
+	values, err := backend.ListValues(products, []string{`color`}, filter.MustParse(`inventory/lt:5`))
+	assert.NoError(err)
+	assert.Equal([]interface{}{nil, `red`}, values[`color`])
+}
+
+func TestParquetBackendRowGroupStatistics(t *testing.T) {
+	assert := require.New(t)
+
+	backend, root := makeParquetBackend(t)
+	defer os.RemoveAll(root)
+
+	products, err := backend.GetCollection(`products`)
+	assert.NoError(err)
+
+	assert.NoError(withParquetReader(filepath.Join(root, `products.parquet`), func(pr *reader.ParquetReader) error {
+		columns := parquetColumns(pr)
+		assert.Len(pr.Footer.RowGroups, 3)
+
+		for spec, expected := range map[string][]bool{
+			`all`:                   {true, true, true},
+			`inventory/gt:10`:       {false, true, true},
+			`inventory/gte:10`:      {true, true, true},
+			`inventory/lt:11`:       {true, false, false},
+			`inventory/15`:          {false, true, false},
+			`inventory/15|25`:       {false, true, true},
+			`inventory/range:12|14`: {false, true, false},
+			`name/item-25`:          {false, false, true},
+			`id/12`:                 {false, true, false},
+			`inventory/not:15`:      {true, true, true},
+			`active/true`:           {true, true, true},
+		} {
+			var offset int64
+			actual := make([]bool, 0)
+
+			for _, rowGroup := range pr.Footer.RowGroups {
+				actual = append(actual, parquetRowGroupMatches(products, filter.MustParse(spec), columns, rowGroup, false, offset))
+				offset += rowGroup.NumRows
+			}
+
+			assert.Equal(expected, actual, spec)
+		}
+
+		return nil
+	}))
+}
+
+func TestParquetBackendAggregator(t *testing.T) {
+	assert := require.New(t)
+
+	backend, root := makeParquetBackend(t)
+	defer os.RemoveAll(root)
+
+	products, err := backend.GetCollection(`products`)
+	assert.NoError(err)
+
+	aggregator := backend.WithAggregator(products)
+	assert.NotNil(aggregator)
+
+	count, err := aggregator.Count(products)
+	assert.NoError(err)
+	assert.EqualValues(30, count)
+
+	count, err = aggregator.Count(products, filter.MustParse(`color/red`))
+	assert.NoError(err)
+	assert.EqualValues(15, count)
+
+	sum, err := aggregator.Sum(products, `inventory`)
+	assert.NoError(err)
+	assert.Equal(float64(465), sum)
+
+	max, err := aggregator.Maximum(products, `inventory`, filter.MustParse(`active/true`))
+	assert.NoError(err)
+	assert.Equal(float64(10), max)
+
+	avg, err := aggregator.Average(products, `price`, filter.MustParse(`inventory/lte:3`))
+	assert.NoError(err)
+	assert.InDelta(10.01, avg, 0.0001)
+
+	f := filter.All()
+	f.Sort = []string{`active`}
+
+	recordset, err := aggregator.GroupBy(products, []string{`active`}, []filter.Aggregate{
+		{
+			Aggregation: filter.Sum,
+			Field:       `inventory`,
+		},
+	}, f)
+	assert.NoError(err)
+	assert.Len(recordset.Records, 2)
+	assert.Equal(false, recordset.Records[0].Get(`active`))
+	assert.EqualValues(410, recordset.Records[0].Get(`inventory`))
+	assert.Equal(true, recordset.Records[1].Get(`active`))
+	assert.EqualValues(55, recordset.Records[1].Get(`inventory`))
+}
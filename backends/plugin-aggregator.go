@@ -0,0 +1,80 @@
+package backends
+
+// this file satifies the Aggregator interface for PluginBackend
+
+import (
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter"
+)
+
+func (self *PluginBackend) Sum(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	return self.aggregateFloat(`Sum`, collection, field, f)
+}
+
+func (self *PluginBackend) Count(collection *dal.Collection, f ...*filter.Filter) (uint64, error) {
+	response := PluginResponse{}
+
+	if err := self.call(`Count`, &PluginRequest{
+		Definition: collection,
+		Filter:     NewPluginFilter(memoryAggregateFilter(f)),
+	}, &response); err == nil {
+		return response.Count, nil
+	} else {
+		return 0, err
+	}
+}
+
+func (self *PluginBackend) Minimum(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	return self.aggregateFloat(`Minimum`, collection, field, f)
+}
+
+func (self *PluginBackend) Maximum(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	return self.aggregateFloat(`Maximum`, collection, field, f)
+}
+
+func (self *PluginBackend) Average(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	return self.aggregateFloat(`Average`, collection, field, f)
+}
+
+func (self *PluginBackend) GroupBy(collection *dal.Collection, groupBy []string, aggregates []filter.Aggregate, f ...*filter.Filter) (*dal.RecordSet, error) {
+	response := PluginResponse{}
+
+	if err := self.call(`GroupBy`, &PluginRequest{
+		Definition: collection,
+		GroupBy:    groupBy,
+		Aggregates: aggregates,
+		Filter:     NewPluginFilter(memoryAggregateFilter(f)),
+	}, &response); err == nil {
+		recordset := dal.NewRecordSet()
+
+		if response.Records != nil {
+			recordset = response.Records
+		}
+
+		return recordset, nil
+	} else {
+		return nil, err
+	}
+}
+
+func (self *PluginBackend) AggregatorConnectionString() *dal.ConnectionString {
+	return &self.conn
+}
+
+func (self *PluginBackend) AggregatorInitialize(parent Backend) error {
+	return nil
+}
+
+func (self *PluginBackend) aggregateFloat(method string, collection *dal.Collection, field string, f []*filter.Filter) (float64, error) {
+	response := PluginResponse{}
+
+	if err := self.call(method, &PluginRequest{
+		Definition: collection,
+		Field:      field,
+		Filter:     NewPluginFilter(memoryAggregateFilter(f)),
+	}, &response); err == nil {
+		return response.Value, nil
+	} else {
+		return 0, err
+	}
+}
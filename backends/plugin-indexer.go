@@ -0,0 +1,162 @@
+package backends
+
+// this file satifies the Indexer interface for PluginBackend
+
+import (
+	"fmt"
+
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter"
+)
+
+func (self *PluginBackend) IndexConnectionString() *dal.ConnectionString {
+	return &self.conn
+}
+
+func (self *PluginBackend) IndexInitialize(_ Backend) error {
+	return nil
+}
+
+func (self *PluginBackend) GetBackend() Backend {
+	return self
+}
+
+func (self *PluginBackend) IndexExists(collection *dal.Collection, id interface{}) bool {
+	response := PluginResponse{}
+
+	if err := self.call(`IndexExists`, &PluginRequest{
+		Definition: collection,
+		ID:         id,
+	}, &response); err == nil {
+		return response.Exists
+	}
+
+	return false
+}
+
+func (self *PluginBackend) IndexRetrieve(collection *dal.Collection, id interface{}) (*dal.Record, error) {
+	response := PluginResponse{}
+
+	if err := self.call(`IndexRetrieve`, &PluginRequest{
+		Definition: collection,
+		ID:         id,
+	}, &response); err == nil {
+		if response.Record == nil {
+			return nil, fmt.Errorf("Record %v does not exist", id)
+		}
+
+		return remoteRecord(collection, response.Record), nil
+	} else {
+		return nil, err
+	}
+}
+
+func (self *PluginBackend) IndexRemove(collection *dal.Collection, ids []interface{}) error {
+	return self.call(`IndexRemove`, &PluginRequest{
+		Definition: collection,
+		IDs:        ids,
+	}, nil)
+}
+
+func (self *PluginBackend) Index(collection *dal.Collection, records *dal.RecordSet) error {
+	return self.call(`Index`, &PluginRequest{
+		Definition: collection,
+		Records:    records,
+	}, nil)
+}
+
+// Performs the query in the plugin and calls resultFn for each record in the page of results that
+// it returns.
+func (self *PluginBackend) QueryFunc(collection *dal.Collection, f *filter.Filter, resultFn IndexResultFunc) error {
+	if f == nil {
+		f = filter.All()
+	}
+
+	querylog.Debugf("[%T] Query using filter %q", self, f.String())
+
+	response := PluginResponse{}
+
+	if err := self.call(`Query`, &PluginRequest{
+		Definition: collection,
+		Filter:     NewPluginFilter(f),
+	}, &response); err != nil {
+		return err
+	}
+
+	if response.Records == nil {
+		return nil
+	}
+
+	page := IndexPage{
+		Page:         response.Records.Page,
+		TotalPages:   response.Records.TotalPages,
+		Limit:        f.Limit,
+		Offset:       f.Offset,
+		TotalResults: response.Records.ResultCount,
+	}
+
+	for _, record := range response.Records.Records {
+		if err := resultFn(remoteRecord(collection, record), nil, page); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (self *PluginBackend) Query(collection *dal.Collection, f *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error) {
+	if f == nil {
+		f = filter.All()
+	}
+
+	// the plugin returns complete records, so there is no need to retrieve them again
+	query := filter.Copy(f)
+	query.Options = make(map[string]interface{})
+
+	for k, v := range f.Options {
+		query.Options[k] = v
+	}
+
+	query.Options[`ForceIndexRecord`] = true
+
+	return DefaultQueryImplementation(self, collection, &query, resultFns...)
+}
+
+func (self *PluginBackend) ListValues(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]interface{}, error) {
+	response := PluginResponse{}
+
+	if err := self.call(`ListValues`, &PluginRequest{
+		Definition: collection,
+		Fields:     fields,
+		Filter:     NewPluginFilter(f),
+	}, &response); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string][]interface{})
+
+	for field, fieldValues := range response.Values {
+		for _, value := range fieldValues {
+			if field == collection.IdentityField {
+				value = memoryIdentity(collection, value)
+			} else {
+				value = memoryJSONFieldValue(collection, field, value)
+			}
+
+			values[field] = append(values[field], value)
+		}
+	}
+
+	return values, nil
+}
+
+func (self *PluginBackend) DeleteQuery(collection *dal.Collection, f *filter.Filter) error {
+	return self.call(`DeleteQuery`, &PluginRequest{
+		Definition: collection,
+		Filter:     NewPluginFilter(f),
+	}, nil)
+}
+
+func (self *PluginBackend) FlushIndex() error {
+	return nil
+}
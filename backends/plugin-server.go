@@ -0,0 +1,599 @@
+package backends
+
+// this file implements the plugin side of the protocol used by PluginBackend
+
+import (
+	"fmt"
+	"io"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter"
+)
+
+// the name of the RPC service that plugins expose
+const PluginServiceName = `Pivot`
+
+// The arguments of a call to a plugin.  Each method of the Backend, Indexer, and Aggregator
+// interfaces is a method of the "Pivot" service (e.g.: "Pivot.Retrieve"), and uses whichever of
+// these fields correspond to its arguments.
+type PluginRequest struct {
+	ConnectionString string             `json:"connection_string,omitempty"`
+	Collection       string             `json:"collection,omitempty"`
+	Definition       *dal.Collection    `json:"definition,omitempty"`
+	ID               interface{}        `json:"id,omitempty"`
+	IDs              []interface{}      `json:"ids,omitempty"`
+	Fields           []string           `json:"fields,omitempty"`
+	Records          *dal.RecordSet     `json:"records,omitempty"`
+	Targets          []string           `json:"targets,omitempty"`
+	Filter           *PluginFilter      `json:"filter,omitempty"`
+	Field            string             `json:"field,omitempty"`
+	GroupBy          []string           `json:"group_by,omitempty"`
+	Aggregates       []filter.Aggregate `json:"aggregates,omitempty"`
+	Timeout          time.Duration      `json:"timeout,omitempty"`
+}
+
+// The results of a call to a plugin.  Only the fields corresponding to the return values of the
+// method that was called are populated.
+type PluginResponse struct {
+	Exists     bool                     `json:"exists,omitempty"`
+	Record     *dal.Record              `json:"record,omitempty"`
+	Records    *dal.RecordSet           `json:"records,omitempty"`
+	Definition *dal.Collection          `json:"definition,omitempty"`
+	Names      []string                 `json:"names,omitempty"`
+	Values     map[string][]interface{} `json:"values,omitempty"`
+	Value      float64                  `json:"value,omitempty"`
+	Count      uint64                   `json:"count,omitempty"`
+}
+
+// The parts of a filter.Filter that are sent to plugins.
+type PluginFilter struct {
+	Spec          string                 `json:"spec,omitempty"`
+	MatchAll      bool                   `json:"match_all,omitempty"`
+	Offset        int                    `json:"offset,omitempty"`
+	Limit         int                    `json:"limit,omitempty"`
+	Criteria      []filter.Criterion     `json:"criteria,omitempty"`
+	Having        []filter.Criterion     `json:"having,omitempty"`
+	Sort          []string               `json:"sort,omitempty"`
+	Fields        []string               `json:"fields,omitempty"`
+	Options       map[string]interface{} `json:"options,omitempty"`
+	Paginate      bool                   `json:"paginate,omitempty"`
+	IdentityField string                 `json:"identity_field,omitempty"`
+}
+
+func NewPluginFilter(f *filter.Filter) *PluginFilter {
+	if f == nil {
+		return nil
+	}
+
+	return &PluginFilter{
+		Spec:          f.Spec,
+		MatchAll:      f.MatchAll,
+		Offset:        f.Offset,
+		Limit:         f.Limit,
+		Criteria:      f.Criteria,
+		Having:        f.Having,
+		Sort:          f.Sort,
+		Fields:        f.Fields,
+		Options:       f.Options,
+		Paginate:      f.Paginate,
+		IdentityField: f.IdentityField,
+	}
+}
+
+// Returns the filter.Filter that this was created from.  Values of time criteria are converted back
+// into times.
+func (self *PluginFilter) Filter() *filter.Filter {
+	if self == nil {
+		return filter.All()
+	}
+
+	f := filter.MakeFilter(self.Spec)
+	f.MatchAll = f.MatchAll || self.MatchAll
+	f.Offset = self.Offset
+	f.Limit = self.Limit
+	f.Paginate = self.Paginate
+	f.IdentityField = sliceutil.OrString(self.IdentityField, filter.DefaultIdentityField)
+	f.Criteria = pluginCriteria(self.Criteria)
+	f.Having = pluginCriteria(self.Having)
+
+	if self.Sort != nil {
+		f.Sort = self.Sort
+	}
+
+	if self.Fields != nil {
+		f.Fields = self.Fields
+	}
+
+	if self.Options != nil {
+		f.Options = self.Options
+	}
+
+	return &f
+}
+
+// Serves the plugin protocol on standard input and output, creating the backend with the given
+// function when the plugin is initialized.  A plugin is an executable that calls this from main():
+//
+//	func main() {
+//	    if err := backends.ServePlugin(backends.NewMemoryBackend); err != nil {
+//	        log.Fatal(err)
+//	    }
+//	}
+//
+// Standard output is reserved for the protocol, so anything else the plugin prints to it is
+// redirected to standard error.  This returns when standard input is closed.
+func ServePlugin(fn BackendFunc) error {
+	stdin := os.Stdin
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+
+	return servePlugin(fn, pluginPipe{
+		ReadCloser:  stdin,
+		WriteCloser: stdout,
+	})
+}
+
+func servePlugin(fn BackendFunc, conn io.ReadWriteCloser) error {
+	server := rpc.NewServer()
+
+	if err := server.RegisterName(PluginServiceName, &pluginService{
+		backendFn: fn,
+	}); err != nil {
+		return err
+	}
+
+	server.ServeCodec(jsonrpc.NewServerCodec(conn))
+	return nil
+}
+
+// the RPC service that calls through to the plugin's backend
+type pluginService struct {
+	backendFn BackendFunc
+	backend   Backend
+	lock      sync.RWMutex
+}
+
+func (self *pluginService) Initialize(req *PluginRequest, resp *PluginResponse) error {
+	if cs, err := dal.ParseConnectionString(req.ConnectionString); err == nil {
+		backend := self.backendFn(cs)
+
+		if backend == nil {
+			return fmt.Errorf("Error occurred instantiating backend %q", cs.Backend())
+		}
+
+		if err := backend.Initialize(); err != nil {
+			return err
+		}
+
+		self.lock.Lock()
+		self.backend = backend
+		self.lock.Unlock()
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *pluginService) RegisterCollection(req *PluginRequest, resp *PluginResponse) error {
+	if backend, err := self.getBackend(); err == nil {
+		if req.Definition == nil {
+			return fmt.Errorf("A collection definition is required")
+		}
+
+		backend.RegisterCollection(req.Definition)
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *pluginService) Exists(req *PluginRequest, resp *PluginResponse) error {
+	if backend, collection, err := self.getCollection(req); err == nil {
+		resp.Exists = backend.Exists(collection.Name, memoryIdentity(collection, req.ID))
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *pluginService) Retrieve(req *PluginRequest, resp *PluginResponse) error {
+	if backend, collection, err := self.getCollection(req); err == nil {
+		if record, err := backend.Retrieve(collection.Name, memoryIdentity(collection, req.ID), req.Fields...); err == nil {
+			resp.Record = pluginResponseRecord(record)
+			return nil
+		} else {
+			return err
+		}
+	} else {
+		return err
+	}
+}
+
+func (self *pluginService) Insert(req *PluginRequest, resp *PluginResponse) error {
+	if backend, collection, err := self.getCollection(req); err == nil {
+		recordset := pluginRequestRecords(collection, req.Records)
+
+		if err := backend.Insert(collection.Name, recordset); err == nil {
+			resp.Records = pluginResponseRecords(recordset)
+			return nil
+		} else {
+			return err
+		}
+	} else {
+		return err
+	}
+}
+
+func (self *pluginService) Update(req *PluginRequest, resp *PluginResponse) error {
+	if backend, collection, err := self.getCollection(req); err == nil {
+		return backend.Update(collection.Name, pluginRequestRecords(collection, req.Records), req.Targets...)
+	} else {
+		return err
+	}
+}
+
+func (self *pluginService) Delete(req *PluginRequest, resp *PluginResponse) error {
+	if backend, collection, err := self.getCollection(req); err == nil {
+		ids := make([]interface{}, len(req.IDs))
+
+		for i, id := range req.IDs {
+			ids[i] = memoryIdentity(collection, id)
+		}
+
+		return backend.Delete(collection.Name, ids...)
+	} else {
+		return err
+	}
+}
+
+func (self *pluginService) CreateCollection(req *PluginRequest, resp *PluginResponse) error {
+	if backend, err := self.getBackend(); err == nil {
+		if req.Definition == nil {
+			return fmt.Errorf("A collection definition is required")
+		}
+
+		return backend.CreateCollection(req.Definition)
+	} else {
+		return err
+	}
+}
+
+func (self *pluginService) DeleteCollection(req *PluginRequest, resp *PluginResponse) error {
+	if backend, err := self.getBackend(); err == nil {
+		return backend.DeleteCollection(req.Collection)
+	} else {
+		return err
+	}
+}
+
+func (self *pluginService) ListCollections(req *PluginRequest, resp *PluginResponse) error {
+	if backend, err := self.getBackend(); err == nil {
+		if names, err := backend.ListCollections(); err == nil {
+			resp.Names = names
+			return nil
+		} else {
+			return err
+		}
+	} else {
+		return err
+	}
+}
+
+func (self *pluginService) GetCollection(req *PluginRequest, resp *PluginResponse) error {
+	if backend, err := self.getBackend(); err == nil {
+		if collection, err := backend.GetCollection(req.Collection); err == nil {
+			resp.Definition = collection
+			return nil
+		} else {
+			return err
+		}
+	} else {
+		return err
+	}
+}
+
+func (self *pluginService) Flush(req *PluginRequest, resp *PluginResponse) error {
+	if backend, err := self.getBackend(); err == nil {
+		return backend.Flush()
+	} else {
+		return err
+	}
+}
+
+func (self *pluginService) Ping(req *PluginRequest, resp *PluginResponse) error {
+	if backend, err := self.getBackend(); err == nil {
+		return backend.Ping(req.Timeout)
+	} else {
+		return err
+	}
+}
+
+func (self *pluginService) IndexExists(req *PluginRequest, resp *PluginResponse) error {
+	if search, collection, err := self.getIndexer(req); err == nil {
+		resp.Exists = search.IndexExists(collection, memoryIdentity(collection, req.ID))
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *pluginService) IndexRetrieve(req *PluginRequest, resp *PluginResponse) error {
+	if search, collection, err := self.getIndexer(req); err == nil {
+		if record, err := search.IndexRetrieve(collection, memoryIdentity(collection, req.ID)); err == nil {
+			resp.Record = pluginResponseRecord(record)
+			return nil
+		} else {
+			return err
+		}
+	} else {
+		return err
+	}
+}
+
+func (self *pluginService) IndexRemove(req *PluginRequest, resp *PluginResponse) error {
+	if search, collection, err := self.getIndexer(req); err == nil {
+		ids := make([]interface{}, len(req.IDs))
+
+		for i, id := range req.IDs {
+			ids[i] = memoryIdentity(collection, id)
+		}
+
+		return search.IndexRemove(collection, ids)
+	} else {
+		return err
+	}
+}
+
+func (self *pluginService) Index(req *PluginRequest, resp *PluginResponse) error {
+	if search, collection, err := self.getIndexer(req); err == nil {
+		return search.Index(collection, pluginRequestRecords(collection, req.Records))
+	} else {
+		return err
+	}
+}
+
+func (self *pluginService) Query(req *PluginRequest, resp *PluginResponse) error {
+	if search, collection, err := self.getIndexer(req); err == nil {
+		if recordset, err := search.Query(collection, req.Filter.Filter()); err == nil {
+			resp.Records = pluginResponseRecords(recordset)
+			return nil
+		} else {
+			return err
+		}
+	} else {
+		return err
+	}
+}
+
+func (self *pluginService) ListValues(req *PluginRequest, resp *PluginResponse) error {
+	if search, collection, err := self.getIndexer(req); err == nil {
+		if values, err := search.ListValues(collection, req.Fields, req.Filter.Filter()); err == nil {
+			resp.Values = values
+			return nil
+		} else {
+			return err
+		}
+	} else {
+		return err
+	}
+}
+
+func (self *pluginService) DeleteQuery(req *PluginRequest, resp *PluginResponse) error {
+	if search, collection, err := self.getIndexer(req); err == nil {
+		return search.DeleteQuery(collection, req.Filter.Filter())
+	} else {
+		return err
+	}
+}
+
+func (self *pluginService) FlushIndex(req *PluginRequest, resp *PluginResponse) error {
+	if search, _, err := self.getIndexer(req); err == nil {
+		return search.FlushIndex()
+	} else {
+		return err
+	}
+}
+
+func (self *pluginService) Sum(req *PluginRequest, resp *PluginResponse) error {
+	if aggregator, collection, err := self.getAggregator(req); err == nil {
+		resp.Value, err = aggregator.Sum(collection, req.Field, req.Filter.Filter())
+		return err
+	} else {
+		return err
+	}
+}
+
+func (self *pluginService) Count(req *PluginRequest, resp *PluginResponse) error {
+	if aggregator, collection, err := self.getAggregator(req); err == nil {
+		resp.Count, err = aggregator.Count(collection, req.Filter.Filter())
+		return err
+	} else {
+		return err
+	}
+}
+
+func (self *pluginService) Minimum(req *PluginRequest, resp *PluginResponse) error {
+	if aggregator, collection, err := self.getAggregator(req); err == nil {
+		resp.Value, err = aggregator.Minimum(collection, req.Field, req.Filter.Filter())
+		return err
+	} else {
+		return err
+	}
+}
+
+func (self *pluginService) Maximum(req *PluginRequest, resp *PluginResponse) error {
+	if aggregator, collection, err := self.getAggregator(req); err == nil {
+		resp.Value, err = aggregator.Maximum(collection, req.Field, req.Filter.Filter())
+		return err
+	} else {
+		return err
+	}
+}
+
+func (self *pluginService) Average(req *PluginRequest, resp *PluginResponse) error {
+	if aggregator, collection, err := self.getAggregator(req); err == nil {
+		resp.Value, err = aggregator.Average(collection, req.Field, req.Filter.Filter())
+		return err
+	} else {
+		return err
+	}
+}
+
+func (self *pluginService) GroupBy(req *PluginRequest, resp *PluginResponse) error {
+	if aggregator, collection, err := self.getAggregator(req); err == nil {
+		if recordset, err := aggregator.GroupBy(collection, req.GroupBy, req.Aggregates, req.Filter.Filter()); err == nil {
+			resp.Records = pluginResponseRecords(recordset)
+			return nil
+		} else {
+			return err
+		}
+	} else {
+		return err
+	}
+}
+
+func (self *pluginService) getBackend() (Backend, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	if self.backend == nil {
+		return nil, fmt.Errorf("Plugin has not been initialized")
+	}
+
+	return self.backend, nil
+}
+
+// Returns the plugin backend's definition of the requested collection.  Collections that the
+// backend doesn't know about are described by the definition sent with the request (if any).
+func (self *pluginService) getCollection(req *PluginRequest) (Backend, *dal.Collection, error) {
+	backend, err := self.getBackend()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	name := req.Collection
+
+	if name == `` && req.Definition != nil {
+		name = req.Definition.Name
+	}
+
+	if collection, err := backend.GetCollection(name); err == nil {
+		return backend, collection, nil
+	} else if req.Definition != nil {
+		return backend, req.Definition, nil
+	} else {
+		return nil, nil, err
+	}
+}
+
+func (self *pluginService) getIndexer(req *PluginRequest) (Indexer, *dal.Collection, error) {
+	if backend, collection, err := self.getCollection(req); err == nil {
+		if search := backend.WithSearch(collection); search != nil {
+			return search, collection, nil
+		} else {
+			return nil, nil, fmt.Errorf("Backend %T does not support complex queries.", backend)
+		}
+	} else {
+		return nil, nil, err
+	}
+}
+
+func (self *pluginService) getAggregator(req *PluginRequest) (Aggregator, *dal.Collection, error) {
+	if backend, collection, err := self.getCollection(req); err == nil {
+		if aggregator := backend.WithAggregator(collection); aggregator != nil {
+			return aggregator, collection, nil
+		} else {
+			return nil, nil, fmt.Errorf("Backend %T does not support aggregations.", backend)
+		}
+	} else {
+		return nil, nil, err
+	}
+}
+
+// values in requests arrive as decoded JSON, so they are converted to the collection's field types
+func pluginRequestRecords(collection *dal.Collection, recordset *dal.RecordSet) *dal.RecordSet {
+	output := dal.NewRecordSet()
+
+	if recordset != nil {
+		for _, record := range recordset.Records {
+			if record != nil {
+				output.Push(remoteRecord(collection, record))
+			}
+		}
+	}
+
+	return output
+}
+
+// errors cannot be represented in JSON, so they are removed from records before they are sent
+func pluginResponseRecord(record *dal.Record) *dal.Record {
+	if record != nil && record.Error != nil {
+		output := *record
+		output.Error = nil
+		return &output
+	}
+
+	return record
+}
+
+func pluginResponseRecords(recordset *dal.RecordSet) *dal.RecordSet {
+	if recordset == nil {
+		return nil
+	}
+
+	output := *recordset
+	output.Records = make([]*dal.Record, len(recordset.Records))
+
+	for i, record := range recordset.Records {
+		output.Records[i] = pluginResponseRecord(record)
+	}
+
+	return &output
+}
+
+func pluginCriteria(criteria []filter.Criterion) []filter.Criterion {
+	output := make([]filter.Criterion, len(criteria))
+
+	for i, criterion := range criteria {
+		output[i] = criterion
+
+		if criterion.Type == dal.TimeType {
+			output[i].Values = make([]interface{}, len(criterion.Values))
+
+			for j, value := range criterion.Values {
+				if tm, err := stringutil.ConvertToTime(value); err == nil {
+					output[i].Values[j] = tm
+				} else {
+					output[i].Values[j] = value
+				}
+			}
+		}
+	}
+
+	return output
+}
+
+// joins the output of a plugin process and the input of the process into one connection
+type pluginPipe struct {
+	io.ReadCloser
+	io.WriteCloser
+}
+
+func (self pluginPipe) Close() error {
+	werr := self.WriteCloser.Close()
+
+	if err := self.ReadCloser.Close(); err != nil {
+		return err
+	}
+
+	return werr
+}
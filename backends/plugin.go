@@ -0,0 +1,506 @@
+package backends
+
+import (
+	"fmt"
+	"io"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter"
+)
+
+var PluginExecutablePrefix = `pivot-plugin-`
+var PluginDirectories = []string{}
+var PluginMaxRestarts = 5
+var PluginShutdownTimeout = 5 * time.Second
+
+// A backend that is implemented by an external executable, so that backends can be added without
+// changing or recompiling this package.  The executable for "plugin://name" is "pivot-plugin-name",
+// which is looked for in PluginDirectories and then in $PATH.  The entire connection string is
+// passed on to the plugin, which can use the rest of it (e.g.: plugin://name/dataset?option=value)
+// however it likes.  Plugins are written with ServePlugin.
+//
+// The plugin is started when the backend is initialized, and communicates using JSON-RPC over its
+// standard input and output; anything it writes to standard error is passed through.  If the plugin
+// exits, the call in progress fails and the plugin is restarted on the next call (after being
+// re-initialized and re-registering every collection), up to PluginMaxRestarts times in a row.
+type PluginBackend struct {
+	conn        dal.ConnectionString
+	executable  string
+	indexer     Indexer
+	collections map[string]*dal.Collection
+	process     *pluginProcess
+	restarts    int
+	lock        sync.RWMutex
+	processLock sync.Mutex
+}
+
+// a running plugin executable
+type pluginProcess struct {
+	cmd    *exec.Cmd
+	client *rpc.Client
+	exited chan struct{}
+}
+
+func NewPluginBackend(connection dal.ConnectionString) Backend {
+	return &PluginBackend{
+		conn:        connection,
+		collections: make(map[string]*dal.Collection),
+	}
+}
+
+func (self *PluginBackend) GetConnectionString() *dal.ConnectionString {
+	return &self.conn
+}
+
+func (self *PluginBackend) Ping(timeout time.Duration) error {
+	client, err := self.getClient()
+
+	if err != nil {
+		return err
+	}
+
+	call := client.Go(PluginServiceName+`.Ping`, &PluginRequest{
+		Timeout: timeout,
+	}, &PluginResponse{}, nil)
+
+	select {
+	case <-call.Done:
+		return self.callError(call.Error)
+	case <-time.After(timeout):
+		return fmt.Errorf("Plugin did not respond within %v", timeout)
+	}
+}
+
+func (self *PluginBackend) RegisterCollection(definition *dal.Collection) {
+	self.lock.Lock()
+	self.collections[definition.Name] = definition
+	self.lock.Unlock()
+
+	// collections are sent to the plugin when it is started, so there's no need to start it here
+	self.processLock.Lock()
+	running := (self.process != nil)
+	self.processLock.Unlock()
+
+	if running {
+		if err := self.call(`RegisterCollection`, &PluginRequest{
+			Definition: definition,
+		}, nil); err != nil {
+			querylog.Warningf("[%T] failed to register collection %q: %v", self, definition.Name, err)
+		}
+	}
+}
+
+func (self *PluginBackend) SetIndexer(indexConnString dal.ConnectionString) error {
+	if indexer, err := MakeIndexer(indexConnString); err == nil {
+		self.indexer = indexer
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *PluginBackend) Initialize() error {
+	name := self.conn.Host()
+
+	if name == `` {
+		return fmt.Errorf("A plugin name is required")
+	}
+
+	if executable, err := findPluginExecutable(PluginExecutablePrefix + name); err == nil {
+		self.executable = executable
+	} else {
+		return err
+	}
+
+	if _, err := self.getClient(); err != nil {
+		return err
+	}
+
+	if self.indexer == nil {
+		self.indexer = self
+	}
+
+	if err := self.indexer.IndexInitialize(self); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (self *PluginBackend) Exists(name string, id interface{}) bool {
+	response := PluginResponse{}
+
+	if err := self.call(`Exists`, &PluginRequest{
+		Collection: name,
+		ID:         id,
+	}, &response); err == nil {
+		return response.Exists
+	}
+
+	return false
+}
+
+func (self *PluginBackend) Retrieve(name string, id interface{}, fields ...string) (*dal.Record, error) {
+	if collection, err := self.GetCollection(name); err == nil {
+		response := PluginResponse{}
+
+		if err := self.call(`Retrieve`, &PluginRequest{
+			Collection: name,
+			ID:         id,
+			Fields:     fields,
+		}, &response); err == nil {
+			if response.Record == nil {
+				return nil, fmt.Errorf("Record %v does not exist", id)
+			}
+
+			return remoteRecord(collection, response.Record), nil
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+}
+
+func (self *PluginBackend) Insert(name string, recordset *dal.RecordSet) error {
+	if collection, err := self.GetCollection(name); err == nil {
+		if err := remotePrepareRecords(collection, recordset, nil); err != nil {
+			return err
+		}
+
+		response := PluginResponse{}
+
+		if err := self.call(`Insert`, &PluginRequest{
+			Collection: name,
+			Records:    recordset,
+		}, &response); err != nil {
+			return err
+		}
+
+		// pick up any IDs that were assigned by the plugin
+		if response.Records != nil {
+			for i, record := range response.Records.Records {
+				if i < len(recordset.Records) && recordset.Records[i].ID == nil && record != nil {
+					recordset.Records[i].ID = memoryIdentity(collection, record.ID)
+				}
+			}
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *PluginBackend) Update(name string, recordset *dal.RecordSet, target ...string) error {
+	if collection, err := self.GetCollection(name); err == nil {
+		if err := remotePrepareRecords(collection, recordset, func(id interface{}) (*dal.Record, error) {
+			return self.Retrieve(name, id)
+		}); err != nil {
+			return err
+		}
+
+		return self.call(`Update`, &PluginRequest{
+			Collection: name,
+			Records:    recordset,
+			Targets:    target,
+		}, nil)
+	} else {
+		return err
+	}
+}
+
+func (self *PluginBackend) Delete(name string, ids ...interface{}) error {
+	return self.call(`Delete`, &PluginRequest{
+		Collection: name,
+		IDs:        ids,
+	}, nil)
+}
+
+func (self *PluginBackend) CreateCollection(definition *dal.Collection) error {
+	if err := self.call(`CreateCollection`, &PluginRequest{
+		Definition: definition,
+	}, nil); err == nil {
+		self.lock.Lock()
+		self.collections[definition.Name] = definition
+		self.lock.Unlock()
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *PluginBackend) DeleteCollection(name string) error {
+	if err := self.call(`DeleteCollection`, &PluginRequest{
+		Collection: name,
+	}, nil); err == nil {
+		self.lock.Lock()
+		delete(self.collections, name)
+		self.lock.Unlock()
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *PluginBackend) ListCollections() ([]string, error) {
+	response := PluginResponse{}
+
+	if err := self.call(`ListCollections`, &PluginRequest{}, &response); err == nil {
+		sort.Strings(response.Names)
+		return response.Names, nil
+	} else {
+		return nil, err
+	}
+}
+
+func (self *PluginBackend) GetCollection(name string) (*dal.Collection, error) {
+	self.lock.RLock()
+	collection, ok := self.collections[name]
+	self.lock.RUnlock()
+
+	if ok {
+		return collection, nil
+	}
+
+	response := PluginResponse{}
+
+	if err := self.call(`GetCollection`, &PluginRequest{
+		Collection: name,
+	}, &response); err == nil {
+		if response.Definition == nil {
+			return nil, dal.CollectionNotFound
+		}
+
+		self.lock.Lock()
+		self.collections[name] = response.Definition
+		self.lock.Unlock()
+
+		return response.Definition, nil
+	} else {
+		return nil, err
+	}
+}
+
+func (self *PluginBackend) WithSearch(collection *dal.Collection, filters ...*filter.Filter) Indexer {
+	return self.indexer
+}
+
+func (self *PluginBackend) WithAggregator(collection *dal.Collection) Aggregator {
+	return self
+}
+
+func (self *PluginBackend) Flush() error {
+	if self.indexer != nil && self.indexer != Indexer(self) {
+		if err := self.indexer.FlushIndex(); err != nil {
+			return err
+		}
+	}
+
+	return self.call(`Flush`, &PluginRequest{}, nil)
+}
+
+// Stops the plugin by closing its standard input, killing it if it has not exited within
+// PluginShutdownTimeout.
+func (self *PluginBackend) Close() error {
+	self.processLock.Lock()
+	defer self.processLock.Unlock()
+
+	if self.process == nil {
+		return nil
+	}
+
+	process := self.process
+	self.process = nil
+	process.client.Close()
+
+	select {
+	case <-process.exited:
+		return nil
+	case <-time.After(PluginShutdownTimeout):
+		return process.cmd.Process.Kill()
+	}
+}
+
+// Calls the given method of the plugin, starting it first if it isn't running.
+func (self *PluginBackend) call(method string, request *PluginRequest, response *PluginResponse) error {
+	client, err := self.getClient()
+
+	if err != nil {
+		return err
+	}
+
+	if response == nil {
+		response = &PluginResponse{}
+	}
+
+	if err := self.callError(client.Call(PluginServiceName+`.`+method, request, response)); err == nil {
+		self.processLock.Lock()
+		self.restarts = 0
+		self.processLock.Unlock()
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+// Errors returned by the plugin are converted back into plain errors, so that checks like
+// dal.IsNotExistError work the same as they would for other backends.
+func (self *PluginBackend) callError(err error) error {
+	switch err.(type) {
+	case nil:
+		return nil
+	case rpc.ServerError:
+		return fmt.Errorf("%s", err.Error())
+	}
+
+	if err == rpc.ErrShutdown || err == io.ErrUnexpectedEOF || err == io.EOF {
+		return fmt.Errorf("Plugin %q exited: %v", self.conn.Host(), err)
+	}
+
+	return err
+}
+
+// returns a client for the running plugin, (re)starting it if necessary
+func (self *PluginBackend) getClient() (*rpc.Client, error) {
+	self.processLock.Lock()
+	defer self.processLock.Unlock()
+
+	if self.process != nil {
+		select {
+		case <-self.process.exited:
+			self.process.client.Close()
+			self.process = nil
+			self.restarts += 1
+		default:
+			return self.process.client, nil
+		}
+	}
+
+	if self.executable == `` {
+		return nil, fmt.Errorf("Backend not initialized")
+	} else if self.restarts > PluginMaxRestarts {
+		return nil, fmt.Errorf("Plugin %q has exited %d times in a row", self.conn.Host(), self.restarts)
+	} else if self.restarts > 0 {
+		querylog.Warningf("[%T] restarting plugin %q (attempt %d)", self, self.conn.Host(), self.restarts)
+	}
+
+	if process, err := self.startProcess(); err == nil {
+		self.process = process
+		return process.client, nil
+	} else {
+		self.restarts += 1
+		return nil, err
+	}
+}
+
+// starts the plugin executable, initializes it, and registers every known collection with it
+func (self *PluginBackend) startProcess() (*pluginProcess, error) {
+	cmd := exec.Command(self.executable)
+	cmd.Stderr = os.Stderr
+
+	// The pipes are created here rather than with cmd.StdinPipe and cmd.StdoutPipe, since cmd.Wait
+	// closes those as soon as the process exits, which can be before the client has read the last
+	// response.  The child's ends are closed once it has started, and ours when the client is.
+	stdin, input, err := os.Pipe()
+
+	if err != nil {
+		return nil, err
+	}
+
+	output, stdout, err := os.Pipe()
+
+	if err != nil {
+		stdin.Close()
+		input.Close()
+		return nil, err
+	}
+
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+
+	err = cmd.Start()
+	stdin.Close()
+	stdout.Close()
+
+	if err != nil {
+		input.Close()
+		output.Close()
+		return nil, err
+	}
+
+	process := &pluginProcess{
+		cmd: cmd,
+		client: jsonrpc.NewClient(pluginPipe{
+			ReadCloser:  output,
+			WriteCloser: input,
+		}),
+		exited: make(chan struct{}),
+	}
+
+	go func() {
+		err := cmd.Wait()
+		querylog.Debugf("[%T] plugin %q exited: %v", self, self.conn.Host(), err)
+		close(process.exited)
+	}()
+
+	requests := []*PluginRequest{
+		{
+			ConnectionString: self.conn.String(),
+		},
+	}
+
+	self.lock.RLock()
+
+	for _, definition := range self.collections {
+		requests = append(requests, &PluginRequest{
+			Definition: definition,
+		})
+	}
+
+	self.lock.RUnlock()
+
+	for i, request := range requests {
+		method := `RegisterCollection`
+
+		if i == 0 {
+			method = `Initialize`
+		}
+
+		if err := process.client.Call(PluginServiceName+`.`+method, request, &PluginResponse{}); err != nil {
+			process.client.Close()
+			cmd.Process.Kill()
+
+			return nil, fmt.Errorf("Plugin %q: %v", self.conn.Host(), self.callError(err))
+		}
+	}
+
+	return process, nil
+}
+
+func findPluginExecutable(name string) (string, error) {
+	for _, dir := range PluginDirectories {
+		candidate := filepath.Join(dir, name)
+
+		if info, err := os.Stat(candidate); err == nil && info.Mode().IsRegular() && info.Mode().Perm()&0111 != 0 {
+			return candidate, nil
+		}
+	}
+
+	if executable, err := exec.LookPath(name); err == nil {
+		return executable, nil
+	} else {
+		return ``, fmt.Errorf("Plugin executable %q not found", name)
+	}
+}
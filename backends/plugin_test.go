@@ -0,0 +1,228 @@
+package backends
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter"
+	"github.com/stretchr/testify/require"
+)
+
+// Not a real test: this is run as the plugin executable by the scripts that makePluginBackend writes.
+func TestPluginHelperProcess(t *testing.T) {
+	if os.Getenv(`PIVOT_TEST_PLUGIN`) == `` {
+		return
+	}
+
+	if err := ServePlugin(NewMemoryBackend); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+}
+
+// Writes a plugin executable with the given script body to a temporary directory and starts it.
+// The returned function stops the plugin and removes the directory.
+func makePluginBackend(t *testing.T, name string, script string) (*PluginBackend, func(), error) {
+	dir, err := ioutil.TempDir(``, `pivot-backend-plugin-`)
+	require.NoError(t, err)
+
+	require.NoError(t, ioutil.WriteFile(
+		filepath.Join(dir, PluginExecutablePrefix+name),
+		[]byte("#!/bin/sh\n"+script+"\n"),
+		0755,
+	))
+
+	directories := PluginDirectories
+	PluginDirectories = []string{dir}
+
+	cs, err := dal.ParseConnectionString(`plugin://` + name)
+	require.NoError(t, err)
+
+	backend := NewPluginBackend(cs).(*PluginBackend)
+	err = backend.Initialize()
+
+	return backend, func() {
+		backend.Close()
+		PluginDirectories = directories
+		os.RemoveAll(dir)
+	}, err
+}
+
+func makeMemoryPluginBackend(t *testing.T) (*PluginBackend, func()) {
+	backend, cleanup, err := makePluginBackend(t, `memtest`, fmt.Sprintf(
+		"PIVOT_TEST_PLUGIN=1 exec %q -test.run='^TestPluginHelperProcess$'",
+		os.Args[0],
+	))
+
+	require.NoError(t, err)
+	return backend, cleanup
+}
+
+func TestPluginBackend(t *testing.T) {
+	assert := require.New(t)
+	backend, cleanup := makeMemoryPluginBackend(t)
+	defer cleanup()
+
+	assert.NoError(backend.Ping(PluginShutdownTimeout))
+
+	collection := dal.NewCollection(`TestPluginBackend`).
+		AddFields(dal.Field{
+			Name:     `name`,
+			Type:     dal.StringType,
+			Required: true,
+		}, dal.Field{
+			Name: `color`,
+			Type: dal.StringType,
+		}, dal.Field{
+			Name: `inventory`,
+			Type: dal.IntType,
+		})
+
+	assert.NoError(backend.CreateCollection(collection))
+
+	names, err := backend.ListCollections()
+	assert.NoError(err)
+	assert.Equal([]string{`TestPluginBackend`}, names)
+
+	assert.NoError(backend.Insert(collection.Name, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `one`).Set(`color`, `red`).Set(`inventory`, 5),
+		dal.NewRecord(2).Set(`name`, `two`).Set(`color`, `blue`).Set(`inventory`, 10),
+		dal.NewRecord(3).Set(`name`, `three`).Set(`color`, `red`).Set(`inventory`, 15),
+	)))
+
+	record, err := backend.Retrieve(collection.Name, 2)
+	assert.NoError(err)
+	assert.EqualValues(2, record.ID)
+	assert.Equal(`two`, record.Get(`name`))
+	assert.Equal(int64(10), record.Get(`inventory`))
+
+	assert.True(backend.Exists(collection.Name, 1))
+	assert.False(backend.Exists(collection.Name, 4))
+
+	_, err = backend.Retrieve(collection.Name, 4)
+	assert.True(dal.IsNotExistError(err))
+
+	// records are validated before they are sent
+	err = backend.Insert(collection.Name, dal.NewRecordSet(dal.NewRecord(4)))
+	assert.IsType(&dal.ValidationError{}, err)
+
+	assert.NoError(backend.Update(collection.Name, dal.NewRecordSet(
+		dal.NewRecord(2).Set(`name`, `TWO`).Set(`color`, `blue`).Set(`inventory`, 10),
+	)))
+
+	record, err = backend.Retrieve(collection.Name, 2)
+	assert.NoError(err)
+	assert.Equal(`TWO`, record.Get(`name`))
+
+	search := backend.WithSearch(collection)
+	assert.NotNil(search)
+
+	recordset, err := search.Query(collection, filter.MustParse(`int:inventory/gte:10`).SortBy(`-inventory`))
+	assert.NoError(err)
+	assert.EqualValues(2, recordset.ResultCount)
+	assert.Len(recordset.Records, 2)
+	assert.EqualValues(3, recordset.Records[0].ID)
+	assert.Equal(int64(15), recordset.Records[0].Get(`inventory`))
+	assert.EqualValues(2, recordset.Records[1].ID)
+
+	values, err := search.ListValues(collection, []string{`color`}, filter.All())
+	assert.NoError(err)
+	assert.ElementsMatch([]interface{}{`red`, `blue`}, values[`color`])
+
+	aggregator := backend.WithAggregator(collection)
+	assert.NotNil(aggregator)
+
+	count, err := aggregator.Count(collection, filter.MustParse(`color/red`))
+	assert.NoError(err)
+	assert.EqualValues(2, count)
+
+	sum, err := aggregator.Sum(collection, `inventory`)
+	assert.NoError(err)
+	assert.Equal(float64(30), sum)
+
+	groups, err := aggregator.GroupBy(collection, []string{`color`}, []filter.Aggregate{
+		{
+			Aggregation: filter.Sum,
+			Field:       `inventory`,
+		},
+	}, filter.All().SortBy(`color`))
+
+	assert.NoError(err)
+	assert.Len(groups.Records, 2)
+	assert.Equal(`blue`, groups.Records[0].Get(`color`))
+	assert.Equal(`red`, groups.Records[1].Get(`color`))
+
+	assert.NoError(backend.Delete(collection.Name, 1))
+	assert.False(backend.Exists(collection.Name, 1))
+
+	assert.NoError(search.DeleteQuery(collection, filter.MustParse(`color/blue`)))
+	assert.False(backend.Exists(collection.Name, 2))
+	assert.True(backend.Exists(collection.Name, 3))
+
+	assert.NoError(backend.DeleteCollection(collection.Name))
+
+	names, err = backend.ListCollections()
+	assert.NoError(err)
+	assert.Empty(names)
+}
+
+func TestPluginBackendRestart(t *testing.T) {
+	assert := require.New(t)
+	backend, cleanup := makeMemoryPluginBackend(t)
+	defer cleanup()
+
+	collection := dal.NewCollection(`TestPluginBackendRestart`).
+		AddFields(dal.Field{
+			Name: `name`,
+			Type: dal.StringType,
+		})
+
+	backend.RegisterCollection(collection)
+	assert.NoError(backend.Insert(collection.Name, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `one`),
+	)))
+
+	// kill the plugin and wait for it to exit
+	process := backend.process
+	assert.NoError(process.cmd.Process.Kill())
+	<-process.exited
+
+	// the plugin is started again, and the collection is registered with it again
+	assert.NoError(backend.Insert(collection.Name, dal.NewRecordSet(
+		dal.NewRecord(2).Set(`name`, `two`),
+	)))
+
+	assert.True(process != backend.process)
+	assert.True(backend.Exists(collection.Name, 2))
+
+	// the memory backend's records do not survive the restart
+	assert.False(backend.Exists(collection.Name, 1))
+}
+
+func TestPluginBackendErrors(t *testing.T) {
+	assert := require.New(t)
+
+	cs, err := dal.ParseConnectionString(`plugin://does-not-exist`)
+	assert.NoError(err)
+	assert.Error(NewPluginBackend(cs).Initialize())
+
+	// a plugin that exits immediately
+	backend, cleanup, err := makePluginBackend(t, `crashing`, `exit 1`)
+	defer cleanup()
+	assert.Error(err)
+
+	// ...is only restarted so many times
+	for i := 0; i < PluginMaxRestarts; i++ {
+		_, err = backend.ListCollections()
+		assert.Error(err)
+	}
+
+	_, err = backend.ListCollections()
+	assert.EqualError(err, fmt.Sprintf("Plugin \"crashing\" has exited %d times in a row", PluginMaxRestarts+1))
+}
@@ -0,0 +1,347 @@
+package backends
+
+// this file satifies the Indexer interface for RedisBackend
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/gomodule/redigo/redis"
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter"
+)
+
+func (self *RedisBackend) IndexConnectionString() *dal.ConnectionString {
+	return &self.conn
+}
+
+func (self *RedisBackend) IndexInitialize(_ Backend) error {
+	return nil
+}
+
+func (self *RedisBackend) GetBackend() Backend {
+	return self
+}
+
+func (self *RedisBackend) IndexExists(collection *dal.Collection, id interface{}) bool {
+	return self.Exists(collection.GetIndexName(), id)
+}
+
+func (self *RedisBackend) IndexRetrieve(collection *dal.Collection, id interface{}) (*dal.Record, error) {
+	return self.Retrieve(collection.GetIndexName(), id)
+}
+
+// secondary indexes are maintained as records are written, so there is nothing to do here
+func (self *RedisBackend) IndexRemove(collection *dal.Collection, ids []interface{}) error {
+	return nil
+}
+
+func (self *RedisBackend) Index(collection *dal.Collection, records *dal.RecordSet) error {
+	return nil
+}
+
+func (self *RedisBackend) QueryFunc(collection *dal.Collection, f *filter.Filter, resultFn IndexResultFunc) error {
+	if f == nil {
+		f = filter.All()
+	}
+
+	querylog.Debugf("[%T] Query using filter %q", self, f.String())
+
+	if records, err := self.matchingRecords(collection, f); err == nil {
+		return emitRecordPage(records, f, resultFn)
+	} else {
+		return err
+	}
+}
+
+func (self *RedisBackend) Query(collection *dal.Collection, f *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error) {
+	if f == nil {
+		f = filter.All()
+	}
+
+	return DefaultQueryImplementation(self, collection, f, resultFns...)
+}
+
+func (self *RedisBackend) ListValues(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]interface{}, error) {
+	values := make(map[string][]interface{})
+
+	if err := self.QueryFunc(collection, f, func(record *dal.Record, err error, page IndexPage) error {
+		if err != nil {
+			return err
+		}
+
+		for _, field := range fields {
+			values[field] = sliceutil.Unique(append(values[field], memoryRecordValue(collection, record, field)))
+		}
+
+		return nil
+	}); err == nil {
+		return values, nil
+	} else {
+		return values, err
+	}
+}
+
+func (self *RedisBackend) DeleteQuery(collection *dal.Collection, f *filter.Filter) error {
+	ids := make([]interface{}, 0)
+
+	if err := self.QueryFunc(collection, f, func(record *dal.Record, err error, page IndexPage) error {
+		if err == nil {
+			ids = append(ids, record.ID)
+		}
+
+		return err
+	}); err == nil {
+		return self.Delete(collection.Name, ids...)
+	} else {
+		return err
+	}
+}
+
+func (self *RedisBackend) FlushIndex() error {
+	return nil
+}
+
+// Returns all records in the collection that match the given filter, sorted according to the
+// filter's sort fields (or by ID if none are given).  Criteria on the ID and indexed fields are
+// answered from the indexes where possible; if none of them can be, every record in the collection
+// is checked.
+func (self *RedisBackend) matchingRecords(collection *dal.Collection, f *filter.Filter) ([]*dal.Record, error) {
+	for _, criterion := range f.Criteria {
+		if !isMemoryOperator(criterion.Operator) {
+			return nil, fmt.Errorf("Unimplemented operator '%s'", criterion.Operator)
+		}
+	}
+
+	definition, err := self.GetCollection(collection.Name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	conn := self.pool.Get()
+	defer conn.Close()
+
+	ids, indexed, err := self.candidateIds(conn, definition, f)
+
+	if err != nil {
+		return nil, err
+	} else if !indexed {
+		prefix := self.collectionKey(definition.Name, `record`, ``)
+
+		if keys, err := self.scanKeys(conn, prefix+`*`); err == nil {
+			ids = make([]string, len(keys))
+
+			for i, key := range keys {
+				ids[i] = strings.TrimPrefix(key, prefix)
+			}
+		} else {
+			return nil, err
+		}
+	}
+
+	matches := make([]*dal.Record, 0)
+
+	for _, id := range ids {
+		if record, err := self.getRecord(conn, definition, id); err == nil {
+			if record != nil && memoryMatchesFilter(definition, f, record) {
+				matches = append(matches, record)
+			}
+		} else {
+			return nil, err
+		}
+	}
+
+	// keys come back from Redis in no particular order, so records are ordered by ID before sorting
+	sort.SliceStable(matches, func(i int, j int) bool {
+		return memoryCompareForSort(matches[i].ID, matches[j].ID) < 0
+	})
+
+	memorySortRecords(definition, matches, f)
+
+	return matches, nil
+}
+
+// Returns the IDs of the records that could match the filter according to the indexes, and whether
+// any index could be used at all.  Single-valued equality criteria on set-indexed fields are
+// intersected by Redis; the results of all other indexed criteria are intersected with them here.
+// The records still need to be checked against the whole filter.
+func (self *RedisBackend) candidateIds(conn redis.Conn, collection *dal.Collection, f *filter.Filter) ([]string, bool, error) {
+	if f.IsMatchAll() {
+		return nil, false, nil
+	}
+
+	setKeys := make([]interface{}, 0)
+	groups := make([][]string, 0)
+
+	for _, criterion := range f.Criteria {
+		values := make([]interface{}, len(criterion.Values))
+
+		for i, v := range criterion.Values {
+			values[i] = memoryCriterionValue(collection, criterion, v)
+		}
+
+		// lookups by ID don't need an index
+		if criterion.Field == f.IdentityField || criterion.Field == collection.IdentityField {
+			if criterion.Operator == `` || criterion.Operator == `is` {
+				ids := make([]string, 0)
+
+				for _, v := range values {
+					if v != nil {
+						ids = append(ids, memoryKey(memoryIdentity(collection, v)))
+					}
+				}
+
+				if len(ids) == len(values) {
+					groups = append(groups, ids)
+				}
+			}
+
+			continue
+		}
+
+		var field dal.Field
+		var isIndexed bool
+
+		for _, indexed := range secondaryIndexFields(collection) {
+			if indexed.Name == criterion.Field {
+				field = indexed
+				isIndexed = true
+				break
+			}
+		}
+
+		if !isIndexed {
+			continue
+		}
+
+		// single equality values on unscored fields are intersected by Redis
+		if !isRedisScoredField(field) && len(values) == 1 && (criterion.Operator == `` || criterion.Operator == `is`) {
+			if v, ok := redisIndexValue(values[0]); ok {
+				setKeys = append(setKeys, self.collectionKey(collection.Name, `index`, field.Name, v))
+			}
+
+			continue
+		}
+
+		var ids []string
+		usable := true
+
+		if criterion.Operator == `range` && isRedisScoredField(field) && len(values)%2 == 0 {
+			for i := 0; i < len(values); i += 2 {
+				lower, lok := redisScore(values[i])
+				upper, uok := redisScore(values[i+1])
+
+				if !lok || !uok {
+					usable = false
+					break
+				}
+
+				if found, err := self.scoreRange(conn, collection, field, lower, upper); err == nil {
+					ids = append(ids, found...)
+				} else {
+					return nil, false, err
+				}
+			}
+		} else {
+			for _, v := range values {
+				if found, ok, err := self.lookupIndex(conn, collection, field, criterion.Operator, v); err == nil {
+					if !ok {
+						usable = false
+						break
+					}
+
+					ids = append(ids, found...)
+				} else {
+					return nil, false, err
+				}
+			}
+		}
+
+		if usable {
+			groups = append(groups, ids)
+		}
+	}
+
+	if len(setKeys) > 0 {
+		if ids, err := redis.Strings(conn.Do(`SINTER`, setKeys...)); err == nil {
+			groups = append(groups, ids)
+		} else {
+			return nil, false, err
+		}
+	}
+
+	if len(groups) == 0 {
+		return nil, false, nil
+	}
+
+	candidates := sliceutil.Stringify(sliceutil.Unique(groups[0]))
+
+	for _, group := range groups[1:] {
+		intersection := make([]string, 0)
+
+		for _, id := range candidates {
+			if sliceutil.ContainsString(group, id) {
+				intersection = append(intersection, id)
+			}
+		}
+
+		candidates = intersection
+	}
+
+	return candidates, true, nil
+}
+
+// Returns the IDs of records whose indexed field could satisfy the operator for the given value, and
+// whether the index can answer that at all.  Sorted set bounds are always inclusive, since the
+// records are checked against the filter afterwards.
+func (self *RedisBackend) lookupIndex(conn redis.Conn, collection *dal.Collection, field dal.Field, operator string, value interface{}) ([]string, bool, error) {
+	if isRedisScoredField(field) {
+		if score, ok := redisScore(value); ok {
+			var ids []string
+			var err error
+
+			switch operator {
+			case ``, `is`:
+				ids, err = self.scoreRange(conn, collection, field, score, score)
+			case `gt`, `gte`:
+				ids, err = self.scoreRange(conn, collection, field, score, math.Inf(1))
+			case `lt`, `lte`:
+				ids, err = self.scoreRange(conn, collection, field, math.Inf(-1), score)
+			default:
+				return nil, false, nil
+			}
+
+			return ids, (err == nil), err
+		}
+	} else if operator == `` || operator == `is` {
+		if v, ok := redisIndexValue(value); ok {
+			ids, err := redis.Strings(conn.Do(`SMEMBERS`, self.collectionKey(collection.Name, `index`, field.Name, v)))
+			return ids, (err == nil), err
+		}
+	}
+
+	return nil, false, nil
+}
+
+func (self *RedisBackend) scoreRange(conn redis.Conn, collection *dal.Collection, field dal.Field, lower float64, upper float64) ([]string, error) {
+	return redis.Strings(conn.Do(
+		`ZRANGEBYSCORE`,
+		self.collectionKey(collection.Name, `scores`, field.Name),
+		redisScoreBound(lower),
+		redisScoreBound(upper),
+	))
+}
+
+func redisScoreBound(score float64) string {
+	if math.IsInf(score, 1) {
+		return `+inf`
+	} else if math.IsInf(score, -1) {
+		return `-inf`
+	}
+
+	return fmt.Sprintf("%v", score)
+}
@@ -0,0 +1,826 @@
+package backends
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/gomodule/redigo/redis"
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter"
+)
+
+var RedisDefaultAddress = `localhost:6379`
+var RedisDefaultKeyPrefix = `pivot`
+var RedisMaxIdleConnections = 8
+var RedisIdleTimeout = 5 * time.Minute
+var RedisScanCount = 1000
+var RedisMaxSaveAttempts = 10
+
+const FormatHash SerializationFormat = `hash`
+
+// the hash field that holds a record's raw data when records are stored as hashes
+const redisDataField = `_data`
+
+// keeps the collection's ID sequence at or above the given value
+var redisBumpSequence = redis.NewScript(1, `
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+
+if tonumber(ARGV[1]) > current then
+	redis.call('SET', KEYS[1], ARGV[1])
+end
+
+return 1
+`)
+
+// A backend that stores records in a Redis server.  Every key belongs to a collection by way of a
+// common prefix (e.g.: "pivot:users:record:42"), and collection definitions are stored in a hash
+// under "pivot:schema".  The prefix can be changed with the "prefix" option, and the database
+// number is taken from the path of the connection string (e.g.: redis://localhost:6379/2).
+//
+// Records are stored as JSON strings by default, or as hashes if the connection string specifies it
+// (e.g.: redis+hash://localhost).  Fields that are marked as indexed, unique, or key are kept in
+// secondary indexes: sorted sets (scored on the value) for numeric and time fields, and one set of
+// record IDs per distinct value for all others.
+type RedisBackend struct {
+	conn        dal.ConnectionString
+	pool        *redis.Pool
+	prefix      string
+	format      SerializationFormat
+	indexer     Indexer
+	aggregator  map[string]Aggregator
+	collections map[string]*dal.Collection
+	lock        sync.RWMutex
+}
+
+func NewRedisBackend(connection dal.ConnectionString) Backend {
+	return &RedisBackend{
+		conn:        connection,
+		format:      FormatJSON,
+		aggregator:  make(map[string]Aggregator),
+		collections: make(map[string]*dal.Collection),
+	}
+}
+
+func (self *RedisBackend) GetConnectionString() *dal.ConnectionString {
+	return &self.conn
+}
+
+func (self *RedisBackend) Ping(timeout time.Duration) error {
+	if self.pool == nil {
+		return fmt.Errorf("Backend not initialized")
+	}
+
+	conn := self.pool.Get()
+	defer conn.Close()
+
+	_, err := redis.DoWithTimeout(conn, timeout, `PING`)
+	return err
+}
+
+func (self *RedisBackend) RegisterCollection(definition *dal.Collection) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.collections[definition.Name] = definition
+}
+
+func (self *RedisBackend) SetIndexer(indexConnString dal.ConnectionString) error {
+	if indexer, err := MakeIndexer(indexConnString); err == nil {
+		self.indexer = indexer
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *RedisBackend) Initialize() error {
+	switch self.conn.Protocol() {
+	case ``, `json`:
+		self.format = FormatJSON
+	case `hash`:
+		self.format = FormatHash
+	default:
+		return fmt.Errorf("Unknown serialization format %q", self.conn.Protocol())
+	}
+
+	address := self.conn.Host()
+
+	if address == `` {
+		address = RedisDefaultAddress
+	} else if !strings.Contains(address, `:`) {
+		address = address + `:6379`
+	}
+
+	options := make([]redis.DialOption, 0)
+
+	if dataset := self.conn.Dataset(); dataset != `` {
+		if db, err := stringutil.ConvertToInteger(dataset); err == nil {
+			options = append(options, redis.DialDatabase(int(db)))
+		} else {
+			return fmt.Errorf("Invalid database number %q", dataset)
+		}
+	}
+
+	if _, password, ok := self.conn.Credentials(); ok && password != `` {
+		options = append(options, redis.DialPassword(password))
+	}
+
+	self.prefix = self.conn.OptString(`prefix`, RedisDefaultKeyPrefix)
+	self.pool = &redis.Pool{
+		MaxIdle:     RedisMaxIdleConnections,
+		IdleTimeout: RedisIdleTimeout,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial(`tcp`, address, options...)
+		},
+	}
+
+	// load the definitions of all collections stored in the database, unless they were already
+	// registered explicitly
+	conn := self.pool.Get()
+	defer conn.Close()
+
+	if schemas, err := redis.StringMap(conn.Do(`HGETALL`, self.schemaKey())); err == nil {
+		self.lock.Lock()
+		defer self.lock.Unlock()
+
+		for name, data := range schemas {
+			definition := new(dal.Collection)
+
+			if err := json.Unmarshal([]byte(data), definition); err != nil {
+				return fmt.Errorf("invalid definition for collection %q: %v", name, err)
+			}
+
+			if _, ok := self.collections[definition.Name]; !ok {
+				self.collections[definition.Name] = definition
+			}
+		}
+	} else {
+		return err
+	}
+
+	if self.indexer == nil {
+		self.indexer = self
+	}
+
+	if err := self.indexer.IndexInitialize(self); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (self *RedisBackend) Insert(name string, recordset *dal.RecordSet) error {
+	return self.save(name, recordset, true)
+}
+
+func (self *RedisBackend) Exists(name string, id interface{}) bool {
+	if definition, err := self.GetCollection(name); err == nil {
+		conn := self.pool.Get()
+		defer conn.Close()
+
+		exists, _ := redis.Bool(conn.Do(`EXISTS`, self.recordKey(definition, id)))
+		return exists
+	}
+
+	return false
+}
+
+func (self *RedisBackend) Retrieve(name string, id interface{}, fields ...string) (*dal.Record, error) {
+	if definition, err := self.GetCollection(name); err == nil {
+		conn := self.pool.Get()
+		defer conn.Close()
+
+		if record, err := self.getRecord(conn, definition, id); err == nil {
+			if record == nil {
+				return nil, fmt.Errorf("Record %q does not exist", id)
+			}
+
+			if len(fields) > 0 {
+				for key := range record.Fields {
+					if !sliceutil.ContainsString(fields, key) {
+						delete(record.Fields, key)
+					}
+				}
+			}
+
+			return record, nil
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+}
+
+// Records that already exist are updated with the fields given in the new record, and all others are
+// inserted.
+func (self *RedisBackend) Update(name string, recordset *dal.RecordSet, target ...string) error {
+	return self.save(name, recordset, false)
+}
+
+func (self *RedisBackend) Delete(name string, ids ...interface{}) error {
+	if definition, err := self.GetCollection(name); err == nil {
+		conn := self.pool.Get()
+		defer conn.Close()
+
+		for _, id := range ids {
+			if existing, err := self.getRecord(conn, definition, id); err == nil && existing != nil {
+				conn.Send(`MULTI`)
+				self.sendUnindex(conn, definition, existing)
+				conn.Send(`DEL`, self.recordKey(definition, existing.ID))
+
+				if _, err := conn.Do(`EXEC`); err != nil {
+					return err
+				}
+			} else if err != nil {
+				return err
+			}
+		}
+
+		// remove documents from index
+		if search := self.WithSearch(definition); search != nil {
+			if err := search.IndexRemove(definition, ids); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *RedisBackend) WithSearch(collection *dal.Collection, filters ...*filter.Filter) Indexer {
+	return self.indexer
+}
+
+func (self *RedisBackend) WithAggregator(collection *dal.Collection) Aggregator {
+	if aggregator, ok := self.aggregator[collection.GetAggregatorName()]; ok {
+		return aggregator
+	}
+
+	// use the indexer to perform aggregations if it supports them
+	if self.indexer != nil && self.indexer != Indexer(self) {
+		if aggregator, ok := self.indexer.(Aggregator); ok {
+			return aggregator
+		}
+	}
+
+	return nil
+}
+
+func (self *RedisBackend) ListCollections() ([]string, error) {
+	conn := self.pool.Get()
+	defer conn.Close()
+
+	if names, err := redis.Strings(conn.Do(`HKEYS`, self.schemaKey())); err == nil {
+		sort.Strings(names)
+		return names, nil
+	} else {
+		return nil, err
+	}
+}
+
+func (self *RedisBackend) CreateCollection(definition *dal.Collection) error {
+	conn := self.pool.Get()
+	defer conn.Close()
+
+	if data, err := json.Marshal(definition); err == nil {
+		if created, err := redis.Bool(conn.Do(`HSETNX`, self.schemaKey(), definition.Name, data)); err == nil {
+			if !created {
+				return fmt.Errorf("Collection %q already exists", definition.Name)
+			}
+		} else {
+			return err
+		}
+	} else {
+		return err
+	}
+
+	self.RegisterCollection(definition)
+	return nil
+}
+
+func (self *RedisBackend) DeleteCollection(name string) error {
+	conn := self.pool.Get()
+	defer conn.Close()
+
+	if removed, err := redis.Bool(conn.Do(`HDEL`, self.schemaKey(), name)); err == nil {
+		if !removed {
+			return dal.CollectionNotFound
+		}
+	} else {
+		return err
+	}
+
+	// remove the records, indexes, and sequence of the collection
+	if keys, err := self.scanKeys(conn, self.collectionKey(name, `*`)); err == nil {
+		for _, key := range keys {
+			if _, err := conn.Do(`DEL`, key); err != nil {
+				return err
+			}
+		}
+	} else {
+		return err
+	}
+
+	self.lock.Lock()
+	delete(self.collections, name)
+	self.lock.Unlock()
+
+	return nil
+}
+
+func (self *RedisBackend) GetCollection(name string) (*dal.Collection, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	if definition, ok := self.collections[name]; ok {
+		return definition, nil
+	} else {
+		return nil, dal.CollectionNotFound
+	}
+}
+
+func (self *RedisBackend) Flush() error {
+	if self.indexer != nil && self.indexer != Indexer(self) {
+		return self.indexer.FlushIndex()
+	}
+
+	return nil
+}
+
+// Formats, validates, and stores each record in the given recordset.  Each record and its index
+// entries are written in a single transaction.  Records without an ID are assigned the next one in
+// sequence if the collection's identity field is an integer, and the IDs of the records in the
+// recordset are updated to match what was stored.
+func (self *RedisBackend) save(name string, recordset *dal.RecordSet, insert bool) error {
+	if definition, err := self.GetCollection(name); err == nil {
+		conn := self.pool.Get()
+		defer conn.Close()
+
+		for _, record := range recordset.Records {
+			var saved bool
+			var sequence interface{}
+
+			// the transaction is aborted if the record or its unique values are changed by another
+			// client while it is being prepared, in which case it is prepared again from scratch
+			// (reusing any ID already taken from the sequence)
+			for attempt := 0; attempt < RedisMaxSaveAttempts && !saved; attempt++ {
+				if saved, err = self.saveRecord(conn, definition, record, insert, &sequence); err != nil {
+					return err
+				}
+			}
+
+			if !saved {
+				return fmt.Errorf("Record %v in collection %q was modified concurrently too many times", record.ID, name)
+			}
+		}
+
+		if search := self.WithSearch(definition); search != nil {
+			if err := search.Index(definition, recordset); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+// Makes one attempt at saving a record, returning false if the transaction was aborted because a
+// watched key changed.  The record's key and the index keys used to check its unique values are
+// watched before they are read.
+//
+// The next ID in the collection's sequence is taken before the transaction (since the record's keys
+// depend on it) and kept in sequence, so that later attempts at saving the same record reuse it.
+// IDs taken for records that are never saved are skipped.
+func (self *RedisBackend) saveRecord(conn redis.Conn, definition *dal.Collection, record *dal.Record, insert bool, sequence *interface{}) (bool, error) {
+	var stored *dal.Record
+	var watched string
+	var err error
+
+	defer conn.Do(`UNWATCH`)
+
+	// records are formatted in place, so each attempt works on a copy of the original
+	input := copyMemoryRecord(record)
+
+	if !insert && record.ID != nil {
+		watched = self.recordKey(definition, record.ID)
+
+		if _, err := conn.Do(`WATCH`, watched); err != nil {
+			return false, err
+		}
+
+		if stored, err = self.getRecord(conn, definition, memoryIdentity(definition, record.ID)); err != nil {
+			return false, err
+		}
+	}
+
+	r, err := memoryPrepareRecord(definition, input, insert, stored)
+
+	if err != nil {
+		return false, err
+	}
+
+	if r.ID == nil && definition.IdentityFieldType == dal.IntType {
+		if *sequence == nil {
+			if seq, err := redis.Int64(conn.Do(`INCR`, self.collectionKey(definition.Name, `sequence`))); err == nil {
+				*sequence = seq
+			} else {
+				return false, err
+			}
+		}
+
+		r.ID = *sequence
+	}
+
+	if r.ID == nil {
+		return false, fmt.Errorf("Cannot save a record without an ID to collection %q", definition.Name)
+	}
+
+	existing := stored
+
+	// the stored record was already read (and its key watched) unless the record is being inserted
+	// or its ID was changed while it was being prepared
+	if key := self.recordKey(definition, r.ID); key != watched {
+		if _, err := conn.Do(`WATCH`, key); err != nil {
+			return false, err
+		}
+
+		if existing, err = self.getRecord(conn, definition, r.ID); err != nil {
+			return false, err
+		}
+	}
+
+	// merge the new fields into the existing record
+	if existing != nil {
+		if insert {
+			return false, fmt.Errorf("Record %q already exists", r.ID)
+		}
+
+		merged := copyMemoryRecord(existing)
+
+		for k, v := range r.Fields {
+			merged.Fields[k] = v
+		}
+
+		if len(r.Data) > 0 {
+			merged.Data = r.Data
+		}
+
+		r = merged
+	}
+
+	if keys := self.uniqueIndexKeys(definition, r); len(keys) > 0 {
+		if _, err := conn.Do(`WATCH`, redis.Args{}.AddFlat(keys)...); err != nil {
+			return false, err
+		}
+	}
+
+	if err := self.checkUnique(conn, definition, r); err != nil {
+		return false, err
+	}
+
+	conn.Send(`MULTI`)
+
+	if existing != nil {
+		self.sendUnindex(conn, definition, existing)
+	}
+
+	if err := self.sendRecord(conn, definition, r); err != nil {
+		conn.Do(`DISCARD`)
+		return false, err
+	}
+
+	self.sendIndex(conn, definition, r)
+
+	// a nil reply means a watched key was modified and nothing was written
+	if reply, err := conn.Do(`EXEC`); err != nil {
+		return false, err
+	} else if reply == nil {
+		return false, nil
+	}
+
+	// keep the sequence ahead of any explicitly-given integer IDs
+	if id, ok := r.ID.(int64); ok {
+		if _, err := redisBumpSequence.Do(conn, self.collectionKey(definition.Name, `sequence`), id); err != nil {
+			return false, err
+		}
+	}
+
+	record.ID = r.ID
+	record.Fields = input.Fields
+	return true, nil
+}
+
+func (self *RedisBackend) schemaKey() string {
+	return self.prefix + `:schema`
+}
+
+func (self *RedisBackend) collectionKey(collection string, parts ...string) string {
+	return strings.Join(append([]string{self.prefix, collection}, parts...), `:`)
+}
+
+func (self *RedisBackend) recordKey(collection *dal.Collection, id interface{}) string {
+	return self.collectionKey(collection.Name, `record`, memoryKey(memoryIdentity(collection, id)))
+}
+
+// retrieves a record, which will be nil if it does not exist
+func (self *RedisBackend) getRecord(conn redis.Conn, collection *dal.Collection, id interface{}) (*dal.Record, error) {
+	key := self.recordKey(collection, id)
+
+	if kind, err := redis.String(conn.Do(`TYPE`, key)); err == nil {
+		var record *dal.Record
+
+		switch kind {
+		case `none`:
+			return nil, nil
+		case `hash`:
+			if values, err := redis.StringMap(conn.Do(`HGETALL`, key)); err == nil {
+				record = decodeRedisHash(collection, values)
+			} else {
+				return nil, err
+			}
+		default:
+			if data, err := redis.Bytes(conn.Do(`GET`, key)); err == nil {
+				record = dal.NewRecord(nil)
+
+				if err := json.Unmarshal(data, record); err != nil {
+					return nil, err
+				}
+
+				if record.Fields == nil {
+					record.Fields = make(map[string]interface{})
+				}
+
+				for k, v := range record.Fields {
+					record.Fields[k] = memoryJSONFieldValue(collection, k, v)
+				}
+			} else {
+				return nil, err
+			}
+		}
+
+		// the ID is always taken from the key, since hashes don't store it
+		record.ID = memoryIdentity(collection, id)
+		return record, nil
+	} else {
+		return nil, err
+	}
+}
+
+// queues the commands that write the given record in the configured format
+func (self *RedisBackend) sendRecord(conn redis.Conn, collection *dal.Collection, record *dal.Record) error {
+	key := self.recordKey(collection, record.ID)
+
+	switch self.format {
+	case FormatHash:
+		args := redis.Args{key}
+
+		for k, v := range record.Fields {
+			if value, ok, err := redisHashValue(v); err == nil {
+				if ok {
+					args = args.Add(k, value)
+				}
+			} else {
+				return err
+			}
+		}
+
+		if len(record.Data) > 0 {
+			args = args.Add(redisDataField, record.Data)
+		}
+
+		// the existing hash is replaced so that fields set to nil are removed
+		conn.Send(`DEL`, key)
+
+		if len(args) > 1 {
+			conn.Send(`HMSET`, args...)
+		} else {
+			conn.Send(`HSET`, key, redisDataField, ``)
+		}
+
+	default:
+		document := map[string]interface{}{
+			`id`:     record.ID,
+			`fields`: record.Fields,
+		}
+
+		if len(record.Data) > 0 {
+			document[`data`] = record.Data
+		}
+
+		if data, err := json.Marshal(document); err == nil {
+			conn.Send(`SET`, key, data)
+		} else {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// returns an error if any of the record's unique fields has a value that another record already has
+func (self *RedisBackend) checkUnique(conn redis.Conn, collection *dal.Collection, record *dal.Record) error {
+	for _, field := range secondaryIndexFields(collection) {
+		if !field.Unique {
+			continue
+		}
+
+		value := record.Get(field.Name)
+
+		if ids, ok, err := self.lookupIndex(conn, collection, field, `is`, value); err == nil && ok {
+			for _, id := range ids {
+				if id != memoryKey(record.ID) {
+					return fmt.Errorf("Value %v for field %q already exists in collection %q", value, field.Name, collection.Name)
+				}
+			}
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// returns the index keys that checkUnique reads for the given record
+func (self *RedisBackend) uniqueIndexKeys(collection *dal.Collection, record *dal.Record) []string {
+	keys := make([]string, 0)
+
+	for _, field := range secondaryIndexFields(collection) {
+		if !field.Unique {
+			continue
+		}
+
+		value := record.Get(field.Name)
+
+		if isRedisScoredField(field) {
+			if _, ok := redisScore(value); ok {
+				keys = append(keys, self.collectionKey(collection.Name, `scores`, field.Name))
+			}
+		} else if v, ok := redisIndexValue(value); ok {
+			keys = append(keys, self.collectionKey(collection.Name, `index`, field.Name, v))
+		}
+	}
+
+	return keys
+}
+
+func (self *RedisBackend) sendIndex(conn redis.Conn, collection *dal.Collection, record *dal.Record) {
+	self.sendIndexCommands(conn, collection, record, false)
+}
+
+func (self *RedisBackend) sendUnindex(conn redis.Conn, collection *dal.Collection, record *dal.Record) {
+	self.sendIndexCommands(conn, collection, record, true)
+}
+
+func (self *RedisBackend) sendIndexCommands(conn redis.Conn, collection *dal.Collection, record *dal.Record, remove bool) {
+	id := memoryKey(record.ID)
+
+	for _, field := range secondaryIndexFields(collection) {
+		value := record.Get(field.Name)
+
+		if isRedisScoredField(field) {
+			if score, ok := redisScore(value); ok {
+				key := self.collectionKey(collection.Name, `scores`, field.Name)
+
+				if remove {
+					conn.Send(`ZREM`, key, id)
+				} else {
+					conn.Send(`ZADD`, key, score, id)
+				}
+			}
+		} else if v, ok := redisIndexValue(value); ok {
+			key := self.collectionKey(collection.Name, `index`, field.Name, v)
+
+			if remove {
+				conn.Send(`SREM`, key, id)
+			} else {
+				conn.Send(`SADD`, key, id)
+			}
+		}
+	}
+}
+
+// returns all keys matching the given pattern
+func (self *RedisBackend) scanKeys(conn redis.Conn, pattern string) ([]string, error) {
+	keys := make([]string, 0)
+	cursor := `0`
+
+	for {
+		if values, err := redis.Values(conn.Do(`SCAN`, cursor, `MATCH`, pattern, `COUNT`, RedisScanCount)); err == nil {
+			var page []string
+
+			if _, err := redis.Scan(values, &cursor, &page); err != nil {
+				return nil, err
+			}
+
+			keys = append(keys, page...)
+
+			if cursor == `0` {
+				return keys, nil
+			}
+		} else {
+			return nil, err
+		}
+	}
+}
+
+func isRedisScoredField(field dal.Field) bool {
+	switch field.Type {
+	case dal.IntType, dal.FloatType, dal.TimeType:
+		return true
+	}
+
+	return false
+}
+
+// numbers are scored on their value, and times on the (fractional) number of seconds since the epoch
+func redisScore(value interface{}) (float64, bool) {
+	if value == nil {
+		return 0, false
+	} else if t, ok := value.(time.Time); ok {
+		return float64(t.UnixNano()) / float64(time.Second), true
+	} else if isMemoryNumber(value) {
+		if v, err := stringutil.ConvertToFloat(value); err == nil {
+			return v, true
+		}
+	}
+
+	return 0, false
+}
+
+// Returns the form of a value used in the key of an equality index set, and whether the value can
+// be indexed at all.
+func redisIndexValue(value interface{}) (string, bool) {
+	switch value.(type) {
+	case nil, map[string]interface{}, []interface{}:
+		return ``, false
+	case time.Time:
+		return value.(time.Time).UTC().Format(time.RFC3339Nano), true
+	}
+
+	return fmt.Sprintf("%v", value), true
+}
+
+// Returns the string form of a value stored in a hash field, and whether it should be stored at
+// all.  Nested values are stored as JSON.
+func redisHashValue(value interface{}) (string, bool, error) {
+	switch v := value.(type) {
+	case nil:
+		return ``, false, nil
+	case string:
+		return v, true, nil
+	case []byte:
+		return string(v), true, nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), true, nil
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprintf("%v", v), true, nil
+	default:
+		if data, err := json.Marshal(v); err == nil {
+			return string(data), true, nil
+		} else {
+			return ``, false, err
+		}
+	}
+}
+
+// Converts the string values of a stored hash to the types declared in the collection's schema.
+// Fields that aren't in the schema are converted to the most appropriate native type.
+func decodeRedisHash(collection *dal.Collection, values map[string]string) *dal.Record {
+	record := dal.NewRecord(nil)
+
+	for key, value := range values {
+		if key == redisDataField {
+			record.Data = []byte(value)
+			continue
+		}
+
+		if field, ok := collection.GetField(key); ok {
+			switch field.Type {
+			case dal.ObjectType, dal.ArrayType:
+				var decoded interface{}
+
+				if err := json.Unmarshal([]byte(value), &decoded); err == nil {
+					record.Fields[key] = memoryFieldValue(collection, key, decoded)
+					continue
+				}
+			}
+
+			record.Fields[key] = memoryFieldValue(collection, key, value)
+		} else {
+			record.Fields[key] = stringutil.Autotype(value)
+		}
+	}
+
+	return record
+}
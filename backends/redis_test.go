@@ -0,0 +1,234 @@
+package backends
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisBackendStorage(t *testing.T) {
+	for _, scheme := range []string{`redis`, `redis+hash`} {
+		assert := require.New(t)
+
+		server, err := miniredis.Run()
+		assert.NoError(err)
+		defer server.Close()
+
+		cs, err := dal.ParseConnectionString(scheme + `://` + server.Addr() + `/0`)
+		assert.NoError(err)
+
+		backend := NewRedisBackend(cs).(*RedisBackend)
+		assert.NoError(backend.Initialize())
+
+		collection := dal.NewCollection(`TestRedisBackendStorage`).
+			AddFields(dal.Field{
+				Name:    `color`,
+				Type:    dal.StringType,
+				Indexed: true,
+			}, dal.Field{
+				Name:    `inventory`,
+				Type:    dal.IntType,
+				Indexed: true,
+			}, dal.Field{
+				Name: `created_at`,
+				Type: dal.TimeType,
+			}, dal.Field{
+				Name: `tags`,
+				Type: dal.ArrayType,
+			})
+
+		created := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+
+		assert.NoError(backend.CreateCollection(collection))
+		assert.NoError(backend.Insert(collection.Name, dal.NewRecordSet(
+			dal.NewRecord(1).Set(`color`, `red`).Set(`inventory`, 34),
+			dal.NewRecord(2).Set(`inventory`, 9).Set(`tags`, []string{`a`, `b`}).Set(`created_at`, created),
+		)), scheme)
+
+		// values are returned as the types declared in the schema, regardless of the storage format
+		record, err := backend.Retrieve(collection.Name, `2`)
+		assert.NoError(err)
+		assert.Equal(int64(2), record.ID)
+		assert.Equal(int64(9), record.Get(`inventory`))
+		assert.Equal([]interface{}{`a`, `b`}, record.Get(`tags`))
+		assert.True(created.Equal(record.Get(`created_at`).(time.Time)))
+		assert.Nil(record.Get(`color`))
+
+		// indexed criteria are answered from sets and sorted sets
+		members, err := server.Members(`pivot:TestRedisBackendStorage:index:color:red`)
+		assert.NoError(err)
+		assert.Equal([]string{`1`}, members)
+		assert.True(server.Exists(`pivot:TestRedisBackendStorage:scores:inventory`))
+
+		conn := backend.pool.Get()
+		ids, ok, err := backend.candidateIds(conn, collection, filter.MustParse(`color/red/inventory/gt:20`))
+		assert.NoError(err)
+		assert.True(ok)
+		assert.Equal([]string{`1`}, ids)
+
+		_, ok, err = backend.candidateIds(conn, collection, filter.MustParse(`color/prefix:r`))
+		assert.NoError(err)
+		assert.False(ok)
+		conn.Close()
+
+		// updates and deletes keep the indexes current
+		assert.NoError(backend.Update(collection.Name, dal.NewRecordSet(dal.NewRecord(1).Set(`color`, `purple`))))
+		assert.False(server.Exists(`pivot:TestRedisBackendStorage:index:color:red`))
+
+		assert.NoError(backend.Delete(collection.Name, 1))
+		assert.False(server.Exists(`pivot:TestRedisBackendStorage:index:color:purple`))
+
+		// new records continue the sequence after explicitly-given IDs
+		recordset := dal.NewRecordSet(dal.NewRecord(nil).Set(`color`, `blue`))
+		assert.NoError(backend.Insert(collection.Name, recordset))
+		assert.Equal(int64(3), recordset.Records[0].ID)
+	}
+}
+
+func TestRedisBackendCollections(t *testing.T) {
+	assert := require.New(t)
+
+	server, err := miniredis.Run()
+	assert.NoError(err)
+	defer server.Close()
+
+	open := func() *RedisBackend {
+		cs, err := dal.ParseConnectionString(`redis://` + server.Addr() + `/3?prefix=test`)
+		assert.NoError(err)
+
+		backend := NewRedisBackend(cs)
+		assert.NoError(backend.Initialize())
+
+		return backend.(*RedisBackend)
+	}
+
+	backend := open()
+
+	collection := dal.NewCollection(`TestRedisBackendCollections`).
+		AddFields(dal.Field{
+			Name:    `name`,
+			Type:    dal.StringType,
+			Indexed: true,
+		})
+
+	assert.NoError(backend.CreateCollection(collection))
+	assert.NoError(backend.Insert(collection.Name, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `first`),
+	)))
+
+	// keys are written to the given database, under the given prefix
+	server.Select(3)
+	assert.True(server.Exists(`test:schema`))
+	assert.True(server.Exists(`test:TestRedisBackendCollections:record:1`))
+	assert.True(server.Exists(`test:TestRedisBackendCollections:index:name:first`))
+
+	// collection definitions are loaded from the server
+	restored := open()
+
+	names, err := restored.ListCollections()
+	assert.NoError(err)
+	assert.Equal([]string{collection.Name}, names)
+
+	definition, err := restored.GetCollection(collection.Name)
+	assert.NoError(err)
+	assert.True(definition.Fields[0].Indexed)
+
+	record, err := restored.Retrieve(collection.Name, 1)
+	assert.NoError(err)
+	assert.Equal(`first`, record.Get(`name`))
+
+	// deleting a collection removes all of its keys
+	assert.NoError(restored.DeleteCollection(collection.Name))
+	assert.Error(restored.DeleteCollection(collection.Name))
+	assert.Empty(server.Keys())
+}
+
+func TestRedisBackendConcurrentSaves(t *testing.T) {
+	assert := require.New(t)
+
+	server, err := miniredis.Run()
+	assert.NoError(err)
+	defer server.Close()
+
+	cs, err := dal.ParseConnectionString(`redis://` + server.Addr() + `/0`)
+	assert.NoError(err)
+
+	backend := NewRedisBackend(cs)
+	assert.NoError(backend.Initialize())
+
+	collection := dal.NewCollection(`TestRedisBackendConcurrentSaves`).
+		AddFields(dal.Field{
+			Name:   `sku`,
+			Type:   dal.StringType,
+			Unique: true,
+		})
+
+	names := []string{`a`, `b`, `c`, `d`, `e`, `f`}
+
+	for _, name := range names {
+		collection.AddFields(dal.Field{
+			Name: name,
+			Type: dal.IntType,
+		})
+	}
+
+	assert.NoError(backend.CreateCollection(collection))
+	assert.NoError(backend.Insert(collection.Name, dal.NewRecordSet(dal.NewRecord(1))))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(names))
+
+	// concurrent updates to different fields of the same record are all kept
+	for i, name := range names {
+		wg.Add(1)
+
+		go func(i int, name string) {
+			defer wg.Done()
+			errs <- backend.Update(collection.Name, dal.NewRecordSet(dal.NewRecord(1).Set(name, i)))
+		}(i, name)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(err)
+	}
+
+	record, err := backend.Retrieve(collection.Name, 1)
+	assert.NoError(err)
+
+	for i, name := range names {
+		assert.Equal(int64(i), record.Get(name), name)
+	}
+
+	// only one of several concurrent inserts with the same unique value succeeds
+	errs = make(chan error, len(names))
+
+	for i := range names {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+			errs <- backend.Insert(collection.Name, dal.NewRecordSet(dal.NewRecord(i+10).Set(`sku`, `x1`)))
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	var saved int
+
+	for err := range errs {
+		if err == nil {
+			saved++
+		}
+	}
+
+	assert.Equal(1, saved)
+}
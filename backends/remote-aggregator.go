@@ -0,0 +1,150 @@
+package backends
+
+// this file satifies the Aggregator interface for RemoteBackend
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter"
+)
+
+func (self *RemoteBackend) Sum(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	return self.aggregateFloat(collection, filter.Sum, field, f)
+}
+
+func (self *RemoteBackend) Count(collection *dal.Collection, flt ...*filter.Filter) (uint64, error) {
+	if value, err := self.aggregate(collection, filter.Count, collection.IdentityField, flt); err == nil {
+		if v, err := stringutil.ConvertToInteger(value); err == nil {
+			return uint64(v), nil
+		} else {
+			return 0, err
+		}
+	} else {
+		return 0, err
+	}
+}
+
+func (self *RemoteBackend) Minimum(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	return self.aggregateFloat(collection, filter.Minimum, field, f)
+}
+
+func (self *RemoteBackend) Maximum(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	return self.aggregateFloat(collection, filter.Maximum, field, f)
+}
+
+func (self *RemoteBackend) Average(collection *dal.Collection, field string, f ...*filter.Filter) (float64, error) {
+	return self.aggregateFloat(collection, filter.Average, field, f)
+}
+
+// Asks the server to group the records matching the filter, passing along the filter's Having
+// criteria, sort order, and bounds.
+func (self *RemoteBackend) GroupBy(collection *dal.Collection, groupBy []string, aggregates []filter.Aggregate, flt ...*filter.Filter) (*dal.RecordSet, error) {
+	f := memoryAggregateFilter(flt)
+	spec, err := remoteFilterSpec(f)
+
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, len(aggregates))
+	escaped := make([]string, len(groupBy))
+
+	for i, aggregate := range aggregates {
+		keys[i] = aggregate.Key()
+	}
+
+	for i, field := range groupBy {
+		escaped[i] = url.PathEscape(field)
+	}
+
+	query := url.Values{
+		`fn`:     []string{strings.Join(keys, `,`)},
+		`q`:      []string{spec},
+		`limit`:  []string{fmt.Sprintf("%d", f.Limit)},
+		`offset`: []string{fmt.Sprintf("%d", f.Offset)},
+	}
+
+	if len(f.Sort) > 0 {
+		query.Set(`sort`, strings.Join(f.Sort, `,`))
+	}
+
+	var recordset dal.RecordSet
+
+	if err := self.request(
+		`GET`,
+		remotePath(`collections`, collection.Name, `group`)+`/`+strings.Join(escaped, `/`),
+		query,
+		nil,
+		&recordset,
+	); err != nil {
+		return nil, err
+	}
+
+	// values decoded from JSON are converted back to the types of the fields they came from
+	for _, record := range recordset.Records {
+		if record.ID != nil {
+			record.ID = memoryIdentity(collection, record.ID)
+		}
+
+		for name, value := range record.Fields {
+			field := name
+
+			for _, aggregate := range aggregates {
+				if filter.AggregateResultName(aggregates, aggregate) == name {
+					field = aggregate.Field
+					break
+				}
+			}
+
+			record.Fields[name] = memoryJSONFieldValue(collection, field, value)
+		}
+	}
+
+	return &recordset, nil
+}
+
+func (self *RemoteBackend) AggregatorConnectionString() *dal.ConnectionString {
+	return &self.conn
+}
+
+func (self *RemoteBackend) AggregatorInitialize(parent Backend) error {
+	return nil
+}
+
+func (self *RemoteBackend) aggregateFloat(collection *dal.Collection, aggregation filter.Aggregation, field string, flt []*filter.Filter) (float64, error) {
+	if value, err := self.aggregate(collection, aggregation, field, flt); err == nil {
+		return stringutil.ConvertToFloat(value)
+	} else {
+		return 0, err
+	}
+}
+
+// asks the server for the given aggregation of a field across all records matching the filter
+func (self *RemoteBackend) aggregate(collection *dal.Collection, aggregation filter.Aggregation, field string, flt []*filter.Filter) (interface{}, error) {
+	spec, err := remoteFilterSpec(memoryAggregateFilter(flt))
+
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]map[string]interface{})
+
+	if err := self.request(
+		`GET`,
+		remotePath(`collections`, collection.Name, `aggregate`, field),
+		url.Values{
+			`fn`: []string{aggregation.String()},
+			`q`:  []string{spec},
+		},
+		nil,
+		&results,
+	); err != nil {
+		return nil, err
+	}
+
+	return results[field][aggregation.String()], nil
+}
@@ -0,0 +1,237 @@
+package backends
+
+// this file satifies the Indexer interface for RemoteBackend
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter"
+)
+
+func (self *RemoteBackend) IndexConnectionString() *dal.ConnectionString {
+	return &self.conn
+}
+
+func (self *RemoteBackend) IndexInitialize(_ Backend) error {
+	return nil
+}
+
+func (self *RemoteBackend) GetBackend() Backend {
+	return self
+}
+
+func (self *RemoteBackend) IndexExists(collection *dal.Collection, id interface{}) bool {
+	return self.Exists(collection.GetIndexName(), id)
+}
+
+func (self *RemoteBackend) IndexRetrieve(collection *dal.Collection, id interface{}) (*dal.Record, error) {
+	return self.Retrieve(collection.GetIndexName(), id)
+}
+
+// The server keeps its own indexes up to date as records are written to it, so there is nothing to
+// do here.
+func (self *RemoteBackend) IndexRemove(collection *dal.Collection, ids []interface{}) error {
+	return nil
+}
+
+// The server keeps its own indexes up to date as records are written to it, so there is nothing to
+// do here.
+func (self *RemoteBackend) Index(collection *dal.Collection, records *dal.RecordSet) error {
+	return nil
+}
+
+// Performs the query on the server and calls resultFn for each record in the page of results that
+// it returns.
+func (self *RemoteBackend) QueryFunc(collection *dal.Collection, f *filter.Filter, resultFn IndexResultFunc) error {
+	if f == nil {
+		f = filter.All()
+	}
+
+	querylog.Debugf("[%T] Query using filter %q", self, f.String())
+
+	spec, err := remoteFilterSpec(f)
+
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{
+		`limit`:  []string{fmt.Sprintf("%d", f.Limit)},
+		`offset`: []string{fmt.Sprintf("%d", f.Offset)},
+	}
+
+	if len(f.Sort) > 0 {
+		query.Set(`sort`, strings.Join(f.Sort, `,`))
+	}
+
+	if len(f.Fields) > 0 {
+		query.Set(`fields`, strings.Join(f.Fields, `,`))
+	}
+
+	var recordset dal.RecordSet
+
+	if err := self.request(
+		`GET`,
+		remotePath(`collections`, collection.Name, `where`)+`/`+remoteEscapeSpec(spec),
+		query,
+		nil,
+		&recordset,
+	); err != nil {
+		return err
+	}
+
+	page := IndexPage{
+		Page:         recordset.Page,
+		TotalPages:   recordset.TotalPages,
+		Limit:        f.Limit,
+		Offset:       f.Offset,
+		TotalResults: recordset.ResultCount,
+	}
+
+	for _, record := range recordset.Records {
+		if err := resultFn(remoteRecord(collection, record), nil, page); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (self *RemoteBackend) Query(collection *dal.Collection, f *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error) {
+	if f == nil {
+		f = filter.All()
+	}
+
+	// the server returns complete records, so there is no need to retrieve them again
+	query := filter.Copy(f)
+	query.Options = make(map[string]interface{})
+
+	for k, v := range f.Options {
+		query.Options[k] = v
+	}
+
+	query.Options[`ForceIndexRecord`] = true
+
+	return DefaultQueryImplementation(self, collection, &query, resultFns...)
+}
+
+func (self *RemoteBackend) ListValues(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]interface{}, error) {
+	if f == nil {
+		f = filter.All()
+	}
+
+	spec, err := remoteFilterSpec(f)
+
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string][]interface{})
+	escaped := make([]string, len(fields))
+
+	for i, field := range fields {
+		escaped[i] = url.PathEscape(field)
+	}
+
+	if err := self.request(
+		`GET`,
+		remotePath(`collections`, collection.Name, `list`)+`/`+strings.Join(escaped, `/`),
+		url.Values{
+			`q`: []string{spec},
+		},
+		nil,
+		&values,
+	); err != nil {
+		return nil, err
+	}
+
+	for field, fieldValues := range values {
+		for i, value := range fieldValues {
+			if field == collection.IdentityField {
+				fieldValues[i] = memoryIdentity(collection, value)
+			} else {
+				fieldValues[i] = memoryJSONFieldValue(collection, field, value)
+			}
+		}
+	}
+
+	return values, nil
+}
+
+// The server deletes records by ID, so the IDs of the matching records are queried first.
+func (self *RemoteBackend) DeleteQuery(collection *dal.Collection, f *filter.Filter) error {
+	ids := make([]interface{}, 0)
+
+	if err := self.QueryFunc(collection, f, func(record *dal.Record, err error, page IndexPage) error {
+		if err == nil {
+			ids = append(ids, record.ID)
+		}
+
+		return err
+	}); err == nil {
+		return self.Delete(collection.Name, ids...)
+	} else {
+		return err
+	}
+}
+
+func (self *RemoteBackend) FlushIndex() error {
+	return nil
+}
+
+// Renders a filter in the syntax accepted by filter.Parse, including its Having criteria.  Values
+// containing the characters used to separate criteria and values cannot be represented, and times
+// are rendered in a format that the server can parse.
+func remoteFilterSpec(f *filter.Filter) (string, error) {
+	if f.IsMatchAll() && len(f.Having) == 0 {
+		return filter.AllValue, nil
+	}
+
+	criteria := make([]string, 0)
+	all := make([]filter.Criterion, 0)
+
+	if !f.IsMatchAll() {
+		all = append(all, f.Criteria...)
+	}
+
+	for _, criterion := range f.Having {
+		criterion.Field = filter.HavingPrefix + criterion.Field
+		all = append(all, criterion)
+	}
+
+	for _, criterion := range all {
+		c := criterion
+		c.Values = make([]interface{}, len(criterion.Values))
+
+		for i, value := range criterion.Values {
+			if tm, ok := value.(time.Time); ok {
+				value = tm.Format(time.RFC3339Nano)
+			}
+
+			if v := fmt.Sprintf("%v", value); strings.Contains(v, filter.CriteriaSeparator) || strings.Contains(v, filter.ValueSeparator) {
+				return ``, fmt.Errorf("Cannot send the value %q of field %q to a remote server", v, criterion.Field)
+			}
+
+			c.Values[i] = value
+		}
+
+		criteria = append(criteria, c.String())
+	}
+
+	return strings.Join(criteria, filter.CriteriaSeparator), nil
+}
+
+// escapes each term of a filter spec so that it can be used in a URL path
+func remoteEscapeSpec(spec string) string {
+	terms := strings.Split(spec, filter.CriteriaSeparator)
+
+	for i, term := range terms {
+		terms[i] = url.PathEscape(term)
+	}
+
+	return strings.Join(terms, `/`)
+}
@@ -0,0 +1,473 @@
+package backends
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter"
+)
+
+var RemoteRequestTimeout = 30 * time.Second
+var RemoteConnectTimeout = 3 * time.Second
+var RemoteTLSTimeout = 10 * time.Second
+var RemoteResponseHeaderTimeout = 10 * time.Second
+
+// A backend that uses the REST API of another Pivot server (e.g.: pivot+http://pivot.local:29029)
+// to store and query records, so that services can use the Go API without having credentials for
+// the database behind that server.  Use "pivot+https://" to connect over TLS.  If the connection
+// string includes a path, it is used as a prefix for all API calls, and credentials are sent using
+// HTTP Basic authentication.
+//
+// Queries, listing values, and aggregations are all performed by the server.  Collection
+// definitions are retrieved from the server the first time they are used, unless they have been
+// registered locally.
+type RemoteBackend struct {
+	conn        dal.ConnectionString
+	indexer     Indexer
+	client      *http.Client
+	baseURL     string
+	collections map[string]*dal.Collection
+	lock        sync.RWMutex
+}
+
+func NewRemoteBackend(connection dal.ConnectionString) Backend {
+	return &RemoteBackend{
+		conn:        connection,
+		collections: make(map[string]*dal.Collection),
+	}
+}
+
+func (self *RemoteBackend) GetConnectionString() *dal.ConnectionString {
+	return &self.conn
+}
+
+func (self *RemoteBackend) Ping(timeout time.Duration) error {
+	if self.client == nil {
+		return fmt.Errorf("Backend not initialized")
+	}
+
+	client := *self.client
+	client.Timeout = timeout
+
+	if req, err := self.newRequest(`GET`, `/api/status`, nil, nil); err == nil {
+		if response, err := client.Do(req); err == nil {
+			defer response.Body.Close()
+
+			return remoteResponseError(response)
+		} else {
+			return err
+		}
+	} else {
+		return err
+	}
+}
+
+func (self *RemoteBackend) RegisterCollection(definition *dal.Collection) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.collections[definition.Name] = definition
+}
+
+func (self *RemoteBackend) SetIndexer(indexConnString dal.ConnectionString) error {
+	if indexer, err := MakeIndexer(indexConnString); err == nil {
+		self.indexer = indexer
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *RemoteBackend) Initialize() error {
+	switch protocol := self.conn.Protocol(); protocol {
+	case ``, `http`, `https`:
+		host := self.conn.Host()
+
+		if host == `` {
+			return fmt.Errorf("A Pivot server address is required")
+		}
+
+		self.baseURL = fmt.Sprintf("%s://%s", sliceutil.OrString(protocol, `http`), host)
+
+		if dataset := strings.Trim(self.conn.Dataset(), `/`); dataset != `` {
+			self.baseURL += `/` + dataset
+		}
+	default:
+		return fmt.Errorf("Unsupported protocol %q", protocol)
+	}
+
+	self.client = &http.Client{
+		Timeout: RemoteRequestTimeout,
+		Transport: &http.Transport{
+			Dial: (&net.Dialer{
+				Timeout:   RemoteConnectTimeout,
+				KeepAlive: 30 * time.Second,
+			}).Dial,
+			TLSHandshakeTimeout:   RemoteTLSTimeout,
+			ResponseHeaderTimeout: RemoteResponseHeaderTimeout,
+		},
+	}
+
+	if err := self.Ping(InitialPingTimeout); err != nil {
+		return err
+	}
+
+	if self.indexer == nil {
+		self.indexer = self
+	}
+
+	if err := self.indexer.IndexInitialize(self); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (self *RemoteBackend) Exists(name string, id interface{}) bool {
+	if _, err := self.Retrieve(name, id); err == nil {
+		return true
+	}
+
+	return false
+}
+
+func (self *RemoteBackend) Retrieve(name string, id interface{}, fields ...string) (*dal.Record, error) {
+	if collection, err := self.GetCollection(name); err == nil {
+		query := make(url.Values)
+
+		if len(fields) > 0 {
+			query.Set(`fields`, strings.Join(fields, `,`))
+		}
+
+		var record dal.Record
+
+		if err := self.request(`GET`, remotePath(`collections`, name, `records`)+`/`+remoteIdentity(id, `:`), query, nil, &record); err == nil {
+			return remoteRecord(collection, &record), nil
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+}
+
+func (self *RemoteBackend) Insert(name string, recordset *dal.RecordSet) error {
+	if collection, err := self.GetCollection(name); err == nil {
+		var inserted dal.RecordSet
+
+		if err := remotePrepareRecords(collection, recordset, nil); err != nil {
+			return err
+		}
+
+		if err := self.request(`POST`, remotePath(`collections`, name, `records`), nil, recordset, &inserted); err != nil {
+			return err
+		}
+
+		// pick up any IDs that were assigned by the server
+		for i, record := range inserted.Records {
+			if i < len(recordset.Records) && recordset.Records[i].ID == nil && record != nil {
+				recordset.Records[i].ID = memoryIdentity(collection, record.ID)
+			}
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *RemoteBackend) Update(name string, recordset *dal.RecordSet, target ...string) error {
+	if len(target) > 0 {
+		return fmt.Errorf("%T does not support update targets", self)
+	}
+
+	if collection, err := self.GetCollection(name); err == nil {
+		if err := remotePrepareRecords(collection, recordset, func(id interface{}) (*dal.Record, error) {
+			return self.Retrieve(name, id)
+		}); err != nil {
+			return err
+		}
+
+		return self.request(`PUT`, remotePath(`collections`, name, `records`), nil, recordset, nil)
+	} else {
+		return err
+	}
+}
+
+func (self *RemoteBackend) Delete(name string, ids ...interface{}) error {
+	for _, id := range ids {
+		if f, ok := id.(*filter.Filter); ok {
+			if collection, err := self.GetCollection(name); err == nil {
+				if err := self.DeleteQuery(collection, f); err != nil {
+					return err
+				}
+			} else {
+				return err
+			}
+		} else if err := self.request(`DELETE`, remotePath(`collections`, name, `records`)+`/`+remoteIdentity(id, `/`), nil, nil, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (self *RemoteBackend) CreateCollection(definition *dal.Collection) error {
+	if err := self.request(`POST`, `/api/schema`, nil, definition, nil); err == nil {
+		self.RegisterCollection(definition)
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *RemoteBackend) DeleteCollection(name string) error {
+	if err := self.request(`DELETE`, remotePath(`schema`, name), nil, nil, nil); err == nil {
+		self.lock.Lock()
+		delete(self.collections, name)
+		self.lock.Unlock()
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *RemoteBackend) ListCollections() ([]string, error) {
+	names := make([]string, 0)
+
+	if err := self.request(`GET`, `/api/schema`, nil, nil, &names); err == nil {
+		sort.Strings(names)
+		return names, nil
+	} else {
+		return nil, err
+	}
+}
+
+func (self *RemoteBackend) GetCollection(name string) (*dal.Collection, error) {
+	self.lock.RLock()
+	collection, ok := self.collections[name]
+	self.lock.RUnlock()
+
+	if ok {
+		return collection, nil
+	}
+
+	var definition dal.Collection
+
+	if err := self.request(`GET`, remotePath(`collections`, name), nil, nil, &definition); err == nil {
+		self.RegisterCollection(&definition)
+		return &definition, nil
+	} else {
+		return nil, err
+	}
+}
+
+func (self *RemoteBackend) WithSearch(collection *dal.Collection, filters ...*filter.Filter) Indexer {
+	return self.indexer
+}
+
+func (self *RemoteBackend) WithAggregator(collection *dal.Collection) Aggregator {
+	return self
+}
+
+func (self *RemoteBackend) Flush() error {
+	if self.indexer != nil && self.indexer != Indexer(self) {
+		return self.indexer.FlushIndex()
+	}
+
+	return nil
+}
+
+func (self *RemoteBackend) newRequest(method string, urlpath string, query url.Values, body interface{}) (*http.Request, error) {
+	var payload io.Reader
+
+	if body != nil {
+		if data, err := json.Marshal(body); err == nil {
+			payload = bytes.NewReader(data)
+		} else {
+			return nil, err
+		}
+	}
+
+	u := self.baseURL + urlpath
+
+	if len(query) > 0 {
+		u += `?` + query.Encode()
+	}
+
+	querylog.Debugf("[%T] %v %v", self, method, u)
+
+	if req, err := http.NewRequest(method, u, payload); err == nil {
+		if body != nil {
+			req.Header.Set(`Content-Type`, `application/json`)
+		}
+
+		if username, password, ok := self.conn.Credentials(); ok {
+			req.SetBasicAuth(username, password)
+		}
+
+		return req, nil
+	} else {
+		return nil, err
+	}
+}
+
+// Performs a request against the server, decoding the JSON response body into output (if given).
+func (self *RemoteBackend) request(method string, urlpath string, query url.Values, body interface{}, output interface{}) error {
+	if self.client == nil {
+		return fmt.Errorf("Backend not initialized")
+	}
+
+	if req, err := self.newRequest(method, urlpath, query, body); err == nil {
+		if response, err := self.client.Do(req); err == nil {
+			defer response.Body.Close()
+
+			if err := remoteResponseError(response); err != nil {
+				return err
+			}
+
+			if output != nil && response.StatusCode != http.StatusNoContent {
+				if err := json.NewDecoder(response.Body).Decode(output); err != nil {
+					return fmt.Errorf("decode error: %v", err)
+				}
+			}
+
+			return nil
+		} else {
+			return err
+		}
+	} else {
+		return err
+	}
+}
+
+// Converts an error response from the server back into the error it describes, so that checks like
+// dal.IsNotExistError and dal.IsCollectionNotFoundErr work the same as they would locally.
+func remoteResponseError(response *http.Response) error {
+	if response.StatusCode < 400 {
+		return nil
+	}
+
+	var body struct {
+		Error      string            `json:"error"`
+		Collection string            `json:"collection"`
+		Errors     []*dal.FieldError `json:"errors"`
+	}
+
+	if err := json.NewDecoder(response.Body).Decode(&body); err == nil && body.Error != `` {
+		if response.StatusCode == http.StatusUnprocessableEntity && len(body.Errors) > 0 {
+			return &dal.ValidationError{
+				Collection: body.Collection,
+				Errors:     body.Errors,
+			}
+		}
+
+		return fmt.Errorf("%s", body.Error)
+	}
+
+	return fmt.Errorf("%v", response.Status)
+}
+
+// builds an API path from the given components, escaping each one
+func remotePath(components ...string) string {
+	for i, component := range components {
+		components[i] = url.PathEscape(component)
+	}
+
+	return `/api/` + strings.Join(components, `/`)
+}
+
+// formats an ID for use in a URL path, joining the values of compound IDs with the given separator
+// (the server reads them separated by a colon, except when deleting records, which uses a slash)
+func remoteIdentity(id interface{}, separator string) string {
+	values := make([]string, 0)
+
+	for _, value := range sliceutil.Sliceify(id) {
+		values = append(values, url.PathEscape(fmt.Sprintf("%v", value)))
+	}
+
+	return strings.Join(values, separator)
+}
+
+// Formatters, validators, and default values that are functions cannot be sent to the server, so
+// those are applied locally before records are written.  Everything that is part of the collection's
+// serialized definition (formatters and validators given by name, computed fields, and rules) is
+// left for the server to apply, so that no value is formatted twice.  When updating, the stored
+// records are retrieved with the given function so that unchanged values aren't formatted again (see
+// dal.Collection.MakeRecordForUpdate); a nil function means the records are being inserted.
+func remotePrepareRecords(collection *dal.Collection, recordset *dal.RecordSet, retrieve func(id interface{}) (*dal.Record, error)) error {
+	local := remoteLocalDefinition(collection)
+
+	for i, record := range recordset.Records {
+		var r *dal.Record
+		var err error
+
+		if retrieve != nil {
+			var stored *dal.Record
+
+			if record.ID != nil {
+				stored, _ = retrieve(record.ID)
+			}
+
+			r, err = local.MakeRecordForUpdate(record, stored)
+		} else {
+			r, err = local.MakeRecord(record)
+		}
+
+		if err == nil {
+			recordset.Records[i] = r
+		} else {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// returns a copy of the collection with only the formatting and validation that the server can't
+// do itself; that is, the parts of the definition that aren't serialized
+func remoteLocalDefinition(collection *dal.Collection) *dal.Collection {
+	local := *collection
+	local.Rules = nil
+	local.Fields = make([]dal.Field, len(collection.Fields))
+
+	for i, field := range collection.Fields {
+		if len(field.FormatterConfig) > 0 {
+			field.Formatter = nil
+			field.FormatterConfig = nil
+
+			// validators check formatted values, so they're applied wherever the formatting is
+			field.Validator = nil
+			field.ValidatorConfig = nil
+		}
+
+		field.Expression = ``
+		local.Fields[i] = field
+	}
+
+	return &local
+}
+
+// values arrive as decoded JSON, so they are converted back to the types of the collection's fields
+func remoteRecord(collection *dal.Collection, record *dal.Record) *dal.Record {
+	output := dal.NewRecord(memoryIdentity(collection, record.ID))
+	output.Data = record.Data
+
+	for name, value := range record.Fields {
+		output.Fields[name] = memoryJSONFieldValue(collection, name, value)
+	}
+
+	return output
+}
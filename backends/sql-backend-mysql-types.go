@@ -0,0 +1,82 @@
+package backends
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter/generators"
+)
+
+var mysqlJsonExtractPattern = regexp.MustCompile("(?i)json_extract\\(`([^`]+)`\\s*,\\s*(?:_\\w+)?'\\$\\.([^']*)'\\)")
+var mysqlJsonPathElementPattern = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"|([^."]+)`)
+
+// Object fields (and lists, which MySQL has no native type for) are stored as JSON, which must be
+// given as a string; byte slices are sent as binary strings, which MySQL will not accept as JSON.
+func mysqlInputValue(field dal.Field, value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+
+	switch field.Type {
+	case dal.ObjectType, dal.ArrayType:
+		switch value.(type) {
+		case string:
+			return value
+		case []byte:
+			return string(value.([]byte))
+		}
+
+		if data, err := json.Marshal(value); err == nil {
+			return string(data)
+		}
+	}
+
+	return value
+}
+
+// Generated columns are virtual, and read their value using the same expression that criteria on the
+// nested field are rendered with; so MySQL can use an index on the column to answer those criteria too.
+func mysqlGeneratedColumn(gen *generators.Sql, field dal.Field) string {
+	return fmt.Sprintf("GENERATED ALWAYS AS (%s) VIRTUAL", gen.ToFieldName(field.Path))
+}
+
+// Returns the statement that creates an index on generated fields that are marked as indexed.
+func mysqlIndexStatements(gen *generators.Sql, collectionName string, field dal.Field) []string {
+	if field.IsGenerated() && field.Indexed {
+		return []string{
+			fmt.Sprintf(
+				"CREATE INDEX %s ON %s (%s)",
+				gen.ToTableName(collectionName+`_`+field.Name+`_idx`),
+				gen.ToTableName(collectionName),
+				gen.ToFieldName(field.Name),
+			),
+		}
+	}
+
+	return nil
+}
+
+// Recovers the path to the nested value a generated column reads from its generation expression
+// (e.g.: json_unquote(json_extract(`meta`,_utf8mb4'$."author"."name"')) -> "meta.author.name").
+func mysqlGeneratedPath(expression string) string {
+	if match := mysqlJsonExtractPattern.FindStringSubmatch(expression); match != nil {
+		path := []string{match[1]}
+
+		for _, element := range mysqlJsonPathElementPattern.FindAllStringSubmatch(match[2], -1) {
+			if element[2] != `` {
+				path = append(path, element[2])
+			} else {
+				path = append(path, strings.Replace(element[1], `\"`, `"`, -1))
+			}
+		}
+
+		if len(path) > 1 {
+			return strings.Join(path, `.`)
+		}
+	}
+
+	return ``
+}
@@ -0,0 +1,110 @@
+package backends
+
+import (
+	"testing"
+
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMysqlJsonColumns(t *testing.T) {
+	assert := require.New(t)
+
+	cs, err := dal.ParseConnectionString(`mysql://localhost/test`)
+	assert.NoError(err)
+
+	backend := NewSqlBackend(cs).(*SqlBackend)
+	_, _, err = backend.initializeMysql()
+	assert.NoError(err)
+
+	collection := dal.NewCollection(`posts`).
+		AddFields(dal.Field{
+			Name: `meta`,
+			Type: dal.ObjectType,
+		}, dal.Field{
+			Name:    `author`,
+			Type:    dal.StringType,
+			Path:    `meta.author.name`,
+			Indexed: true,
+		})
+
+	gen := backend.makeQueryGen(collection)
+
+	// objects are stored as JSON, and generated columns read from them
+	meta, _ := collection.GetField(`meta`)
+	def, err := backend.columnDefinition(gen, meta)
+	assert.NoError(err)
+	assert.Equal("`meta` JSON", def)
+
+	author, _ := collection.GetField(`author`)
+	def, err = backend.columnDefinition(gen, author)
+	assert.NoError(err)
+	assert.Equal("`author` VARCHAR(255) GENERATED ALWAYS AS (JSON_UNQUOTE(JSON_EXTRACT(`meta`, '$.\"author\".\"name\"'))) VIRTUAL", def)
+
+	assert.Equal([]string{
+		"CREATE INDEX `posts_author_idx` ON `posts` (`author`)",
+	}, mysqlIndexStatements(gen, collection.Name, author))
+
+	assert.Nil(mysqlIndexStatements(gen, collection.Name, meta))
+
+	// indexes on generated columns can be added and dropped once the table exists
+	stmts, rebuild, err := backend.mysqlMigrateDelta(gen, collection.Name, dal.SchemaDelta{
+		Type:           dal.FieldDelta,
+		Issue:          dal.FieldPropertyIssue,
+		Collection:     collection.Name,
+		Name:           author.Name,
+		Parameter:      `Indexed`,
+		ReferenceField: &author,
+	})
+
+	assert.NoError(err)
+	assert.False(rebuild)
+	assert.Equal([]string{
+		"CREATE INDEX `posts_author_idx` ON `posts` (`author`)",
+	}, stmts)
+
+	unindexed := author
+	unindexed.Indexed = false
+
+	stmts, rebuild, err = backend.mysqlMigrateDelta(gen, collection.Name, dal.SchemaDelta{
+		Type:           dal.FieldDelta,
+		Issue:          dal.FieldPropertyIssue,
+		Collection:     collection.Name,
+		Name:           author.Name,
+		Parameter:      `Indexed`,
+		ReferenceField: &unindexed,
+	})
+
+	assert.NoError(err)
+	assert.False(rebuild)
+	assert.Equal([]string{
+		"DROP INDEX `posts_author_idx` ON `posts`",
+	}, stmts)
+
+	// index differences are found between generated columns only
+	assert.Len(author.Diff(&unindexed), 1)
+	assert.Equal(`Indexed`, author.Diff(&unindexed)[0].Parameter)
+
+	plain := dal.Field{Name: `title`, Type: dal.StringType, Indexed: true}
+	assert.Empty(plain.Diff(&dal.Field{Name: `title`, Type: dal.StringType}))
+
+	// nested values are queried using the same expression
+	stmt, err := filter.Render(gen, collection.Name, filter.MustParse(`meta.author.name/is:bob`))
+	assert.NoError(err)
+	assert.Equal("SELECT * FROM `posts` WHERE (JSON_UNQUOTE(JSON_EXTRACT(`meta`, '$.\"author\".\"name\"')) = ?)", string(stmt))
+
+	// generated columns are read back as the path they were declared with
+	assert.Equal(`meta.author.name`, mysqlGeneratedPath("json_unquote(json_extract(`meta`,_utf8mb4'$.\"author\".\"name\"'))"))
+	assert.Equal(`meta.views`, mysqlGeneratedPath("json_extract(`meta`,'$.views')"))
+	assert.Equal(``, mysqlGeneratedPath("(`a` + `b`)"))
+
+	// generated values are never written, and objects are written as JSON strings
+	assert.True(backend.isGeneratedField(collection, `author`))
+	assert.False(backend.isGeneratedField(collection, `meta`))
+	assert.Equal(`{"author":{"name":"bob"}}`, mysqlInputValue(meta, map[string]interface{}{
+		`author`: map[string]interface{}{
+			`name`: `bob`,
+		},
+	}))
+}
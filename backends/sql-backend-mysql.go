@@ -23,6 +23,14 @@ func (self *SqlBackend) initializeMysql() (string, string, error) {
 	self.listAllTablesQuery = `SHOW TABLES`
 	self.createPrimaryKeyIntFormat = `%s INT AUTO_INCREMENT NOT NULL PRIMARY KEY`
 	self.createPrimaryKeyStrFormat = `%s VARCHAR(255) NOT NULL PRIMARY KEY`
+	self.migrateDeltaFunc = self.mysqlMigrateDelta
+
+	// store objects as JSON, and read nested values (and generated columns) from them natively
+	self.queryGenNestedFieldFormat = "JSON_UNQUOTE(JSON_EXTRACT(`%s`, '$.\"%s\"'))"
+	self.queryGenNestedFieldJoiner = `"."`
+	self.inputValueFunc = mysqlInputValue
+	self.indexStatementsFunc = mysqlIndexStatements
+	self.generatedColumnFunc = mysqlGeneratedColumn
 
 	// the bespoke method for determining table information for sqlite3
 	self.refreshCollectionFunc = func(datasetName string, collectionName string) (*dal.Collection, error) {
@@ -37,6 +45,8 @@ func (self *SqlBackend) initializeMysql() (string, string, error) {
 				`IS_NULLABLE`,
 				`COLUMN_DEFAULT`,
 				`COLUMN_KEY`,
+				`EXTRA`,
+				`GENERATION_EXPRESSION`,
 			}
 
 			queryGen := self.makeQueryGen(nil)
@@ -57,10 +67,10 @@ func (self *SqlBackend) initializeMysql() (string, string, error) {
 					for rows.Next() {
 						var i int
 						var column, columnType, nullable string
-						var defaultValue, keyType sql.NullString
+						var defaultValue, keyType, extra, generationExpression sql.NullString
 
 						// populate variables from column values
-						if err := rows.Scan(&i, &column, &columnType, &nullable, &defaultValue, &keyType); err == nil {
+						if err := rows.Scan(&i, &column, &columnType, &nullable, &defaultValue, &keyType, &extra, &generationExpression); err == nil {
 							// start building the dal.Field
 							field := dal.Field{
 								Name:       column,
@@ -68,6 +78,11 @@ func (self *SqlBackend) initializeMysql() (string, string, error) {
 								Required:   (nullable != `YES`),
 							}
 
+							// generated columns are declared by the path to the nested value they read
+							if strings.Contains(strings.ToUpper(extra.String), `GENERATED`) {
+								field.Path = mysqlGeneratedPath(generationExpression.String)
+							}
+
 							// set default value if it's not NULL
 							if defaultValue.Valid {
 								field.DefaultValue = stringutil.Autotype(defaultValue.String)
@@ -91,12 +106,19 @@ func (self *SqlBackend) initializeMysql() (string, string, error) {
 									field.Type = dal.IntType
 								}
 
+							} else if columnType == `ENUM` {
+								field.Type = dal.EnumType
+								field.Values = queryGen.SplitEnumValues(field.NativeType)
+
 							} else if columnType == `FLOAT` || columnType == `DOUBLE` || columnType == `DECIMAL` {
 								field.Type = dal.FloatType
 
 							} else if strings.HasPrefix(columnType, `DATE`) || strings.Contains(columnType, `TIME`) {
 								field.Type = dal.TimeType
 
+							} else if columnType == `JSON` {
+								field.Type = dal.ObjectType
+
 							} else {
 								if field.Length == objectFieldHintLength {
 									field.Type = dal.ObjectType
@@ -114,7 +136,12 @@ func (self *SqlBackend) initializeMysql() (string, string, error) {
 							case `UNI`:
 								field.Unique = true
 							case `MUL`:
-								field.Key = true
+								// generated columns are only ever indexed to query them
+								if field.IsGenerated() {
+									field.Indexed = true
+								} else {
+									field.Key = true
+								}
 							}
 
 							// add field to the collection we're building
@@ -167,3 +194,55 @@ func (self *SqlBackend) initializeMysql() (string, string, error) {
 
 	return `mysql`, dsn, nil
 }
+
+func (self *SqlBackend) mysqlMigrateDelta(gen *generators.Sql, collectionName string, delta dal.SchemaDelta) ([]string, bool, error) {
+	field := *delta.ReferenceField
+	table := gen.ToTableName(collectionName)
+
+	switch delta.Issue {
+	case dal.FieldMissingIssue:
+		if def, err := self.columnDefinition(gen, field); err == nil {
+			return append([]string{
+				fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", table, def),
+			}, mysqlIndexStatements(gen, collectionName, field)...), false, nil
+		} else {
+			return nil, false, err
+		}
+
+	case dal.FieldPropertyIssue:
+		if delta.Parameter == `Indexed` && field.IsGenerated() {
+			if field.Indexed {
+				return mysqlIndexStatements(gen, collectionName, field), false, nil
+			} else {
+				return []string{
+					fmt.Sprintf("DROP INDEX %s ON %s", gen.ToTableName(collectionName+`_`+field.Name+`_idx`), table),
+				}, false, nil
+			}
+		} else if delta.Parameter == `Unique` {
+			if field.Unique {
+				return []string{
+					fmt.Sprintf("ALTER TABLE %s ADD UNIQUE (%s)", table, gen.ToFieldName(field.Name)),
+				}, false, nil
+			} else {
+				// unique indexes created without an explicit name are named after the column
+				return []string{
+					fmt.Sprintf("ALTER TABLE %s DROP INDEX %s", table, gen.ToFieldName(field.Name)),
+				}, false, nil
+			}
+		}
+
+		fallthrough
+
+	default:
+		// MODIFY COLUMN leaves existing indexes in place, so uniqueness is handled separately
+		field.Unique = false
+
+		if def, err := self.columnDefinition(gen, field); err == nil {
+			return []string{
+				fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s", table, def),
+			}, false, nil
+		} else {
+			return nil, false, err
+		}
+	}
+}
@@ -0,0 +1,370 @@
+package backends
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/lib/pq"
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter"
+	"github.com/sniperkit/pivot/filter/generators"
+)
+
+var PostgresTextSearchConfig = `english`
+
+// Object fields are stored as JSONB, which the driver expects to be given as a JSON string.  Lists
+// of scalar values are stored using native arrays, and other lists as JSONB.
+func postgresInputValue(field dal.Field, value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+
+	switch field.Type {
+	case dal.ObjectType:
+		return postgresJSON(value)
+
+	case dal.ArrayType:
+		if generators.IsNativeArraySubtype(field.Subtype) {
+			return pq.Array(sliceutil.Sliceify(value))
+		} else {
+			return postgresJSON(value)
+		}
+	}
+
+	return value
+}
+
+func postgresJSON(value interface{}) interface{} {
+	switch value.(type) {
+	case string, []byte:
+		return value
+	}
+
+	if data, err := json.Marshal(value); err == nil {
+		return string(data)
+	} else {
+		return value
+	}
+}
+
+// Decodes a native array (e.g.: {a,b,NULL}) into its elements, which are converted to the field's
+// subtype like any other list.
+func postgresDecodeArray(field dal.Field, data []byte) ([]interface{}, error) {
+	var elements []sql.NullString
+
+	if err := pq.Array(&elements).Scan(data); err != nil {
+		return nil, err
+	}
+
+	items := make([]interface{}, len(elements))
+
+	for i, element := range elements {
+		if element.Valid {
+			items[i] = element.String
+
+			// booleans are encoded as "t" and "f"
+			if field.Subtype == dal.BooleanType {
+				items[i] = (element.String == `t`)
+			}
+		}
+	}
+
+	return items, nil
+}
+
+// Returns the statements that create a GIN index on object and list fields that are marked as indexed,
+// which supports the containment (@>) and overlap (&&) operators used to query them.
+func postgresIndexStatements(gen *generators.Sql, collectionName string, field dal.Field) []string {
+	if field.Indexed {
+		switch field.Type {
+		case dal.ObjectType, dal.ArrayType:
+			return []string{
+				fmt.Sprintf(
+					"CREATE INDEX IF NOT EXISTS %s ON %s USING GIN (%s)",
+					gen.ToTableName(collectionName+`_`+field.Name+`_gin`),
+					gen.ToTableName(collectionName),
+					gen.ToFieldName(field.Name),
+				),
+			}
+		}
+	}
+
+	return nil
+}
+
+// Renders criteria on native list fields and on values nested in object fields.
+//
+// A list matches if it contains any of the values (&&), or none of them for not:, or all of them
+// for contains: (@>).  Nested values are tested for (in)equality by object containment (@>), so
+// that the object field's GIN index can be used, and compared as JSON values so that numbers are
+// compared as numbers.  Other operators on nested values compare their text.
+func (self *SqlBackend) postgresCriterionFunc(collection *dal.Collection) generators.SqlCriterionFunc {
+	return func(criterion filter.Criterion, addValue func(value interface{}) string) (string, bool, error) {
+		path := strings.Split(criterion.Field, `.`)
+		field, ok := collection.GetField(path[0])
+
+		if !ok || postgresHasNull(criterion.Values) {
+			return ``, false, nil
+		}
+
+		column := fmt.Sprintf(self.queryGenFieldFormat, field.Name)
+
+		switch field.Type {
+		case dal.ArrayType:
+			if len(path) == 1 && generators.IsNativeArraySubtype(field.Subtype) {
+				values := pq.Array(criterion.Values)
+
+				switch criterion.Operator {
+				case ``, `is`:
+					return fmt.Sprintf("%s && %s", column, addValue(values)), true, nil
+				case `not`:
+					return fmt.Sprintf("NOT COALESCE(%s && %s, FALSE)", column, addValue(values)), true, nil
+				case `contains`:
+					return fmt.Sprintf("%s @> %s", column, addValue(values)), true, nil
+				default:
+					return ``, false, fmt.Errorf("Operator '%s' cannot be used with list field %q", criterion.Operator, field.Name)
+				}
+			}
+
+		case dal.ObjectType:
+			if len(path) > 1 {
+				conditions := make([]string, 0)
+
+				for _, value := range criterion.Values {
+					value = postgresCriterionValue(criterion, value)
+
+					switch criterion.Operator {
+					case ``, `is`, `not`:
+						var object interface{} = value
+
+						for i := len(path) - 1; i > 0; i-- {
+							object = map[string]interface{}{
+								path[i]: object,
+							}
+						}
+
+						conditions = append(conditions, fmt.Sprintf("%s @> %s", column, addValue(postgresJSON(object))))
+
+					case `gt`, `gte`, `lt`, `lte`:
+						operator := map[string]string{
+							`gt`:  `>`,
+							`gte`: `>=`,
+							`lt`:  `<`,
+							`lte`: `<=`,
+						}[criterion.Operator]
+
+						conditions = append(conditions, fmt.Sprintf(
+							"(%s #> %s) %s CAST(%s AS JSONB)",
+							column,
+							postgresPathLiteral(path[1:]),
+							operator,
+							addValue(postgresJSON(value)),
+						))
+
+					default:
+						return ``, false, nil
+					}
+				}
+
+				if criterion.Operator == `not` {
+					return fmt.Sprintf("NOT COALESCE(%s, FALSE)", strings.Join(conditions, ` OR `)), true, nil
+				} else {
+					return strings.Join(conditions, ` OR `), true, nil
+				}
+			}
+		}
+
+		return ``, false, nil
+	}
+}
+
+func postgresHasNull(values []interface{}) bool {
+	for _, value := range values {
+		if value == nil || strings.ToUpper(fmt.Sprintf("%v", value)) == `NULL` {
+			return true
+		}
+	}
+
+	return false
+}
+
+// criterion values are typed the same way they are for other criteria
+func postgresCriterionValue(criterion filter.Criterion, value interface{}) interface{} {
+	if v, ok := value.(string); ok {
+		if criterion.Type == dal.StringType {
+			return v
+		} else {
+			return stringutil.Autotype(v)
+		}
+	}
+
+	return value
+}
+
+// renders a path to a nested value as a text array literal (e.g.: '{"a","b"}')
+func postgresPathLiteral(path []string) string {
+	elements := make([]string, len(path))
+
+	for i, element := range path {
+		element = strings.Replace(element, `\`, `\\`, -1)
+		element = strings.Replace(element, `"`, `\"`, -1)
+		elements[i] = `"` + element + `"`
+	}
+
+	return `'{` + strings.Replace(strings.Join(elements, `,`), `'`, `''`, -1) + `}'`
+}
+
+// Maps the element type of a native array column (e.g.: "_int8") to a DAL type.
+func postgresArraySubtype(udtName string) dal.Type {
+	switch strings.TrimPrefix(strings.ToLower(udtName), `_`) {
+	case `text`, `varchar`, `bpchar`:
+		return dal.StringType
+	case `int2`, `int4`, `int8`:
+		return dal.IntType
+	case `float4`, `float8`, `numeric`:
+		return dal.FloatType
+	case `bool`:
+		return dal.BooleanType
+	case `timestamp`, `timestamptz`, `date`:
+		return dal.TimeType
+	case `uuid`:
+		return dal.UUIDType
+	default:
+		return ``
+	}
+}
+
+// Indexes the text of each string field using an expression index on its tsvector (enabled with
+// "postgres://...?fts=true", using the text search configuration given by ftsConfig, or
+// PostgresTextSearchConfig).  contains: criteria on string fields match records containing all of
+// the words in any of the values (with stemming), and prefix: criteria match records containing words
+// that start with the last word of a value.  All other criteria are handled as usual.
+type postgresTextIndex struct {
+	backend *SqlBackend
+	config  string
+}
+
+// Indexes any existing collections that are not indexed yet.
+func (self *postgresTextIndex) Initialize() error {
+	for _, name := range self.backend.existingCollectionNames() {
+		if err := self.Create(name, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Creates the text search index for each string field in the named collection, replacing existing
+// ones if replace is true.
+func (self *postgresTextIndex) Create(collectionName string, replace bool) error {
+	gen := self.backend.makeQueryGen(nil)
+	collection, err := self.backend.refreshCollectionFunc(self.backend.conn.Dataset(), collectionName)
+
+	if err != nil {
+		return err
+	}
+
+	stmts := make([]string, 0)
+
+	for _, field := range sqlTextIndexFields(collection) {
+		index := gen.ToTableName(collectionName + `_` + field + `_tsv`)
+
+		if replace {
+			stmts = append(stmts, fmt.Sprintf("DROP INDEX IF EXISTS %s", index))
+		}
+
+		stmts = append(stmts, fmt.Sprintf(
+			"CREATE INDEX IF NOT EXISTS %s ON %s USING GIN (%s)",
+			index,
+			gen.ToTableName(collectionName),
+			self.vector(gen.ToFieldName(field)),
+		))
+	}
+
+	return self.backend.execStatements(stmts)
+}
+
+// The indexes are dropped along with the collection's table.
+func (self *postgresTextIndex) Drop(collectionName string) error {
+	return nil
+}
+
+func (self *postgresTextIndex) Configure(queryGen *generators.Sql, collection *dal.Collection) {
+	fields := sqlTextIndexFields(collection)
+
+	if len(fields) == 0 {
+		return
+	}
+
+	next := queryGen.CriterionFunc
+
+	queryGen.CriterionFunc = func(criterion filter.Criterion, addValue func(value interface{}) string) (string, bool, error) {
+		if sliceutil.ContainsString(fields, criterion.Field) && !postgresHasNull(criterion.Values) {
+			var queryFunc string
+
+			switch criterion.Operator {
+			case `contains`:
+				queryFunc = `plainto_tsquery`
+			case `prefix`:
+				queryFunc = `to_tsquery`
+			}
+
+			if queryFunc != `` {
+				conditions := make([]string, 0)
+
+				for _, value := range criterion.Values {
+					query := fmt.Sprintf("%v", value)
+
+					if criterion.Operator == `prefix` {
+						query = postgresPrefixQuery(query)
+					}
+
+					conditions = append(conditions, fmt.Sprintf(
+						"%s @@ %s('%s', %s)",
+						self.vector(queryGen.ToFieldName(criterion.Field)),
+						queryFunc,
+						self.config,
+						addValue(query),
+					))
+				}
+
+				return strings.Join(conditions, ` OR `), true, nil
+			}
+		}
+
+		if next != nil {
+			return next(criterion, addValue)
+		}
+
+		return ``, false, nil
+	}
+}
+
+// the expression being indexed and searched, which must be identical in both places
+func (self *postgresTextIndex) vector(column string) string {
+	return fmt.Sprintf("to_tsvector('%s', COALESCE(%s, ''))", self.config, column)
+}
+
+// Renders a value as a tsquery matching all of its words, the last of which is a prefix
+// (e.g.: "quick br" becomes 'quick' & 'br':*).
+func postgresPrefixQuery(value string) string {
+	words := strings.Fields(value)
+
+	for i, word := range words {
+		word = strings.Replace(word, `\`, `\\`, -1)
+		words[i] = `'` + strings.Replace(word, `'`, `''`, -1) + `'`
+	}
+
+	if len(words) == 0 {
+		return `''`
+	}
+
+	words[len(words)-1] += `:*`
+
+	return strings.Join(words, ` & `)
+}
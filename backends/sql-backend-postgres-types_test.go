@@ -0,0 +1,71 @@
+package backends
+
+import (
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresNativeQueries(t *testing.T) {
+	assert := require.New(t)
+
+	cs, err := dal.ParseConnectionString(`postgres://localhost/test?fts=true`)
+	assert.NoError(err)
+
+	backend := NewSqlBackend(cs).(*SqlBackend)
+	_, dsn, err := backend.initializePostgres()
+	assert.NoError(err)
+	assert.Equal(`postgres://localhost:5432/test?sslmode=disable`, dsn)
+
+	collection := dal.NewCollection(`posts`).
+		AddFields(dal.Field{
+			Name: `title`,
+			Type: dal.StringType,
+		}, dal.Field{
+			Name:    `tags`,
+			Type:    dal.ArrayType,
+			Subtype: dal.StringType,
+		}, dal.Field{
+			Name: `meta`,
+			Type: dal.ObjectType,
+		})
+
+	render := func(spec string) (string, []interface{}) {
+		gen := backend.makeQueryGen(collection)
+		stmt, err := filter.Render(gen, collection.Name, filter.MustParse(spec))
+		assert.NoError(err)
+
+		return string(stmt), gen.GetValues()
+	}
+
+	stmt, values := render(`tags/is:a|b`)
+	assert.Equal(`SELECT * FROM "posts" WHERE ("tags" && $1)`, stmt)
+	assert.Equal([]interface{}{pq.Array([]interface{}{`a`, `b`})}, values)
+
+	stmt, _ = render(`tags/contains:a/title/not:x`)
+	assert.Equal(`SELECT * FROM "posts" WHERE ("tags" @> $1) AND ("title" <> $2)`, stmt)
+
+	stmt, values = render(`meta.author.name/is:bob`)
+	assert.Equal(`SELECT * FROM "posts" WHERE ("meta" @> $1)`, stmt)
+	assert.Equal([]interface{}{`{"author":{"name":"bob"}}`}, values)
+
+	stmt, values = render(`meta.views/gt:10`)
+	assert.Equal(`SELECT * FROM "posts" WHERE (("meta" #> '{"views"}') > CAST($1 AS JSONB))`, stmt)
+	assert.Equal([]interface{}{`10`}, values)
+
+	stmt, values = render(`title/prefix:quick br`)
+	assert.Equal(`SELECT * FROM "posts" WHERE (to_tsvector('english', COALESCE("title", '')) @@ to_tsquery('english', $1))`, stmt)
+	assert.Equal([]interface{}{`'quick' & 'br':*`}, values)
+
+	// objects and lists are written as JSON and native arrays
+	field, _ := collection.GetField(`meta`)
+	assert.Equal(`{"a":1}`, postgresInputValue(field, map[string]interface{}{`a`: 1}))
+
+	field, _ = collection.GetField(`tags`)
+	items, err := postgresDecodeArray(field, []byte(`{a,"b c",NULL}`))
+	assert.NoError(err)
+	assert.Equal([]interface{}{`a`, `b c`, nil}, items)
+}
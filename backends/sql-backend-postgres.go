@@ -24,6 +24,23 @@ func (self *SqlBackend) initializePostgres() (string, string, error) {
 	self.listAllTablesQuery = `SELECT table_name from information_schema.TABLES WHERE table_catalog = CURRENT_CATALOG AND table_schema = 'public'`
 	self.createPrimaryKeyIntFormat = `%s BIGSERIAL PRIMARY KEY`
 	self.createPrimaryKeyStrFormat = `%s VARCHAR(255) PRIMARY KEY`
+	self.migrateDeltaFunc = self.postgresMigrateDelta
+
+	// store objects as JSONB and lists of scalar values as native arrays, and query them natively
+	self.queryGenNestedFieldFormat = "(%q#>>'{%v}')"
+	self.queryGenNestedFieldJoiner = `,`
+	self.inputValueFunc = postgresInputValue
+	self.decodeArrayFunc = postgresDecodeArray
+	self.criterionFunc = self.postgresCriterionFunc
+	self.indexStatementsFunc = postgresIndexStatements
+
+	// maintain tsvector indexes for full text search
+	if self.conn.OptBool(`fts`, false) {
+		self.textIndex = &postgresTextIndex{
+			backend: self,
+			config:  strings.Replace(self.conn.OptString(`ftsConfig`, PostgresTextSearchConfig), `'`, `''`, -1),
+		}
+	}
 
 	// the bespoke method for determining table information for sqlite3
 	self.refreshCollectionFunc = func(datasetName string, collectionName string) (*dal.Collection, error) {
@@ -76,6 +93,7 @@ func (self *SqlBackend) initializePostgres() (string, string, error) {
 				`ordinal_position`,
 				`column_name`,
 				`data_type`,
+				`udt_name`,
 				`character_octet_length`,
 				`is_nullable`,
 				`column_default`,
@@ -99,11 +117,11 @@ func (self *SqlBackend) initializePostgres() (string, string, error) {
 					for rows.Next() {
 						var i int
 						var octetLength sql.NullInt64
-						var column, columnType, nullable string
+						var column, columnType, udtName, nullable string
 						var defaultValue sql.NullString
 
 						// populate variables from column values
-						if err := rows.Scan(&i, &column, &columnType, &octetLength, &nullable, &defaultValue); err == nil {
+						if err := rows.Scan(&i, &column, &columnType, &udtName, &octetLength, &nullable, &defaultValue); err == nil {
 							// start building the dal.Field
 							field := dal.Field{
 								Name:       column,
@@ -129,6 +147,9 @@ func (self *SqlBackend) initializePostgres() (string, string, error) {
 							} else if strings.HasPrefix(columnType, `BOOL`) {
 								field.Type = dal.BooleanType
 
+							} else if columnType == `UUID` {
+								field.Type = dal.UUIDType
+
 							} else if strings.HasPrefix(columnType, `INT`) || strings.HasSuffix(columnType, `INT`) {
 								if field.Length == 1 {
 									field.Type = dal.BooleanType
@@ -142,6 +163,13 @@ func (self *SqlBackend) initializePostgres() (string, string, error) {
 							} else if strings.HasPrefix(columnType, `DATE`) || strings.Contains(columnType, `TIME`) {
 								field.Type = dal.TimeType
 
+							} else if strings.HasPrefix(columnType, `JSON`) {
+								field.Type = dal.ObjectType
+
+							} else if columnType == `ARRAY` {
+								field.Type = dal.ArrayType
+								field.Subtype = postgresArraySubtype(udtName)
+
 							} else {
 								if field.Length == objectFieldHintLength {
 									field.Type = dal.ObjectType
@@ -205,7 +233,7 @@ func (self *SqlBackend) initializePostgres() (string, string, error) {
 	// pull out pivot-specific options first
 	for k, vv := range opts {
 		switch k {
-		case `autoregister`:
+		case `autoregister`, `fts`, `ftsConfig`:
 			self.conn.Options[k] = strings.Join(vv, `,`)
 			opts.Del(k)
 		}
@@ -219,3 +247,57 @@ func (self *SqlBackend) initializePostgres() (string, string, error) {
 
 	return `postgres`, dsn, nil
 }
+
+func (self *SqlBackend) postgresMigrateDelta(gen *generators.Sql, collectionName string, delta dal.SchemaDelta) ([]string, bool, error) {
+	field := *delta.ReferenceField
+	table := gen.ToTableName(collectionName)
+	column := gen.ToFieldName(field.Name)
+
+	switch delta.Issue {
+	case dal.FieldMissingIssue:
+		if def, err := self.columnDefinition(gen, field); err == nil {
+			return append([]string{
+				fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", table, def),
+			}, postgresIndexStatements(gen, collectionName, field)...), false, nil
+		} else {
+			return nil, false, err
+		}
+
+	case dal.FieldPropertyIssue:
+		switch delta.Parameter {
+		case `Required`:
+			if field.Required {
+				return []string{
+					fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET NOT NULL", table, column),
+				}, false, nil
+			} else {
+				return []string{
+					fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s DROP NOT NULL", table, column),
+				}, false, nil
+			}
+
+		case `Unique`:
+			if field.Unique {
+				return []string{
+					fmt.Sprintf("ALTER TABLE %s ADD UNIQUE (%s)", table, column),
+				}, false, nil
+			} else {
+				// unique constraints created without an explicit name are named <table>_<column>_key
+				return []string{
+					fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %q", table, collectionName+`_`+field.Name+`_key`),
+				}, false, nil
+			}
+		}
+
+		fallthrough
+
+	default:
+		if nativeType, err := self.columnNativeType(gen, field); err == nil {
+			return []string{
+				fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s USING %s::%s", table, column, nativeType, column, nativeType),
+			}, false, nil
+		} else {
+			return nil, false, err
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package backends
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter/generators"
+)
+
+var SqliteTextIndexSuffix = `_fts`
+
+// Maintains an FTS5 table for each collection with string fields (enabled with "sqlite://...?fts=true").
+// The FTS5 table is named after the collection (e.g.: "posts_fts" for "posts"), uses the collection's
+// table as its external content, and is kept up to date by triggers on that table; so records are
+// indexed however they are written, and the text itself is not stored twice.
+//
+// contains: and prefix: criteria on string fields are answered from the index (matching whole words
+// rather than any substring), and results are sorted by relevance (bm25) unless a sort order is given.
+// All other criteria are handled as usual.
+type sqliteTextIndex struct {
+	backend *SqlBackend
+}
+
+// Verifies that SQLite was built with FTS5, and indexes any existing collections that are not indexed yet.
+func (self *sqliteTextIndex) Initialize() error {
+	var available bool
+
+	if rows, err := self.backend.db.Query(`PRAGMA compile_options`); err == nil {
+		defer rows.Close()
+
+		for rows.Next() {
+			var option string
+
+			if err := rows.Scan(&option); err == nil {
+				if option == `ENABLE_FTS5` {
+					available = true
+				}
+			} else {
+				return err
+			}
+		}
+	} else {
+		return err
+	}
+
+	if !available {
+		return fmt.Errorf("sqlite: full text search requires FTS5, which is only included when building with the fts5 tag (e.g.: go build --tags fts5)")
+	}
+
+	for _, name := range self.backend.existingCollectionNames() {
+		if err := self.Create(name, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Creates the FTS5 table and triggers for the named collection and indexes its existing records.  If
+// the collection is already indexed, the index is only rebuilt if replace is true.
+func (self *sqliteTextIndex) Create(collectionName string, replace bool) error {
+	gen := self.backend.makeQueryGen(nil)
+	name := collectionName + SqliteTextIndexSuffix
+
+	if !replace {
+		var count int
+
+		if err := self.backend.db.QueryRow(
+			`SELECT COUNT(1) FROM sqlite_master WHERE type = 'table' AND name = ?`, name,
+		).Scan(&count); err != nil {
+			return err
+		} else if count > 0 {
+			return nil
+		}
+	}
+
+	// index the columns that actually exist, which the triggers will refer to
+	collection, err := self.backend.refreshCollectionFunc(self.backend.conn.Dataset(), collectionName)
+
+	if err != nil {
+		return err
+	}
+
+	fields := sqlTextIndexFields(collection)
+
+	if len(fields) == 0 {
+		return self.Drop(collectionName)
+	}
+
+	table := gen.ToTableName(collectionName)
+	index := gen.ToTableName(name)
+	columns := make([]string, len(fields))
+	oldValues := make([]string, len(fields))
+	newValues := make([]string, len(fields))
+
+	for i, field := range fields {
+		columns[i] = gen.ToFieldName(field)
+		oldValues[i] = `old.` + columns[i]
+		newValues[i] = `new.` + columns[i]
+	}
+
+	insertNew := fmt.Sprintf(
+		"INSERT INTO %s (rowid, %s) VALUES (new.rowid, %s);",
+		index,
+		strings.Join(columns, `, `),
+		strings.Join(newValues, `, `),
+	)
+
+	deleteOld := fmt.Sprintf(
+		"INSERT INTO %s (%s, rowid, %s) VALUES ('delete', old.rowid, %s);",
+		index,
+		index,
+		strings.Join(columns, `, `),
+		strings.Join(oldValues, `, `),
+	)
+
+	stmts := append(self.dropStatements(gen, collectionName), []string{
+		fmt.Sprintf(
+			"CREATE VIRTUAL TABLE %s USING fts5(%s, content='%s')",
+			index,
+			strings.Join(columns, `, `),
+			strings.Replace(collectionName, `'`, `''`, -1),
+		),
+		fmt.Sprintf("CREATE TRIGGER %s AFTER INSERT ON %s BEGIN %s END", gen.ToTableName(name+`_insert`), table, insertNew),
+		fmt.Sprintf("CREATE TRIGGER %s AFTER DELETE ON %s BEGIN %s END", gen.ToTableName(name+`_delete`), table, deleteOld),
+		fmt.Sprintf("CREATE TRIGGER %s AFTER UPDATE ON %s BEGIN %s %s END", gen.ToTableName(name+`_update`), table, deleteOld, insertNew),
+		fmt.Sprintf("INSERT INTO %s (%s) VALUES ('rebuild')", index, index),
+	}...)
+
+	return self.backend.execStatements(stmts)
+}
+
+// Removes the FTS5 table and triggers for the named collection.
+func (self *sqliteTextIndex) Drop(collectionName string) error {
+	return self.backend.execStatements(self.dropStatements(self.backend.makeQueryGen(nil), collectionName))
+}
+
+// Sets up the query generator to answer contains: and prefix: criteria on the collection's string
+// fields from its FTS5 table.
+func (self *sqliteTextIndex) Configure(queryGen *generators.Sql, collection *dal.Collection) {
+	if fields := sqlTextIndexFields(collection); len(fields) > 0 {
+		table := queryGen.ToTableName(collection.Name)
+		index := queryGen.ToTableName(collection.Name + SqliteTextIndexSuffix)
+
+		queryGen.TextSearchFields = fields
+		queryGen.TextSearchQuery = generators.SqliteTextSearchQuery
+		queryGen.TextSearchJoinFormat = fmt.Sprintf(
+			"INNER JOIN (SELECT rowid AS \"_fts_rowid\", bm25(%s) AS \"_fts_rank\" FROM %s WHERE %s MATCH %%s) AS \"_fts\" ON \"_fts\".\"_fts_rowid\" = %s.rowid",
+			index,
+			index,
+			index,
+			table,
+		)
+
+		queryGen.TextSearchWhereFormat = fmt.Sprintf("rowid IN (SELECT rowid FROM %s WHERE %s MATCH %%s)", index, index)
+		queryGen.TextSearchRankField = `"_fts"."_fts_rank"`
+	}
+}
+
+func (self *sqliteTextIndex) dropStatements(gen *generators.Sql, collectionName string) []string {
+	name := collectionName + SqliteTextIndexSuffix
+
+	return []string{
+		fmt.Sprintf("DROP TRIGGER IF EXISTS %s", gen.ToTableName(name+`_insert`)),
+		fmt.Sprintf("DROP TRIGGER IF EXISTS %s", gen.ToTableName(name+`_delete`)),
+		fmt.Sprintf("DROP TRIGGER IF EXISTS %s", gen.ToTableName(name+`_update`)),
+		fmt.Sprintf("DROP TABLE IF EXISTS %s", gen.ToTableName(name)),
+	}
+}
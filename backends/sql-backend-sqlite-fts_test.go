@@ -0,0 +1,134 @@
+package backends
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func makeSqliteTextSearchBackend(t *testing.T, dir string) *SqlBackend {
+	cs, err := dal.ParseConnectionString(`sqlite:///` + filepath.Join(dir, `test.db`) + `?fts=true`)
+	require.NoError(t, err)
+
+	backend := NewSqlBackend(cs).(*SqlBackend)
+
+	if err := backend.Initialize(); err != nil {
+		if strings.Contains(err.Error(), `FTS5`) {
+			t.Skip(err)
+		}
+
+		require.NoError(t, err)
+	}
+
+	return backend
+}
+
+func TestSqliteTextSearch(t *testing.T) {
+	assert := require.New(t)
+	dir, err := ioutil.TempDir(``, `pivot-backend-sqlite-fts-`)
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	backend := makeSqliteTextSearchBackend(t, dir)
+
+	collection := dal.NewCollection(`posts`).
+		AddFields(dal.Field{
+			Name: `title`,
+			Type: dal.StringType,
+		}, dal.Field{
+			Name: `body`,
+			Type: dal.StringType,
+		}, dal.Field{
+			Name: `views`,
+			Type: dal.IntType,
+		})
+
+	assert.NoError(backend.CreateCollection(collection))
+
+	// the index tables are not collections
+	names, err := backend.ListCollections()
+	assert.NoError(err)
+	assert.Equal([]string{`posts`}, names)
+
+	assert.NoError(backend.Insert(collection.Name, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`title`, `The quick brown fox`).Set(`body`, `jumps over the lazy dog`).Set(`views`, 5),
+		dal.NewRecord(2).Set(`title`, `Foxes`).Set(`body`, `a fox is a fox is a fox`).Set(`views`, 20),
+		dal.NewRecord(3).Set(`title`, `Dogs`).Set(`body`, `jumping dogs and sleeping foxhounds`).Set(`views`, 15),
+	)))
+
+	search := backend.WithSearch(collection)
+
+	// whole words only, most relevant first
+	recordset, err := search.Query(collection, filter.MustParse(`body/contains:fox`))
+	assert.NoError(err)
+	assert.Len(recordset.Records, 1)
+	assert.EqualValues(2, recordset.Records[0].ID)
+	assert.Equal(`Foxes`, recordset.Records[0].Get(`title`))
+
+	recordset, err = search.Query(collection, filter.MustParse(`body/prefix:fox`))
+	assert.NoError(err)
+	assert.Len(recordset.Records, 2)
+	assert.EqualValues(2, recordset.Records[0].ID)
+	assert.EqualValues(3, recordset.Records[1].ID)
+
+	// other criteria and sort orders still apply
+	recordset, err = search.Query(collection, filter.MustParse(`body/prefix:jump/int:views/gt:10`))
+	assert.NoError(err)
+	assert.Len(recordset.Records, 1)
+	assert.EqualValues(3, recordset.Records[0].ID)
+
+	recordset, err = search.Query(collection, filter.MustParse(`body/prefix:fox|lazy`).SortBy(`views`))
+	assert.NoError(err)
+	assert.Len(recordset.Records, 3)
+	assert.EqualValues(1, recordset.Records[0].ID)
+	assert.EqualValues(3, recordset.Records[1].ID)
+	assert.EqualValues(2, recordset.Records[2].ID)
+
+	count, err := backend.WithAggregator(collection).Count(collection, filter.MustParse(`title/contains:fox|dogs`))
+	assert.NoError(err)
+	assert.EqualValues(2, count)
+
+	// the index is kept up to date as records change
+	assert.NoError(backend.Update(collection.Name, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`title`, `The quick brown cat`).Set(`body`, `naps`).Set(`views`, 5),
+	)))
+
+	recordset, err = search.Query(collection, filter.MustParse(`title/contains:fox`))
+	assert.NoError(err)
+	assert.Empty(recordset.Records)
+
+	recordset, err = search.Query(collection, filter.MustParse(`title/contains:cat`))
+	assert.NoError(err)
+	assert.Len(recordset.Records, 1)
+
+	assert.NoError(search.DeleteQuery(collection, filter.MustParse(`body/contains:fox`)))
+	assert.False(backend.Exists(collection.Name, 2))
+	assert.True(backend.Exists(collection.Name, 3))
+
+	assert.NoError(backend.Delete(collection.Name, 3))
+
+	recordset, err = search.Query(collection, filter.MustParse(`body/prefix:jump`))
+	assert.NoError(err)
+	assert.Empty(recordset.Records)
+
+	// existing indexes are kept when the database is opened again
+	other := makeSqliteTextSearchBackend(t, dir)
+	other.RegisterCollection(collection)
+
+	recordset, err = other.WithSearch(collection).Query(collection, filter.MustParse(`title/prefix:ca`))
+	assert.NoError(err)
+	assert.Len(recordset.Records, 1)
+	assert.EqualValues(1, recordset.Records[0].ID)
+
+	assert.NoError(other.DeleteCollection(collection.Name))
+
+	var tables int
+	assert.NoError(other.db.QueryRow(`SELECT COUNT(1) FROM sqlite_master`).Scan(&tables))
+	assert.Zero(tables)
+}
@@ -25,6 +25,19 @@ func (self *SqlBackend) initializeSqlite() (string, string, error) {
 	self.listAllTablesQuery = `SELECT name FROM sqlite_master`
 	self.createPrimaryKeyIntFormat = `%s INTEGER NOT NULL PRIMARY KEY ASC`
 	self.createPrimaryKeyStrFormat = `%s TEXT NOT NULL PRIMARY KEY`
+	self.migrateDeltaFunc = self.sqliteMigrateDelta
+
+	// maintain FTS5 indexes for full text search
+	if self.conn.OptBool(`fts`, false) {
+		self.textIndex = &sqliteTextIndex{
+			backend: self,
+		}
+
+		// ...whose tables are not collections
+		self.listAllTablesQuery = `SELECT name FROM sqlite_master m WHERE NOT EXISTS (` +
+			`SELECT 1 FROM sqlite_master v WHERE v.sql LIKE 'CREATE VIRTUAL TABLE%' ` +
+			`AND (m.name = v.name OR substr(m.name, 1, length(v.name) + 1) = v.name || '_'))`
+	}
 
 	// the bespoke method for determining table information for sqlite3
 	self.refreshCollectionFunc = func(datasetName string, collectionName string) (*dal.Collection, error) {
@@ -236,3 +249,26 @@ func (self *SqlBackend) sqliteGetTableConstraints(constraintType string, collect
 		return nil, err
 	}
 }
+
+// SQLite's ALTER TABLE support is limited to adding columns, so anything else requires rebuilding the table.
+func (self *SqlBackend) sqliteMigrateDelta(gen *generators.Sql, collectionName string, delta dal.SchemaDelta) ([]string, bool, error) {
+	field := *delta.ReferenceField
+
+	switch delta.Issue {
+	case dal.FieldMissingIssue:
+		// columns added via ALTER TABLE cannot be UNIQUE, and cannot be NOT NULL without a default value
+		if field.Unique || (field.Required && field.DefaultValue == nil) {
+			return nil, true, nil
+		}
+
+		if def, err := self.columnDefinition(gen, field); err == nil {
+			return []string{
+				fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", gen.ToTableName(collectionName), def),
+			}, false, nil
+		} else {
+			return nil, false, err
+		}
+	}
+
+	return nil, true, nil
+}
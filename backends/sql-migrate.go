@@ -0,0 +1,237 @@
+package backends
+
+// this file satifies the Migratable interface for SqlBackend
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/typeutil"
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter/generators"
+)
+
+var sqlMigrationTablePrefix = `_pivot_migrate_`
+
+// Returns the statements needed to resolve a single field-level schema delta.  If the change cannot
+// be expressed as an ALTER TABLE statement, rebuild should be true; in which case the table will be
+// recreated with the desired schema and the existing data copied into it.
+type sqlMigrateDeltaFunc func(gen *generators.Sql, collectionName string, delta dal.SchemaDelta) (stmts []string, rebuild bool, err error)
+
+// Applies the given schema differences to the database.  All statements are executed in a single
+// transaction, so either all changes are applied or none are.  The exception is MySQL, which commits
+// the transaction implicitly after each schema change, so a migration that fails there may be left
+// partially applied.
+func (self *SqlBackend) Migrate(diff []dal.SchemaDelta) error {
+	if stmts, err := self.MigrationStatements(diff); err == nil {
+		if len(stmts) == 0 {
+			return nil
+		}
+
+		if tx, err := self.db.Begin(); err == nil {
+			for _, stmt := range stmts {
+				querylog.Debugf("[%T] %s", self, stmt)
+
+				if _, err := tx.Exec(stmt); err != nil {
+					defer tx.Rollback()
+					return fmt.Errorf("migration failed: %v", err)
+				}
+			}
+
+			if err := tx.Commit(); err != nil {
+				return err
+			}
+		} else {
+			return err
+		}
+
+		// refresh the schema of every collection we just touched
+		for _, name := range self.collectionsInDiff(diff) {
+			var definition *dal.Collection
+
+			if c, err := self.getCollectionFromCache(name); err == nil {
+				definition = c
+			}
+
+			if err := self.refreshCollectionFromDatabase(name, definition); err != nil {
+				return err
+			}
+
+			// the table may have been rebuilt or gained columns, so its full text index is rebuilt too
+			if self.textIndex != nil {
+				if err := self.textIndex.Create(name, true); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+// Returns the SQL statements that would be executed to resolve the given schema differences,
+// without executing them.
+func (self *SqlBackend) MigrationStatements(diff []dal.SchemaDelta) ([]string, error) {
+	if self.migrateDeltaFunc == nil {
+		return nil, fmt.Errorf("Migrations are not supported by %q backends", self.conn.Backend())
+	}
+
+	stmts := make([]string, 0)
+
+	for _, name := range self.collectionsInDiff(diff) {
+		gen := self.makeQueryGen(nil)
+		collectionStmts := make([]string, 0)
+		rebuild := false
+
+		for _, delta := range diff {
+			if delta.Collection != name {
+				continue
+			}
+
+			switch delta.Issue {
+			case dal.CollectionNameIssue, dal.CollectionKeyNameIssue, dal.CollectionKeyTypeIssue:
+				return nil, fmt.Errorf("Cannot migrate collection %q: %v", name, delta)
+			}
+
+			if delta.ReferenceField == nil {
+				return nil, fmt.Errorf("Cannot migrate field %q in collection %q: no field definition was given", delta.Name, name)
+			}
+
+			// existing rows would have no value for the new column
+			if delta.Issue == dal.FieldMissingIssue {
+				if field := delta.ReferenceField; field.Required && !field.IsGenerated() {
+					if field.DefaultValue == nil || typeutil.IsFunction(field.DefaultValue) {
+						return nil, fmt.Errorf("Cannot add required field %q to collection %q without a default value", delta.Name, name)
+					}
+				}
+			}
+
+			if resolve, err := sqlCheckFieldProperty(name, delta); err != nil {
+				return nil, err
+			} else if !resolve {
+				continue
+			}
+
+			if s, r, err := self.migrateDeltaFunc(gen, name, delta); err == nil {
+				collectionStmts = append(collectionStmts, s...)
+
+				if r {
+					rebuild = true
+				}
+			} else {
+				return nil, err
+			}
+		}
+
+		// rebuilding the table addresses all deltas at once, so the individual statements are not needed
+		if rebuild {
+			if s, err := self.rebuildTableStatements(name, diff); err == nil {
+				collectionStmts = s
+			} else {
+				return nil, err
+			}
+		}
+
+		stmts = append(stmts, sliceutil.Stringify(sliceutil.Unique(collectionStmts))...)
+	}
+
+	return stmts, nil
+}
+
+// Generates statements that create a new table with the desired schema, copy all existing data into
+// it, then replace the existing table with the new one.
+func (self *SqlBackend) rebuildTableStatements(name string, diff []dal.SchemaDelta) ([]string, error) {
+	if actual, err := self.refreshCollectionFunc(self.conn.Dataset(), name); err == nil {
+		desired := dal.NewCollection(name)
+		desired.IdentityField = actual.IdentityField
+		desired.IdentityFieldType = actual.IdentityFieldType
+		copyFields := make([]string, 0)
+		gen := self.makeQueryGen(nil)
+
+		if desired.IdentityField == `` {
+			return nil, fmt.Errorf("Cannot rebuild table %q: no primary key was found", name)
+		} else {
+			copyFields = append(copyFields, gen.ToFieldName(desired.IdentityField))
+		}
+
+		// start with the fields as they exist now, replacing any we have new definitions for
+		for _, field := range actual.Fields {
+			if field.Name == actual.IdentityField {
+				continue
+			}
+
+			for _, delta := range diff {
+				if delta.Collection == name && delta.Name == field.Name && delta.ReferenceField != nil {
+					field = *delta.ReferenceField
+					break
+				}
+			}
+
+			desired.Fields = append(desired.Fields, field)
+			copyFields = append(copyFields, gen.ToFieldName(field.Name))
+		}
+
+		// then add any fields that don't exist yet
+		for _, delta := range diff {
+			if delta.Collection == name && delta.Issue == dal.FieldMissingIssue {
+				if _, ok := desired.GetField(delta.Name); !ok {
+					desired.Fields = append(desired.Fields, *delta.ReferenceField)
+				}
+			}
+		}
+
+		tempName := sqlMigrationTablePrefix + name
+
+		if create, err := self.createTableStatement(tempName, desired); err == nil {
+			return []string{
+				create,
+				fmt.Sprintf(
+					"INSERT INTO %s (%s) SELECT %s FROM %s",
+					gen.ToTableName(tempName),
+					strings.Join(copyFields, `, `),
+					strings.Join(copyFields, `, `),
+					gen.ToTableName(name),
+				),
+				fmt.Sprintf(self.dropTableQuery, gen.ToTableName(name)),
+				fmt.Sprintf("ALTER TABLE %s RENAME TO %s", gen.ToTableName(tempName), gen.ToTableName(name)),
+			}, nil
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+}
+
+func (self *SqlBackend) collectionsInDiff(diff []dal.SchemaDelta) []string {
+	names := make([]string, 0)
+
+	for _, delta := range diff {
+		if !sliceutil.ContainsString(names, delta.Collection) {
+			names = append(names, delta.Collection)
+		}
+	}
+
+	return names
+}
+
+// Determines whether a field property delta needs to be resolved at all.  Changes to properties that
+// are not persisted in the table schema are skipped, and changes to properties that cannot be altered
+// once a table is created (or that migrations don't know how to alter) are returned as errors.
+func sqlCheckFieldProperty(collectionName string, delta dal.SchemaDelta) (bool, error) {
+	if delta.Issue != dal.FieldPropertyIssue {
+		return true, nil
+	}
+
+	switch delta.Parameter {
+	case `Required`, `Unique`, `Precision`, `Indexed`:
+		return true, nil
+	case `KeyType`, `Subtype`, `ValidateOnPopulate`:
+		return false, nil
+	default:
+		return false, fmt.Errorf("Cannot migrate field %q in collection %q: cannot change %s", delta.Name, collectionName, delta.Parameter)
+	}
+}
@@ -43,6 +43,33 @@ type additionalIndexer struct {
 
 type sqlTableDetailsFunc func(datasetName string, collectionName string) (*dal.Collection, error)
 
+// Returns a function that renders criteria on the collection's fields that need database-specific
+// syntax (see generators.SqlCriterionFunc).
+type sqlCriterionFunc func(collection *dal.Collection) generators.SqlCriterionFunc
+
+// Converts a field's value into what the database driver expects for that field's column.
+type sqlInputValueFunc func(field dal.Field, value interface{}) interface{}
+
+// Decodes a value read from a column with a native list type.
+type sqlDecodeArrayFunc func(field dal.Field, data []byte) ([]interface{}, error)
+
+// Returns the statements that create any indexes the given field needs beyond those implied by its
+// column definition.
+type sqlIndexStatementsFunc func(gen *generators.Sql, collectionName string, field dal.Field) []string
+
+// Returns the clause that makes a column generate its value from the nested value named by the given
+// field's Path.
+type sqlGeneratedColumnFunc func(gen *generators.Sql, field dal.Field) string
+
+// Full text indexes that are kept alongside the tables of a database, which contains: and prefix:
+// criteria on string fields are answered from instead of LIKE.
+type sqlTextIndex interface {
+	Initialize() error
+	Create(collectionName string, replace bool) error
+	Drop(collectionName string) error
+	Configure(queryGen *generators.Sql, collection *dal.Collection)
+}
+
 type SqlBackend struct {
 	Backend
 	Indexer
@@ -57,6 +84,7 @@ type SqlBackend struct {
 	queryGenTableFormat         string
 	queryGenFieldFormat         string
 	queryGenNestedFieldFormat   string
+	queryGenNestedFieldJoiner   string
 	queryGenNormalizerFormat    string
 	listAllTablesQuery          string
 	createPrimaryKeyIntFormat   string
@@ -64,10 +92,34 @@ type SqlBackend struct {
 	showTableDetailQuery        string
 	refreshCollectionFunc       sqlTableDetailsFunc
 	dropTableQuery              string
+	migrateDeltaFunc            sqlMigrateDeltaFunc
+	criterionFunc               sqlCriterionFunc
+	inputValueFunc              sqlInputValueFunc
+	decodeArrayFunc             sqlDecodeArrayFunc
+	indexStatementsFunc         sqlIndexStatementsFunc
+	generatedColumnFunc         sqlGeneratedColumnFunc
+	textIndex                   sqlTextIndex
 	registeredCollections       sync.Map
 	knownCollections            map[string]bool
 }
 
+// Returns the names of the string fields in the collection (other than the identity field).
+func sqlTextIndexFields(collection *dal.Collection) []string {
+	fields := make([]string, 0)
+
+	for _, field := range collection.Fields {
+		if field.Identity || field.Name == collection.IdentityField {
+			continue
+		}
+
+		if field.Type == dal.StringType {
+			fields = append(fields, field.Name)
+		}
+	}
+
+	return fields
+}
+
 func NewSqlBackend(connection dal.ConnectionString) Backend {
 	backend := &SqlBackend{
 		conn:                      &connection,
@@ -156,6 +208,12 @@ func (self *SqlBackend) Initialize() error {
 		return err
 	}
 
+	if self.textIndex != nil {
+		if err := self.textIndex.Initialize(); err != nil {
+			return err
+		}
+	}
+
 	if err := self.indexer.IndexInitialize(self); err != nil {
 		return err
 	}
@@ -198,6 +256,7 @@ func (self *SqlBackend) Insert(name string, recordset *dal.RecordSet) error {
 				if r, err := collection.MakeRecord(record); err == nil {
 					record = r
 				} else {
+					defer tx.Rollback()
 					return err
 				}
 
@@ -207,8 +266,12 @@ func (self *SqlBackend) Insert(name string, recordset *dal.RecordSet) error {
 
 				// add record data to query input
 				for k, v := range record.Fields {
+					if self.isGeneratedField(collection, k) {
+						continue
+					}
+
 					// convert incoming values to their destination field types
-					queryGen.InputData[k] = collection.ConvertValue(k, v)
+					queryGen.InputData[k] = self.inputValue(collection, k, collection.ConvertValue(k, v))
 				}
 
 				// set the primary key
@@ -342,6 +405,61 @@ func (self *SqlBackend) Retrieve(name string, id interface{}, fields ...string)
 	}
 }
 
+// retrieves the stored versions of the given records (those that exist) in a single query within the
+// given transaction, keyed on their IDs
+func (self *SqlBackend) retrieveForUpdate(tx *sql.Tx, collection *dal.Collection, records []*dal.Record) (map[string]*dal.Record, error) {
+	stored := make(map[string]*dal.Record)
+	ids := make([]string, 0)
+
+	for _, record := range records {
+		if record.ID != nil && record.ID != `` {
+			ids = append(ids, fmt.Sprintf("%v", record.ID))
+		}
+	}
+
+	if len(ids) == 0 {
+		return stored, nil
+	}
+
+	if f, err := filter.FromMap(map[string]interface{}{
+		collection.IdentityField: `is:` + strings.Join(ids, `|`),
+	}); err == nil {
+		queryGen := self.makeQueryGen(collection)
+
+		if err := queryGen.Initialize(collection.Name); err == nil {
+			if stmt, err := filter.Render(queryGen, collection.Name, f); err == nil {
+				querylog.Debugf("[%T] %s %v", self, string(stmt[:]), queryGen.GetValues())
+
+				if rows, err := tx.Query(string(stmt[:]), queryGen.GetValues()...); err == nil {
+					defer rows.Close()
+
+					if columns, err := rows.Columns(); err == nil {
+						for rows.Next() {
+							if record, err := self.scanFnValueToRecord(queryGen, collection, columns, reflect.ValueOf(rows.Scan), nil); err == nil {
+								stored[fmt.Sprintf("%v", record.ID)] = record
+							} else {
+								return nil, err
+							}
+						}
+
+						return stored, rows.Err()
+					} else {
+						return nil, err
+					}
+				} else {
+					return nil, err
+				}
+			} else {
+				return nil, err
+			}
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+}
+
 func (self *SqlBackend) Update(name string, recordset *dal.RecordSet, target ...string) error {
 	var targetFilter *filter.Filter
 
@@ -355,11 +473,21 @@ func (self *SqlBackend) Update(name string, recordset *dal.RecordSet, target ...
 
 	if collection, err := self.getCollectionFromCache(name); err == nil {
 		if tx, err := self.db.Begin(); err == nil {
+			// read the records being updated, so that they're formatted and validated against what
+			// is stored
+			stored, err := self.retrieveForUpdate(tx, collection, recordset.Records)
+
+			if err != nil {
+				defer tx.Rollback()
+				return err
+			}
+
 			// for each record being updated...
 			for _, record := range recordset.Records {
-				if r, err := collection.MakeRecord(record); err == nil {
+				if r, err := collection.MakeRecordForUpdate(record, stored[fmt.Sprintf("%v", record.ID)]); err == nil {
 					record = r
 				} else {
+					defer tx.Rollback()
 					return err
 				}
 
@@ -392,8 +520,8 @@ func (self *SqlBackend) Update(name string, recordset *dal.RecordSet, target ...
 
 				// add all non-ID fields to the record's Fields set
 				for k, v := range record.Fields {
-					if k != collection.IdentityField {
-						queryGen.InputData[k] = v
+					if k != collection.IdentityField && !self.isGeneratedField(collection, k) {
+						queryGen.InputData[k] = self.inputValue(collection, k, v)
 					}
 				}
 
@@ -485,6 +613,13 @@ func (self *SqlBackend) WithAggregator(collection *dal.Collection) Aggregator {
 		return aggregator
 	}
 
+	// use the indexer to perform aggregations if it supports them
+	if self.indexer != nil && self.indexer != Indexer(self) {
+		if aggregator, ok := self.indexer.(Aggregator); ok {
+			return aggregator
+		}
+	}
+
 	defaultAggregator, _ := self.aggregator[``]
 
 	return defaultAggregator
@@ -531,12 +666,62 @@ func (self *SqlBackend) CreateCollection(definition *dal.Collection) error {
 		definition.IdentityField = dal.DefaultIdentityField
 	}
 
+	values := make([]interface{}, 0)
+	stmt, err := self.createTableStatement(definition.Name, definition)
+
+	if err != nil {
+		return err
+	}
+
+	if tx, err := self.db.Begin(); err == nil {
+		querylog.Debugf("[%T] %s %v", self, string(stmt[:]), values)
+
+		if _, err := tx.Exec(stmt, values...); err == nil {
+			if self.indexStatementsFunc != nil {
+				gen := self.makeQueryGen(definition)
+
+				for _, field := range definition.Fields {
+					for _, indexStmt := range self.indexStatementsFunc(gen, definition.Name, field) {
+						querylog.Debugf("[%T] %s", self, indexStmt)
+
+						if _, err := tx.Exec(indexStmt); err != nil {
+							defer tx.Rollback()
+							return err
+						}
+					}
+				}
+			}
+
+			if err := tx.Commit(); err != nil {
+				return err
+			}
+
+			self.RegisterCollection(definition)
+
+			if err := self.refreshCollectionFromDatabase(definition.Name, definition); err != nil {
+				querylog.Debugf("[%T] failed to refresh collection: %v", self, err)
+			}
+
+			if self.textIndex != nil {
+				return self.textIndex.Create(definition.Name, true)
+			}
+
+			return nil
+		} else {
+			defer tx.Rollback()
+			return err
+		}
+	} else {
+		return err
+	}
+}
+
+func (self *SqlBackend) createTableStatement(tableName string, definition *dal.Collection) (string, error) {
 	gen := self.makeQueryGen(definition)
 
-	stmt := fmt.Sprintf("CREATE TABLE %s (", gen.ToTableName(definition.Name))
+	stmt := fmt.Sprintf("CREATE TABLE %s (", gen.ToTableName(tableName))
 
 	fields := []string{}
-	values := make([]interface{}, 0)
 
 	if definition.IdentityField != `` {
 		switch definition.IdentityFieldType {
@@ -548,66 +733,83 @@ func (self *SqlBackend) CreateCollection(definition *dal.Collection) error {
 	}
 
 	for _, field := range definition.Fields {
-		var def string
-
-		// This is weird...
-		//
-		// So Raw fields and Object fields are stored using the same datatype (BLOB), which
-		// means that when we read back the schema definition, we don't have a decisive way of
-		// knowing whether that field should be treated as Raw or Object.  So we create Object fields
-		// with a specific length.  This serves as a hint to us that we should treat this field as an object field.
-		//
-		// We could also do this with comments, but not all SQL servers necessarily support comments on
-		// table schemata, so this feels more reliable in practical usage.
-		//
-		if field.Type == dal.ObjectType {
-			field.Length = objectFieldHintLength
+		if field.Name == definition.IdentityField {
+			continue
 		}
 
-		if nativeType, err := gen.ToNativeType(field.Type, []dal.Type{field.Subtype}, field.Length); err == nil {
-			def = fmt.Sprintf("%s %s", gen.ToFieldName(field.Name), nativeType)
+		if def, err := self.columnDefinition(gen, field); err == nil {
+			fields = append(fields, def)
 		} else {
-			return err
+			return ``, err
 		}
+	}
 
-		if field.Required {
-			def += ` NOT NULL`
-		}
+	stmt += strings.Join(fields, `, `)
+	stmt += `)`
 
-		if field.Unique {
-			def += ` UNIQUE`
-		}
+	return stmt, nil
+}
 
-		// if the default value is neither nil nor a function
-		if v := field.DefaultValue; v != nil && !typeutil.IsFunction(field.DefaultValue) {
-			def += fmt.Sprintf(" DEFAULT %v", gen.ToNativeValue(field.Type, []dal.Type{field.Subtype}, v))
-		}
+// Returns the column definition clause (name, type, and constraints) for the given field, as used in
+// CREATE TABLE and ALTER TABLE statements.
+func (self *SqlBackend) columnDefinition(gen *generators.Sql, field dal.Field) (string, error) {
+	var def string
 
-		fields = append(fields, def)
+	if nativeType, err := self.columnNativeType(gen, field); err == nil {
+		def = fmt.Sprintf("%s %s", gen.ToFieldName(field.Name), nativeType)
+	} else {
+		return ``, err
 	}
 
-	stmt += strings.Join(fields, `, `)
-	stmt += `)`
+	// generated columns cannot have a default value
+	if field.IsGenerated() && self.generatedColumnFunc != nil {
+		def += ` ` + self.generatedColumnFunc(gen, field)
+		field.DefaultValue = nil
+	}
 
-	if tx, err := self.db.Begin(); err == nil {
-		querylog.Debugf("[%T] %s %v", self, string(stmt[:]), values)
+	if field.Required {
+		def += ` NOT NULL`
+	}
 
-		if _, err := tx.Exec(stmt, values...); err == nil {
-			defer func() {
-				self.RegisterCollection(definition)
+	if field.Unique {
+		def += ` UNIQUE`
+	}
 
-				if err := self.refreshCollectionFromDatabase(definition.Name, definition); err != nil {
-					querylog.Debugf("[%T] failed to refresh collection: %v", self, err)
-				}
-			}()
-			return tx.Commit()
-		} else {
-			defer tx.Rollback()
-			return err
+	if constraint := gen.ToEnumConstraint(field); constraint != `` {
+		def += ` ` + constraint
+	}
+
+	// if the default value is neither nil nor a function
+	if v := field.DefaultValue; v != nil && !typeutil.IsFunction(field.DefaultValue) {
+		def += fmt.Sprintf(" DEFAULT %v", gen.ToNativeValue(field.Type, []dal.Type{field.Subtype}, v))
+	}
+
+	return def, nil
+}
+
+// Returns the native column type for the given field.
+func (self *SqlBackend) columnNativeType(gen *generators.Sql, field dal.Field) (string, error) {
+	// This is weird...
+	//
+	// So Raw fields and Object fields are stored using the same datatype (BLOB), which
+	// means that when we read back the schema definition, we don't have a decisive way of
+	// knowing whether that field should be treated as Raw or Object.  So we create Object fields
+	// with a specific length.  This serves as a hint to us that we should treat this field as an object field.
+	//
+	// We could also do this with comments, but not all SQL servers necessarily support comments on
+	// table schemata, so this feels more reliable in practical usage.
+	//
+	// Lists are stored the same way on databases without a native list type.
+	//
+	// Databases with a native object type (e.g.: PostgreSQL's JSONB) don't need the hint.
+	//
+	if gen.TypeMapping.ObjectType == gen.TypeMapping.RawType {
+		if field.Type == dal.ObjectType || (field.Type == dal.ArrayType && gen.TypeMapping.ArrayType == ``) {
+			field.Length = objectFieldHintLength
 		}
-	} else {
-		return err
 	}
+
+	return gen.ToNativeFieldType(field)
 }
 
 func (self *SqlBackend) DeleteCollection(collectionName string) error {
@@ -619,7 +821,17 @@ func (self *SqlBackend) DeleteCollection(collectionName string) error {
 			querylog.Debugf("[%T] %s", self, string(stmt[:]))
 
 			if _, err := tx.Exec(stmt); err == nil {
-				return tx.Commit()
+				if err := tx.Commit(); err == nil {
+					delete(self.knownCollections, collectionName)
+
+					if self.textIndex != nil {
+						return self.textIndex.Drop(collectionName)
+					}
+
+					return nil
+				} else {
+					return err
+				}
 			} else {
 				defer tx.Rollback()
 				return err
@@ -680,6 +892,10 @@ func (self *SqlBackend) makeQueryGen(collection *dal.Collection) *generators.Sql
 		queryGen.NestedFieldNameFormat = v
 	}
 
+	if v := self.queryGenNestedFieldJoiner; v != `` {
+		queryGen.NestedFieldJoiner = v
+	}
+
 	if collection != nil {
 		// perform string normalization on non-pk, non-key string fields
 		for _, field := range collection.Fields {
@@ -696,11 +912,74 @@ func (self *SqlBackend) makeQueryGen(collection *dal.Collection) *generators.Sql
 		if v := self.queryGenNormalizerFormat; v != `` {
 			queryGen.NormalizerFormat = v
 		}
+
+		if self.criterionFunc != nil {
+			queryGen.CriterionFunc = self.criterionFunc(collection)
+		}
+
+		if self.textIndex != nil {
+			self.textIndex.Configure(queryGen, collection)
+		}
 	}
 
 	return queryGen
 }
 
+// Returns the names of the registered collections that exist in the database.
+func (self *SqlBackend) existingCollectionNames() []string {
+	names := make([]string, 0)
+
+	self.registeredCollections.Range(func(key, _ interface{}) bool {
+		if self.knownCollections[key.(string)] {
+			names = append(names, key.(string))
+		}
+
+		return true
+	})
+
+	return names
+}
+
+// Executes the given statements in a single transaction.
+func (self *SqlBackend) execStatements(stmts []string) error {
+	if tx, err := self.db.Begin(); err == nil {
+		for _, stmt := range stmts {
+			querylog.Debugf("[%T] %s", self, stmt)
+
+			if _, err := tx.Exec(stmt); err != nil {
+				defer tx.Rollback()
+				return err
+			}
+		}
+
+		return tx.Commit()
+	} else {
+		return err
+	}
+}
+
+// Returns whether the named field's value is generated by the database, and so cannot be written to.
+func (self *SqlBackend) isGeneratedField(collection *dal.Collection, name string) bool {
+	if self.generatedColumnFunc != nil {
+		if field, ok := collection.GetField(name); ok {
+			return field.IsGenerated()
+		}
+	}
+
+	return false
+}
+
+// converts a value being written to a field into what the database driver expects
+func (self *SqlBackend) inputValue(collection *dal.Collection, name string, value interface{}) interface{} {
+	if self.inputValueFunc != nil {
+		if field, ok := collection.GetField(name); ok {
+			return self.inputValueFunc(field, value)
+		}
+	}
+
+	return value
+}
+
 func (self *SqlBackend) scanFnValueToRecord(queryGen *generators.Sql, collection *dal.Collection, columns []string, scanFn reflect.Value, wantedFields []string) (*dal.Record, error) {
 	if scanFn.Kind() != reflect.Func {
 		return nil, fmt.Errorf("Can only accept a function value")
@@ -717,13 +996,16 @@ func (self *SqlBackend) scanFnValueToRecord(queryGen *generators.Sql, collection
 		baseColumn := strings.Split(column, queryGen.NestedFieldSeparator)[0]
 
 		if field, ok := collection.GetField(baseColumn); ok {
-			if field.DefaultValue != nil {
+			if field.Type == dal.ArrayType {
+				// lists are read back as JSON-encoded text
+				output[i] = sql.NullString{}
+			} else if field.DefaultValue != nil {
 				output[i] = field.GetDefaultValue()
 			} else if field.Required {
 				output[i] = field.GetTypeInstance()
 			} else {
 				switch field.Type {
-				case dal.StringType, dal.TimeType, dal.ObjectType:
+				case dal.StringType, dal.TimeType, dal.ObjectType, dal.ArrayType, dal.EnumType, dal.DecimalType, dal.UUIDType:
 					output[i] = sql.NullString{}
 
 				case dal.BooleanType:
@@ -813,6 +1095,21 @@ func (self *SqlBackend) scanFnValueToRecord(queryGen *generators.Sql, collection
 							value = []byte(v)
 						}
 
+					case dal.BytesType:
+						value = []byte(v)
+
+					case dal.ArrayType:
+						// lists stored using a native list type have their own encoding
+						if self.decodeArrayFunc != nil && strings.HasPrefix(string(v), `{`) {
+							if items, err := self.decodeArrayFunc(field, v); err == nil {
+								value = items
+							} else {
+								return nil, err
+							}
+						} else {
+							value = string(v)
+						}
+
 					default:
 						value = nil
 
@@ -913,38 +1210,6 @@ func (self *SqlBackend) scanFnValueToRecord(queryGen *generators.Sql, collection
 	}
 }
 
-// func (self *SqlBackend) Migrate(diff []dal.SchemaDelta) error {
-// 	for _, delta := range diff {
-// 		switch delta.Issue {
-// 		case dal.CollectionKeyNameIssue, dal.CollectionKeyTypeIssue:
-// 			return fmt.Errorf("Cannot alter key name or type for %T", self)
-
-// 		case dal.FieldMissingIssue:
-// 			// ALTER TABLE ADD COLUMN ...
-// 			if collection, err := self.getCollectionFromCache(delta.Collection); err == nil {
-// 				if field, ok := collection.GetField(delta.Name); ok {
-
-// 				} else {
-// 					return fmt.Errorf("Cannot add field %q: not in collection %q", delta.Name, delta.Collection)
-// 				}
-// 			} else {
-// 				return fmt.Errorf("Cannot add field %q: %v", delta.Name, err)
-// 			}
-
-// 		case dal.FieldNameIssue:
-// 			// ALTER TABLE ADD COLUMN ...
-// 		case dal.FieldLengthIssue:
-// 			// ALTER TABLE  ...
-// 		case dal.FieldTypeIssue:
-// 			// ALTER TABLE  ...
-// 		case dal.FieldPropertyIssue:
-// 			// ...
-// 		}
-// 	}
-
-// 	return fmt.Errorf("Not Implemented")
-// }
-
 func (self *SqlBackend) refreshAllCollections() error {
 	if !self.conn.OptBool(`autoregister`, DefaultAutoregister) {
 		return nil
@@ -0,0 +1,185 @@
+package backends
+
+// this file satifies the Indexer interface for TabularBackend
+
+import (
+	"fmt"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter"
+)
+
+func (self *TabularBackend) IndexConnectionString() *dal.ConnectionString {
+	return &self.conn
+}
+
+func (self *TabularBackend) IndexInitialize(_ Backend) error {
+	return nil
+}
+
+func (self *TabularBackend) GetBackend() Backend {
+	return self
+}
+
+func (self *TabularBackend) IndexExists(collection *dal.Collection, id interface{}) bool {
+	return self.Exists(collection.GetIndexName(), id)
+}
+
+func (self *TabularBackend) IndexRetrieve(collection *dal.Collection, id interface{}) (*dal.Record, error) {
+	return self.Retrieve(collection.GetIndexName(), id)
+}
+
+// the data files are read in full for every query, so there is nothing to do here
+func (self *TabularBackend) IndexRemove(collection *dal.Collection, ids []interface{}) error {
+	return nil
+}
+
+func (self *TabularBackend) Index(collection *dal.Collection, records *dal.RecordSet) error {
+	return nil
+}
+
+// Reads the collection's data file and calls resultFn for each matching record.  Unsorted queries are
+// streamed, stopping as soon as the filter's limit is reached (so the total number of results is
+// unknown); sorted queries need to read every matching record before returning any of them.
+func (self *TabularBackend) QueryFunc(collection *dal.Collection, f *filter.Filter, resultFn IndexResultFunc) error {
+	if f == nil {
+		f = filter.All()
+	}
+
+	querylog.Debugf("[%T] Query using filter %q", self, f.String())
+
+	for _, criterion := range f.Criteria {
+		if !isMemoryOperator(criterion.Operator) {
+			return fmt.Errorf("Unimplemented operator '%s'", criterion.Operator)
+		}
+	}
+
+	definition, err := self.GetCollection(collection.Name)
+
+	if err != nil {
+		return err
+	}
+
+	if len(f.GetSort()) > 0 {
+		matches := make([]*dal.Record, 0)
+
+		if err := self.eachRecord(definition, func(record *dal.Record) error {
+			if memoryMatchesFilter(definition, f, record) {
+				matches = append(matches, record)
+			}
+
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		memorySortRecords(definition, matches, f)
+
+		return emitRecordPage(matches, f, func(record *dal.Record, err error, page IndexPage) error {
+			if len(f.Fields) > 0 {
+				projectTabularRecord(record, f.Fields)
+			}
+
+			return resultFn(record, err, page)
+		})
+	}
+
+	page := IndexPage{
+		Page:         1,
+		TotalPages:   1,
+		Limit:        f.Limit,
+		Offset:       f.Offset,
+		TotalResults: -1,
+	}
+
+	if f.Limit > 0 {
+		page.Page = (f.Offset / f.Limit) + 1
+	}
+
+	processed := 0
+
+	return self.eachRecord(definition, func(record *dal.Record) error {
+		if !memoryMatchesFilter(definition, f, record) {
+			return nil
+		}
+
+		processed += 1
+
+		if processed <= f.Offset {
+			return nil
+		}
+
+		if len(f.Fields) > 0 {
+			projectTabularRecord(record, f.Fields)
+		}
+
+		if err := resultFn(record, nil, page); err != nil {
+			return err
+		}
+
+		if f.Limit > 0 && processed >= (f.Offset+f.Limit) {
+			return IndexerResultsStop
+		}
+
+		return nil
+	})
+}
+
+func (self *TabularBackend) Query(collection *dal.Collection, f *filter.Filter, resultFns ...IndexResultFunc) (*dal.RecordSet, error) {
+	if f == nil {
+		f = filter.All()
+	}
+
+	// use the record that comes back from the QueryFunc as-is instead of reading the file again
+	query := filter.Copy(f)
+	query.Options = make(map[string]interface{})
+
+	for k, v := range f.Options {
+		query.Options[k] = v
+	}
+
+	query.Options[`ForceIndexRecord`] = true
+
+	return DefaultQueryImplementation(self, collection, &query, resultFns...)
+}
+
+func (self *TabularBackend) ListValues(collection *dal.Collection, fields []string, f *filter.Filter) (map[string][]interface{}, error) {
+	values := make(map[string][]interface{})
+
+	if err := self.QueryFunc(collection, f, func(record *dal.Record, err error, page IndexPage) error {
+		if err != nil {
+			return err
+		}
+
+		for _, field := range fields {
+			values[field] = sliceutil.Unique(append(values[field], memoryRecordValue(collection, record, field)))
+		}
+
+		return nil
+	}); err == nil {
+		return values, nil
+	} else {
+		return values, err
+	}
+}
+
+func (self *TabularBackend) DeleteQuery(collection *dal.Collection, f *filter.Filter) error {
+	ids := make([]interface{}, 0)
+
+	if err := self.QueryFunc(collection, f, func(record *dal.Record, err error, page IndexPage) error {
+		if err == nil {
+			ids = append(ids, record.ID)
+		}
+
+		return err
+	}); err == nil {
+		return self.Delete(collection.Name, ids...)
+	} else {
+		return err
+	}
+}
+
+func (self *TabularBackend) FlushIndex() error {
+	return nil
+}
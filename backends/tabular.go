@@ -0,0 +1,1159 @@
+package backends
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter"
+)
+
+var TabularInferenceRows = 1000
+var TabularSchemaSuffix = `.schema.json`
+var TabularMaxLineSize = 16 * 1024 * 1024
+
+const (
+	FormatTSV    SerializationFormat = `tsv`
+	FormatNDJSON SerializationFormat = `ndjson`
+)
+
+// A backend where each collection is a single CSV, TSV, or newline-delimited JSON file in a
+// directory (e.g.: tabular+tsv:///path/to/dir stores the "users" collection in /path/to/dir/users.tsv).
+//
+// A collection's schema is read from a sidecar file next to it (users.schema.json) if one exists, and
+// is otherwise inferred from the file's header row and the values in the first TabularInferenceRows
+// rows.  Collections created with CreateCollection always have a sidecar schema.  If a file has a
+// column named for the collection's identity field, its values are the record IDs; otherwise each
+// record is identified by its (1-based) row number, and deleting a record renumbers the ones after it.
+//
+// Queries read the file one row at a time.  Inserts are appended to the end of the file, and updates
+// and deletes rewrite the whole file to a temporary file that then atomically replaces it.
+type TabularBackend struct {
+	conn        dal.ConnectionString
+	root        string
+	format      SerializationFormat
+	indexer     Indexer
+	aggregator  map[string]Aggregator
+	collections map[string]*dal.Collection
+	lock        sync.RWMutex
+	writeLock   sync.Mutex
+}
+
+func NewTabularBackend(connection dal.ConnectionString) Backend {
+	return &TabularBackend{
+		conn:        connection,
+		format:      FormatCSV,
+		aggregator:  make(map[string]Aggregator),
+		collections: make(map[string]*dal.Collection),
+	}
+}
+
+func (self *TabularBackend) GetConnectionString() *dal.ConnectionString {
+	return &self.conn
+}
+
+func (self *TabularBackend) Ping(timeout time.Duration) error {
+	if self.root == `` {
+		return fmt.Errorf("Backend not initialized")
+	} else if _, err := os.Stat(self.root); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (self *TabularBackend) RegisterCollection(definition *dal.Collection) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.collections[definition.Name] = definition
+}
+
+func (self *TabularBackend) SetIndexer(indexConnString dal.ConnectionString) error {
+	if indexer, err := MakeIndexer(indexConnString); err == nil {
+		self.indexer = indexer
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *TabularBackend) Initialize() error {
+	switch self.conn.Protocol() {
+	case ``, `csv`:
+		self.format = FormatCSV
+	case `tsv`:
+		self.format = FormatTSV
+	case `ndjson`:
+		self.format = FormatNDJSON
+	default:
+		return fmt.Errorf("Unknown serialization format %q", self.conn.Protocol())
+	}
+
+	// connection strings are handled the same way as the bolt backend's, but point at a directory
+	if root, err := boltPath(&self.conn); err == nil {
+		self.root = root
+	} else {
+		return err
+	}
+
+	if err := os.MkdirAll(self.root, 0700); err != nil {
+		return err
+	}
+
+	// load or infer the schemas of all existing collections, unless they were already registered
+	if names, err := self.ListCollections(); err == nil {
+		for _, name := range names {
+			if _, err := self.GetCollection(name); err == nil {
+				continue
+			}
+
+			if definition, err := self.loadSchema(name); err == nil {
+				self.RegisterCollection(definition)
+			} else {
+				return fmt.Errorf("Cannot load schema for collection %q: %v", name, err)
+			}
+		}
+	} else {
+		return err
+	}
+
+	if self.indexer == nil {
+		self.indexer = self
+	}
+
+	if err := self.indexer.IndexInitialize(self); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Appends the given records to the end of the collection's file.
+func (self *TabularBackend) Insert(name string, recordset *dal.RecordSet) error {
+	if definition, err := self.GetCollection(name); err == nil {
+		self.writeLock.Lock()
+		defer self.writeLock.Unlock()
+
+		columns, hasIdentity, err := self.columns(definition)
+
+		if err != nil {
+			return err
+		}
+
+		// find the highest existing ID (or row number), and make sure none of the new records exist
+		var last int64
+		var rows int64
+		existing := make(map[string]bool)
+
+		for _, record := range recordset.Records {
+			if record.ID != nil {
+				if !hasIdentity {
+					return fmt.Errorf("Collection %q identifies records by row number; IDs cannot be assigned to new records", name)
+				}
+
+				existing[memoryKey(memoryIdentity(definition, record.ID))] = false
+			}
+		}
+
+		if err := self.eachRecord(definition, func(record *dal.Record) error {
+			rows += 1
+
+			if id, ok := record.ID.(int64); ok && id > last {
+				last = id
+			}
+
+			if _, ok := existing[memoryKey(record.ID)]; ok {
+				return fmt.Errorf("Record %q already exists", record.ID)
+			}
+
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		if !hasIdentity {
+			last = rows
+		}
+
+		records := make([]*dal.Record, len(recordset.Records))
+
+		for i, record := range recordset.Records {
+			if r, err := memoryPrepareRecord(definition, record, true, nil); err == nil {
+				if r.ID == nil {
+					if definition.IdentityFieldType == dal.IntType || !hasIdentity {
+						last += 1
+						r.ID = last
+					} else {
+						return fmt.Errorf("Cannot save a record without an ID to collection %q", name)
+					}
+				}
+
+				if err := checkTabularColumns(definition, columns, r); err != nil {
+					return err
+				}
+
+				records[i] = r
+			} else {
+				return err
+			}
+		}
+
+		if err := self.appendRecords(definition, columns, hasIdentity, records); err != nil {
+			return err
+		}
+
+		for i, record := range recordset.Records {
+			record.ID = records[i].ID
+		}
+
+		if search := self.WithSearch(definition); search != nil && search != Indexer(self) {
+			if err := search.Index(definition, recordset); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *TabularBackend) Exists(name string, id interface{}) bool {
+	_, err := self.Retrieve(name, id)
+	return (err == nil)
+}
+
+func (self *TabularBackend) Retrieve(name string, id interface{}, fields ...string) (*dal.Record, error) {
+	if definition, err := self.GetCollection(name); err == nil {
+		key := memoryKey(memoryIdentity(definition, id))
+		var found *dal.Record
+
+		if err := self.eachRecord(definition, func(record *dal.Record) error {
+			if memoryKey(record.ID) == key {
+				found = record
+				return IndexerResultsStop
+			}
+
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+
+		if found == nil {
+			return nil, fmt.Errorf("Record %q does not exist", id)
+		}
+
+		if len(fields) > 0 {
+			projectTabularRecord(found, fields)
+		}
+
+		return found, nil
+	} else {
+		return nil, err
+	}
+}
+
+// Records that already exist are updated with the fields given in the new record, and all others are
+// appended to the end of the file.
+func (self *TabularBackend) Update(name string, recordset *dal.RecordSet, target ...string) error {
+	if definition, err := self.GetCollection(name); err == nil {
+		updates := make(map[string]*dal.Record)
+		order := make([]string, 0)
+
+		// records are formatted and validated as they are merged into the existing rows
+		for _, record := range recordset.Records {
+			if id := memoryIdentity(definition, record.ID); id != nil {
+				key := memoryKey(id)
+
+				if _, ok := updates[key]; !ok {
+					order = append(order, key)
+				}
+
+				updates[key] = record
+			} else {
+				return fmt.Errorf("Cannot update a record without an ID in collection %q", name)
+			}
+		}
+
+		self.writeLock.Lock()
+		defer self.writeLock.Unlock()
+
+		if err := self.rewrite(definition, func(record *dal.Record) (*dal.Record, error) {
+			key := memoryKey(record.ID)
+
+			if update, ok := updates[key]; ok {
+				if r, err := memoryPrepareRecord(definition, update, false, record); err == nil {
+					for k, v := range r.Fields {
+						record.Fields[k] = v
+					}
+				} else {
+					return nil, err
+				}
+
+				delete(updates, key)
+			}
+
+			return record, nil
+		}, func() ([]*dal.Record, error) {
+			inserts := make([]*dal.Record, 0)
+
+			for _, key := range order {
+				if update, ok := updates[key]; ok {
+					if r, err := memoryPrepareRecord(definition, update, false, nil); err == nil {
+						inserts = append(inserts, r)
+					} else {
+						return nil, err
+					}
+				}
+			}
+
+			return inserts, nil
+		}); err != nil {
+			return err
+		}
+
+		if search := self.WithSearch(definition); search != nil && search != Indexer(self) {
+			if err := search.Index(definition, recordset); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *TabularBackend) Delete(name string, ids ...interface{}) error {
+	if definition, err := self.GetCollection(name); err == nil {
+		remove := make(map[string]bool)
+
+		for _, id := range ids {
+			remove[memoryKey(memoryIdentity(definition, id))] = true
+		}
+
+		self.writeLock.Lock()
+		defer self.writeLock.Unlock()
+
+		if err := self.rewrite(definition, func(record *dal.Record) (*dal.Record, error) {
+			if remove[memoryKey(record.ID)] {
+				return nil, nil
+			}
+
+			return record, nil
+		}, nil); err != nil {
+			return err
+		}
+
+		// remove documents from index
+		if search := self.WithSearch(definition); search != nil && search != Indexer(self) {
+			if err := search.IndexRemove(definition, ids); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *TabularBackend) WithSearch(collection *dal.Collection, filters ...*filter.Filter) Indexer {
+	return self.indexer
+}
+
+func (self *TabularBackend) WithAggregator(collection *dal.Collection) Aggregator {
+	if aggregator, ok := self.aggregator[collection.GetAggregatorName()]; ok {
+		return aggregator
+	}
+
+	// use the indexer to perform aggregations if it supports them
+	if self.indexer != nil && self.indexer != Indexer(self) {
+		if aggregator, ok := self.indexer.(Aggregator); ok {
+			return aggregator
+		}
+	}
+
+	return nil
+}
+
+// Returns the names of all collections with a data file in the backend's directory.
+func (self *TabularBackend) ListCollections() ([]string, error) {
+	names := make([]string, 0)
+	ext := `.` + string(self.format)
+
+	if entries, err := ioutil.ReadDir(self.root); err == nil {
+		for _, entry := range entries {
+			if !entry.IsDir() && filepath.Ext(entry.Name()) == ext {
+				names = append(names, strings.TrimSuffix(entry.Name(), ext))
+			}
+		}
+	} else {
+		return nil, err
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// Creates an empty data file (containing only the header row, for CSV and TSV) and a sidecar schema
+// for the collection.
+func (self *TabularBackend) CreateCollection(definition *dal.Collection) error {
+	self.writeLock.Lock()
+	defer self.writeLock.Unlock()
+
+	filename := self.dataPath(definition.Name)
+
+	if _, err := os.Stat(filename); err == nil {
+		return fmt.Errorf("Collection %q already exists", definition.Name)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if data, err := json.MarshalIndent(definition, ``, `  `); err == nil {
+		if err := writeFileAtomic(self.schemaPath(definition.Name), data); err != nil {
+			return err
+		}
+	} else {
+		return err
+	}
+
+	columns := []string{tabularIdentityColumn(definition)}
+
+	for _, field := range definition.Fields {
+		if field.Name != columns[0] {
+			columns = append(columns, field.Name)
+		}
+	}
+
+	if err := self.writeRecords(filename, definition, columns, true, nil, nil, nil); err != nil {
+		return err
+	}
+
+	self.RegisterCollection(definition)
+	return nil
+}
+
+func (self *TabularBackend) DeleteCollection(name string) error {
+	self.writeLock.Lock()
+	defer self.writeLock.Unlock()
+
+	if err := os.Remove(self.dataPath(name)); err != nil {
+		if os.IsNotExist(err) {
+			return dal.CollectionNotFound
+		}
+
+		return err
+	}
+
+	if err := os.Remove(self.schemaPath(name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	self.lock.Lock()
+	delete(self.collections, name)
+	self.lock.Unlock()
+
+	return nil
+}
+
+func (self *TabularBackend) GetCollection(name string) (*dal.Collection, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	if definition, ok := self.collections[name]; ok {
+		return definition, nil
+	} else {
+		return nil, dal.CollectionNotFound
+	}
+}
+
+func (self *TabularBackend) Flush() error {
+	if self.indexer != nil && self.indexer != Indexer(self) {
+		return self.indexer.FlushIndex()
+	}
+
+	return nil
+}
+
+func (self *TabularBackend) dataPath(name string) string {
+	return filepath.Join(self.root, name+`.`+string(self.format))
+}
+
+func (self *TabularBackend) schemaPath(name string) string {
+	return filepath.Join(self.root, name+TabularSchemaSuffix)
+}
+
+// reads the collection's sidecar schema, or infers one from the contents of its data file
+func (self *TabularBackend) loadSchema(name string) (*dal.Collection, error) {
+	if data, err := ioutil.ReadFile(self.schemaPath(name)); err == nil {
+		definition := dal.NewCollection(name)
+
+		if err := json.Unmarshal(data, definition); err != nil {
+			return nil, err
+		}
+
+		return definition, nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	file, err := os.Open(self.dataPath(name))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	definition := dal.NewCollection(name)
+	samples := make(map[string][]interface{})
+	columns := make([]string, 0)
+
+	// collect up to TabularInferenceRows non-empty values from each column
+	add := func(column string, value interface{}) {
+		if !sliceutil.ContainsString(columns, column) {
+			columns = append(columns, column)
+		}
+
+		if value != nil && value != `` && len(samples[column]) < TabularInferenceRows {
+			samples[column] = append(samples[column], value)
+		}
+	}
+
+	if self.format == FormatNDJSON {
+		scanner := newTabularScanner(file)
+
+		for i := 0; i < TabularInferenceRows && scanner.Scan(); i++ {
+			var row map[string]interface{}
+
+			if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+				return nil, fmt.Errorf("line %d: %v", i+1, err)
+			}
+
+			keys := make([]string, 0, len(row))
+
+			for key := range row {
+				keys = append(keys, key)
+			}
+
+			sort.Strings(keys)
+
+			for _, key := range keys {
+				add(key, row[key])
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	} else {
+		reader := self.newCsvReader(file)
+
+		if header, err := reader.Read(); err == nil {
+			for _, column := range header {
+				add(column, nil)
+			}
+
+			for i := 0; i < TabularInferenceRows; i++ {
+				if row, err := reader.Read(); err == nil {
+					for c, value := range row {
+						if c < len(header) {
+							add(header[c], value)
+						}
+					}
+				} else if err == io.EOF {
+					break
+				} else {
+					return nil, err
+				}
+			}
+		} else if err != io.EOF {
+			return nil, err
+		}
+	}
+
+	definition.IdentityFieldType = dal.IntType
+
+	for _, column := range columns {
+		fieldType := inferTabularType(samples[column])
+
+		if column == definition.IdentityField {
+			definition.IdentityFieldType = fieldType
+		} else {
+			definition.AddFields(dal.Field{
+				Name: column,
+				Type: fieldType,
+			})
+		}
+	}
+
+	return definition, nil
+}
+
+// Returns the columns of the collection's data file, and whether the identity field is one of them.
+// Newline-delimited JSON files have no header, so the collection's fields are used instead.
+func (self *TabularBackend) columns(collection *dal.Collection) ([]string, bool, error) {
+	identity := tabularIdentityColumn(collection)
+
+	if self.format != FormatNDJSON {
+		if file, err := os.Open(self.dataPath(collection.Name)); err == nil {
+			defer file.Close()
+
+			if header, err := self.newCsvReader(file).Read(); err == nil {
+				return header, sliceutil.ContainsString(header, identity), nil
+			} else if err != io.EOF {
+				return nil, false, err
+			}
+		} else if !os.IsNotExist(err) {
+			return nil, false, err
+		}
+	}
+
+	columns := []string{identity}
+
+	for _, field := range collection.Fields {
+		if field.Name != identity {
+			columns = append(columns, field.Name)
+		}
+	}
+
+	return columns, true, nil
+}
+
+// Calls fn with each record in the collection's data file, in order, until fn returns an error.
+// Returning IndexerResultsStop stops reading without returning an error.
+func (self *TabularBackend) eachRecord(collection *dal.Collection, fn func(record *dal.Record) error) error {
+	file, err := os.Open(self.dataPath(collection.Name))
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			return dal.CollectionNotFound
+		}
+
+		return err
+	}
+
+	defer file.Close()
+
+	identity := tabularIdentityColumn(collection)
+	var row int64
+
+	each := func(record *dal.Record) error {
+		row += 1
+
+		if record.ID == nil {
+			record.ID = row
+		} else {
+			record.ID = memoryIdentity(collection, record.ID)
+		}
+
+		return fn(record)
+	}
+
+	if self.format == FormatNDJSON {
+		scanner := newTabularScanner(file)
+
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+
+			if line == `` {
+				continue
+			}
+
+			var values map[string]interface{}
+
+			if err := json.Unmarshal([]byte(line), &values); err != nil {
+				return fmt.Errorf("%v: line %d: %v", collection.Name, row+1, err)
+			}
+
+			record := dal.NewRecord(values[identity])
+			delete(values, identity)
+
+			for key, value := range values {
+				if value != nil {
+					record.Fields[key] = memoryJSONFieldValue(collection, key, value)
+				}
+			}
+
+			if err := each(record); err == IndexerResultsStop {
+				return nil
+			} else if err != nil {
+				return err
+			}
+		}
+
+		return scanner.Err()
+	}
+
+	reader := self.newCsvReader(file)
+	header, err := reader.Read()
+
+	if err == io.EOF {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for {
+		values, err := reader.Read()
+
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		record := dal.NewRecord(nil)
+
+		for i, value := range values {
+			if i >= len(header) || value == `` {
+				continue
+			}
+
+			if header[i] == identity {
+				record.ID = value
+			} else {
+				record.Fields[header[i]] = tabularFieldValue(collection, header[i], value)
+			}
+		}
+
+		if err := each(record); err == IndexerResultsStop {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// Passes every record in the collection through fn (which returns the record to write, or nil to
+// remove it), appends any records returned by tail, and atomically replaces the data file with the
+// result.
+func (self *TabularBackend) rewrite(collection *dal.Collection, fn func(record *dal.Record) (*dal.Record, error), tail func() ([]*dal.Record, error)) error {
+	columns, hasIdentity, err := self.columns(collection)
+
+	if err != nil {
+		return err
+	}
+
+	records := make(chan *dal.Record)
+	scanned := make(chan error, 1)
+	done := make(chan error, 1)
+	filename := self.dataPath(collection.Name)
+
+	go func() {
+		done <- self.writeRecords(filename, collection, columns, hasIdentity, records, scanned, tail)
+	}()
+
+	err = self.eachRecord(collection, func(record *dal.Record) error {
+		if r, err := fn(record); err == nil {
+			if r != nil {
+				if err := checkTabularColumns(collection, columns, r); err != nil {
+					return err
+				}
+
+				records <- r
+			}
+
+			return nil
+		} else {
+			return err
+		}
+	})
+
+	// the writer needs to know whether the scan finished before it replaces the data file
+	scanned <- err
+	close(records)
+
+	if werr := <-done; err == nil {
+		err = werr
+	}
+
+	return err
+}
+
+// Writes a header (for CSV and TSV) followed by the given records to a temporary file, which then
+// replaces the named file.  Once the records channel is closed, the error (if any) from reading the
+// records is received from scanned; if there was one, the named file is left untouched.  Otherwise, the
+// records returned by tail (if given) are written after them.
+func (self *TabularBackend) writeRecords(filename string, collection *dal.Collection, columns []string, hasIdentity bool, records <-chan *dal.Record, scanned <-chan error, tail func() ([]*dal.Record, error)) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), `.`+filepath.Base(filename)+`-`)
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	writer := self.newRowWriter(tmp, collection, columns, hasIdentity)
+	var werr error
+
+	if self.format != FormatNDJSON {
+		werr = writer.header()
+	}
+
+	if records != nil {
+		for record := range records {
+			if werr == nil {
+				werr = writer.write(record)
+			}
+		}
+	}
+
+	if scanned != nil {
+		if err := <-scanned; err != nil {
+			werr = err
+		}
+	}
+
+	if werr == nil && tail != nil {
+		if inserts, err := tail(); err == nil {
+			for _, record := range inserts {
+				if err := checkTabularColumns(collection, columns, record); err != nil {
+					werr = err
+					break
+				} else if werr = writer.write(record); werr != nil {
+					break
+				}
+			}
+		} else {
+			werr = err
+		}
+	}
+
+	if werr == nil {
+		werr = writer.flush()
+	}
+
+	if err := tmp.Close(); werr == nil {
+		werr = err
+	}
+
+	if werr != nil {
+		return werr
+	}
+
+	if err := chmodLike(tmp.Name(), filename); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filename)
+}
+
+func (self *TabularBackend) appendRecords(collection *dal.Collection, columns []string, hasIdentity bool, records []*dal.Record) error {
+	filename := self.dataPath(collection.Name)
+
+	file, err := os.OpenFile(filename, os.O_RDWR|os.O_APPEND, 0600)
+
+	if os.IsNotExist(err) {
+		return self.writeRecords(filename, collection, columns, hasIdentity, nil, nil, func() ([]*dal.Record, error) {
+			return records, nil
+		})
+	} else if err != nil {
+		return err
+	}
+
+	// make sure the file ends with a newline before appending to it, reading only its last byte
+	if info, err := file.Stat(); err == nil {
+		if size := info.Size(); size > 0 {
+			last := make([]byte, 1)
+
+			if _, err := file.ReadAt(last, size-1); err != nil {
+				file.Close()
+				return err
+			}
+
+			if last[0] != '\n' {
+				if _, err := file.Write([]byte("\n")); err != nil {
+					file.Close()
+					return err
+				}
+			}
+		}
+	} else {
+		file.Close()
+		return err
+	}
+
+	writer := self.newRowWriter(file, collection, columns, hasIdentity)
+
+	for _, record := range records {
+		if err := writer.write(record); err != nil {
+			file.Close()
+			return err
+		}
+	}
+
+	if err := writer.flush(); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+func (self *TabularBackend) newCsvReader(r io.Reader) *csv.Reader {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	if self.format == FormatTSV {
+		reader.Comma = '\t'
+		reader.LazyQuotes = true
+	}
+
+	return reader
+}
+
+type tabularRowWriter struct {
+	format      SerializationFormat
+	csv         *csv.Writer
+	out         *bufio.Writer
+	collection  *dal.Collection
+	columns     []string
+	hasIdentity bool
+}
+
+func (self *TabularBackend) newRowWriter(w io.Writer, collection *dal.Collection, columns []string, hasIdentity bool) *tabularRowWriter {
+	writer := &tabularRowWriter{
+		format:      self.format,
+		out:         bufio.NewWriter(w),
+		collection:  collection,
+		columns:     columns,
+		hasIdentity: hasIdentity,
+	}
+
+	writer.csv = csv.NewWriter(writer.out)
+
+	if self.format == FormatTSV {
+		writer.csv.Comma = '\t'
+	}
+
+	return writer
+}
+
+func (self *tabularRowWriter) header() error {
+	return self.csv.Write(self.columns)
+}
+
+func (self *tabularRowWriter) write(record *dal.Record) error {
+	identity := tabularIdentityColumn(self.collection)
+
+	if self.format == FormatNDJSON {
+		values := make(map[string]interface{})
+
+		if self.hasIdentity {
+			values[identity] = record.ID
+		}
+
+		for key, value := range record.Fields {
+			if value != nil {
+				values[key] = value
+			}
+		}
+
+		if data, err := json.Marshal(values); err == nil {
+			if _, err := self.out.Write(append(data, '\n')); err != nil {
+				return err
+			}
+
+			return nil
+		} else {
+			return err
+		}
+	}
+
+	row := make([]string, len(self.columns))
+
+	for i, column := range self.columns {
+		var value interface{}
+
+		if column == identity {
+			value = record.ID
+		} else {
+			value = record.Get(column)
+		}
+
+		if v, ok, err := redisHashValue(value); err == nil {
+			if ok {
+				row[i] = v
+			}
+		} else {
+			return err
+		}
+	}
+
+	return self.csv.Write(row)
+}
+
+func (self *tabularRowWriter) flush() error {
+	self.csv.Flush()
+
+	if err := self.csv.Error(); err != nil {
+		return err
+	}
+
+	return self.out.Flush()
+}
+
+func tabularIdentityColumn(collection *dal.Collection) string {
+	if collection.IdentityField != `` {
+		return collection.IdentityField
+	}
+
+	return dal.DefaultIdentityField
+}
+
+// CSV and TSV records can only contain values for fields that the file has a column for.
+func checkTabularColumns(collection *dal.Collection, columns []string, record *dal.Record) error {
+	for key, value := range record.Fields {
+		if value != nil && !sliceutil.ContainsString(columns, key) {
+			return fmt.Errorf("Field %q is not a column of collection %q", key, collection.Name)
+		}
+	}
+
+	return nil
+}
+
+// Converts a string read from a CSV or TSV file to the type of the named field.  Nested values are
+// expected to be JSON-encoded.
+func tabularFieldValue(collection *dal.Collection, name string, value string) interface{} {
+	if field, ok := collection.GetField(name); ok {
+		switch field.Type {
+		case dal.ObjectType, dal.ArrayType:
+			var decoded interface{}
+
+			if err := json.Unmarshal([]byte(value), &decoded); err == nil {
+				return memoryFieldValue(collection, name, decoded)
+			}
+		}
+
+		return memoryFieldValue(collection, name, value)
+	}
+
+	return stringutil.Autotype(value)
+}
+
+func projectTabularRecord(record *dal.Record, fields []string) {
+	for key := range record.Fields {
+		if !sliceutil.ContainsString(fields, key) {
+			delete(record.Fields, key)
+		}
+	}
+}
+
+// Returns the most specific type that all of the given sample values can be converted to.  Values
+// read from CSV and TSV files are strings; those read from JSON may be any JSON type.
+func inferTabularType(samples []interface{}) dal.Type {
+	if len(samples) == 0 {
+		return dal.StringType
+	}
+
+	candidates := []dal.Type{dal.IntType, dal.FloatType, dal.BooleanType, dal.TimeType, dal.ObjectType, dal.ArrayType}
+
+	for _, sample := range samples {
+		remaining := make([]dal.Type, 0, len(candidates))
+
+		for _, candidate := range candidates {
+			if isTabularType(candidate, sample) {
+				remaining = append(remaining, candidate)
+			}
+		}
+
+		if candidates = remaining; len(candidates) == 0 {
+			return dal.StringType
+		}
+	}
+
+	return candidates[0]
+}
+
+func isTabularType(candidate dal.Type, value interface{}) bool {
+	switch v := value.(type) {
+	case string:
+		switch candidate {
+		case dal.IntType:
+			_, err := strconv.ParseInt(v, 10, 64)
+			return (err == nil)
+		case dal.FloatType:
+			_, err := strconv.ParseFloat(v, 64)
+			return (err == nil)
+		case dal.BooleanType:
+			return (v == `true` || v == `false`)
+		case dal.TimeType:
+			return stringutil.IsTime(v)
+		}
+	case float64:
+		switch candidate {
+		case dal.IntType:
+			return (v == float64(int64(v)))
+		case dal.FloatType:
+			return true
+		}
+	case bool:
+		return (candidate == dal.BooleanType)
+	case map[string]interface{}:
+		return (candidate == dal.ObjectType)
+	case []interface{}:
+		return (candidate == dal.ArrayType)
+	}
+
+	return false
+}
+
+func newTabularScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), TabularMaxLineSize)
+	return scanner
+}
+
+// writes data to a temporary file in the same directory as filename, then renames it into place
+func writeFileAtomic(filename string, data []byte) error {
+	if tmp, err := ioutil.TempFile(filepath.Dir(filename), `.`+filepath.Base(filename)+`-`); err == nil {
+		defer os.Remove(tmp.Name())
+
+		if _, err := tmp.Write(data); err != nil {
+			tmp.Close()
+			return err
+		}
+
+		if err := tmp.Close(); err != nil {
+			return err
+		}
+
+		if err := chmodLike(tmp.Name(), filename); err != nil {
+			return err
+		}
+
+		return os.Rename(tmp.Name(), filename)
+	} else {
+		return err
+	}
+}
+
+// Temporary files are only readable by their owner, so before one replaces the named file it is given
+// the same permissions (or those of a newly-created file, if the named file doesn't exist).
+func chmodLike(tmpname string, filename string) error {
+	var mode os.FileMode = 0644
+
+	if stat, err := os.Stat(filename); err == nil {
+		mode = stat.Mode().Perm()
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	return os.Chmod(tmpname, mode)
+}
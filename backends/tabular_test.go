@@ -0,0 +1,292 @@
+package backends
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTabularBackendInference(t *testing.T) {
+	assert := require.New(t)
+
+	root, err := ioutil.TempDir(``, `pivot-backend-tabular-`)
+	assert.NoError(err)
+	defer os.RemoveAll(root)
+
+	data, err := ioutil.ReadFile(`../test/us-fips.csv`)
+	assert.NoError(err)
+	assert.NoError(ioutil.WriteFile(filepath.Join(root, `counties.csv`), data, 0600))
+
+	cs, err := dal.ParseConnectionString(`tabular://` + root)
+	assert.NoError(err)
+
+	backend := NewTabularBackend(cs)
+	assert.NoError(backend.Initialize())
+
+	names, err := backend.ListCollections()
+	assert.NoError(err)
+	assert.Equal([]string{`counties`}, names)
+
+	// the schema is inferred from the header and values, and records are identified by row number
+	collection, err := backend.GetCollection(`counties`)
+	assert.NoError(err)
+	assert.EqualValues(dal.IntType, collection.IdentityFieldType)
+	assert.Len(collection.Fields, 4)
+
+	for name, fieldType := range map[string]dal.Type{
+		`State`:       dal.StringType,
+		`County Name`: dal.StringType,
+		`FIPS State`:  dal.IntType,
+		`FIPS County`: dal.IntType,
+	} {
+		field, ok := collection.GetField(name)
+		assert.True(ok, name)
+		assert.Equal(fieldType, field.Type, name)
+	}
+
+	record, err := backend.Retrieve(`counties`, 2)
+	assert.NoError(err)
+	assert.Equal(`Baldwin`, record.Get(`County Name`))
+	assert.Equal(int64(3), record.Get(`FIPS County`))
+
+	recordset, err := backend.Query(collection, filter.MustParse(`State/Alabama`))
+	assert.NoError(err)
+	assert.Len(recordset.Records, 67)
+
+	// unsorted queries stop reading once the limit is reached
+	f := filter.MustParse(`State/Alabama`)
+	f.Limit = 2
+	f.Offset = 1
+
+	recordset, err = backend.Query(collection, f)
+	assert.NoError(err)
+	assert.Len(recordset.Records, 2)
+	assert.Equal(int64(2), recordset.Records[0].ID)
+	assert.Equal(int64(3), recordset.Records[1].ID)
+
+	f = filter.MustParse(`State/Alabama`)
+	f.Sort = []string{`-FIPS County`}
+	f.Limit = 1
+
+	recordset, err = backend.Query(collection, f)
+	assert.NoError(err)
+	assert.Len(recordset.Records, 1)
+	assert.Equal(`Winston`, recordset.Records[0].Get(`County Name`))
+	assert.EqualValues(67, recordset.ResultCount)
+
+	// deleting a row renumbers the rows after it
+	assert.NoError(backend.Delete(`counties`, 1))
+
+	record, err = backend.Retrieve(`counties`, 1)
+	assert.NoError(err)
+	assert.Equal(`Baldwin`, record.Get(`County Name`))
+
+	// new rows are numbered after the existing ones, and cannot be given IDs
+	recordset = dal.NewRecordSet(dal.NewRecord(nil).Set(`State`, `Nowhere`).Set(`FIPS State`, 99))
+	assert.NoError(backend.Insert(`counties`, recordset))
+	assert.Equal(int64(3142), recordset.Records[0].ID)
+	assert.Error(backend.Insert(`counties`, dal.NewRecordSet(dal.NewRecord(5000))))
+
+	record, err = backend.Retrieve(`counties`, 3142)
+	assert.NoError(err)
+	assert.Equal(`Nowhere`, record.Get(`State`))
+	assert.Nil(record.Get(`County Name`))
+}
+
+func TestTabularBackendFailedRewrite(t *testing.T) {
+	assert := require.New(t)
+
+	root, err := ioutil.TempDir(``, `pivot-backend-tabular-`)
+	assert.NoError(err)
+	defer os.RemoveAll(root)
+
+	data, err := ioutil.ReadFile(`../test/us-fips.csv`)
+	assert.NoError(err)
+	assert.NoError(ioutil.WriteFile(filepath.Join(root, `counties.csv`), data, 0600))
+
+	cs, err := dal.ParseConnectionString(`tabular://` + root)
+	assert.NoError(err)
+
+	backend := NewTabularBackend(cs)
+	assert.NoError(backend.Initialize())
+
+	// a failed update leaves the data file exactly as it was, without any temporary files
+	assert.Error(backend.Update(`counties`, dal.NewRecordSet(
+		dal.NewRecord(2).Set(`Population`, 182265),
+		dal.NewRecord(5000).Set(`State`, `Nowhere`),
+	)))
+
+	after, err := ioutil.ReadFile(filepath.Join(root, `counties.csv`))
+	assert.NoError(err)
+	assert.Equal(data, after)
+
+	entries, err := ioutil.ReadDir(root)
+	assert.NoError(err)
+	assert.Len(entries, 1)
+}
+
+func TestTabularBackendRewriteKeepsMode(t *testing.T) {
+	assert := require.New(t)
+
+	root, err := ioutil.TempDir(``, `pivot-backend-tabular-`)
+	assert.NoError(err)
+	defer os.RemoveAll(root)
+
+	filename := filepath.Join(root, `counties.csv`)
+
+	data, err := ioutil.ReadFile(`../test/us-fips.csv`)
+	assert.NoError(err)
+	assert.NoError(ioutil.WriteFile(filename, data, 0640))
+	assert.NoError(os.Chmod(filename, 0640))
+
+	cs, err := dal.ParseConnectionString(`tabular://` + root)
+	assert.NoError(err)
+
+	backend := NewTabularBackend(cs)
+	assert.NoError(backend.Initialize())
+
+	assert.NoError(backend.Update(`counties`, dal.NewRecordSet(
+		dal.NewRecord(2).Set(`Population`, 182265),
+	)))
+
+	stat, err := os.Stat(filename)
+	assert.NoError(err)
+	assert.Equal(os.FileMode(0640), stat.Mode().Perm())
+}
+
+func TestTabularBackendFormats(t *testing.T) {
+	for _, format := range []SerializationFormat{FormatCSV, FormatTSV, FormatNDJSON} {
+		assert := require.New(t)
+
+		root, err := ioutil.TempDir(``, `pivot-backend-tabular-`)
+		assert.NoError(err)
+		defer os.RemoveAll(root)
+
+		cs, err := dal.ParseConnectionString(`tabular+` + string(format) + `://` + root)
+		assert.NoError(err)
+
+		backend := NewTabularBackend(cs)
+		assert.NoError(backend.Initialize())
+
+		collection := dal.NewCollection(`TestTabularBackendFormats`).
+			AddFields(dal.Field{
+				Name: `name`,
+				Type: dal.StringType,
+			}, dal.Field{
+				Name: `count`,
+				Type: dal.IntType,
+			}, dal.Field{
+				Name: `tags`,
+				Type: dal.ArrayType,
+			}, dal.Field{
+				Name: `created_at`,
+				Type: dal.TimeType,
+			})
+
+		assert.NoError(backend.CreateCollection(collection))
+		assert.Error(backend.CreateCollection(collection))
+
+		base := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+		recordset := dal.NewRecordSet(
+			dal.NewRecord(nil).Set(`name`, "first, \"quoted\"").Set(`count`, 3).Set(`created_at`, base),
+			dal.NewRecord(nil).Set(`name`, `second`).Set(`count`, 1).Set(`tags`, []string{`a`, `b`}),
+			dal.NewRecord(10).Set(`name`, `tenth`).Set(`count`, 2),
+		)
+
+		assert.NoError(backend.Insert(collection.Name, recordset), string(format))
+		assert.Equal(int64(1), recordset.Records[0].ID)
+		assert.Equal(int64(2), recordset.Records[1].ID)
+
+		assert.Error(backend.Insert(collection.Name, dal.NewRecordSet(dal.NewRecord(10))))
+
+		recordset = dal.NewRecordSet(dal.NewRecord(nil).Set(`name`, `eleventh`))
+		assert.NoError(backend.Insert(collection.Name, recordset))
+		assert.Equal(int64(11), recordset.Records[0].ID)
+
+		// values are returned as the types declared in the schema
+		record, err := backend.Retrieve(collection.Name, `1`)
+		assert.NoError(err)
+		assert.Equal("first, \"quoted\"", record.Get(`name`))
+		assert.Equal(int64(3), record.Get(`count`))
+		assert.True(base.Equal(record.Get(`created_at`).(time.Time)))
+		assert.Nil(record.Get(`tags`))
+
+		record, err = backend.Retrieve(collection.Name, 2)
+		assert.NoError(err)
+		assert.Equal([]interface{}{`a`, `b`}, record.Get(`tags`))
+
+		// updates and deletes rewrite the data file
+		assert.NoError(backend.Update(collection.Name, dal.NewRecordSet(dal.NewRecord(2).Set(`count`, 5))))
+		assert.NoError(backend.Delete(collection.Name, 1))
+
+		// rewrites replace the data file without leaving temporary files behind
+		entries, err := ioutil.ReadDir(root)
+		assert.NoError(err)
+		assert.Len(entries, 2)
+
+		// the collection is loaded from its sidecar schema
+		restored := NewTabularBackend(cs)
+		assert.NoError(restored.Initialize())
+
+		definition, err := restored.GetCollection(collection.Name)
+		assert.NoError(err)
+		assert.Equal(collection.Fields, definition.Fields)
+
+		recordset, err = restored.Query(definition, filter.All())
+		assert.NoError(err)
+		assert.Len(recordset.Records, 3)
+
+		assert.NoError(restored.DeleteCollection(collection.Name))
+		assert.Error(restored.DeleteCollection(collection.Name))
+
+		entries, err = ioutil.ReadDir(root)
+		assert.NoError(err)
+		assert.Empty(entries)
+	}
+}
+
+func TestTabularBackendInferNDJSON(t *testing.T) {
+	assert := require.New(t)
+
+	root, err := ioutil.TempDir(``, `pivot-backend-tabular-`)
+	assert.NoError(err)
+	defer os.RemoveAll(root)
+
+	assert.NoError(ioutil.WriteFile(filepath.Join(root, `events.ndjson`), []byte(
+		`{"id":"a","count":1,"ratio":0.5,"ok":true,"at":"2018-01-01T00:00:00Z","meta":{"x":1}}`+"\n"+
+			`{"id":"b","count":2,"ratio":1,"ok":false}`+"\n",
+	), 0600))
+
+	cs, err := dal.ParseConnectionString(`tabular+ndjson://` + root)
+	assert.NoError(err)
+
+	backend := NewTabularBackend(cs)
+	assert.NoError(backend.Initialize())
+
+	collection, err := backend.GetCollection(`events`)
+	assert.NoError(err)
+	assert.EqualValues(dal.StringType, collection.IdentityFieldType)
+
+	for name, fieldType := range map[string]dal.Type{
+		`count`: dal.IntType,
+		`ratio`: dal.FloatType,
+		`ok`:    dal.BooleanType,
+		`at`:    dal.TimeType,
+		`meta`:  dal.ObjectType,
+	} {
+		field, ok := collection.GetField(name)
+		assert.True(ok, name)
+		assert.Equal(fieldType, field.Type, name)
+	}
+
+	record, err := backend.Retrieve(`events`, `b`)
+	assert.NoError(err)
+	assert.Equal(int64(2), record.Get(`count`))
+	assert.Equal(false, record.Get(`ok`))
+}
@@ -1,6 +1,7 @@
 package dal
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
 
@@ -40,6 +41,7 @@ type Collection struct {
 	IdentityFieldFormatter   FieldFormatterFunc      `json:"-"`
 	IdentityFieldValidator   FieldValidatorFunc      `json:"-"`
 	PreSaveValidator         CollectionValidatorFunc `json:"-"`
+	Rules                    []string                `json:"rules,omitempty"`
 	recordType               reflect.Type
 	instanceInitializer      InitializerFunc
 }
@@ -120,6 +122,14 @@ func (self *Collection) ApplyDefinition(definition *Collection) error {
 			self.IdentityFieldValidator = fn
 		}
 
+		if fn := definition.PreSaveValidator; fn != nil {
+			self.PreSaveValidator = fn
+		}
+
+		if len(definition.Rules) > 0 {
+			self.Rules = definition.Rules
+		}
+
 		for i, field := range self.Fields {
 			if defField, ok := definition.GetField(field.Name); ok {
 				if field.Description == `` {
@@ -144,6 +154,10 @@ func (self *Collection) ApplyDefinition(definition *Collection) error {
 				self.Fields[i].ValidateOnPopulate = defField.ValidateOnPopulate
 				self.Fields[i].Validator = defField.Validator
 				self.Fields[i].Formatter = defField.Formatter
+				self.Fields[i].FormatterConfig = defField.FormatterConfig
+				self.Fields[i].ValidatorConfig = defField.ValidatorConfig
+				self.Fields[i].Expression = defField.Expression
+				self.Fields[i].Path = defField.Path
 			} else {
 				return fmt.Errorf("Definition is missing field %q", field.Name)
 			}
@@ -199,7 +213,7 @@ func (self *Collection) NewInstance(initializers ...InitializerFunc) interface{}
 		instanceV = reflect.ValueOf(instance).Elem()
 	}
 
-	structFields, _ := getFieldsForStruct(instance)
+	structFields, _ := getFieldsForStruct(instance, true)
 
 	for _, field := range self.Fields {
 		var zeroValue interface{}
@@ -241,7 +255,7 @@ func (self *Collection) NewInstance(initializers ...InitializerFunc) interface{}
 			case reflect.Struct:
 				if structFields != nil {
 					if fieldDescr, ok := structFields[field.Name]; ok {
-						typeutil.SetValue(fieldDescr.ReflectField, zeroValue)
+						setStructValue(fieldDescr.ReflectField, zeroValue)
 					}
 				}
 			}
@@ -267,8 +281,18 @@ func (self *Collection) NewInstance(initializers ...InitializerFunc) interface{}
 }
 
 func (self *Collection) FillDefaults(record *Record) {
+	self.fillDefaults(record, nil)
+}
+
+// like FillDefaults, except that fields which have a value in the stored record (if given) are
+// left out, so that an update doesn't replace them with their defaults
+func (self *Collection) fillDefaults(record *Record, stored *Record) {
 	for _, field := range self.Fields {
 		if field.DefaultValue != nil {
+			if stored != nil && !typeutil.IsZero(stored.Get(field.Name)) {
+				continue
+			}
+
 			if typeutil.IsZero(record.Get(field.Name)) {
 				record.Set(field.Name, field.GetDefaultValue())
 			}
@@ -333,6 +357,12 @@ func (self *Collection) ConvertValue(name string, value interface{}) interface{}
 }
 
 func (self *Collection) formatAndValidateId(id interface{}, op FieldOperation, record *Record) (interface{}, error) {
+	idFieldName := self.IdentityField
+
+	if idFieldName == `` {
+		idFieldName = DefaultIdentityField
+	}
+
 	// if specified, apply a formatter to the ID
 	if self.IdentityFieldFormatter != nil {
 		// NOTE: because we want the option to generate IDs based on the values of other record fields,
@@ -340,58 +370,159 @@ func (self *Collection) formatAndValidateId(id interface{}, op FieldOperation, r
 		if idI, err := self.IdentityFieldFormatter(record, op); err == nil {
 			id = idI
 		} else {
-			return id, err
+			return id, &FieldError{
+				Field:   idFieldName,
+				Rule:    `formatter`,
+				Message: err.Error(),
+			}
 		}
 	}
 
 	// if given, validate the ID value
 	if self.IdentityFieldValidator != nil {
 		if err := self.IdentityFieldValidator(id); err != nil {
-			return id, err
+			return id, &FieldError{
+				Field:   idFieldName,
+				Rule:    `validator`,
+				Message: err.Error(),
+			}
 		}
 	}
 
 	return id, nil
 }
 
-// Generates a Record instance from the given value based on this collection's schema.
-func (self *Collection) MakeRecord(in interface{}) (*Record, error) {
+// Like formatAndValidateField, except that if the field uses a formatter that can't be applied
+// twice (see RegisterOnceFormatter), a value that is the same as the one in the existing record (if
+// given) is kept as it was stored rather than being formatted again.  It is still validated.
+func (self *Collection) formatAndValidateUpdatedField(field Field, value interface{}, existing *Record, verr *ValidationError) (interface{}, bool) {
+	if existing != nil && hasOnceFormatter(field.FormatterConfig) {
+		if stored, ok := existing.Fields[field.Name]; ok && stored != nil && reflect.DeepEqual(stored, value) {
+			return self.validateField(field, stored, verr)
+		}
+	}
+
+	return self.formatAndValidateField(field, value, verr)
+}
+
+// formats, converts (for the extended types), and validates a single field value, adding any
+// failure to the given ValidationError
+func (self *Collection) formatAndValidateField(field Field, value interface{}, verr *ValidationError) (interface{}, bool) {
+	if v, err := field.Format(value, PersistOperation); err == nil {
+		return self.validateField(field, v, verr)
+	} else {
+		verr.Add(field.Name, `formatter`, err)
+	}
+
+	return value, false
+}
+
+// converts (for the extended types) and validates an already-formatted field value, adding any
+// failure to the given ValidationError
+func (self *Collection) validateField(field Field, value interface{}, verr *ValidationError) (interface{}, bool) {
+	if field.Type.IsExtended() {
+		if cv, err := field.ConvertValue(value); err == nil {
+			value = cv
+		} else {
+			verr.Add(field.Name, `type`, err)
+			return value, false
+		}
+	}
+
+	if err := field.Validate(value); err == nil {
+		return value, true
+	} else {
+		verr.Add(field.Name, `validator`, err)
+	}
+
+	return value, false
+}
+
+// Generates a Record instance from the given value based on this collection's schema.  If any
+// fields fail formatting or validation (or are required but missing), a *ValidationError listing
+// every failure is returned.
+//
+// If the record replaces a stored one, the stored record can be given so that values of hashed
+// fields that are unchanged from it are kept as they were stored (and not hashed a second time).
+func (self *Collection) MakeRecord(in interface{}, existing ...*Record) (*Record, error) {
+	if len(existing) > 0 {
+		return self.makeRecord(in, false, existing[0])
+	} else {
+		return self.makeRecord(in, false, nil)
+	}
+}
+
+// Generates a Record instance from the given value for updating an existing record with only the
+// fields present in the value.  Unlike MakeRecord, required fields only fail if they are explicitly
+// set to nil.
+//
+// The stored record being updated should be given (if it exists).  Computed fields and rules are
+// evaluated against the stored record with the new values merged over it, default values are only
+// filled in for fields that the stored record doesn't have a value for, and values of hashed fields
+// that are unchanged from it are kept as they were stored (so that a retrieved record that is saved
+// again doesn't have its hashed values hashed a second time).
+func (self *Collection) MakeRecordForUpdate(in interface{}, existing ...*Record) (*Record, error) {
+	if len(existing) > 0 {
+		return self.makeRecord(in, true, existing[0])
+	} else {
+		return self.makeRecord(in, true, nil)
+	}
+}
+
+func (self *Collection) makeRecord(in interface{}, update bool, existing *Record) (*Record, error) {
 	var idFieldName string
 
 	if err := validatePtrToStructType(in); err != nil {
 		return nil, err
 	}
 
+	verr := NewValidationError(self.Name)
+
+	// the stored record is only merged with records that update it, not those that replace it
+	var merge *Record
+
+	if update {
+		merge = existing
+	}
+
 	// if the argument is already a record, return it as-is
 	if record, ok := in.(*Record); ok {
-		self.FillDefaults(record)
+		self.fillDefaults(record, merge)
 
 		// we're returning the record we were given, but first we need to validate and format it
 		for key, value := range record.Fields {
 			if field, ok := self.GetField(key); ok {
-				if v, err := field.Format(value, PersistOperation); err == nil {
-					if err := field.Validate(v); err == nil {
-						record.Fields[key] = v
-					} else {
-						return nil, err
-					}
-				} else {
-					return nil, err
+				if field.IsDerived() {
+					continue
+				}
+
+				if v, ok := self.formatAndValidateUpdatedField(field, value, existing, verr); ok {
+					record.Fields[key] = v
 				}
 			} else {
 				delete(record.Fields, key)
 			}
 		}
 
+		self.formatDerivedFields(record, merge, verr, nil)
+
 		// validate ID value
 		if idI, err := self.formatAndValidateId(record.ID, PersistOperation, record); err == nil {
 			record.ID = idI
 		} else {
-			return nil, err
+			verr.Add(self.IdentityField, `validator`, err)
+		}
+
+		if !update {
+			self.validateRequiredFields(record, verr)
 		}
 
 		// validate whole record (if specified)
-		if err := self.ValidateRecord(record, PersistOperation); err != nil {
+		if err := self.validateRecord(record, merge, PersistOperation); err != nil {
+			verr.Add(``, `record`, err)
+		}
+
+		if err := verr.OrNil(); err != nil {
 			return nil, err
 		}
 
@@ -402,63 +533,61 @@ func (self *Collection) MakeRecord(in interface{}) (*Record, error) {
 	record := NewRecord(nil)
 
 	// populate it with default values
-	self.FillDefaults(record)
+	self.fillDefaults(record, merge)
 
 	// get details for the fields present on the given input struct
-	if fields, err := getFieldsForStruct(in); err == nil {
+	if fields, err := getFieldsForStruct(in, false); err == nil {
 		// for each field descriptor...
 		for tagName, fieldDescr := range fields {
-			if fieldDescr.Field.IsExported() {
-				value := fieldDescr.Field.Value()
-
-				// set the ID field if this field is explicitly marked as the identity
-				if fieldDescr.Identity && !typeutil.IsZero(fieldDescr.Field) {
-					idFieldName = tagName
-					record.ID = value
-				} else {
-					if collectionField, ok := self.GetField(tagName); ok {
-						// validate and format value according to the collection field's rules
-						if v, err := collectionField.Format(value, PersistOperation); err == nil {
-							if err := collectionField.Validate(v); err == nil {
-								value = v
-							} else {
-								return nil, err
-							}
-						} else {
-							return nil, err
-						}
+			value := recordValueFromStruct(fieldDescr.ReflectField)
 
-						// if we're supposed to skip empty values, and this value is indeed empty, skip
-						if fieldDescr.OmitEmpty && typeutil.IsZero(value) {
-							continue
-						}
+			// set the ID field if this field is explicitly marked as the identity
+			if fieldDescr.Identity && !fieldDescr.IsZero() {
+				idFieldName = tagName
+				record.ID = value
+			} else {
+				if collectionField, ok := self.GetField(tagName); ok {
+					if collectionField.IsDerived() {
+						continue
+					}
 
-						// set the value in the output record
-						record.Set(tagName, value)
+					// validate and format value according to the collection field's rules
+					if v, ok := self.formatAndValidateUpdatedField(collectionField, value, existing, verr); ok {
+						value = v
+					} else {
+						continue
+					}
 
-						// make sure the corresponding value in the input struct matches
-						typeutil.SetValue(
-							fieldDescr.ReflectField,
-							value,
-						)
+					// if we're supposed to skip empty values, and this value is indeed empty, skip
+					if fieldDescr.OmitEmpty && typeutil.IsZero(value) {
+						continue
 					}
+
+					// set the value in the output record
+					record.Set(tagName, value)
+
+					// make sure the corresponding value in the input struct matches
+					setStructValue(
+						fieldDescr.ReflectField,
+						value,
+					)
 				}
 			}
 		}
 
+		self.formatDerivedFields(record, merge, verr, fields)
+
 		// an identity column was not explicitly specified, so try to find the column that matches
 		// our identity field name
 		if record.ID == nil {
 			for tagName, fieldDescr := range fields {
 				if tagName == self.IdentityField {
-					if fieldDescr.Field.IsExported() {
-						// skip fields containing a zero value
-						if !typeutil.IsZero(fieldDescr.Field) {
-							idFieldName = tagName
-							record.ID = fieldDescr.Field.Value()
-							delete(record.Fields, tagName)
-							break
-						}
+					// skip fields containing a zero value
+					if !fieldDescr.IsZero() {
+						idFieldName = tagName
+						record.ID = recordValueFromStruct(fieldDescr.ReflectField)
+						delete(record.Fields, tagName)
+						break
 					}
 				}
 			}
@@ -468,9 +597,9 @@ func (self *Collection) MakeRecord(in interface{}) (*Record, error) {
 		for _, fieldName := range []string{`id`, `ID`, `Id`} {
 			if record.ID == nil {
 				if f, ok := fields[fieldName]; ok {
-					if !f.Field.IsZero() {
+					if !f.IsZero() {
 						idFieldName = fieldName
-						record.ID = f.Field.Value()
+						record.ID = recordValueFromStruct(f.ReflectField)
 						delete(record.Fields, fieldName)
 						break
 					}
@@ -488,11 +617,19 @@ func (self *Collection) MakeRecord(in interface{}) (*Record, error) {
 				}
 			}
 		} else {
-			return nil, err
+			verr.Add(self.IdentityField, `validator`, err)
+		}
+
+		if !update {
+			self.validateRequiredFields(record, verr)
 		}
 
 		// validate whole record (if specified)
-		if err := self.ValidateRecord(record, PersistOperation); err != nil {
+		if err := self.validateRecord(record, merge, PersistOperation); err != nil {
+			verr.Add(``, `record`, err)
+		}
+
+		if err := verr.OrNil(); err != nil {
 			return nil, err
 		}
 
@@ -502,7 +639,65 @@ func (self *Collection) MakeRecord(in interface{}) (*Record, error) {
 	}
 }
 
+// derived fields are formatted once all other fields are populated, since their formatters are
+// passed the whole record.  Computed fields are evaluated in the order they are declared, so an
+// expression may refer to computed fields that precede it.  If the record came from a struct, the
+// struct field is updated to match.  When updating, fields missing from the record are taken from the
+// existing one (if given).
+func (self *Collection) formatDerivedFields(record *Record, existing *Record, verr *ValidationError, fields map[string]fieldDescription) {
+	for _, field := range self.Fields {
+		if !field.IsDerived() {
+			continue
+		}
+
+		var input interface{} = mergeRecords(existing, record)
+
+		if field.IsComputed() {
+			if expr, err := compileExpression(field.Expression); err == nil {
+				if v, err := expr.Evaluate(self.expressionValues(record, existing)); err == nil {
+					input = v
+				} else {
+					verr.Add(field.Name, `expression`, err)
+					continue
+				}
+			} else {
+				verr.Add(field.Name, `expression`, err)
+				continue
+			}
+		}
+
+		if v, ok := self.formatAndValidateField(field, input, verr); ok {
+			record.Set(field.Name, v)
+
+			if fieldDescr, ok := fields[field.Name]; ok {
+				setStructValue(fieldDescr.ReflectField, v)
+			}
+		}
+	}
+}
+
+// required fields must be present in a record, regardless of whether the backend enforces this
+func (self *Collection) validateRequiredFields(record *Record, verr *ValidationError) {
+	for _, field := range self.Fields {
+		if !field.Required || field.Identity || field.Name == self.IdentityField {
+			continue
+		}
+
+		if _, ok := record.Fields[field.Name]; !ok && !verr.hasField(field.Name) {
+			verr.Add(field.Name, `required`, field.Validate(nil))
+		}
+	}
+}
+
+// Validates the record as a whole using the collection's PreSaveValidator and rule expressions.  If
+// any rules are not satisfied, a *ValidationError listing each of them is returned.
 func (self *Collection) ValidateRecord(record *Record, op FieldOperation) error {
+	return self.validateRecord(record, nil, op)
+}
+
+// when updating, rules are checked against the existing record (if given) with the record merged
+// over it
+func (self *Collection) validateRecord(record *Record, existing *Record, op FieldOperation) error {
 	switch op {
 	case PersistOperation:
 		// validate whole record (if specified)
@@ -511,11 +706,93 @@ func (self *Collection) ValidateRecord(record *Record, op FieldOperation) error
 				return err
 			}
 		}
+
+		if len(self.Rules) > 0 {
+			verr := NewValidationError(self.Name)
+			values := self.expressionValues(record, existing)
+
+			for _, rule := range self.Rules {
+				if expr, err := compileExpression(rule); err == nil {
+					if ok, err := expr.Test(values); err != nil {
+						verr.Add(``, `rule`, fmt.Errorf("rule %q: %v", rule, err))
+					} else if !ok {
+						verr.Add(``, `rule`, fmt.Errorf("record must satisfy: %v", expr))
+					}
+				} else {
+					verr.Add(``, `rule`, fmt.Errorf("rule %q: %v", rule, err))
+				}
+			}
+
+			return verr.OrNil()
+		}
 	}
 
 	return nil
 }
 
+// the values available to rule and computed field expressions: all fields (including those of the
+// existing record that aren't being updated) plus the identity
+func (self *Collection) expressionValues(record *Record, existing *Record) map[string]interface{} {
+	merged := mergeRecords(existing, record)
+	values := make(map[string]interface{})
+
+	for k, v := range merged.Fields {
+		values[k] = v
+	}
+
+	if self.IdentityField != `` {
+		values[self.IdentityField] = merged.ID
+	}
+
+	return values
+}
+
+// returns the given record with its fields merged over those of the existing record, or the record
+// itself if there is no existing one
+func mergeRecords(existing *Record, record *Record) *Record {
+	if existing == nil {
+		return record
+	}
+
+	merged := NewRecord(record.ID)
+	merged.Data = record.Data
+
+	if merged.ID == nil {
+		merged.ID = existing.ID
+	}
+
+	for k, v := range existing.Fields {
+		merged.Fields[k] = v
+	}
+
+	for k, v := range record.Fields {
+		merged.Fields[k] = v
+	}
+
+	return merged
+}
+
+func (self *Collection) UnmarshalJSON(b []byte) error {
+	type Alias Collection
+
+	if err := json.Unmarshal(b, &struct {
+		*Alias
+	}{
+		Alias: (*Alias)(self),
+	}); err == nil {
+		// surface invalid rules when the schema is loaded rather than when records are saved
+		for _, rule := range self.Rules {
+			if _, err := compileExpression(rule); err != nil {
+				return fmt.Errorf("rule %q: %v", rule, err)
+			}
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
 func (self *Collection) Diff(actual *Collection) []SchemaDelta {
 	differences := make([]SchemaDelta, 0)
 
@@ -558,21 +835,27 @@ func (self *Collection) Diff(actual *Collection) []SchemaDelta {
 	}
 
 	for _, myField := range self.Fields {
+		// keep a reference to the desired field definition so that backends can use it to
+		// resolve the difference
+		reference := myField
+
 		if theirField, ok := actual.GetField(myField.Name); ok {
 			if diff := myField.Diff(&theirField); diff != nil {
 				for i, _ := range diff {
 					diff[i].Collection = self.Name
+					diff[i].ReferenceField = &reference
 				}
 
 				differences = append(differences, diff...)
 			}
 		} else {
 			differences = append(differences, SchemaDelta{
-				Type:       FieldDelta,
-				Issue:      FieldMissingIssue,
-				Message:    `is missing`,
-				Collection: self.Name,
-				Name:       myField.Name,
+				Type:           FieldDelta,
+				Issue:          FieldMissingIssue,
+				Message:        `is missing`,
+				Collection:     self.Name,
+				Name:           myField.Name,
+				ReferenceField: &reference,
 			})
 		}
 	}
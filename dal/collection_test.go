@@ -142,3 +142,133 @@ func TestCollectionValidator(t *testing.T) {
 	assert.Error(collection.ValidateRecord(NewRecord(`two`), PersistOperation))
 	assert.NoError(collection.ValidateRecord(NewRecord(`three`), PersistOperation))
 }
+
+func TestCollectionMakeRecordValidationErrors(t *testing.T) {
+	assert := require.New(t)
+
+	collection := NewCollection(`TestCollectionMakeRecordValidationErrors`)
+	collection.AddFields([]Field{
+		{
+			Name:     `name`,
+			Type:     StringType,
+			Required: true,
+		}, {
+			Name: `color`,
+			Type: StringType,
+			ValidatorConfig: map[string]interface{}{
+				`one-of`: []interface{}{`red`, `green`, `blue`},
+			},
+		}, {
+			Name: `age`,
+			Type: IntType,
+			Formatter: func(value interface{}, op FieldOperation) (interface{}, error) {
+				return nil, fmt.Errorf("cannot format %v", value)
+			},
+		},
+	}...)
+
+	for i, field := range collection.Fields {
+		if len(field.ValidatorConfig) > 0 {
+			validator, err := ValidatorFromMap(field.ValidatorConfig)
+			assert.NoError(err)
+			collection.Fields[i].Validator = validator
+		}
+	}
+
+	_, err := collection.MakeRecord(NewRecord(`one`).Set(`color`, `orange`).Set(`age`, 42))
+	assert.Error(err)
+	assert.True(IsValidationErr(err))
+
+	verr := err.(*ValidationError)
+	assert.Equal(`TestCollectionMakeRecordValidationErrors`, verr.Collection)
+	assert.Len(verr.Errors, 3)
+
+	assert.Equal(`age`, verr.Errors[0].Field)
+	assert.Equal(`formatter`, verr.Errors[0].Rule)
+	assert.Equal(`cannot format 42`, verr.Errors[0].Message)
+
+	assert.Equal(`color`, verr.Errors[1].Field)
+	assert.Equal(`one-of`, verr.Errors[1].Rule)
+
+	assert.Equal(`name`, verr.Errors[2].Field)
+	assert.Equal(`required`, verr.Errors[2].Rule)
+
+	assert.Equal(map[string][]string{
+		`age`:   []string{`cannot format 42`},
+		`color`: []string{verr.Errors[1].Message},
+		`name`:  []string{`is required`},
+	}, verr.Fields())
+
+	// explicitly setting a required field to nil fails the same way as omitting it
+	_, err = collection.MakeRecord(NewRecord(`two`).Set(`name`, nil).Set(`color`, `red`))
+	assert.Error(err)
+	assert.Len(err.(*ValidationError).Errors, 1)
+	assert.Equal(`required`, err.(*ValidationError).Errors[0].Rule)
+
+	record, err := collection.MakeRecord(NewRecord(`three`).Set(`name`, `Three`).Set(`color`, `red`))
+	assert.NoError(err)
+	assert.Equal(`Three`, record.Get(`name`))
+
+	// updates may leave out required fields, but may not set them to nil
+	record, err = collection.MakeRecordForUpdate(NewRecord(`three`).Set(`color`, `blue`))
+	assert.NoError(err)
+	assert.Equal(map[string]interface{}{
+		`color`: `blue`,
+	}, record.Fields)
+
+	_, err = collection.MakeRecordForUpdate(NewRecord(`three`).Set(`name`, nil))
+	assert.Error(err)
+	assert.Equal(`required`, err.(*ValidationError).Errors[0].Rule)
+}
+
+func TestCollectionMakeRecordForUpdateUnchanged(t *testing.T) {
+	assert := require.New(t)
+
+	hash, err := Hash(`sha256`, nil)
+	assert.NoError(err)
+
+	validated := make([]interface{}, 0)
+	collection := NewCollection(`TestCollectionMakeRecordForUpdateUnchanged`).
+		AddFields(Field{
+			Name:      `password`,
+			Type:      StringType,
+			Formatter: hash,
+			FormatterConfig: map[string]interface{}{
+				`hash`: `sha256`,
+			},
+			Validator: func(value interface{}) error {
+				validated = append(validated, value)
+				return nil
+			},
+		}, Field{
+			Name: `name`,
+			Type: StringType,
+		})
+
+	stored, err := collection.MakeRecord(NewRecord(`one`).Set(`password`, `secret`).Set(`name`, `first`))
+	assert.NoError(err)
+	assert.Equal(`2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b`, stored.Get(`password`))
+
+	// hashed values that are the same as the stored ones are not hashed again
+	record, err := collection.MakeRecordForUpdate(
+		NewRecord(`one`).Set(`password`, stored.Get(`password`)).Set(`name`, `second`),
+		stored,
+	)
+
+	assert.NoError(err)
+	assert.Equal(`2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b`, record.Get(`password`))
+	assert.Equal(`second`, record.Get(`name`))
+
+	// ...but they are still validated
+	assert.Len(validated, 2)
+	assert.Equal(stored.Get(`password`), validated[1])
+
+	// changed values are always hashed, however they look
+	record, err = collection.MakeRecordForUpdate(
+		NewRecord(`one`).Set(`password`, `e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855`),
+		stored,
+	)
+
+	assert.NoError(err)
+	assert.Equal(`cd372fb85148700fa88095e3492d3f9f5beb43e555e5ff26d95f5a6adc36f8e6`, record.Get(`password`))
+}
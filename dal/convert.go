@@ -0,0 +1,222 @@
+package dal
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/typeutil"
+)
+
+var decimalPattern = regexp.MustCompile(`^[-+]?(\d+\.?\d*|\.\d+)([eE][-+]?\d+)?$`)
+
+// Converts the given value to a decimal number string.  Values are handled as exact decimals
+// rather than floating point numbers, so no precision is lost for values that are already strings.
+// If places is greater than zero, the value is rounded to that many decimal places.
+func ConvertToDecimal(in interface{}, places int) (string, error) {
+	var str string
+
+	switch v := in.(type) {
+	case float32:
+		str = strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case float64:
+		str = strconv.FormatFloat(v, 'f', -1, 64)
+	case []byte:
+		str = string(v)
+	case *big.Rat:
+		str = v.FloatString(decimalPlacesNeeded(v))
+	case *big.Float:
+		str = v.Text('f', -1)
+	default:
+		str = fmt.Sprintf("%v", in)
+	}
+
+	str = strings.TrimSpace(str)
+
+	if !decimalPattern.MatchString(str) {
+		return ``, fmt.Errorf("expected a decimal number, got: %v", in)
+	}
+
+	rat, ok := new(big.Rat).SetString(str)
+
+	if !ok {
+		return ``, fmt.Errorf("expected a decimal number, got: %v", in)
+	}
+
+	if places > 0 {
+		return rat.FloatString(places), nil
+	} else if strings.ContainsAny(str, `eE`) {
+		return rat.FloatString(decimalPlacesNeeded(rat)), nil
+	}
+
+	// keep the value as given, aside from normalizing the sign and leading zero
+	str = strings.TrimPrefix(str, `+`)
+
+	if strings.HasPrefix(str, `.`) {
+		str = `0` + str
+	} else if strings.HasPrefix(str, `-.`) {
+		str = `-0` + strings.TrimPrefix(str, `-`)
+	}
+
+	return str, nil
+}
+
+// returns the number of decimal places required to represent the given rational number exactly
+func decimalPlacesNeeded(rat *big.Rat) int {
+	for places := 0; places < 64; places++ {
+		if check, ok := new(big.Rat).SetString(rat.FloatString(places)); ok && check.Cmp(rat) == 0 {
+			return places
+		}
+	}
+
+	return 64
+}
+
+// Converts the given value to a UUID in canonical form (lowercase, hyphenated).  Strings may be
+// given with or without hyphens, braces, or a "urn:uuid:" prefix; byte slices must be 16 bytes
+// long.
+func ConvertToUUID(in interface{}) (string, error) {
+	var raw []byte
+
+	switch v := in.(type) {
+	case [16]byte:
+		raw = v[:]
+	case []byte:
+		if len(v) == 16 {
+			raw = v
+		} else {
+			return ConvertToUUID(string(v))
+		}
+	default:
+		str := strings.ToLower(strings.TrimSpace(fmt.Sprintf("%v", in)))
+		str = strings.TrimPrefix(str, `urn:uuid:`)
+		str = strings.TrimSuffix(strings.TrimPrefix(str, `{`), `}`)
+
+		if len(str) == 36 && !uuidPattern.MatchString(str) {
+			return ``, fmt.Errorf("expected a UUID, got: %v", in)
+		}
+
+		if v, err := hex.DecodeString(strings.Replace(str, `-`, ``, -1)); err == nil && len(v) == 16 {
+			raw = v
+		} else {
+			return ``, fmt.Errorf("expected a UUID, got: %v", in)
+		}
+	}
+
+	out := hex.EncodeToString(raw)
+
+	return out[0:8] + `-` + out[8:12] + `-` + out[12:16] + `-` + out[16:20] + `-` + out[20:32], nil
+}
+
+// Converts the given value to a byte slice.  Strings are used as-is; values that were decoded from
+// JSON, in which byte slices are encoded as base64, are decoded using DecodeJSONValue first.
+func ConvertToBytes(in interface{}) ([]byte, error) {
+	switch v := in.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	case fmt.Stringer:
+		return []byte(v.String()), nil
+	default:
+		return nil, fmt.Errorf("cannot convert %T to bytes", in)
+	}
+}
+
+// Byte slices are encoded in JSON as base64 strings.  Given a value that was decoded from JSON, this
+// decodes strings given for bytes fields; all other values are returned as they are.
+func (self *Field) DecodeJSONValue(in interface{}) (interface{}, error) {
+	if v, ok := in.(string); ok && self.Type == BytesType {
+		if decoded, err := base64.StdEncoding.DecodeString(v); err == nil {
+			return decoded, nil
+		} else {
+			return nil, fmt.Errorf("field %q: invalid base64 value: %v", self.Name, err)
+		}
+	}
+
+	return in, nil
+}
+
+// Decodes the values of a record that was decoded from JSON (see Field.DecodeJSONValue).
+func (self *Collection) DecodeJSONRecord(record *Record) error {
+	for key, value := range record.Fields {
+		if field, ok := self.GetField(key); ok {
+			if v, err := field.DecodeJSONValue(value); err == nil {
+				record.Fields[key] = v
+			} else {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// converts each element of the given value (which may be an array, slice, or JSON-encoded array) to
+// this field's subtype
+func (self *Field) convertList(in interface{}) ([]interface{}, error) {
+	var items []interface{}
+
+	switch v := in.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		in = string(v)
+	}
+
+	if str, ok := in.(string); ok {
+		if str = strings.TrimSpace(str); str == `` {
+			return nil, nil
+		} else if strings.HasPrefix(str, `[`) {
+			if err := json.Unmarshal([]byte(str), &items); err != nil {
+				return nil, fmt.Errorf("invalid list: %v", err)
+			}
+		} else {
+			items = []interface{}{str}
+		}
+	} else if typeutil.IsArray(in) {
+		items = sliceutil.Sliceify(in)
+	} else {
+		items = []interface{}{in}
+	}
+
+	element := &Field{
+		Name:      self.Name,
+		Type:      self.Subtype,
+		Precision: self.Precision,
+		Values:    self.Values,
+	}
+
+	// elements are only converted if the list is declared with a concrete element type
+	switch element.Type {
+	case ``, AutoType, ArrayType:
+		return items, nil
+	}
+
+	output := make([]interface{}, len(items))
+
+	for i, item := range items {
+		if v, err := element.ConvertValue(item); err == nil {
+			output[i] = v
+		} else {
+			return nil, fmt.Errorf("element %d: %v", i, err)
+		}
+	}
+
+	return output, nil
+}
+
+// returns an error if the given value is not one of this field's enumerated values
+func (self *Field) checkEnum(value string) error {
+	if len(self.Values) > 0 && !sliceutil.ContainsString(self.Values, value) {
+		return fmt.Errorf("expected one of: %s; got: %q", strings.Join(self.Values, `, `), value)
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package dal
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -34,3 +35,106 @@ func IsExistError(err error) bool {
 
 	return strings.HasSuffix(err.Error(), ` already exists`)
 }
+
+// Describes a single field that failed formatting or validation.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (self *FieldError) Error() string {
+	if self.Field == `` {
+		return self.Message
+	}
+
+	return fmt.Sprintf("field %q: %s", self.Field, self.Message)
+}
+
+// Returned when a record fails formatting or validation.  Rather than stopping at the first
+// failure, every failing field is listed.
+type ValidationError struct {
+	Collection string        `json:"collection,omitempty"`
+	Errors     []*FieldError `json:"errors"`
+}
+
+func NewValidationError(collection string) *ValidationError {
+	return &ValidationError{
+		Collection: collection,
+		Errors:     make([]*FieldError, 0),
+	}
+}
+
+func (self *ValidationError) Error() string {
+	messages := make([]string, len(self.Errors))
+
+	for i, ferr := range self.Errors {
+		messages[i] = ferr.Error()
+	}
+
+	return fmt.Sprintf("validation failed: %s", strings.Join(messages, `; `))
+}
+
+// Adds the given error to the list of failures.  Errors that are already FieldErrors (or
+// ValidationErrors) are added as-is; all others are attributed to the given field and rule.
+func (self *ValidationError) Add(field string, rule string, err error) {
+	switch err.(type) {
+	case *FieldError:
+		ferr := err.(*FieldError)
+
+		if ferr.Field == `` {
+			ferr.Field = field
+		}
+
+		self.Errors = append(self.Errors, ferr)
+
+	case *ValidationError:
+		self.Errors = append(self.Errors, err.(*ValidationError).Errors...)
+
+	default:
+		self.Errors = append(self.Errors, &FieldError{
+			Field:   field,
+			Rule:    rule,
+			Message: err.Error(),
+		})
+	}
+}
+
+// Returns a map of field names to all error messages for that field.
+func (self *ValidationError) Fields() map[string][]string {
+	fields := make(map[string][]string)
+
+	for _, ferr := range self.Errors {
+		fields[ferr.Field] = append(fields[ferr.Field], ferr.Message)
+	}
+
+	return fields
+}
+
+// Returns the error, or nil if there are no failures.
+func (self *ValidationError) OrNil() error {
+	if len(self.Errors) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(self.Errors, func(i int, j int) bool {
+		return self.Errors[i].Field < self.Errors[j].Field
+	})
+
+	return self
+}
+
+func IsValidationErr(err error) bool {
+	_, ok := err.(*ValidationError)
+	return ok
+}
+
+func (self *ValidationError) hasField(field string) bool {
+	for _, ferr := range self.Errors {
+		if ferr.Field == field {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,975 @@
+package dal
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/ghetzel/go-stockutil/maputil"
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/ghetzel/go-stockutil/typeutil"
+)
+
+// The maximum length of an expression, in characters.
+var MaxExpressionLength = 4096
+
+// An Expression is a compiled statement in the small expression language used by schema files for
+// field rules, record rules, and computed fields.  Expressions can only read the values they are
+// given and call a fixed set of functions, so they are safe to load from untrusted schema files.
+//
+// The language supports:
+//
+//   - literals: numbers, 'single' or "double" quoted strings, true, false, null, and [lists]
+//   - identifiers that reference values (nested values are accessed with dots, e.g.: address.city)
+//   - arithmetic: + - * / % (+ also concatenates strings)
+//   - comparison: == != < <= > >= and the "in" operator for testing list membership
+//   - logic: && || ! (or: and, or, not)
+//   - functions: len, lower, upper, trim, abs, min, max, round, now, contains, matches
+//
+// Times (and strings that look like times) are compared chronologically.
+type Expression struct {
+	text string
+	root exprNode
+}
+
+var expressionCache = make(map[string]*Expression)
+var expressionCacheLock sync.RWMutex
+
+// Parses the given text into an Expression.
+func ParseExpression(text string) (*Expression, error) {
+	if len(text) > MaxExpressionLength {
+		return nil, fmt.Errorf("expression exceeds %d characters", MaxExpressionLength)
+	}
+
+	if tokens, err := tokenizeExpression(text); err == nil {
+		parser := &exprParser{
+			tokens: tokens,
+		}
+
+		if root, err := parser.parseOr(); err == nil {
+			if tok := parser.peek(); tok.kind != exprEOF {
+				return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+			}
+
+			return &Expression{
+				text: text,
+				root: root,
+			}, nil
+		} else {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+}
+
+// returns a parsed expression, reusing previously-parsed expressions with the same text
+func compileExpression(text string) (*Expression, error) {
+	expressionCacheLock.RLock()
+	expr, ok := expressionCache[text]
+	expressionCacheLock.RUnlock()
+
+	if ok {
+		return expr, nil
+	}
+
+	if expr, err := ParseExpression(text); err == nil {
+		expressionCacheLock.Lock()
+		expressionCache[text] = expr
+		expressionCacheLock.Unlock()
+
+		return expr, nil
+	} else {
+		return nil, err
+	}
+}
+
+func (self *Expression) String() string {
+	return self.text
+}
+
+// Evaluates the expression, resolving identifiers from the given values.
+func (self *Expression) Evaluate(values map[string]interface{}) (interface{}, error) {
+	return self.root.eval(values)
+}
+
+// Evaluates the expression and returns whether the result is "truthy": true, a non-zero number, or a
+// non-empty value.
+func (self *Expression) Test(values map[string]interface{}) (bool, error) {
+	if v, err := self.Evaluate(values); err == nil {
+		return exprTruthy(v), nil
+	} else {
+		return false, err
+	}
+}
+
+// Validates that values satisfy the given expression, in which the value is referred to as "value".
+// Nil values are not checked.
+func ValidateExpression(text string) (FieldValidatorFunc, error) {
+	if expr, err := compileExpression(text); err == nil {
+		return func(value interface{}) error {
+			if value == nil {
+				return nil
+			}
+
+			if ok, err := expr.Test(map[string]interface{}{
+				`value`: value,
+			}); err == nil {
+				if !ok {
+					return fmt.Errorf("value must satisfy: %v", expr)
+				}
+
+				return nil
+			} else {
+				return err
+			}
+		}, nil
+	} else {
+		return nil, err
+	}
+}
+
+// tokenizer
+// -------------------------------------------------------------------------------------------------
+
+type exprTokenKind int
+
+const (
+	exprEOF exprTokenKind = iota
+	exprNumber
+	exprString
+	exprIdent
+	exprOperator
+)
+
+type exprToken struct {
+	kind  exprTokenKind
+	text  string
+	value interface{}
+	pos   int
+}
+
+var exprOperators = []string{
+	`||`, `&&`, `==`, `!=`, `<=`, `>=`, `<`, `>`, `+`, `-`, `*`, `/`, `%`, `!`, `(`, `)`, `[`, `]`, `,`,
+}
+
+// word forms of operators
+var exprWordOperators = map[string]string{
+	`and`: `&&`,
+	`or`:  `||`,
+	`not`: `!`,
+	`in`:  `in`,
+}
+
+func tokenizeExpression(text string) ([]exprToken, error) {
+	tokens := make([]exprToken, 0)
+	runes := []rune(text)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.' || runes[i] == 'e' || runes[i] == 'E') {
+				i++
+			}
+
+			literal := string(runes[start:i])
+			var value interface{}
+
+			if v, err := strconv.ParseInt(literal, 10, 64); err == nil {
+				value = v
+			} else if v, err := strconv.ParseFloat(literal, 64); err == nil {
+				value = v
+			} else {
+				return nil, fmt.Errorf("invalid number %q at position %d", literal, start)
+			}
+
+			tokens = append(tokens, exprToken{
+				kind:  exprNumber,
+				text:  literal,
+				value: value,
+				pos:   start,
+			})
+
+		case r == '"' || r == '\'':
+			start := i
+			quote := r
+			value := make([]rune, 0)
+			i++
+
+			for {
+				if i >= len(runes) {
+					return nil, fmt.Errorf("unterminated string at position %d", start)
+				} else if runes[i] == '\\' && i+1 < len(runes) {
+					value = append(value, runes[i+1])
+					i += 2
+				} else if runes[i] == quote {
+					i++
+					break
+				} else {
+					value = append(value, runes[i])
+					i++
+				}
+			}
+
+			tokens = append(tokens, exprToken{
+				kind:  exprString,
+				text:  string(runes[start:i]),
+				value: string(value),
+				pos:   start,
+			})
+
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '.') {
+				i++
+			}
+
+			word := string(runes[start:i])
+
+			if op, ok := exprWordOperators[word]; ok {
+				tokens = append(tokens, exprToken{
+					kind: exprOperator,
+					text: op,
+					pos:  start,
+				})
+			} else {
+				tokens = append(tokens, exprToken{
+					kind: exprIdent,
+					text: word,
+					pos:  start,
+				})
+			}
+
+		default:
+			matched := false
+
+			for _, op := range exprOperators {
+				if strings.HasPrefix(string(runes[i:]), op) {
+					tokens = append(tokens, exprToken{
+						kind: exprOperator,
+						text: op,
+						pos:  i,
+					})
+
+					i += len([]rune(op))
+					matched = true
+					break
+				}
+			}
+
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at position %d", r, i)
+			}
+		}
+	}
+
+	return append(tokens, exprToken{
+		kind: exprEOF,
+		pos:  len(runes),
+	}), nil
+}
+
+// parser
+// -------------------------------------------------------------------------------------------------
+
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+}
+
+func (self *exprParser) peek() exprToken {
+	return self.tokens[self.pos]
+}
+
+func (self *exprParser) next() exprToken {
+	tok := self.tokens[self.pos]
+
+	if tok.kind != exprEOF {
+		self.pos++
+	}
+
+	return tok
+}
+
+// consumes the next token if it is one of the given operators
+func (self *exprParser) accept(operators ...string) (string, bool) {
+	if tok := self.peek(); tok.kind == exprOperator && sliceutil.ContainsString(operators, tok.text) {
+		self.pos++
+		return tok.text, true
+	}
+
+	return ``, false
+}
+
+func (self *exprParser) expect(operator string) error {
+	if _, ok := self.accept(operator); ok {
+		return nil
+	} else if tok := self.peek(); tok.kind == exprEOF {
+		return fmt.Errorf("expected %q at end of expression", operator)
+	} else {
+		return fmt.Errorf("expected %q at position %d, got %q", operator, tok.pos, tok.text)
+	}
+}
+
+func (self *exprParser) parseBinary(next func() (exprNode, error), operators ...string) (exprNode, error) {
+	if left, err := next(); err == nil {
+		for {
+			if op, ok := self.accept(operators...); ok {
+				if right, err := next(); err == nil {
+					left = &exprBinary{
+						op:    op,
+						left:  left,
+						right: right,
+					}
+				} else {
+					return nil, err
+				}
+			} else {
+				return left, nil
+			}
+		}
+	} else {
+		return nil, err
+	}
+}
+
+func (self *exprParser) parseOr() (exprNode, error) {
+	return self.parseBinary(self.parseAnd, `||`)
+}
+
+func (self *exprParser) parseAnd() (exprNode, error) {
+	return self.parseBinary(self.parseComparison, `&&`)
+}
+
+func (self *exprParser) parseComparison() (exprNode, error) {
+	return self.parseBinary(self.parseAdditive, `==`, `!=`, `<`, `<=`, `>`, `>=`, `in`)
+}
+
+func (self *exprParser) parseAdditive() (exprNode, error) {
+	return self.parseBinary(self.parseMultiplicative, `+`, `-`)
+}
+
+func (self *exprParser) parseMultiplicative() (exprNode, error) {
+	return self.parseBinary(self.parseUnary, `*`, `/`, `%`)
+}
+
+func (self *exprParser) parseUnary() (exprNode, error) {
+	if op, ok := self.accept(`!`, `-`); ok {
+		if operand, err := self.parseUnary(); err == nil {
+			return &exprUnary{
+				op:      op,
+				operand: operand,
+			}, nil
+		} else {
+			return nil, err
+		}
+	}
+
+	return self.parsePrimary()
+}
+
+func (self *exprParser) parsePrimary() (exprNode, error) {
+	tok := self.next()
+
+	switch tok.kind {
+	case exprNumber, exprString:
+		return &exprLiteral{
+			value: tok.value,
+		}, nil
+
+	case exprIdent:
+		switch tok.text {
+		case `true`:
+			return &exprLiteral{value: true}, nil
+		case `false`:
+			return &exprLiteral{value: false}, nil
+		case `null`, `nil`:
+			return &exprLiteral{value: nil}, nil
+		}
+
+		// function call
+		if _, ok := self.accept(`(`); ok {
+			if fn, ok := exprFunctions[tok.text]; ok {
+				if args, err := self.parseList(`)`); err == nil {
+					return &exprCall{
+						name: tok.text,
+						fn:   fn,
+						args: args,
+					}, nil
+				} else {
+					return nil, err
+				}
+			} else {
+				return nil, fmt.Errorf("unknown function %q at position %d", tok.text, tok.pos)
+			}
+		}
+
+		return &exprIdentifier{
+			path: strings.Split(tok.text, `.`),
+		}, nil
+
+	case exprOperator:
+		switch tok.text {
+		case `(`:
+			if inner, err := self.parseOr(); err == nil {
+				if err := self.expect(`)`); err == nil {
+					return inner, nil
+				} else {
+					return nil, err
+				}
+			} else {
+				return nil, err
+			}
+
+		case `[`:
+			if items, err := self.parseList(`]`); err == nil {
+				return &exprList{
+					items: items,
+				}, nil
+			} else {
+				return nil, err
+			}
+		}
+	case exprEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+
+	return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+}
+
+// parses a comma-separated list of expressions ending with the given closing operator
+func (self *exprParser) parseList(closing string) ([]exprNode, error) {
+	items := make([]exprNode, 0)
+
+	if _, ok := self.accept(closing); ok {
+		return items, nil
+	}
+
+	for {
+		if item, err := self.parseOr(); err == nil {
+			items = append(items, item)
+		} else {
+			return nil, err
+		}
+
+		if _, ok := self.accept(`,`); !ok {
+			break
+		}
+	}
+
+	if err := self.expect(closing); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// evaluation
+// -------------------------------------------------------------------------------------------------
+
+type exprNode interface {
+	eval(values map[string]interface{}) (interface{}, error)
+}
+
+type exprLiteral struct {
+	value interface{}
+}
+
+func (self *exprLiteral) eval(_ map[string]interface{}) (interface{}, error) {
+	return self.value, nil
+}
+
+type exprIdentifier struct {
+	path []string
+}
+
+func (self *exprIdentifier) eval(values map[string]interface{}) (interface{}, error) {
+	if len(self.path) == 1 {
+		return values[self.path[0]], nil
+	}
+
+	return maputil.DeepGet(values, self.path), nil
+}
+
+type exprList struct {
+	items []exprNode
+}
+
+func (self *exprList) eval(values map[string]interface{}) (interface{}, error) {
+	out := make([]interface{}, len(self.items))
+
+	for i, item := range self.items {
+		if v, err := item.eval(values); err == nil {
+			out[i] = v
+		} else {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
+
+type exprUnary struct {
+	op      string
+	operand exprNode
+}
+
+func (self *exprUnary) eval(values map[string]interface{}) (interface{}, error) {
+	if v, err := self.operand.eval(values); err == nil {
+		switch self.op {
+		case `!`:
+			return !exprTruthy(v), nil
+		default:
+			if n, ok := exprNumeric(v); ok {
+				return exprNumberResult(-n, exprIsInteger(v)), nil
+			}
+
+			return nil, fmt.Errorf("cannot negate %T", v)
+		}
+	} else {
+		return nil, err
+	}
+}
+
+type exprBinary struct {
+	op    string
+	left  exprNode
+	right exprNode
+}
+
+func (self *exprBinary) eval(values map[string]interface{}) (interface{}, error) {
+	left, err := self.left.eval(values)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// short-circuit logical operators
+	switch self.op {
+	case `&&`:
+		if !exprTruthy(left) {
+			return false, nil
+		}
+	case `||`:
+		if exprTruthy(left) {
+			return true, nil
+		}
+	}
+
+	right, err := self.right.eval(values)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch self.op {
+	case `&&`, `||`:
+		return exprTruthy(right), nil
+
+	case `==`:
+		return exprEqual(left, right), nil
+
+	case `!=`:
+		return !exprEqual(left, right), nil
+
+	case `<`, `<=`, `>`, `>=`:
+		if cmp, ok := exprCompare(left, right); ok {
+			switch self.op {
+			case `<`:
+				return cmp < 0, nil
+			case `<=`:
+				return cmp <= 0, nil
+			case `>`:
+				return cmp > 0, nil
+			default:
+				return cmp >= 0, nil
+			}
+		}
+
+		// comparisons involving missing or incomparable values are false
+		return false, nil
+
+	case `in`:
+		if right == nil {
+			return false, nil
+		} else if typeutil.IsArray(right) {
+			for _, item := range sliceutil.Sliceify(right) {
+				if exprEqual(left, item) {
+					return true, nil
+				}
+			}
+
+			return false, nil
+		} else if rStr, ok := right.(string); ok {
+			return strings.Contains(rStr, fmt.Sprintf("%v", left)), nil
+		}
+
+		return nil, fmt.Errorf("right side of 'in' must be a list or string, got %T", right)
+
+	default:
+		return exprArithmetic(self.op, left, right)
+	}
+}
+
+type exprCall struct {
+	name string
+	fn   func(args []interface{}) (interface{}, error)
+	args []exprNode
+}
+
+func (self *exprCall) eval(values map[string]interface{}) (interface{}, error) {
+	args := make([]interface{}, len(self.args))
+
+	for i, arg := range self.args {
+		if v, err := arg.eval(values); err == nil {
+			args[i] = v
+		} else {
+			return nil, err
+		}
+	}
+
+	if v, err := self.fn(args); err == nil {
+		return v, nil
+	} else {
+		return nil, fmt.Errorf("%s(): %v", self.name, err)
+	}
+}
+
+var exprFunctions = map[string]func(args []interface{}) (interface{}, error){
+	`len`: func(args []interface{}) (interface{}, error) {
+		if err := exprArity(args, 1); err != nil {
+			return nil, err
+		} else if args[0] == nil {
+			return int64(0), nil
+		} else if l, err := validatorLength(args[0]); err == nil {
+			return int64(l), nil
+		} else {
+			return nil, err
+		}
+	},
+	`lower`: func(args []interface{}) (interface{}, error) {
+		return exprStringFunction(args, strings.ToLower)
+	},
+	`upper`: func(args []interface{}) (interface{}, error) {
+		return exprStringFunction(args, strings.ToUpper)
+	},
+	`trim`: func(args []interface{}) (interface{}, error) {
+		return exprStringFunction(args, strings.TrimSpace)
+	},
+	`abs`: func(args []interface{}) (interface{}, error) {
+		if err := exprArity(args, 1); err != nil {
+			return nil, err
+		} else if n, ok := exprNumeric(args[0]); ok {
+			return exprNumberResult(math.Abs(n), exprIsInteger(args[0])), nil
+		} else {
+			return nil, fmt.Errorf("expected a number, got %T", args[0])
+		}
+	},
+	`min`: func(args []interface{}) (interface{}, error) {
+		return exprExtreme(args, -1)
+	},
+	`max`: func(args []interface{}) (interface{}, error) {
+		return exprExtreme(args, 1)
+	},
+	`round`: func(args []interface{}) (interface{}, error) {
+		if len(args) == 1 {
+			args = append(args, int64(0))
+		}
+
+		if err := exprArity(args, 2); err != nil {
+			return nil, err
+		} else if args[0] == nil {
+			return nil, nil
+		}
+
+		return Round(int(typeutil.V(args[1]).Int()))(args[0], PersistOperation)
+	},
+	`now`: func(args []interface{}) (interface{}, error) {
+		if err := exprArity(args, 0); err != nil {
+			return nil, err
+		}
+
+		return time.Now(), nil
+	},
+	`contains`: func(args []interface{}) (interface{}, error) {
+		if err := exprArity(args, 2); err != nil {
+			return nil, err
+		} else if typeutil.IsArray(args[0]) {
+			for _, item := range sliceutil.Sliceify(args[0]) {
+				if exprEqual(item, args[1]) {
+					return true, nil
+				}
+			}
+
+			return false, nil
+		} else if args[0] == nil {
+			return false, nil
+		}
+
+		return strings.Contains(fmt.Sprintf("%v", args[0]), fmt.Sprintf("%v", args[1])), nil
+	},
+	`matches`: func(args []interface{}) (interface{}, error) {
+		if err := exprArity(args, 2); err != nil {
+			return nil, err
+		} else if args[0] == nil {
+			return false, nil
+		} else if pattern, err := regexp.Compile(fmt.Sprintf("%v", args[1])); err == nil {
+			return pattern.MatchString(fmt.Sprintf("%v", args[0])), nil
+		} else {
+			return nil, err
+		}
+	},
+}
+
+func exprArity(args []interface{}, n int) error {
+	if len(args) != n {
+		return fmt.Errorf("expected %d arguments, got %d", n, len(args))
+	}
+
+	return nil
+}
+
+func exprStringFunction(args []interface{}, fn func(string) string) (interface{}, error) {
+	if err := exprArity(args, 1); err != nil {
+		return nil, err
+	}
+
+	return formatString(args[0], fn)
+}
+
+// returns the smallest (direction < 0) or largest (direction > 0) of the given values
+func exprExtreme(args []interface{}, direction int) (interface{}, error) {
+	if len(args) == 1 && typeutil.IsArray(args[0]) {
+		args = sliceutil.Sliceify(args[0])
+	}
+
+	if len(args) == 0 {
+		return nil, fmt.Errorf("expected at least one argument")
+	}
+
+	best := args[0]
+
+	for _, arg := range args[1:] {
+		if cmp, ok := exprCompare(arg, best); ok {
+			if (direction < 0 && cmp < 0) || (direction > 0 && cmp > 0) {
+				best = arg
+			}
+		} else {
+			return nil, fmt.Errorf("cannot compare %T and %T", arg, best)
+		}
+	}
+
+	return best, nil
+}
+
+func exprTruthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ``
+	default:
+		if n, ok := exprNumeric(value); ok {
+			return n != 0
+		}
+
+		return !typeutil.IsEmpty(value)
+	}
+}
+
+// returns the value as a float64 if it is a number (strings are not considered numbers)
+func exprNumeric(value interface{}) (float64, bool) {
+	if value == nil {
+		return 0, false
+	}
+
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if v, err := stringutil.ConvertToFloat(value); err == nil {
+			return v, true
+		}
+	}
+
+	return 0, false
+}
+
+func exprIsInteger(value interface{}) bool {
+	if value == nil {
+		return false
+	}
+
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+
+	return false
+}
+
+func exprNumberResult(value float64, integer bool) interface{} {
+	if integer {
+		return int64(value)
+	}
+
+	return value
+}
+
+// returns the value as a time if it is one (or is a string that can be parsed as one)
+func exprTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case *time.Time:
+		if v != nil {
+			return *v, true
+		}
+	case string:
+		if stringutil.IsTime(v) {
+			if t, err := stringutil.ConvertToTime(v); err == nil {
+				return t, true
+			}
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func exprEqual(left interface{}, right interface{}) bool {
+	if left == nil || right == nil {
+		return left == nil && right == nil
+	}
+
+	if cmp, ok := exprCompare(left, right); ok {
+		return cmp == 0
+	}
+
+	return reflect.DeepEqual(left, right) || fmt.Sprintf("%v", left) == fmt.Sprintf("%v", right)
+}
+
+// compares two values, returning -1, 0, or 1 and whether the values could be compared at all
+func exprCompare(left interface{}, right interface{}) (int, bool) {
+	if left == nil || right == nil {
+		return 0, false
+	}
+
+	// numbers
+	if l, ok := exprNumeric(left); ok {
+		r, ok := exprNumeric(right)
+
+		if !ok {
+			if v, err := stringutil.ConvertToFloat(right); err == nil {
+				r, ok = v, true
+			}
+		}
+
+		if ok {
+			return exprSign(l - r), true
+		}
+	} else if r, ok := exprNumeric(right); ok {
+		if l, err := stringutil.ConvertToFloat(left); err == nil {
+			return exprSign(l - r), true
+		}
+	}
+
+	// times
+	if l, ok := exprTime(left); ok {
+		if r, ok := exprTime(right); ok {
+			switch {
+			case l.Before(r):
+				return -1, true
+			case l.After(r):
+				return 1, true
+			default:
+				return 0, true
+			}
+		}
+	}
+
+	// strings
+	if l, ok := left.(string); ok {
+		if r, ok := right.(string); ok {
+			return strings.Compare(l, r), true
+		}
+	}
+
+	return 0, false
+}
+
+func exprSign(v float64) int {
+	switch {
+	case v < 0:
+		return -1
+	case v > 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func exprArithmetic(op string, left interface{}, right interface{}) (interface{}, error) {
+	if left == nil || right == nil {
+		return nil, nil
+	}
+
+	// string concatenation
+	if op == `+` {
+		_, lIsString := left.(string)
+		_, rIsString := right.(string)
+
+		if lIsString || rIsString {
+			return fmt.Sprintf("%v%v", left, right), nil
+		}
+	}
+
+	l, lok := exprNumeric(left)
+	r, rok := exprNumeric(right)
+
+	if !lok || !rok {
+		return nil, fmt.Errorf("cannot apply %q to %T and %T", op, left, right)
+	}
+
+	integer := exprIsInteger(left) && exprIsInteger(right)
+
+	switch op {
+	case `+`:
+		return exprNumberResult(l+r, integer), nil
+	case `-`:
+		return exprNumberResult(l-r, integer), nil
+	case `*`:
+		return exprNumberResult(l*r, integer), nil
+	case `/`:
+		if r == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+
+		return l / r, nil
+	case `%`:
+		if r == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+
+		return exprNumberResult(math.Mod(l, r), integer), nil
+	default:
+		return nil, fmt.Errorf("unknown operator %q", op)
+	}
+}
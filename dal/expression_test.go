@@ -0,0 +1,181 @@
+package dal
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpressionEvaluate(t *testing.T) {
+	assert := require.New(t)
+
+	values := map[string]interface{}{
+		`price`:      2.5,
+		`qty`:        int64(4),
+		`name`:       `Widget`,
+		`tags`:       []string{`red`, `blue`},
+		`start_time`: `2018-01-01T00:00:00Z`,
+		`end_time`:   time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC),
+		`address`: map[string]interface{}{
+			`city`: `Springfield`,
+		},
+	}
+
+	for text, expected := range map[string]interface{}{
+		`1 + 2 * 3`:                          int64(7),
+		`(1 + 2) * 3`:                        int64(9),
+		`7 / 2`:                              3.5,
+		`7 % 4`:                              int64(3),
+		`-qty + 1`:                           int64(-3),
+		`price * qty`:                        10.0,
+		`qty > 0 && qty < 100`:               true,
+		`qty > 10 || price < 1`:              false,
+		`!(qty == 4)`:                        false,
+		`not (qty == 4) or name == "Widget"`: true,
+		`name + "-" + qty`:                   `Widget-4`,
+		`end_time > start_time`:              true,
+		`start_time >= end_time`:             false,
+		`'red' in tags`:                      true,
+		`qty in [1, 2, 3]`:                   false,
+		`address.city == 'Springfield'`:      true,
+		`missing > 0`:                        false,
+		`missing == null`:                    true,
+		`len(name) == 6 && upper(name) == 'WIDGET'`: true,
+		`max(1, qty, 3)`:            int64(4),
+		`min([price, qty])`:         2.5,
+		`round(price * 1.23, 1)`:    3.1,
+		`abs(-2)`:                   int64(2),
+		`contains(tags, 'blue')`:    true,
+		`matches(name, '^W')`:       true,
+		`trim(' x ') == lower('X')`: true,
+	} {
+		expr, err := ParseExpression(text)
+		assert.NoError(err, text)
+
+		actual, err := expr.Evaluate(values)
+		assert.NoError(err, text)
+		assert.Equal(expected, actual, text)
+	}
+
+	for _, text := range []string{
+		``,
+		`1 +`,
+		`(1 + 2`,
+		`value > `,
+		`'unterminated`,
+		`exec("rm -rf /")`,
+		`value $ 2`,
+		`[1, 2`,
+	} {
+		_, err := ParseExpression(text)
+		assert.Error(err, text)
+	}
+
+	expr, err := ParseExpression(`qty / 0`)
+	assert.NoError(err)
+	_, err = expr.Evaluate(values)
+	assert.Error(err)
+}
+
+func TestExpressionValidator(t *testing.T) {
+	assert := require.New(t)
+
+	validator, err := ValidatorFromMap(map[string]interface{}{
+		`expression`: `value > 0 && value < 100`,
+	})
+
+	assert.NoError(err)
+	assert.NoError(validator(50))
+	assert.NoError(validator(nil))
+	assert.Error(validator(0))
+	assert.Error(validator(100))
+
+	_, err = ValidatorFromMap(map[string]interface{}{
+		`expression`: `value >`,
+	})
+
+	assert.Error(err)
+}
+
+func TestCollectionRulesAndComputedFields(t *testing.T) {
+	assert := require.New(t)
+
+	var orders Collection
+
+	assert.NoError(json.Unmarshal([]byte(`{
+		"name": "orders",
+		"rules": ["end_time > start_time"],
+		"fields": [
+			{"name": "price", "type": "float"},
+			{"name": "qty",   "type": "int", "validators": {"expression": "value > 0"}},
+			{"name": "start_time", "type": "time"},
+			{"name": "end_time",   "type": "time"},
+			{"name": "total",      "type": "float", "expression": "price * qty"},
+			{"name": "label",      "type": "str",   "expression": "'x' + total", "formatters": {"uppercase": true}}
+		]
+	}`), &orders))
+
+	record, err := orders.MakeRecord(NewRecord(1).SetFields(map[string]interface{}{
+		`price`:      2.5,
+		`qty`:        4,
+		`start_time`: `2018-01-01T00:00:00Z`,
+		`end_time`:   `2018-01-02T00:00:00Z`,
+		`total`:      1,
+	}))
+
+	assert.NoError(err)
+	assert.Equal(10.0, record.Get(`total`))
+	assert.Equal(`X10`, record.Get(`label`))
+
+	_, err = orders.MakeRecord(NewRecord(2).SetFields(map[string]interface{}{
+		`price`:      2.5,
+		`qty`:        0,
+		`start_time`: `2018-01-02T00:00:00Z`,
+		`end_time`:   `2018-01-01T00:00:00Z`,
+	}))
+
+	assert.True(IsValidationErr(err))
+	verr := err.(*ValidationError)
+	assert.Len(verr.Errors, 2)
+	assert.Equal(``, verr.Errors[0].Field)
+	assert.Equal(`rule`, verr.Errors[0].Rule)
+	assert.Equal(`qty`, verr.Errors[1].Field)
+	assert.Equal(`expression`, verr.Errors[1].Rule)
+
+	// updates evaluate computed fields and rules against the stored record with the update merged in
+	stored, err := orders.MakeRecord(NewRecord(1).SetFields(map[string]interface{}{
+		`price`:      2.5,
+		`qty`:        4,
+		`start_time`: `2018-01-01T00:00:00Z`,
+		`end_time`:   `2018-01-02T00:00:00Z`,
+	}))
+
+	assert.NoError(err)
+
+	record, err = orders.MakeRecordForUpdate(NewRecord(1).Set(`qty`, 2), stored)
+	assert.NoError(err)
+	assert.Equal(5.0, record.Get(`total`))
+	assert.Equal(`X5`, record.Get(`label`))
+	assert.Nil(record.Get(`price`))
+
+	record, err = orders.MakeRecordForUpdate(NewRecord(1).Set(`end_time`, `2018-01-03T00:00:00Z`), stored)
+	assert.NoError(err)
+	assert.Equal(10.0, record.Get(`total`))
+
+	_, err = orders.MakeRecordForUpdate(NewRecord(1).Set(`end_time`, `2017-12-31T00:00:00Z`), stored)
+	assert.True(IsValidationErr(err))
+	assert.Equal(`rule`, err.(*ValidationError).Errors[0].Rule)
+
+	_, err = orders.MakeRecordForUpdate(NewRecord(1).SetFields(map[string]interface{}{
+		`start_time`: `2018-01-02T00:00:00Z`,
+		`end_time`:   `2018-01-01T00:00:00Z`,
+	}), stored)
+
+	assert.True(IsValidationErr(err))
+
+	// invalid expressions are reported when the schema is loaded
+	assert.Error(json.Unmarshal([]byte(`{"name": "x", "rules": ["a >"]}`), &Collection{}))
+	assert.Error(json.Unmarshal([]byte(`{"name": "x", "expression": "a +"}`), &Field{}))
+}
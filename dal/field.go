@@ -24,6 +24,7 @@ type Field struct {
 	Key                bool                   `json:"key,omitempty"`
 	Required           bool                   `json:"required,omitempty"`
 	Unique             bool                   `json:"unique,omitempty"`
+	Indexed            bool                   `json:"indexed,omitempty"`
 	DefaultValue       interface{}            `json:"default,omitempty"`
 	NativeType         string                 `json:"native_type,omitempty"`
 	ValidateOnPopulate bool                   `json:"validate_on_populate,omitempty"`
@@ -31,6 +32,9 @@ type Field struct {
 	Formatter          FieldFormatterFunc     `json:"-"`
 	FormatterConfig    map[string]interface{} `json:"formatters,omitempty"`
 	ValidatorConfig    map[string]interface{} `json:"validators,omitempty"`
+	Expression         string                 `json:"expression,omitempty"`
+	Path               string                 `json:"path,omitempty"`
+	Values             []string               `json:"values,omitempty"`
 }
 
 func (self *Field) ConvertValue(in interface{}) (interface{}, error) {
@@ -64,6 +68,45 @@ func (self *Field) ConvertValue(in interface{}) (interface{}, error) {
 		}
 
 		convertType = stringutil.Time
+
+	case ArrayType:
+		if v, err := self.convertList(in); err == nil {
+			in = v
+		} else {
+			return nil, err
+		}
+	}
+
+	// the extended scalar types leave nil values alone
+	if in != nil {
+		switch self.Type {
+		case EnumType:
+			if v := fmt.Sprintf("%v", in); v != `` {
+				if err := self.checkEnum(v); err == nil {
+					in = v
+				} else {
+					return nil, err
+				}
+			}
+		case DecimalType:
+			if v, err := ConvertToDecimal(in, self.Precision); err == nil {
+				in = v
+			} else {
+				return nil, err
+			}
+		case UUIDType:
+			if v, err := ConvertToUUID(in); err == nil {
+				in = v
+			} else {
+				return nil, err
+			}
+		case BytesType:
+			if v, err := ConvertToBytes(in); err == nil {
+				in = v
+			} else {
+				return nil, err
+			}
+		}
 	}
 
 	if convertType != stringutil.Invalid {
@@ -124,21 +167,43 @@ func (self *Field) GetTypeInstance() interface{} {
 		return &time.Time{}
 	case ObjectType:
 		return make(map[string]interface{})
+	case ArrayType:
+		return make([]interface{}, 0)
+	case EnumType, DecimalType, UUIDType:
+		return ``
 	default:
 		return make([]byte, 0)
 	}
 }
 
+// Validates the given value against this field's rules, returning a *FieldError describing the
+// first rule that failed.
 func (self *Field) Validate(value interface{}) error {
 	// automatically validate that required fields aren't being given a nil value
 	if self.Required && value == nil {
-		return fmt.Errorf("field %q is required", self.Name)
+		return &FieldError{
+			Field:   self.Name,
+			Rule:    `required`,
+			Message: `is required`,
+		}
 	}
 
 	if self.Validator == nil {
 		return nil
 	} else if err := self.Validator(value); err != nil {
-		return fmt.Errorf("validation error: %v", err)
+		if ferr, ok := err.(*FieldError); ok {
+			return &FieldError{
+				Field:   self.Name,
+				Rule:    ferr.Rule,
+				Message: ferr.Message,
+			}
+		}
+
+		return &FieldError{
+			Field:   self.Name,
+			Rule:    `validator`,
+			Message: err.Error(),
+		}
 	} else {
 		return nil
 	}
@@ -151,11 +216,35 @@ func (self *Field) Format(value interface{}, op FieldOperation) (interface{}, er
 		if v, err := self.Formatter(value, op); err == nil {
 			return v, nil
 		} else {
-			return v, fmt.Errorf("formatter error: %v", err)
+			return v, &FieldError{
+				Field:   self.Name,
+				Rule:    `formatter`,
+				Message: err.Error(),
+			}
 		}
 	}
 }
 
+// Returns whether this field's value is derived from other fields in the record (i.e.: it is
+// configured with the "fields" formatter).  Derived fields are formatted after all other fields, and
+// their formatters are passed the whole record.
+func (self *Field) IsDerived() bool {
+	_, ok := self.FormatterConfig[`fields`]
+	return ok || self.IsComputed()
+}
+
+// Returns whether this field's value is calculated from an expression over the other fields in the
+// record.
+func (self *Field) IsComputed() bool {
+	return (self.Expression != ``)
+}
+
+// Returns whether this field's value is read from a value nested in another field (e.g.:
+// "meta.author.name"), which backends that support it store as a generated column.
+func (self *Field) IsGenerated() bool {
+	return (self.Path != ``)
+}
+
 func (self *Field) Diff(other *Field) []SchemaDelta {
 	diff := make([]SchemaDelta, 0)
 	mine := structs.New(self)
@@ -166,6 +255,12 @@ func (self *Field) Diff(other *Field) []SchemaDelta {
 			theirField, _ := theirs.FieldOk(myField.Name())
 			deltaIssue := UnknownIssue
 
+			// secondary indexes are only reported by backends that store generated columns, so they
+			// are only compared when both fields are generated
+			if myField.Name() == `Indexed` && !(self.IsGenerated() && other.IsGenerated()) {
+				continue
+			}
+
 			switch myField.Name() {
 			// skip parameters:
 			//
@@ -175,8 +270,10 @@ func (self *Field) Diff(other *Field) []SchemaDelta {
 			//		this is largely for the use of the client application and won't always have a backend-persistent counterpart
 			//  DefaultValue:
 			//		this is a value that is interpreted by the backend and may not be retrievable after definition
+			//  Path:
+			//		backends without generated columns store these fields as regular ones
 			//
-			case `NativeType`, `Description`, `DefaultValue`, `Validator`, `Formatter`, `FormatterConfig`, `ValidatorConfig`:
+			case `NativeType`, `Description`, `DefaultValue`, `Validator`, `Formatter`, `FormatterConfig`, `ValidatorConfig`, `Expression`, `Values`, `Path`:
 				continue
 			case `Length`:
 				if myV, ok := myField.Value().(int); ok {
@@ -203,8 +300,9 @@ func (self *Field) Diff(other *Field) []SchemaDelta {
 					if theirT, ok := theirField.Value().(Type); ok {
 						if myT != theirT {
 							// ObjectType fields can be stored as a RawType on backends without
-							// a native object type, so we treat raw fields as object fields
-							if myT == ObjectType && theirT == RawType {
+							// a native object type (and likewise for the other extended types), so
+							// we treat those as equivalent
+							if myT.IsStoredAs(theirT) {
 								continue
 							}
 
@@ -297,6 +395,12 @@ func (self *Field) UnmarshalJSON(b []byte) error {
 			}
 		}
 
+		if self.Expression != `` {
+			if _, err := compileExpression(self.Expression); err != nil {
+				return fmt.Errorf("expression error: %v", err)
+			}
+		}
+
 		return nil
 	} else {
 		return err
@@ -241,3 +241,163 @@ func TestFieldConvertValueBool(t *testing.T) {
 // func TestFieldConvertValueTime(t *testing.T) {}
 // func TestFieldConvertValueObject(t *testing.T) {}
 // func TestFieldConvertValueRaw(t *testing.T) {}
+
+func TestFieldConvertValueExtendedTypes(t *testing.T) {
+	assert := require.New(t)
+
+	// lists
+	field := &Field{
+		Type:    ArrayType,
+		Subtype: IntType,
+	}
+
+	for input, expected := range map[string][]interface{}{
+		`[1, "2", 3.0]`: {int64(1), int64(2), int64(3)},
+		`4`:             {int64(4)},
+	} {
+		value, err := field.ConvertValue(input)
+		assert.NoError(err)
+		assert.Equal(expected, value, input)
+	}
+
+	value, err := field.ConvertValue([]string{`5`, `6`})
+	assert.NoError(err)
+	assert.Equal([]interface{}{int64(5), int64(6)}, value)
+
+	_, err = field.ConvertValue([]string{`5`, `six`})
+	assert.Error(err)
+
+	// enums
+	field = &Field{
+		Type:   EnumType,
+		Values: []string{`draft`, `published`},
+	}
+
+	value, err = field.ConvertValue(`draft`)
+	assert.NoError(err)
+	assert.Equal(`draft`, value)
+
+	_, err = field.ConvertValue(`Draft`)
+	assert.Error(err)
+
+	value, err = field.ConvertValue(nil)
+	assert.NoError(err)
+	assert.Nil(value)
+
+	// decimals
+	field = &Field{
+		Type: DecimalType,
+	}
+
+	for input, expected := range map[interface{}]string{
+		`19.99`:                  `19.99`,
+		`+.5`:                    `0.5`,
+		`-.25`:                   `-0.25`,
+		`1.5e3`:                  `1500`,
+		0.1:                      `0.1`,
+		int64(42):                `42`,
+		`12345678901234567890.1`: `12345678901234567890.1`,
+	} {
+		value, err := field.ConvertValue(input)
+		assert.NoError(err)
+		assert.Equal(expected, value, fmt.Sprintf("%v", input))
+	}
+
+	_, err = field.ConvertValue(`1/3`)
+	assert.Error(err)
+
+	field.Precision = 2
+	value, err = field.ConvertValue(`2.675`)
+	assert.NoError(err)
+	assert.Equal(`2.68`, value)
+
+	// UUIDs
+	field = &Field{
+		Type: UUIDType,
+	}
+
+	for _, input := range []interface{}{
+		`1B4E28BA-2FA1-11D2-883F-0016D3CCA427`,
+		`1b4e28ba2fa111d2883f0016d3cca427`,
+		`{1b4e28ba-2fa1-11d2-883f-0016d3cca427}`,
+		`urn:uuid:1b4e28ba-2fa1-11d2-883f-0016d3cca427`,
+		[]byte{0x1b, 0x4e, 0x28, 0xba, 0x2f, 0xa1, 0x11, 0xd2, 0x88, 0x3f, 0x00, 0x16, 0xd3, 0xcc, 0xa4, 0x27},
+	} {
+		value, err := field.ConvertValue(input)
+		assert.NoError(err)
+		assert.Equal(`1b4e28ba-2fa1-11d2-883f-0016d3cca427`, value)
+	}
+
+	_, err = field.ConvertValue(`1b4e28ba-2fa1-11d2-883f`)
+	assert.Error(err)
+
+	// bytes
+	field = &Field{
+		Type: BytesType,
+	}
+
+	// strings are stored as they are, even if they happen to be valid base64
+	value, err = field.ConvertValue(`test`)
+	assert.NoError(err)
+	assert.Equal([]byte(`test`), value)
+
+	value, err = field.ConvertValue([]byte{0x00, 0x01})
+	assert.NoError(err)
+	assert.Equal([]byte{0x00, 0x01}, value)
+
+	// values decoded from JSON are base64-encoded
+	value, err = field.DecodeJSONValue(`aGVsbG8=`)
+	assert.NoError(err)
+	assert.Equal([]byte(`hello`), value)
+
+	_, err = field.DecodeJSONValue(`hello`)
+	assert.Error(err)
+
+	value, err = (&Field{Type: StringType}).DecodeJSONValue(`aGVsbG8=`)
+	assert.NoError(err)
+	assert.Equal(`aGVsbG8=`, value)
+}
+
+func TestCollectionMakeRecordExtendedTypes(t *testing.T) {
+	assert := require.New(t)
+
+	collection := NewCollection(`products`)
+	collection.Fields = []Field{
+		{
+			Name:    `sizes`,
+			Type:    ArrayType,
+			Subtype: IntType,
+		}, {
+			Name:   `status`,
+			Type:   EnumType,
+			Values: []string{`active`, `retired`},
+		}, {
+			Name:      `price`,
+			Type:      DecimalType,
+			Precision: 2,
+		},
+	}
+
+	record, err := collection.MakeRecord(NewRecord(1).SetFields(map[string]interface{}{
+		`sizes`:  []interface{}{`8`, 10},
+		`status`: `active`,
+		`price`:  `10.5`,
+	}))
+
+	assert.NoError(err)
+	assert.Equal([]interface{}{int64(8), int64(10)}, record.Get(`sizes`))
+	assert.Equal(`10.50`, record.Get(`price`))
+
+	_, err = collection.MakeRecord(NewRecord(2).SetFields(map[string]interface{}{
+		`sizes`:  []interface{}{`eight`},
+		`status`: `unknown`,
+		`price`:  `ten`,
+	}))
+
+	assert.True(IsValidationErr(err))
+	assert.Len(err.(*ValidationError).Errors, 3)
+
+	for _, ferr := range err.(*ValidationError).Errors {
+		assert.Equal(`type`, ferr.Rule)
+	}
+}
@@ -1,13 +1,29 @@
 package dal
 
 import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/base32"
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
+	"hash"
+	"math"
+	"math/big"
+	"sort"
 	"strings"
+	"sync"
+	"text/template"
 	"time"
+	"unicode"
 
+	"github.com/ghetzel/go-stockutil/maputil"
+	"github.com/ghetzel/go-stockutil/sliceutil"
 	"github.com/ghetzel/go-stockutil/stringutil"
 	"github.com/ghetzel/go-stockutil/typeutil"
 	"github.com/jbenet/go-base58"
@@ -31,26 +47,28 @@ var HexEncoder = func(src []byte) (string, error) {
 	return hex.EncodeToString(src), nil
 }
 
-func FormatterFromMap(in map[string]interface{}) (FieldFormatterFunc, error) {
-	formatters := make([]FieldFormatterFunc, 0)
+// Creates a formatter from the arguments given for it in a field's FormatterConfig.
+type FormatterFactory func(args interface{}) (FieldFormatterFunc, error)
 
-	for name, defn := range in {
-		if formatter, err := GetFormatter(name, defn); err == nil {
-			formatters = append(formatters, formatter)
-		} else {
-			return nil, fmt.Errorf("Invalid formatter configuration %v: %v", name, err)
-		}
-	}
+var registeredFormatters = make(map[string]FormatterFactory)
+var onceFormatters = make(map[string]bool)
+var formattersLock sync.RWMutex
 
-	return FormatAll(formatters), nil
-}
+// formatters that produce a value from nothing (or from other fields) run before those that
+// transform the value they are given
+var formatterPriority = []string{`fields`}
 
-func GetFormatter(name string, args interface{}) (FieldFormatterFunc, error) {
-	switch name {
-	case `uuid`:
-		return GenerateUUID, nil
+func init() {
+	RegisterFormatter(`uuid`, staticFormatter(GenerateUUID))
+	RegisterFormatter(`ulid`, staticFormatter(GenerateULID))
+	RegisterFormatter(`ksuid`, staticFormatter(GenerateKSUID))
+	RegisterFormatter(`trim-space`, staticFormatter(TrimSpace))
+	RegisterFormatter(`lowercase`, staticFormatter(Lowercase))
+	RegisterFormatter(`uppercase`, staticFormatter(Uppercase))
+	RegisterFormatter(`current-time`, staticFormatter(CurrentTime))
+	RegisterFormatter(`current-time-if-unset`, staticFormatter(CurrentTimeIfUnset))
 
-	case `encoded-uuid`:
+	RegisterFormatter(`encoded-uuid`, func(args interface{}) (FieldFormatterFunc, error) {
 		var encoder EncoderFunc
 
 		switch fmt.Sprintf("%v", args) {
@@ -65,31 +83,191 @@ func GetFormatter(name string, args interface{}) (FieldFormatterFunc, error) {
 		}
 
 		return GenerateEncodedUUID(encoder), nil
+	})
 
-	case `trim-space`:
-		return TrimSpace, nil
+	RegisterFormatter(`fields`, func(args interface{}) (FieldFormatterFunc, error) {
+		var formatter FieldFormatterFunc
 
-	// case `fields`:
-	// 	if typeutil.IsMap(args) {
+		// either a template string, or a map containing a format string and a list of fields
+		if config, ok := args.(map[string]interface{}); ok {
+			format := typeutil.V(config[`format`]).String()
+			fields := sliceutil.Stringify(sliceutil.Sliceify(config[`fields`]))
 
-	// 		return DeriveFromFields()
-	// 	}
+			if format == `` || len(fields) == 0 {
+				return nil, fmt.Errorf("Must specify a format and a list of fields for formatter 'fields'")
+			}
 
-	case `current-time`:
-		return CurrentTime, nil
+			formatter = DeriveFromFields(format, fields...)
+		} else if f, err := DeriveFromTemplate(typeutil.V(args).String()); err == nil {
+			formatter = f
+		} else {
+			return nil, err
+		}
 
-	case `current-time-if-unset`:
-		return CurrentTimeIfUnset, nil
+		// values that aren't records (e.g.: when data is being retrieved) are already derived
+		return func(value interface{}, op FieldOperation) (interface{}, error) {
+			if _, ok := value.(*Record); ok {
+				return formatter(value, op)
+			}
 
-	default:
+			return value, nil
+		}, nil
+	})
+
+	RegisterFormatter(`slugify`, func(args interface{}) (FieldFormatterFunc, error) {
+		separator := `-`
+
+		if v, ok := args.(string); ok && v != `` {
+			separator = v
+		}
+
+		return Slugify(separator), nil
+	})
+
+	RegisterFormatter(`truncate`, func(args interface{}) (FieldFormatterFunc, error) {
+		if length, err := stringutil.ConvertToInteger(args); err == nil && length > 0 {
+			return Truncate(int(length)), nil
+		} else {
+			return nil, fmt.Errorf("Must specify a positive integer length for formatter 'truncate'")
+		}
+	})
+
+	RegisterOnceFormatter(`hash`, func(args interface{}) (FieldFormatterFunc, error) {
+		algorithm := typeutil.V(args).String()
+		key := ``
+
+		// HMACs are configured with a map containing the algorithm and key
+		if config, ok := args.(map[string]interface{}); ok {
+			algorithm = typeutil.V(config[`algorithm`]).String()
+			key = typeutil.V(config[`key`]).String()
+
+			if key == `` {
+				return nil, fmt.Errorf("Must specify a key for HMAC formatter 'hash'")
+			}
+		}
+
+		if algorithm == `` || algorithm == `true` {
+			algorithm = `sha256`
+		}
+
+		return Hash(algorithm, []byte(key))
+	})
+
+	RegisterFormatter(`round`, func(args interface{}) (FieldFormatterFunc, error) {
+		if places, err := stringutil.ConvertToInteger(args); err == nil {
+			return Round(int(places)), nil
+		} else {
+			return nil, fmt.Errorf("Must specify a number of decimal places for formatter 'round'")
+		}
+	})
+
+	RegisterFormatter(`timezone`, func(args interface{}) (FieldFormatterFunc, error) {
+		if location, err := time.LoadLocation(typeutil.V(args).String()); err == nil {
+			return ConvertTimezone(location), nil
+		} else {
+			return nil, err
+		}
+	})
+}
+
+// Registers a formatter that can be referenced by name in a field's FormatterConfig (and so from
+// schema files).  Registering a name that already exists replaces the existing formatter.
+func RegisterFormatter(name string, factory FormatterFactory) {
+	formattersLock.Lock()
+	defer formattersLock.Unlock()
+
+	registeredFormatters[name] = factory
+	delete(onceFormatters, name)
+}
+
+// Registers a formatter (like RegisterFormatter) that must not be applied to its own output, such as
+// a hash.  When a record is updated, values of fields using it that are unchanged from the stored
+// record are kept as they were stored instead of being formatted again.
+func RegisterOnceFormatter(name string, factory FormatterFactory) {
+	formattersLock.Lock()
+	defer formattersLock.Unlock()
+
+	registeredFormatters[name] = factory
+	onceFormatters[name] = true
+}
+
+// returns whether any of the formatters in the given FormatterConfig must only be applied once
+func hasOnceFormatter(config map[string]interface{}) bool {
+	formattersLock.RLock()
+	defer formattersLock.RUnlock()
+
+	for name := range config {
+		if onceFormatters[name] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the names of all registered formatters.
+func FormatterNames() []string {
+	formattersLock.RLock()
+	defer formattersLock.RUnlock()
+
+	names := maputil.StringKeys(registeredFormatters)
+	sort.Strings(names)
+
+	return names
+}
+
+func FormatterFromMap(in map[string]interface{}) (FieldFormatterFunc, error) {
+	formatters := make([]FieldFormatterFunc, 0)
+	names := make([]string, 0)
+
+	for _, name := range formatterPriority {
+		if _, ok := in[name]; ok {
+			names = append(names, name)
+		}
+	}
+
+	// the remaining formatters are applied in a consistent order
+	others := make([]string, 0)
+
+	for _, name := range maputil.StringKeys(in) {
+		if !sliceutil.ContainsString(formatterPriority, name) {
+			others = append(others, name)
+		}
+	}
+
+	sort.Strings(others)
+	names = append(names, others...)
+
+	for _, name := range names {
+		if formatter, err := GetFormatter(name, in[name]); err == nil {
+			formatters = append(formatters, formatter)
+		} else {
+			return nil, fmt.Errorf("Invalid formatter configuration %v: %v", name, err)
+		}
+	}
+
+	return FormatAll(formatters), nil
+}
+
+func GetFormatter(name string, args interface{}) (FieldFormatterFunc, error) {
+	formattersLock.RLock()
+	factory, ok := registeredFormatters[name]
+	formattersLock.RUnlock()
+
+	if ok {
+		return factory(args)
+	} else {
 		return nil, fmt.Errorf("Unknown formatter %q", name)
 	}
 }
 
+// Applies each formatter in turn, passing the output of one formatter as the input of the next.
 func FormatAll(formatters []FieldFormatterFunc) FieldFormatterFunc {
 	return func(value interface{}, op FieldOperation) (interface{}, error) {
-		for i, formatter := range formatters {
-			if v, err := formatter(value, op); (i+1) == len(formatters) || err != nil {
+		for _, formatter := range formatters {
+			if v, err := formatter(value, op); err == nil {
+				value = v
+			} else {
 				return v, err
 			}
 		}
@@ -98,6 +276,13 @@ func FormatAll(formatters []FieldFormatterFunc) FieldFormatterFunc {
 	}
 }
 
+// returns a factory for formatters that take no arguments
+func staticFormatter(formatter FieldFormatterFunc) FormatterFactory {
+	return func(_ interface{}) (FieldFormatterFunc, error) {
+		return formatter, nil
+	}
+}
+
 func TrimSpace(value interface{}, _ FieldOperation) (interface{}, error) {
 	if record, ok := value.(*Record); ok {
 		value = record.ID
@@ -175,3 +360,251 @@ func CurrentTimeIfUnset(value interface{}, op FieldOperation) (interface{}, erro
 
 	return value, nil
 }
+
+// Derives a value by rendering the given text/template with the record's fields as data (e.g.:
+// "{{ .first_name }} {{ .last_name }}").  The functions lower, upper, trim, and slugify are available
+// in templates.
+func DeriveFromTemplate(text string) (FieldFormatterFunc, error) {
+	if text == `` {
+		return nil, fmt.Errorf("Must specify a template for formatter 'fields'")
+	}
+
+	if tmpl, err := template.New(`fields`).Option(`missingkey=error`).Funcs(template.FuncMap{
+		`lower`: strings.ToLower,
+		`upper`: strings.ToUpper,
+		`trim`:  strings.TrimSpace,
+		`slugify`: func(in interface{}) string {
+			return slugify(fmt.Sprintf("%v", in), `-`)
+		},
+	}).Parse(text); err == nil {
+		return func(input interface{}, _ FieldOperation) (interface{}, error) {
+			if record, ok := input.(*Record); ok {
+				output := bytes.NewBuffer(nil)
+
+				if err := tmpl.Execute(output, record.Fields); err == nil {
+					return output.String(), nil
+				} else {
+					return nil, err
+				}
+			} else {
+				return nil, fmt.Errorf("DeriveFromTemplate formatter requires a *dal.Record argument, got %T", input)
+			}
+		}, nil
+	} else {
+		return nil, err
+	}
+}
+
+func Lowercase(value interface{}, _ FieldOperation) (interface{}, error) {
+	return formatString(value, strings.ToLower)
+}
+
+func Uppercase(value interface{}, _ FieldOperation) (interface{}, error) {
+	return formatString(value, strings.ToUpper)
+}
+
+// Converts values into lowercase strings containing only letters and numbers, with all other runs
+// of characters replaced by the given separator.
+func Slugify(separator string) FieldFormatterFunc {
+	return func(value interface{}, _ FieldOperation) (interface{}, error) {
+		return formatString(value, func(in string) string {
+			return slugify(in, separator)
+		})
+	}
+}
+
+// Truncates strings to at most the given number of characters.
+func Truncate(length int) FieldFormatterFunc {
+	return func(value interface{}, _ FieldOperation) (interface{}, error) {
+		return formatString(value, func(in string) string {
+			if runes := []rune(in); len(runes) > length {
+				return string(runes[:length])
+			}
+
+			return in
+		})
+	}
+}
+
+// Replaces values with the hex-encoded hash of their string representation when they are persisted.
+// Supported algorithms are md5, sha1, sha256, and sha512.  If a key is given, an HMAC is generated.
+func Hash(algorithm string, key []byte) (FieldFormatterFunc, error) {
+	var hasher func() hash.Hash
+
+	switch algorithm {
+	case `md5`:
+		hasher = md5.New
+	case `sha1`:
+		hasher = sha1.New
+	case `sha256`:
+		hasher = sha256.New
+	case `sha512`:
+		hasher = sha512.New
+	default:
+		return nil, fmt.Errorf("Unknown hash algorithm %q", algorithm)
+	}
+
+	return func(value interface{}, op FieldOperation) (interface{}, error) {
+		// only hash values on their way into the database
+		if op != PersistOperation {
+			return value, nil
+		}
+
+		return formatString(value, func(in string) string {
+			var h hash.Hash
+
+			if len(key) > 0 {
+				h = hmac.New(hasher, key)
+			} else {
+				h = hasher()
+			}
+
+			h.Write([]byte(in))
+			return hex.EncodeToString(h.Sum(nil))
+		})
+	}, nil
+}
+
+// Rounds numeric values to the given number of decimal places.
+func Round(places int) FieldFormatterFunc {
+	return func(value interface{}, _ FieldOperation) (interface{}, error) {
+		if record, ok := value.(*Record); ok {
+			value = record.ID
+		}
+
+		if value == nil {
+			return nil, nil
+		}
+
+		if v, err := stringutil.ConvertToFloat(value); err == nil {
+			factor := math.Pow(10, float64(places))
+			return math.Round(v*factor) / factor, nil
+		} else {
+			return value, err
+		}
+	}
+}
+
+// Converts time values to the given timezone.
+func ConvertTimezone(location *time.Location) FieldFormatterFunc {
+	return func(value interface{}, _ FieldOperation) (interface{}, error) {
+		if record, ok := value.(*Record); ok {
+			value = record.ID
+		}
+
+		switch value.(type) {
+		case nil:
+			return nil, nil
+		case time.Time:
+			return value.(time.Time).In(location), nil
+		case *time.Time:
+			if t := value.(*time.Time); t != nil {
+				return t.In(location), nil
+			}
+
+			return nil, nil
+		default:
+			if typeutil.IsZero(value) {
+				return value, nil
+			} else if t, err := stringutil.ConvertToTime(value); err == nil {
+				return t.In(location), nil
+			} else {
+				return value, err
+			}
+		}
+	}
+}
+
+// Generates a ULID (a lexically-sortable, timestamp-prefixed identifier) if the value is unset.
+func GenerateULID(value interface{}, _ FieldOperation) (interface{}, error) {
+	if record, ok := value.(*Record); ok {
+		value = record.ID
+	}
+
+	if typeutil.IsZero(value) {
+		id := make([]byte, 16)
+		ms := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+
+		// 48-bit millisecond timestamp followed by 80 bits of randomness
+		for i := 0; i < 6; i++ {
+			id[i] = byte(ms >> uint(40-(8*i)))
+		}
+
+		if _, err := rand.Read(id[6:]); err != nil {
+			return value, err
+		}
+
+		value = encodeBigEndian(id, ulidAlphabet, 26)
+	}
+
+	return value, nil
+}
+
+// Generates a KSUID (a K-sortable, timestamp-prefixed identifier) if the value is unset.
+func GenerateKSUID(value interface{}, _ FieldOperation) (interface{}, error) {
+	if record, ok := value.(*Record); ok {
+		value = record.ID
+	}
+
+	if typeutil.IsZero(value) {
+		id := make([]byte, 20)
+		ts := uint32(time.Now().Unix() - ksuidEpoch)
+
+		// 32-bit timestamp (seconds since the KSUID epoch) followed by 128 bits of randomness
+		for i := 0; i < 4; i++ {
+			id[i] = byte(ts >> uint(24-(8*i)))
+		}
+
+		if _, err := rand.Read(id[4:]); err != nil {
+			return value, err
+		}
+
+		value = encodeBigEndian(id, ksuidAlphabet, 27)
+	}
+
+	return value, nil
+}
+
+const ulidAlphabet = `0123456789ABCDEFGHJKMNPQRSTVWXYZ`
+const ksuidAlphabet = `0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz`
+const ksuidEpoch = 1400000000
+
+// encodes the given bytes as a big-endian number in the given alphabet, left-padded to length
+func encodeBigEndian(data []byte, alphabet string, length int) string {
+	n := new(big.Int).SetBytes(data)
+	base := big.NewInt(int64(len(alphabet)))
+	mod := new(big.Int)
+	out := make([]byte, length)
+
+	for i := length - 1; i >= 0; i-- {
+		n.DivMod(n, base, mod)
+		out[i] = alphabet[mod.Int64()]
+	}
+
+	return string(out)
+}
+
+func slugify(in string, separator string) string {
+	words := strings.FieldsFunc(strings.ToLower(in), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	return strings.Join(words, separator)
+}
+
+// applies the given function to string values, leaving nil values untouched
+func formatString(value interface{}, fn func(string) string) (interface{}, error) {
+	if record, ok := value.(*Record); ok {
+		value = record.ID
+	}
+
+	if value == nil {
+		return nil, nil
+	}
+
+	if vStr, err := stringutil.ToString(value); err == nil {
+		return fn(vStr), nil
+	} else {
+		return value, err
+	}
+}
@@ -0,0 +1,194 @@
+package dal
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormattersFromConfig(t *testing.T) {
+	assert := require.New(t)
+
+	for config, values := range map[string]map[interface{}]interface{}{
+		`{"lowercase": true}`: {
+			`Hello World`: `hello world`,
+			nil:           nil,
+		},
+		`{"uppercase": true}`: {
+			`Hello World`: `HELLO WORLD`,
+		},
+		`{"slugify": true}`: {
+			`  Hello, World! `: `hello-world`,
+			`Déjà Vu`:          `déjà-vu`,
+		},
+		`{"slugify": "_", "truncate": 8}`: {
+			`Hello, World!`: `hello_wo`,
+		},
+		`{"truncate": 3}`: {
+			`日本語です`: `日本語`,
+			`ab`:    `ab`,
+		},
+		`{"hash": "sha256"}`: {
+			`secret`: `2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b`,
+		},
+		`{"round": 2}`: {
+			3.14159: 3.14,
+			`2.718`: 2.72,
+			nil:     nil,
+		},
+		`{"timezone": "UTC"}`: {
+			`2018-01-01T12:00:00-05:00`: time.Date(2018, 1, 1, 17, 0, 0, 0, time.UTC),
+		},
+	} {
+		var formatterConfig map[string]interface{}
+
+		assert.NoError(json.Unmarshal([]byte(config), &formatterConfig))
+
+		formatter, err := FormatterFromMap(formatterConfig)
+		assert.NoError(err, config)
+
+		for input, expected := range values {
+			output, err := formatter(input, PersistOperation)
+			assert.NoError(err, config)
+			assert.Equal(expected, output, fmt.Sprintf("%s: %v", config, input))
+		}
+	}
+
+	// HMACs differ from a plain hash of the same value
+	hmac, err := GetFormatter(`hash`, map[string]interface{}{
+		`algorithm`: `sha256`,
+		`key`:       `key`,
+	})
+
+	assert.NoError(err)
+
+	output, err := hmac(`secret`, PersistOperation)
+	assert.NoError(err)
+	assert.Len(output, 64)
+	assert.NotEqual(`2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b`, output)
+
+	// hashes are only applied when persisting
+	output, err = hmac(`secret`, RetrieveOperation)
+	assert.NoError(err)
+	assert.Equal(`secret`, output)
+
+	_, err = FormatterFromMap(map[string]interface{}{
+		`hash`: `crc32`,
+	})
+
+	assert.Error(err)
+}
+
+func TestFormatterIdGenerators(t *testing.T) {
+	assert := require.New(t)
+
+	ulid, err := GenerateULID(nil, PersistOperation)
+	assert.NoError(err)
+	assert.Len(ulid, 26)
+	assert.Regexp(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`, ulid)
+
+	next, err := GenerateULID(nil, PersistOperation)
+	assert.NoError(err)
+	assert.NotEqual(ulid, next)
+
+	ksuid, err := GenerateKSUID(nil, PersistOperation)
+	assert.NoError(err)
+	assert.Len(ksuid, 27)
+	assert.Regexp(`^[0-9A-Za-z]{27}$`, ksuid)
+
+	// existing values are left alone
+	existing, err := GenerateKSUID(`already-set`, PersistOperation)
+	assert.NoError(err)
+	assert.Equal(`already-set`, existing)
+}
+
+func TestFormatterDerivedFields(t *testing.T) {
+	assert := require.New(t)
+
+	var collection Collection
+
+	assert.NoError(json.Unmarshal([]byte(`{
+		"name": "articles",
+		"fields": [{
+			"name": "title",
+			"type": "str"
+		}, {
+			"name": "author",
+			"type": "str"
+		}, {
+			"name": "slug",
+			"type": "str",
+			"formatters": {
+				"fields": "{{ .author }} {{ .title }}",
+				"slugify": true
+			}
+		}, {
+			"name": "summary",
+			"type": "str",
+			"formatters": {
+				"fields": {
+					"format": "%v by %v",
+					"fields": ["title", "author"]
+				}
+			}
+		}]
+	}`), &collection))
+
+	record, err := collection.MakeRecord(NewRecord(1).Set(`title`, `Hello, World!`).Set(`author`, `Bob`))
+	assert.NoError(err)
+	assert.Equal(`bob-hello-world`, record.Get(`slug`))
+	assert.Equal(`Hello, World! by Bob`, record.Get(`summary`))
+
+	// missing source fields are reported as a formatting failure
+	_, err = collection.MakeRecord(NewRecord(2).Set(`title`, `Untitled`))
+	assert.Error(err)
+	assert.Equal(`slug`, err.(*ValidationError).Errors[0].Field)
+	assert.Equal(`formatter`, err.(*ValidationError).Errors[0].Rule)
+
+	type article struct {
+		ID     int    `pivot:"id,identity"`
+		Title  string `pivot:"title"`
+		Author string `pivot:"author"`
+		Slug   string `pivot:"slug"`
+	}
+
+	instance := article{
+		ID:     3,
+		Title:  `Structs`,
+		Author: `Alice`,
+	}
+
+	_, err = collection.MakeRecord(&instance)
+	assert.NoError(err)
+	assert.Equal(`alice-structs`, instance.Slug)
+}
+
+func TestRegisterFormatter(t *testing.T) {
+	assert := require.New(t)
+
+	RegisterFormatter(`prefix`, func(args interface{}) (FieldFormatterFunc, error) {
+		return func(value interface{}, _ FieldOperation) (interface{}, error) {
+			return fmt.Sprintf("%v%v", args, value), nil
+		}, nil
+	})
+
+	assert.Contains(FormatterNames(), `prefix`)
+
+	var field Field
+
+	assert.NoError(json.Unmarshal([]byte(`{
+		"name": "code",
+		"type": "str",
+		"formatters": {
+			"prefix": "x-",
+			"uppercase": true
+		}
+	}`), &field))
+
+	value, err := field.Format(`abc`, PersistOperation)
+	assert.NoError(err)
+	assert.Equal(`X-ABC`, value)
+}
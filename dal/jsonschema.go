@@ -0,0 +1,538 @@
+package dal
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ghetzel/go-stockutil/maputil"
+	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/typeutil"
+)
+
+var JSONSchemaDialect = `https://json-schema.org/draft/2020-12/schema`
+
+// Returns a JSON Schema (draft 2020-12) document describing records in this collection as a flat
+// object containing the identity field and all other fields as properties.
+func (self *Collection) JSONSchema() map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := make([]string, 0)
+
+	if self.IdentityField != `` {
+		identityType := self.IdentityFieldType
+
+		if identityType == `` {
+			identityType = DefaultIdentityFieldType
+		}
+
+		properties[self.IdentityField] = (&Field{
+			Name: self.IdentityField,
+			Type: identityType,
+		}).JSONSchema()
+	}
+
+	for _, field := range self.Fields {
+		if field.Name == self.IdentityField {
+			continue
+		}
+
+		properties[field.Name] = field.JSONSchema()
+
+		if field.Required {
+			required = append(required, field.Name)
+		}
+	}
+
+	schema := map[string]interface{}{
+		`$schema`:    JSONSchemaDialect,
+		`title`:      self.Name,
+		`type`:       `object`,
+		`properties`: properties,
+	}
+
+	if len(required) > 0 {
+		schema[`required`] = required
+	}
+
+	return schema
+}
+
+// Returns a JSON Schema describing values of this field.
+func (self *Field) JSONSchema() map[string]interface{} {
+	schema := make(map[string]interface{})
+
+	if t := jsonSchemaTypeFor(self.Type); t != `` {
+		schema[`type`] = t
+	}
+
+	switch self.Type {
+	case StringType:
+		if self.Length > 0 {
+			schema[`maxLength`] = self.Length
+		}
+
+	case TimeType:
+		schema[`format`] = `date-time`
+
+	case RawType:
+		schema[`contentEncoding`] = `base64`
+
+	case ObjectType:
+		if t := jsonSchemaTypeFor(self.Subtype); t != `` {
+			schema[`additionalProperties`] = map[string]interface{}{
+				`type`: t,
+			}
+		}
+
+	case ArrayType:
+		if t := jsonSchemaTypeFor(self.Subtype); t != `` {
+			schema[`items`] = map[string]interface{}{
+				`type`: t,
+			}
+		}
+
+	case EnumType:
+		if len(self.Values) > 0 {
+			schema[`enum`] = self.Values
+		}
+
+	case DecimalType:
+		schema[`pattern`] = decimalPattern.String()
+
+	case UUIDType:
+		schema[`format`] = `uuid`
+
+	case BytesType:
+		schema[`contentEncoding`] = `base64`
+	}
+
+	if self.Description != `` {
+		schema[`description`] = self.Description
+	}
+
+	// computed values are ignored if given, so they're effectively read-only
+	if self.IsComputed() {
+		schema[`readOnly`] = true
+	}
+
+	// functions can't be represented, so only include literal default values
+	if v := self.DefaultValue; v != nil && !typeutil.IsFunction(v) {
+		schema[`default`] = v
+	}
+
+	for name, args := range self.ValidatorConfig {
+		switch name {
+		case `one-of`:
+			if typeutil.IsArray(args) {
+				schema[`enum`] = sliceutil.Sliceify(args)
+			}
+
+		case `not-empty`:
+			switch self.Type {
+			case StringType:
+				schema[`minLength`] = 1
+			case ObjectType:
+				schema[`minProperties`] = 1
+			}
+
+		case `not-zero`:
+			schema[`not`] = map[string]interface{}{
+				`const`: 0,
+			}
+
+		case `positive-integer`:
+			schema[`type`] = `integer`
+			schema[`exclusiveMinimum`] = 0
+
+		case `positive-or-zero-integer`:
+			schema[`type`] = `integer`
+			schema[`minimum`] = 0
+
+		case `regex`:
+			schema[`pattern`] = typeutil.V(args).String()
+
+		case `min-length`:
+			schema[`minLength`] = typeutil.V(args).Int()
+
+		case `max-length`:
+			schema[`maxLength`] = typeutil.V(args).Int()
+
+		case `range`:
+			if min, max, err := validatorRangeArgs(args); err == nil {
+				if min != nil {
+					schema[`minimum`] = min
+				}
+
+				if max != nil {
+					schema[`maximum`] = max
+				}
+			}
+
+		case `email`:
+			schema[`format`] = `email`
+
+		case `url`:
+			schema[`format`] = `uri`
+
+		case `uuid`:
+			schema[`format`] = `uuid`
+
+		case `ip`:
+			switch typeutil.V(args).String() {
+			case `v4`, `4`:
+				schema[`format`] = `ipv4`
+			case `v6`, `6`:
+				schema[`format`] = `ipv6`
+			}
+		}
+	}
+
+	return schema
+}
+
+func jsonSchemaTypeFor(t Type) string {
+	switch t {
+	case StringType, TimeType, RawType, EnumType, DecimalType, UUIDType, BytesType:
+		return `string`
+	case ArrayType:
+		return `array`
+	case BooleanType:
+		return `boolean`
+	case IntType:
+		return `integer`
+	case FloatType:
+		return `number`
+	case ObjectType:
+		return `object`
+	default:
+		return ``
+	}
+}
+
+// JSON Schema keywords that are handled (or safely ignored) when importing a schema.
+var jsonSchemaKnownKeywords = []string{
+	`$schema`, `$id`, `$comment`, `title`, `description`, `type`, `properties`, `required`,
+	`default`, `enum`, `maxLength`, `minLength`, `minimum`, `maximum`, `exclusiveMinimum`, `format`,
+	`pattern`,
+	`contentEncoding`, `additionalProperties`, `items`, `examples`, `$defs`, `definitions`,
+}
+
+// Creates a Collection from a JSON Schema document describing an object.  Properties become fields;
+// the property named by DefaultIdentityField (if present) becomes the identity field.  Keywords
+// that have no equivalent in a Collection definition are returned as warnings.
+func CollectionFromJSONSchema(name string, schema map[string]interface{}) (*Collection, []string, error) {
+	warnings := make([]string, 0)
+
+	if v, ok := schema[`title`].(string); ok && v != `` && name == `` {
+		name = v
+	}
+
+	if name == `` {
+		return nil, nil, fmt.Errorf("JSON Schema must specify a title")
+	}
+
+	if t, _ := jsonSchemaType(schema[`type`]); t != `` && t != `object` {
+		return nil, nil, fmt.Errorf("JSON Schema for %q must describe an object, got %q", name, t)
+	}
+
+	collection := NewCollection(name)
+	required := sliceutil.Stringify(sliceutil.Sliceify(schema[`required`]))
+	properties, _ := schema[`properties`].(map[string]interface{})
+
+	for key := range schema {
+		switch key {
+		case `$defs`, `definitions`, `additionalProperties`:
+			continue
+		}
+
+		if !sliceutil.ContainsString(jsonSchemaKnownKeywords, key) {
+			warnings = append(warnings, fmt.Sprintf("%s: unsupported keyword %q", name, key))
+		}
+	}
+
+	// iterate over properties in a stable order
+	propertyNames := maputil.StringKeys(properties)
+	sort.Strings(propertyNames)
+
+	for _, propertyName := range propertyNames {
+		propertySchema, ok := properties[propertyName].(map[string]interface{})
+
+		if !ok {
+			warnings = append(warnings, fmt.Sprintf("%s.%s: property schema is not an object", name, propertyName))
+			continue
+		}
+
+		field, fieldWarnings := fieldFromJSONSchema(name, propertyName, propertySchema)
+		warnings = append(warnings, fieldWarnings...)
+
+		if propertyName == DefaultIdentityField {
+			collection.IdentityField = propertyName
+
+			if field.Type != `` {
+				collection.IdentityFieldType = field.Type
+			}
+
+			continue
+		}
+
+		field.Required = sliceutil.ContainsString(required, propertyName)
+		collection.Fields = append(collection.Fields, field)
+	}
+
+	return collection, warnings, nil
+}
+
+func fieldFromJSONSchema(collectionName string, name string, schema map[string]interface{}) (Field, []string) {
+	field := Field{
+		Name: name,
+	}
+
+	warnings := make([]string, 0)
+	warn := func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf("%s.%s: ", collectionName, name)+fmt.Sprintf(format, args...))
+	}
+
+	validators := make(map[string]interface{})
+	t, multiple := jsonSchemaType(schema[`type`])
+
+	if multiple {
+		warn("multiple types are not supported, using %q", t)
+	}
+
+	switch t {
+	case `string`:
+		field.Type = StringType
+
+		switch format := typeutil.V(schema[`format`]).String(); format {
+		case `date-time`, `date`:
+			field.Type = TimeType
+		case `email`, `uuid`:
+			validators[format] = true
+		case `uri`:
+			validators[`url`] = true
+		case `ipv4`:
+			validators[`ip`] = `v4`
+		case `ipv6`:
+			validators[`ip`] = `v6`
+		case ``:
+			break
+		default:
+			warn("unsupported string format %q", format)
+		}
+
+		if v, ok := schema[`pattern`]; ok {
+			validators[`regex`] = v
+		}
+
+		if typeutil.V(schema[`contentEncoding`]).String() == `base64` {
+			field.Type = RawType
+		}
+
+	case `integer`:
+		field.Type = IntType
+	case `number`:
+		field.Type = FloatType
+	case `boolean`:
+		field.Type = BooleanType
+
+	case `object`:
+		field.Type = ObjectType
+
+		if additional, ok := schema[`additionalProperties`].(map[string]interface{}); ok {
+			field.Subtype = jsonSchemaDalType(additional)
+		} else if nested, ok := schema[`properties`].(map[string]interface{}); ok {
+			// nested objects can only describe the type of their values if they all share one
+			subtypes := make([]interface{}, 0)
+
+			for _, propertySchema := range nested {
+				if ps, ok := propertySchema.(map[string]interface{}); ok {
+					subtypes = append(subtypes, jsonSchemaDalType(ps))
+				}
+			}
+
+			if subtypes = sliceutil.Unique(subtypes); len(subtypes) == 1 {
+				field.Subtype, _ = subtypes[0].(Type)
+			} else if len(subtypes) > 1 {
+				warn("nested properties have mixed types and will not be validated")
+			}
+		}
+
+	case `array`:
+		field.Type = ArrayType
+
+		if items, ok := schema[`items`].(map[string]interface{}); ok {
+			field.Subtype = jsonSchemaDalType(items)
+		}
+
+	case ``:
+		field.Type = AutoType
+
+	default:
+		warn("unsupported type %q", t)
+		field.Type = RawType
+	}
+
+	if v, ok := schema[`description`].(string); ok {
+		field.Description = v
+	}
+
+	if v, ok := schema[`default`]; ok {
+		field.DefaultValue = v
+	}
+
+	if v, ok := schema[`maxLength`]; ok {
+		field.Length = int(typeutil.V(v).Int())
+	}
+
+	if v, ok := schema[`enum`]; ok {
+		validators[`one-of`] = sliceutil.Sliceify(v)
+	}
+
+	if v, ok := schema[`minLength`]; ok {
+		if typeutil.V(v).Int() == 1 {
+			validators[`not-empty`] = true
+		} else {
+			validators[`min-length`] = typeutil.V(v).Int()
+		}
+	}
+
+	bounds := make(map[string]interface{})
+
+	if v, ok := schema[`minimum`]; ok {
+		if typeutil.V(v).Float() == 0 && t == `integer` {
+			validators[`positive-or-zero-integer`] = true
+		} else {
+			bounds[`min`] = v
+		}
+	}
+
+	if v, ok := schema[`maximum`]; ok {
+		bounds[`max`] = v
+	}
+
+	if len(bounds) > 0 {
+		validators[`range`] = bounds
+	}
+
+	if v, ok := schema[`exclusiveMinimum`]; ok {
+		if typeutil.V(v).Float() == 0 && t == `integer` {
+			validators[`positive-integer`] = true
+		} else {
+			warn("exclusiveMinimum of %v is not supported", v)
+		}
+	}
+
+	if len(validators) > 0 {
+		field.ValidatorConfig = validators
+	}
+
+	for key := range schema {
+		if !sliceutil.ContainsString(jsonSchemaKnownKeywords, key) {
+			warn("unsupported keyword %q", key)
+		}
+	}
+
+	// only the type of nested values is kept, so anything else they specify is not enforced
+	if v, ok := schema[`required`]; ok {
+		warn("required nested properties %v are not enforced", v)
+	}
+
+	jsonSchemaWalkNested(name, schema, func(path string, key string) {
+		warn("keyword %q of %s is not enforced", key, path)
+	})
+
+	return field, warnings
+}
+
+// JSON Schema keywords of nested schemas that describe their structure (which is imported as the
+// field's subtype) rather than constraining their values.
+var jsonSchemaStructuralKeywords = []string{
+	`$comment`, `title`, `description`, `type`, `examples`, `properties`, `items`,
+	`additionalProperties`,
+}
+
+// calls fn for each keyword of each schema nested in the given one (in its properties, items, and
+// additionalProperties) that constrains the nested values
+func jsonSchemaWalkNested(path string, schema map[string]interface{}, fn func(path string, key string)) {
+	nested := make(map[string]map[string]interface{})
+
+	if properties, ok := schema[`properties`].(map[string]interface{}); ok {
+		for name, ps := range properties {
+			if ps, ok := ps.(map[string]interface{}); ok {
+				nested[path+`.`+name] = ps
+			}
+		}
+	}
+
+	if items, ok := schema[`items`].(map[string]interface{}); ok {
+		nested[path+`[]`] = items
+	}
+
+	if additional, ok := schema[`additionalProperties`].(map[string]interface{}); ok {
+		nested[path+`.*`] = additional
+	}
+
+	paths := maputil.StringKeys(nested)
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		ns := nested[p]
+		keys := maputil.StringKeys(ns)
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if sliceutil.ContainsString(jsonSchemaStructuralKeywords, key) {
+				continue
+			} else if key == `format` && jsonSchemaDalType(ns) == TimeType {
+				continue
+			}
+
+			fn(p, key)
+		}
+
+		jsonSchemaWalkNested(p, ns, fn)
+	}
+}
+
+// returns the (first non-null) type named in a JSON Schema "type" keyword, and whether more than one
+// non-null type was given
+func jsonSchemaType(in interface{}) (string, bool) {
+	types := make([]string, 0)
+
+	for _, t := range sliceutil.Stringify(sliceutil.Compact(sliceutil.Sliceify(in))) {
+		if t != `null` {
+			types = append(types, t)
+		}
+	}
+
+	if len(types) == 0 {
+		return ``, false
+	}
+
+	return types[0], (len(types) > 1)
+}
+
+func jsonSchemaDalType(schema map[string]interface{}) Type {
+	t, _ := jsonSchemaType(schema[`type`])
+
+	switch t {
+	case `string`:
+		switch typeutil.V(schema[`format`]).String() {
+		case `date-time`, `date`:
+			return TimeType
+		}
+
+		return StringType
+	case `integer`:
+		return IntType
+	case `number`:
+		return FloatType
+	case `boolean`:
+		return BooleanType
+	case `object`:
+		return ObjectType
+	case `array`:
+		return ArrayType
+	default:
+		return AutoType
+	}
+}
@@ -0,0 +1,238 @@
+package dal
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectionJSONSchema(t *testing.T) {
+	assert := require.New(t)
+
+	collection := &Collection{
+		Name:              `widgets`,
+		IdentityField:     `id`,
+		IdentityFieldType: StringType,
+		Fields: []Field{
+			{
+				Name:        `type`,
+				Description: `The type of widget.`,
+				Type:        StringType,
+				Length:      32,
+				Required:    true,
+				ValidatorConfig: map[string]interface{}{
+					`one-of`: []interface{}{`foo`, `bar`, `baz`},
+				},
+			}, {
+				Name:         `enabled`,
+				Type:         BooleanType,
+				DefaultValue: true,
+			}, {
+				Name: `count`,
+				Type: IntType,
+				ValidatorConfig: map[string]interface{}{
+					`positive-or-zero-integer`: true,
+				},
+			}, {
+				Name: `price`,
+				Type: FloatType,
+			}, {
+				Name:         `created_at`,
+				Type:         TimeType,
+				DefaultValue: CurrentTime,
+			}, {
+				Name:    `properties`,
+				Type:    ObjectType,
+				Subtype: StringType,
+			}, {
+				Name: `data`,
+				Type: RawType,
+			},
+		},
+	}
+
+	assert.Equal(map[string]interface{}{
+		`$schema`: JSONSchemaDialect,
+		`title`:   `widgets`,
+		`type`:    `object`,
+		`properties`: map[string]interface{}{
+			`id`: map[string]interface{}{
+				`type`: `string`,
+			},
+			`type`: map[string]interface{}{
+				`type`:        `string`,
+				`maxLength`:   32,
+				`description`: `The type of widget.`,
+				`enum`:        []interface{}{`foo`, `bar`, `baz`},
+			},
+			`enabled`: map[string]interface{}{
+				`type`:    `boolean`,
+				`default`: true,
+			},
+			`count`: map[string]interface{}{
+				`type`:    `integer`,
+				`minimum`: 0,
+			},
+			`price`: map[string]interface{}{
+				`type`: `number`,
+			},
+			`created_at`: map[string]interface{}{
+				`type`:   `string`,
+				`format`: `date-time`,
+			},
+			`properties`: map[string]interface{}{
+				`type`: `object`,
+				`additionalProperties`: map[string]interface{}{
+					`type`: `string`,
+				},
+			},
+			`data`: map[string]interface{}{
+				`type`:            `string`,
+				`contentEncoding`: `base64`,
+			},
+		},
+		`required`: []string{`type`},
+	}, collection.JSONSchema())
+}
+
+func TestCollectionFromJSONSchema(t *testing.T) {
+	assert := require.New(t)
+
+	var schema map[string]interface{}
+
+	assert.Nil(json.Unmarshal([]byte(`{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title": "widgets",
+		"type": "object",
+		"required": ["type"],
+		"properties": {
+			"id": {
+				"type": "string"
+			},
+			"type": {
+				"type": "string",
+				"description": "The type of widget.",
+				"maxLength": 32,
+				"enum": ["foo", "bar", "baz"]
+			},
+			"enabled": {
+				"type": ["boolean", "null"],
+				"default": true
+			},
+			"created_at": {
+				"type": "string",
+				"format": "date-time"
+			},
+			"dimensions": {
+				"type": "object",
+				"properties": {
+					"width": {"type": "number"},
+					"height": {"type": "number"}
+				}
+			},
+			"code": {
+				"type": "string",
+				"pattern": "^[A-Z]+$",
+				"multipleOf": 2
+			}
+		},
+		"anyOf": []
+	}`), &schema))
+
+	collection, warnings, err := CollectionFromJSONSchema(``, schema)
+	assert.Nil(err)
+	assert.Equal(`widgets`, collection.Name)
+	assert.Equal(`id`, collection.IdentityField)
+	assert.Equal(StringType, collection.IdentityFieldType)
+	assert.Len(collection.Fields, 5)
+
+	field, ok := collection.GetField(`type`)
+	assert.True(ok)
+	assert.Equal(StringType, field.Type)
+	assert.Equal(`The type of widget.`, field.Description)
+	assert.Equal(32, field.Length)
+	assert.True(field.Required)
+	assert.Equal([]interface{}{`foo`, `bar`, `baz`}, field.ValidatorConfig[`one-of`])
+
+	field, ok = collection.GetField(`enabled`)
+	assert.True(ok)
+	assert.Equal(Type(BooleanType), field.Type)
+	assert.Equal(true, field.DefaultValue)
+	assert.False(field.Required)
+
+	field, ok = collection.GetField(`created_at`)
+	assert.True(ok)
+	assert.Equal(Type(TimeType), field.Type)
+
+	field, ok = collection.GetField(`dimensions`)
+	assert.True(ok)
+	assert.Equal(Type(ObjectType), field.Type)
+	assert.Equal(Type(FloatType), field.Subtype)
+
+	assert.Equal([]string{
+		`widgets: unsupported keyword "anyOf"`,
+		`widgets.code: unsupported keyword "multipleOf"`,
+	}, warnings)
+
+	field, ok = collection.GetField(`code`)
+	assert.True(ok)
+	assert.Equal(`^[A-Z]+$`, field.ValidatorConfig[`regex`])
+
+	// round-trip the exported schema
+	roundtrip, warnings, err := CollectionFromJSONSchema(``, collection.JSONSchema())
+	assert.Nil(err)
+	assert.Empty(warnings)
+	assert.Equal(collection.Name, roundtrip.Name)
+	assert.Len(roundtrip.Fields, len(collection.Fields))
+
+	// constraints on nested values and non-integer exclusive minimums are reported, not dropped
+	assert.Nil(json.Unmarshal([]byte(`{
+		"title": "orders",
+		"properties": {
+			"address": {
+				"type": "object",
+				"required": ["zip"],
+				"properties": {
+					"zip": {"type": "string", "maxLength": 5},
+					"placed_at": {"type": "string", "format": "date-time"}
+				}
+			},
+			"tags": {
+				"type": "array",
+				"items": {"type": "string", "enum": ["a", "b"]}
+			},
+			"price": {
+				"type": "number",
+				"exclusiveMinimum": 0
+			},
+			"qty": {
+				"type": "integer",
+				"exclusiveMinimum": 0
+			}
+		}
+	}`), &schema))
+
+	collection, warnings, err = CollectionFromJSONSchema(``, schema)
+	assert.Nil(err)
+	assert.Equal([]string{
+		`orders.address: required nested properties [zip] are not enforced`,
+		`orders.address: keyword "maxLength" of address.zip is not enforced`,
+		`orders.price: exclusiveMinimum of 0 is not supported`,
+		`orders.tags: keyword "enum" of tags[] is not enforced`,
+	}, warnings)
+
+	field, ok = collection.GetField(`price`)
+	assert.True(ok)
+	assert.Empty(field.ValidatorConfig)
+
+	field, ok = collection.GetField(`qty`)
+	assert.True(ok)
+	assert.Equal(true, field.ValidatorConfig[`positive-integer`])
+
+	_, _, err = CollectionFromJSONSchema(``, map[string]interface{}{
+		`type`: `array`,
+	})
+
+	assert.Error(err)
+}
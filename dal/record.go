@@ -7,7 +7,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/fatih/structs"
 	"github.com/ghetzel/go-stockutil/log"
 	"github.com/ghetzel/go-stockutil/maputil"
 	"github.com/ghetzel/go-stockutil/stringutil"
@@ -199,7 +198,6 @@ func (self *Record) Populate(into interface{}, collection *Collection) error {
 			}
 		}
 
-		instanceStruct := structs.New(into)
 		var idFieldName string
 		var fallbackIdFieldName string
 
@@ -221,110 +219,109 @@ func (self *Record) Populate(into interface{}, collection *Collection) error {
 
 		if idFieldName != `` {
 			// get field descriptors for the output struct
-			if fields, err := getFieldsForStruct(into); err == nil {
+			fields, err := getFieldsForStruct(into, true)
+
+			if err == nil {
 				// for each value in the record's fields map...
 				for key, value := range self.Fields {
 					// only operate on fields that exist in the output struct
 					if field, ok := fields[key]; ok {
-						// only operate on exported output struct fields
-						if field.Field.IsExported() {
-							// skip the identity field, we handle that separately
-							if field.Identity || field.Field.Name() == idFieldName {
-								continue
-							}
+						// skip the identity field, we handle that separately
+						if field.Identity || field.Name == idFieldName {
+							continue
+						}
 
-							// if a collection is specified, then use the corresponding field from that collection
-							// to format the value first
-							if collection != nil {
-								if collectionField, ok := collection.GetField(key); ok {
-									// use the field's type in the collection schema to convert the value
-									if v, err := collectionField.ConvertValue(value); err == nil {
-										value = v
-									} else {
-										return err
-									}
+						// if a collection is specified, then use the corresponding field from that collection
+						// to format the value first
+						if collection != nil {
+							if collectionField, ok := collection.GetField(key); ok {
+								// use the field's type in the collection schema to convert the value
+								if v, err := collectionField.ConvertValue(value); err == nil {
+									value = v
+								} else {
+									return err
+								}
 
-									// apply formatters to this value
-									if v, err := collectionField.Format(value, RetrieveOperation); err == nil {
-										value = v
-									} else {
-										return err
-									}
+								// apply formatters to this value
+								if v, err := collectionField.Format(value, RetrieveOperation); err == nil {
+									value = v
+								} else {
+									return err
+								}
 
-									// this specifies that we should double-check the validity of the values coming in
-									if collectionField.ValidateOnPopulate {
-										// validate the value
-										if err := collectionField.Validate(value); err != nil {
-											return err
-										}
+								// this specifies that we should double-check the validity of the values coming in
+								if collectionField.ValidateOnPopulate {
+									// validate the value
+									if err := collectionField.Validate(value); err != nil {
+										return err
 									}
-								} else {
-									// because we were given a collection, we know whether we should actually
-									// work with this field or not
-									continue
 								}
+							} else {
+								// because we were given a collection, we know whether we should actually
+								// work with this field or not
+								continue
 							}
+						}
 
-							// skip values that are that type's zero value if OmitEmpty is set
-							if field.OmitEmpty {
-								if typeutil.IsZero(value) {
-									continue
-								}
+						// skip values that are that type's zero value if OmitEmpty is set
+						if field.OmitEmpty {
+							if typeutil.IsZero(value) {
+								continue
 							}
+						}
 
-							// get the underlying type of the field
-							fType := reflect.TypeOf(field.Field.Value())
-							vValue := reflect.ValueOf(value)
-
-							// convert the value to the field's type if necessary
-							if fType != nil {
-								if vValue.IsValid() {
-									if !vValue.Type().AssignableTo(fType) {
-										if vValue.Type().ConvertibleTo(fType) {
-											vValue = vValue.Convert(fType)
-											value = vValue.Interface()
-										}
+						// get the underlying type of the field
+						fType := field.ReflectField.Type()
+						vValue := reflect.ValueOf(value)
+
+						// convert the value to the field's type if necessary
+						if fType != nil {
+							if vValue.IsValid() {
+								if !vValue.Type().AssignableTo(fType) {
+									if vValue.Type().ConvertibleTo(fType) {
+										vValue = vValue.Convert(fType)
+										value = vValue.Interface()
 									}
-								} else {
-									value = reflect.Zero(fType).Interface()
 								}
+							} else {
+								value = reflect.Zero(fType).Interface()
 							}
+						}
 
-							// last-ditch effort to handle weird edge cases
-							switch field.ReflectField.Type().String() {
-							case `time.Time`, `*time.Time`:
-								isPtr := strings.HasPrefix(field.ReflectField.Type().String(), `*`)
+						// last-ditch effort to handle weird edge cases
+						switch field.ReflectField.Type().String() {
+						case `time.Time`, `*time.Time`:
+							isPtr := strings.HasPrefix(field.ReflectField.Type().String(), `*`)
 
-								if v, err := stringutil.ConvertToTime(value); err == nil {
-									if isPtr {
-										value = &v
-									} else {
-										value = v
-									}
-								} else if v, err := stringutil.ConvertToInteger(value); err == nil {
-									var vT time.Time
-
-									// guess at whether we're dealing with epoch seconds or nanoseconds
-									if v <= 4294967296 {
-										vT = time.Unix(v, 0)
-									} else {
-										vT = time.Unix(0, v)
-									}
+							if v, err := stringutil.ConvertToTime(value); err == nil {
+								if isPtr {
+									value = &v
+								} else {
+									value = v
+								}
+							} else if v, err := stringutil.ConvertToInteger(value); err == nil {
+								var vT time.Time
 
-									if isPtr {
-										value = &vT
-									} else {
-										value = vT
-									}
+								// guess at whether we're dealing with epoch seconds or nanoseconds
+								if v <= 4294967296 {
+									vT = time.Unix(v, 0)
 								} else {
-									return err
+									vT = time.Unix(0, v)
 								}
-							}
 
-							if err := typeutil.SetValue(field.ReflectField, value); err != nil {
-								log.Warningf("Failed to set field %s: %v", field.Field.Name(), err)
+								if isPtr {
+									value = &vT
+								} else {
+									value = vT
+								}
+							} else {
+								return err
 							}
 						}
+
+						if err := setStructValue(field.ReflectField, value); err != nil {
+							log.Warningf("Failed to set field %s: %v", field.Name, err)
+						}
 					}
 				}
 			} else {
@@ -332,7 +329,7 @@ func (self *Record) Populate(into interface{}, collection *Collection) error {
 			}
 
 			// get the underlying field from the struct we're outputting to
-			if _, ok := instanceStruct.FieldOk(idFieldName); ok {
+			if idField, ok := fieldByStructName(fields, idFieldName); ok {
 				// if possible, format and validate the record ID first.
 				// this lets us create (for example) random IDs
 				if collection != nil {
@@ -344,16 +341,7 @@ func (self *Record) Populate(into interface{}, collection *Collection) error {
 				}
 
 				if self.ID != nil {
-					id := self.ID
-					reflectField := reflect.ValueOf(into)
-
-					if reflectField.Kind() == reflect.Ptr {
-						reflectField = reflectField.Elem()
-					}
-
-					reflectField = reflectField.FieldByName(idFieldName)
-
-					if err := typeutil.SetValue(reflectField, id); err != nil {
+					if err := typeutil.SetValue(idField.ReflectField, self.ID); err != nil {
 						return fmt.Errorf("Field '%s' is not settable: %v", idFieldName, err)
 					}
 				}
@@ -1,24 +1,39 @@
 package dal
 
 import (
+	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
 
-	"github.com/fatih/structs"
 	"github.com/ghetzel/go-stockutil/sliceutil"
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/ghetzel/go-stockutil/typeutil"
 )
 
 var RecordStructTag = `pivot`
 var DefaultStructIdentityFieldName = `ID`
 
 type fieldDescription struct {
-	Field        *structs.Field
+	Name         string
 	ReflectField reflect.Value
 	Identity     bool
 	OmitEmpty    bool
 }
 
+// Returns the current value of the struct field.
+func (self fieldDescription) Value() interface{} {
+	return self.ReflectField.Interface()
+}
+
+// Returns whether the struct field contains its type's zero value.
+func (self fieldDescription) IsZero() bool {
+	return typeutil.IsZero(self.Value())
+}
+
 type Model interface{}
 
 func GetIdentityFieldName(instance interface{}, fallbackIdentityFieldName string) (string, error) {
@@ -26,16 +41,16 @@ func GetIdentityFieldName(instance interface{}, fallbackIdentityFieldName string
 		return ``, err
 	}
 
-	s := structs.New(instance)
+	fields, err := getFieldsForStruct(instance, false)
 
-	// find a field with an ",identity" tag and get its value
-	for _, field := range s.Fields() {
-		if tag := field.Tag(RecordStructTag); tag != `` {
-			v := strings.Split(tag, `,`)
+	if err != nil {
+		return ``, err
+	}
 
-			if sliceutil.ContainsString(v[1:], `identity`) {
-				return field.Name(), nil
-			}
+	// find a field with an ",identity" tag
+	for _, field := range fields {
+		if field.Identity {
+			return field.Name, nil
 		}
 	}
 
@@ -43,9 +58,9 @@ func GetIdentityFieldName(instance interface{}, fallbackIdentityFieldName string
 		fallbackIdentityFieldName = DefaultStructIdentityFieldName
 	}
 
-	if _, ok := s.FieldOk(fallbackIdentityFieldName); ok {
+	if _, ok := fieldByStructName(fields, fallbackIdentityFieldName); ok {
 		return fallbackIdentityFieldName, nil
-	} else if _, ok := s.FieldOk(DefaultStructIdentityFieldName); ok {
+	} else if _, ok := fieldByStructName(fields, DefaultStructIdentityFieldName); ok {
 		return DefaultStructIdentityFieldName, nil
 	}
 
@@ -70,10 +85,11 @@ func validatePtrToStructType(instance interface{}) error {
 	return fmt.Errorf("Can only operate on pointer to struct, got %T", instance)
 }
 
-func getFieldsForStruct(instance interface{}) (map[string]fieldDescription, error) {
-	fields := make(map[string]fieldDescription)
-	identitySet := false
-
+// Returns descriptions of the exported fields of the given struct, keyed on the name used for them
+// in records.  The fields of embedded structs (and of struct fields tagged ",inline") are promoted
+// into the parent, with fields declared at a shallower depth taking precedence.  Embedded pointers
+// that are nil are skipped unless allocate is true, in which case they are set to a new instance.
+func getFieldsForStruct(instance interface{}, allocate bool) (map[string]fieldDescription, error) {
 	reflectStruct := reflect.ValueOf(instance)
 
 	if reflectStruct.Kind() == reflect.Ptr {
@@ -84,40 +100,551 @@ func getFieldsForStruct(instance interface{}) (map[string]fieldDescription, erro
 		return nil, fmt.Errorf("value must be a struct")
 	}
 
-	instanceStruct := structs.New(instance)
+	return getFieldsForStructValue(reflectStruct, allocate), nil
+}
+
+func getFieldsForStructValue(reflectStruct reflect.Value, allocate bool) map[string]fieldDescription {
+	fields := make(map[string]fieldDescription)
+	level := []reflect.Value{reflectStruct}
+
+	// walk the struct breadth-first so that promoted fields never shadow shallower ones
+	for len(level) > 0 {
+		next := make([]reflect.Value, 0)
+		found := make(map[string]fieldDescription)
+
+		for _, structV := range level {
+			structT := structV.Type()
+
+			for i := 0; i < structT.NumField(); i++ {
+				structField := structT.Field(i)
+				tag := structField.Tag.Get(RecordStructTag)
+
+				// embedded Models only carry the collection name
+				if tag == `-` || (structField.Anonymous && structField.Type == modelType) {
+					continue
+				}
+
+				name, options := parseStructTag(tag)
+				fieldV := structV.Field(i)
+
+				if isPromotedStructField(structField, name, options) {
+					if fieldV.Kind() == reflect.Ptr {
+						if fieldV.IsNil() {
+							if allocate && fieldV.CanSet() {
+								fieldV.Set(reflect.New(fieldV.Type().Elem()))
+							} else {
+								continue
+							}
+						}
+
+						fieldV = fieldV.Elem()
+					}
+
+					next = append(next, fieldV)
+					continue
+				}
+
+				if structField.PkgPath != `` {
+					continue
+				}
+
+				if name == `` {
+					name = structField.Name
+				}
+
+				if _, ok := fields[name]; ok {
+					continue
+				} else if _, ok := found[name]; ok {
+					continue
+				}
+
+				_, identity := options[`identity`]
+				_, omitEmpty := options[`omitempty`]
+
+				found[name] = fieldDescription{
+					Name:         structField.Name,
+					ReflectField: fieldV,
+					Identity:     identity,
+					OmitEmpty:    omitEmpty,
+				}
+			}
+		}
+
+		for name, field := range found {
+			fields[name] = field
+		}
+
+		level = next
+	}
+
+	return fields
+}
+
+// returns whether the fields of the given struct field should be promoted into its parent, which is
+// the case for untagged embedded structs and for struct fields tagged ",inline"
+func isPromotedStructField(structField reflect.StructField, name string, options map[string]string) bool {
+	if !isNestedStructType(structField.Type) {
+		return false
+	}
+
+	if _, ok := options[`inline`]; ok {
+		return true
+	}
+
+	return structField.Anonymous && name == ``
+}
+
+// returns whether the given type (or the type it points to) is a struct whose fields are mapped
+// individually, as opposed to a struct-based value type like time.Time or sql.NullString
+func isNestedStructType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct || t == timeType {
+		return false
+	}
+
+	if t.Implements(valuerType) || reflect.PtrTo(t).Implements(scannerType) {
+		return false
+	}
+
+	return true
+}
+
+// returns the description of the field with the given Go field name
+func fieldByStructName(fields map[string]fieldDescription, name string) (fieldDescription, bool) {
+	for _, field := range fields {
+		if field.Name == name {
+			return field, true
+		}
+	}
+
+	return fieldDescription{}, false
+}
+
+// Converts the value of a struct field into the form it is stored in a record.  Nested structs
+// become maps (using the same field names as top-level structs), slices of structs become slices
+// of maps, nil pointers become nil, and optional types like sql.NullString become either the value
+// they hold or nil.
+func recordValueFromStruct(value reflect.Value) interface{} {
+	if !value.IsValid() {
+		return nil
+	}
+
+	if value.Type().Implements(valuerType) {
+		if value.Kind() == reflect.Ptr && value.IsNil() {
+			return nil
+		}
+
+		if v, err := value.Interface().(driver.Valuer).Value(); err == nil {
+			return v
+		}
+
+		return value.Interface()
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			return nil
+		} else if isNestedStructType(value.Type()) {
+			return recordValueFromStruct(value.Elem())
+		}
+
+	case reflect.Struct:
+		if isNestedStructType(value.Type()) {
+			output := make(map[string]interface{})
+
+			for name, field := range getFieldsForStructValue(value, false) {
+				if field.OmitEmpty && field.IsZero() {
+					continue
+				}
+
+				output[name] = recordValueFromStruct(field.ReflectField)
+			}
+
+			return output
+		}
+
+	case reflect.Slice, reflect.Array:
+		if elemT := value.Type().Elem(); isNestedStructType(elemT) || elemT.Implements(valuerType) {
+			if value.Kind() == reflect.Slice && value.IsNil() {
+				return nil
+			}
+
+			output := make([]interface{}, value.Len())
+
+			for i := 0; i < value.Len(); i++ {
+				output[i] = recordValueFromStruct(value.Index(i))
+			}
+
+			return output
+		}
+	}
+
+	return value.Interface()
+}
+
+// Sets a struct field from a value stored in a record, reversing the conversions performed by
+// recordValueFromStruct.  Types implementing sql.Scanner are given the value to scan, and nil
+// pointers to nested structs are allocated as needed.
+func setStructValue(target reflect.Value, value interface{}) error {
+	if !target.CanSet() {
+		return typeutil.SetValue(target, value)
+	}
+
+	if value != nil && reflect.TypeOf(value).AssignableTo(target.Type()) {
+		target.Set(reflect.ValueOf(value))
+		return nil
+	}
+
+	if target.CanAddr() {
+		if scanner, ok := target.Addr().Interface().(sql.Scanner); ok {
+			return scanner.Scan(value)
+		}
+	}
+
+	if value == nil {
+		target.Set(reflect.Zero(target.Type()))
+		return nil
+	}
+
+	switch target.Kind() {
+	case reflect.Ptr:
+		elemT := target.Type().Elem()
+
+		if isNestedStructType(elemT) || reflect.PtrTo(elemT).Implements(scannerType) {
+			elem := reflect.New(elemT)
 
-	for _, field := range instanceStruct.Fields() {
-		var identity, omitEmpty bool
+			if err := setStructValue(elem.Elem(), value); err == nil {
+				target.Set(elem)
+				return nil
+			} else {
+				return err
+			}
+		}
 
-		name := field.Name()
+	case reflect.Struct:
+		if isNestedStructType(target.Type()) {
+			if values, ok := value.(map[string]interface{}); ok {
+				fields := getFieldsForStructValue(target, true)
 
-		// read struct tags to determine how values are mapped to struct fields
-		if tag := field.Tag(RecordStructTag); tag != `` {
-			v := strings.Split(tag, `,`)
+				for key, v := range values {
+					if field, ok := fields[key]; ok {
+						if err := setStructValue(field.ReflectField, v); err != nil {
+							return fmt.Errorf("%s: %v", key, err)
+						}
+					}
+				}
 
-			// if the first value isn't an empty string, that's what we're calling the field
-			if v[0] != `` {
-				name = v[0]
+				return nil
 			}
+		}
+
+	case reflect.Slice:
+		elemT := target.Type().Elem()
 
-			// set additional flags from tag options
-			if len(v) > 1 {
-				identity = sliceutil.ContainsString(v[1:], `identity`)
-				omitEmpty = sliceutil.ContainsString(v[1:], `omitempty`)
+		if isNestedStructType(elemT) || reflect.PtrTo(elemT).Implements(scannerType) {
+			if typeutil.IsArray(value) {
+				items := sliceutil.Sliceify(value)
+				output := reflect.MakeSlice(target.Type(), len(items), len(items))
+
+				for i, item := range items {
+					if err := setStructValue(output.Index(i), item); err != nil {
+						return fmt.Errorf("element %d: %v", i, err)
+					}
+				}
+
+				target.Set(output)
+				return nil
 			}
 		}
+	}
+
+	return typeutil.SetValue(target, value)
+}
+
+var modelType = reflect.TypeOf((*Model)(nil)).Elem()
+var timeType = reflect.TypeOf(time.Time{})
+var bytesType = reflect.TypeOf([]byte{})
+var valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
 
-		if !identitySet && identity {
+// Generates a Collection definition from the given struct, using the same struct tags that are used
+// to populate instances of the struct from records.  The collection name is taken from the tag on an
+// embedded dal.Model field, or is the underscored name of the struct type if not specified.
+//
+// In addition to the field name, the following tag options are supported:
+//
+//	identity          this field is the collection's identity field
+//	required          values must be provided for this field
+//	unique            values of this field must be unique
+//	key               this field is part of a composite key
+//	length=N          the maximum length of the field
+//	precision=N       the precision of numeric fields
+//	default=VALUE     the default value of the field
+//	description=TEXT  a description of the field (cannot contain commas)
+//	type=TYPE         override the field type detected from the Go type
+//	subtype=TYPE      the type of values contained in the field
+//	keytype=TYPE      the type of keys contained in the field
+//	values=A|B|C      the values permitted in an enum field
+//	inline            promote the fields of this struct into the parent
+//
+// The fields of embedded structs are promoted into the collection as they would be in Go, nested
+// structs are stored as objects, and optional types like sql.NullString take the type of the value
+// they hold.  Fields tagged with "-" and unexported fields are skipped.
+func CollectionFromStruct(instance interface{}) (*Collection, error) {
+	if err := validatePtrToStructType(instance); err != nil {
+		return nil, err
+	}
+
+	structT := reflect.TypeOf(instance)
+
+	if structT.Kind() == reflect.Ptr {
+		structT = structT.Elem()
+	}
+
+	collection := NewCollection(stringutil.Underscore(structT.Name()))
+	collection.SetRecordType(instance)
+
+	identitySet := false
+	structFields := flattenStructFields(structT)
+	explicitIdentity := hasIdentityTag(structFields)
+
+	for _, structField := range structFields {
+		tag := structField.Tag.Get(RecordStructTag)
+
+		// the tag on an embedded Model names the collection
+		if structField.Anonymous && structField.Type == modelType {
+			if name, _ := parseStructTag(tag); name != `` {
+				collection.Name = name
+			}
+
+			continue
+		}
+
+		if structField.PkgPath != `` || tag == `-` {
+			continue
+		}
+
+		name, options := parseStructTag(tag)
+		field := Field{
+			Name: name,
+			Type: typeFromReflectType(structField.Type),
+		}
+
+		if field.Type == ArrayType {
+			field.Subtype = typeFromReflectType(structField.Type.Elem())
+		}
+
+		if field.Name == `` {
+			field.Name = structField.Name
+		}
+
+		for option, value := range options {
+			switch option {
+			case `identity`:
+				field.Identity = true
+			case `required`:
+				field.Required = true
+			case `unique`:
+				field.Unique = true
+			case `indexed`:
+				field.Indexed = true
+			case `key`:
+				field.Key = true
+			case `length`, `precision`:
+				if v, err := strconv.Atoi(value); err == nil {
+					if option == `length` {
+						field.Length = v
+					} else {
+						field.Precision = v
+					}
+				} else {
+					return nil, fmt.Errorf("field %s: invalid %s %q", structField.Name, option, value)
+				}
+			case `default`:
+				field.DefaultValue = stringutil.Autotype(value)
+			case `description`:
+				field.Description = value
+			case `type`:
+				field.Type = Type(value)
+			case `subtype`:
+				field.Subtype = Type(value)
+			case `keytype`:
+				field.KeyType = Type(value)
+			case `values`:
+				field.Values = strings.Split(value, `|`)
+			case `omitempty`, `inline`:
+				continue
+			default:
+				return nil, fmt.Errorf("field %s: unknown tag option %q", structField.Name, option)
+			}
+		}
+
+		// fields named ID are the identity field unless another field is explicitly marked as such
+		if !identitySet && (field.Identity || (structField.Name == DefaultStructIdentityFieldName && !explicitIdentity)) {
 			identitySet = true
+
+			if name == `` {
+				name = DefaultIdentityField
+			}
+
+			collection.IdentityField = name
+			collection.IdentityFieldType = field.Type
+			continue
+		}
+
+		collection.Fields = append(collection.Fields, field)
+	}
+
+	return collection, nil
+}
+
+// splits a struct tag into the field name and a map of options
+func parseStructTag(tag string) (string, map[string]string) {
+	parts := strings.Split(tag, `,`)
+	options := make(map[string]string)
+
+	for _, option := range parts[1:] {
+		if option == `` {
+			continue
 		}
 
-		fields[name] = fieldDescription{
-			Field:        field,
-			ReflectField: reflectStruct.FieldByName(field.Name()),
-			Identity:     identity,
-			OmitEmpty:    omitEmpty,
+		key, value := stringutil.SplitPair(option, `=`)
+		options[key] = value
+	}
+
+	return parts[0], options
+}
+
+// returns the fields of the given struct type, with the fields of embedded and inline structs
+// promoted in their place (following the same precedence rules as getFieldsForStruct)
+func flattenStructFields(structT reflect.Type) []reflect.StructField {
+	output := make([]reflect.StructField, 0)
+	seen := make(map[string]bool)
+	level := []reflect.Type{structT}
+
+	for depth := 0; len(level) > 0; depth++ {
+		next := make([]reflect.Type, 0)
+		found := make([]reflect.StructField, 0)
+		foundNames := make(map[string]bool)
+
+		for _, t := range level {
+			for i := 0; i < t.NumField(); i++ {
+				structField := t.Field(i)
+				name, options := parseStructTag(structField.Tag.Get(RecordStructTag))
+
+				if structField.Anonymous && structField.Type == modelType {
+					// only the outermost Model names the collection
+					if depth == 0 {
+						output = append(output, structField)
+					}
+
+					continue
+				}
+
+				if isPromotedStructField(structField, name, options) {
+					if structField.Type.Kind() == reflect.Ptr {
+						next = append(next, structField.Type.Elem())
+					} else {
+						next = append(next, structField.Type)
+					}
+
+					continue
+				}
+
+				if name == `` {
+					name = structField.Name
+				}
+
+				// the first of several fields with the same name at the same depth wins
+				if !seen[name] && !foundNames[name] {
+					found = append(found, structField)
+					foundNames[name] = true
+				}
+			}
+		}
+
+		for _, structField := range found {
+			name, _ := parseStructTag(structField.Tag.Get(RecordStructTag))
+
+			if name == `` {
+				name = structField.Name
+			}
+
+			seen[name] = true
+			output = append(output, structField)
+		}
+
+		level = next
+	}
+
+	return output
+}
+
+func hasIdentityTag(structFields []reflect.StructField) bool {
+	for _, structField := range structFields {
+		_, options := parseStructTag(structField.Tag.Get(RecordStructTag))
+
+		if _, ok := options[`identity`]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func typeFromReflectType(t reflect.Type) Type {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	// optional types take the type of the value they wrap
+	if valueT, ok := nullableValueType(t); ok {
+		t = valueT
+	}
+
+	if t == timeType {
+		return TimeType
+	} else if t == bytesType {
+		return BytesType
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return StringType
+	case reflect.Bool:
+		return BooleanType
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return IntType
+	case reflect.Float32, reflect.Float64:
+		return FloatType
+	case reflect.Slice, reflect.Array:
+		return ArrayType
+	default:
+		return ObjectType
+	}
+}
+
+// Returns the type of the value held by an optional type like sql.NullString; that is, a struct that
+// implements driver.Valuer and consists of a boolean Valid field and one other field.
+func nullableValueType(t reflect.Type) (reflect.Type, bool) {
+	if t.Kind() != reflect.Struct || t.NumField() != 2 || !t.Implements(valuerType) {
+		return nil, false
+	}
+
+	if valid, ok := t.FieldByName(`Valid`); !ok || valid.Type.Kind() != reflect.Bool {
+		return nil, false
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		if field := t.Field(i); field.Name != `Valid` {
+			return field.Type, true
 		}
 	}
 
-	return fields, nil
+	return nil, false
 }
@@ -1,7 +1,10 @@
 package dal
 
 import (
+	"database/sql"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -49,3 +52,274 @@ func TestGetIdentityFieldName(t *testing.T) {
 	id, err = GetIdentityFieldName(&f4, `UUID`)
 	assert.Equal(`UUID`, id)
 }
+
+type TestWidget struct {
+	Model     `pivot:"widgets"`
+	ID        string    `pivot:"id,identity"`
+	Type      string    `pivot:"type,required,length=32,description=The type of widget."`
+	Usage     string    `pivot:"usage,omitempty"`
+	Enabled   bool      `pivot:"enabled,default=true"`
+	Serial    int64     `pivot:"serial,unique"`
+	Price     float64   `pivot:"price,precision=2"`
+	Tags      []string  `pivot:"tags"`
+	Data      []byte    `pivot:"data"`
+	CreatedAt time.Time `pivot:"created_at"`
+	Skipped   string    `pivot:"-"`
+	internal  string
+}
+
+func TestCollectionFromStruct(t *testing.T) {
+	assert := require.New(t)
+
+	collection, err := CollectionFromStruct(&TestWidget{})
+	assert.Nil(err)
+
+	assert.Equal(`widgets`, collection.Name)
+	assert.Equal(`id`, collection.IdentityField)
+	assert.Equal(StringType, collection.IdentityFieldType)
+	assert.Equal(reflect.TypeOf(TestWidget{}), collection.recordType)
+	assert.Equal([]Field{
+		{
+			Name:        `type`,
+			Description: `The type of widget.`,
+			Type:        StringType,
+			Length:      32,
+			Required:    true,
+		}, {
+			Name: `usage`,
+			Type: StringType,
+		}, {
+			Name:         `enabled`,
+			Type:         BooleanType,
+			DefaultValue: true,
+		}, {
+			Name:   `serial`,
+			Type:   IntType,
+			Unique: true,
+		}, {
+			Name:      `price`,
+			Type:      FloatType,
+			Precision: 2,
+		}, {
+			Name:    `tags`,
+			Type:    ArrayType,
+			Subtype: StringType,
+		}, {
+			Name: `data`,
+			Type: BytesType,
+		}, {
+			Name: `created_at`,
+			Type: TimeType,
+		},
+	}, collection.Fields)
+
+	// untagged ID fields are the identity, and the collection name comes from the type
+	collection, err = CollectionFromStruct(&TestRecordThree{})
+	assert.Nil(err)
+	assert.Equal(`test_record_three`, collection.Name)
+	assert.Equal(DefaultIdentityField, collection.IdentityField)
+	assert.Equal([]Field{
+		{
+			Name: `UUID`,
+			Type: StringType,
+		},
+	}, collection.Fields)
+
+	collection, err = CollectionFromStruct(&TestRecord{})
+	assert.Nil(err)
+	assert.Equal(`test_records`, collection.Name)
+	assert.Equal(`id`, collection.IdentityField)
+	assert.Equal(Type(IntType), collection.IdentityFieldType)
+	assert.Len(collection.Fields, 2)
+
+	_, err = CollectionFromStruct(&struct {
+		Name string `pivot:"name,length=long"`
+	}{})
+	assert.Error(err)
+
+	_, err = CollectionFromStruct(&struct {
+		Name string `pivot:"name,bogus"`
+	}{})
+	assert.Error(err)
+}
+
+type TestTimestamps struct {
+	CreatedAt time.Time  `pivot:"created_at"`
+	UpdatedAt *time.Time `pivot:"updated_at"`
+}
+
+type TestAddress struct {
+	Street string `pivot:"street"`
+	City   string `pivot:"city,omitempty"`
+}
+
+type TestContact struct {
+	Kind  string `pivot:"kind"`
+	Value string `pivot:"value"`
+}
+
+type TestPerson struct {
+	Model `pivot:"people"`
+	TestTimestamps
+	ID       string         `pivot:"id,identity"`
+	Name     string         `pivot:"name"`
+	Nickname sql.NullString `pivot:"nickname"`
+	Age      sql.NullInt64  `pivot:"age"`
+	Address  TestAddress    `pivot:"address"`
+	Billing  *TestAddress   `pivot:"billing"`
+	Contacts []TestContact  `pivot:"contacts"`
+	Extra    struct {
+		Notes string `pivot:"notes"`
+	} `pivot:",inline"`
+}
+
+func TestCollectionFromStructNested(t *testing.T) {
+	assert := require.New(t)
+
+	collection, err := CollectionFromStruct(&TestPerson{})
+	assert.Nil(err)
+	assert.Equal(`people`, collection.Name)
+	assert.Equal(`id`, collection.IdentityField)
+
+	types := make(map[string]Type)
+
+	for _, field := range collection.Fields {
+		types[field.Name] = field.Type
+	}
+
+	assert.Equal(map[string]Type{
+		`name`:       StringType,
+		`nickname`:   StringType,
+		`age`:        IntType,
+		`address`:    ObjectType,
+		`billing`:    ObjectType,
+		`contacts`:   ArrayType,
+		`notes`:      StringType,
+		`created_at`: TimeType,
+		`updated_at`: TimeType,
+	}, types)
+
+	// promoted fields come after those declared directly on the struct
+	assert.Equal(`contacts`, collection.Fields[5].Name)
+	assert.Equal(`created_at`, collection.Fields[6].Name)
+	assert.Equal(`notes`, collection.Fields[8].Name)
+
+	// a name promoted from several structs at the same depth only becomes one field
+	collection, err = CollectionFromStruct(&struct {
+		TestTimestamps
+		Other struct {
+			CreatedAt time.Time `pivot:"created_at"`
+		} `pivot:",inline"`
+	}{})
+
+	assert.Nil(err)
+	assert.Len(collection.Fields, 2)
+	assert.Equal(`created_at`, collection.Fields[0].Name)
+	assert.Equal(`updated_at`, collection.Fields[1].Name)
+}
+
+func TestStructRecordRoundTrip(t *testing.T) {
+	assert := require.New(t)
+
+	collection, err := CollectionFromStruct(&TestPerson{})
+	assert.Nil(err)
+
+	created := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	person := TestPerson{
+		TestTimestamps: TestTimestamps{
+			CreatedAt: created,
+		},
+		ID:       `p1`,
+		Name:     `Alice`,
+		Nickname: sql.NullString{String: `Al`, Valid: true},
+		Address: TestAddress{
+			Street: `1 Main St`,
+		},
+		Contacts: []TestContact{
+			{Kind: `email`, Value: `alice@example.com`},
+		},
+	}
+
+	person.Extra.Notes = `hello`
+
+	record, err := collection.MakeRecord(&person)
+	assert.Nil(err)
+	assert.Equal(`p1`, record.ID)
+	assert.Equal(`Al`, record.Get(`nickname`))
+	assert.Nil(record.Get(`age`))
+	assert.Nil(record.Get(`billing`))
+	assert.Nil(record.Get(`updated_at`))
+	assert.Equal(`hello`, record.Get(`notes`))
+	assert.Equal(created, record.Get(`created_at`))
+	assert.Equal(map[string]interface{}{
+		`street`: `1 Main St`,
+	}, record.Get(`address`))
+	assert.Equal([]interface{}{
+		map[string]interface{}{
+			`kind`:  `email`,
+			`value`: `alice@example.com`,
+		},
+	}, record.Get(`contacts`))
+
+	// populate a new instance from a record, as it would come back from a backend
+	output := NewRecord(`p2`).SetFields(map[string]interface{}{
+		`name`:       `Bob`,
+		`nickname`:   nil,
+		`age`:        int64(42),
+		`notes`:      `world`,
+		`created_at`: created,
+		`updated_at`: created,
+		`address`: map[string]interface{}{
+			`street`: `2 Side St`,
+			`city`:   `Springfield`,
+		},
+		`billing`: map[string]interface{}{
+			`city`: `Shelbyville`,
+		},
+		`contacts`: []interface{}{
+			map[string]interface{}{
+				`kind`:  `phone`,
+				`value`: `555-1234`,
+			},
+		},
+	})
+
+	var bob TestPerson
+
+	assert.Nil(output.Populate(&bob, collection))
+	assert.Equal(`p2`, bob.ID)
+	assert.Equal(`Bob`, bob.Name)
+	assert.False(bob.Nickname.Valid)
+	assert.Equal(sql.NullInt64{Int64: 42, Valid: true}, bob.Age)
+	assert.Equal(`world`, bob.Extra.Notes)
+	assert.Equal(created, bob.CreatedAt)
+	assert.NotNil(bob.UpdatedAt)
+	assert.Equal(created, *bob.UpdatedAt)
+	assert.Equal(TestAddress{Street: `2 Side St`, City: `Springfield`}, bob.Address)
+	assert.Equal(&TestAddress{City: `Shelbyville`}, bob.Billing)
+	assert.Equal([]TestContact{
+		{Kind: `phone`, Value: `555-1234`},
+	}, bob.Contacts)
+}
+
+type testEmbeddedIdentity struct {
+	ID int `pivot:"id,identity"`
+}
+
+func TestGetIdentityFieldNamePromoted(t *testing.T) {
+	assert := require.New(t)
+
+	thing := struct {
+		*testEmbeddedIdentity
+		Name string `pivot:"name"`
+	}{
+		testEmbeddedIdentity: &testEmbeddedIdentity{},
+	}
+
+	id, err := GetIdentityFieldName(&thing, ``)
+	assert.Nil(err)
+	assert.Equal(`ID`, id)
+
+	assert.Nil(NewRecord(5).Populate(&thing, nil))
+	assert.Equal(5, thing.ID)
+}
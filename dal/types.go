@@ -16,8 +16,50 @@ const (
 	TimeType         = `time`
 	ObjectType       = `object`
 	RawType          = `raw`
+	ArrayType        = `array`
+	EnumType         = `enum`
+	DecimalType      = `decimal`
+	UUIDType         = `uuid`
+	BytesType        = `bytes`
 )
 
+// Backends without native support for the extended types store them using one of these more basic
+// types, so when comparing schemata, fields of these types are considered equivalent.
+var typeStorageEquivalents = map[Type][]Type{
+	ObjectType:  {RawType},
+	ArrayType:   {ObjectType, RawType},
+	EnumType:    {StringType},
+	DecimalType: {StringType, FloatType},
+	UUIDType:    {StringType},
+	BytesType:   {RawType},
+}
+
+// Returns whether this is one of the extended types, whose values are converted (and checked) as
+// part of validating a record, rather than only when they are read from or written to a backend.
+func (self Type) IsExtended() bool {
+	switch self {
+	case ArrayType, EnumType, DecimalType, UUIDType, BytesType:
+		return true
+	default:
+		return false
+	}
+}
+
+// Returns whether values of this type can be stored in a field of the given type without loss.
+func (self Type) IsStoredAs(other Type) bool {
+	if self == other {
+		return true
+	}
+
+	for _, t := range typeStorageEquivalents[self] {
+		if t == other {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (self Type) String() string {
 	return string(self)
 }
@@ -55,14 +97,15 @@ const (
 )
 
 type SchemaDelta struct {
-	Type       DeltaType
-	Issue      DeltaIssue
-	Message    string
-	Collection string
-	Name       string
-	Parameter  string
-	Desired    interface{}
-	Actual     interface{}
+	Type           DeltaType
+	Issue          DeltaIssue
+	Message        string
+	Collection     string
+	Name           string
+	Parameter      string
+	Desired        interface{}
+	Actual         interface{}
+	ReferenceField *Field
 }
 
 func (self SchemaDelta) String() string {
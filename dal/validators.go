@@ -2,18 +2,35 @@ package dal
 
 import (
 	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
+	"github.com/ghetzel/go-stockutil/maputil"
 	"github.com/ghetzel/go-stockutil/sliceutil"
 	"github.com/ghetzel/go-stockutil/stringutil"
 	"github.com/ghetzel/go-stockutil/typeutil"
 )
 
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
 func ValidatorFromMap(in map[string]interface{}) (FieldValidatorFunc, error) {
 	validators := make([]FieldValidatorFunc, 0)
+	names := maputil.StringKeys(in)
+
+	// apply validators in a consistent order
+	sort.Strings(names)
 
-	for name, defn := range in {
-		if validator, err := GetValidator(name, defn); err == nil {
-			validators = append(validators, validator)
+	for _, name := range names {
+		if validator, err := GetValidator(name, in[name]); err == nil {
+			validators = append(validators, namedValidator(name, validator))
 		} else {
 			return nil, fmt.Errorf("Invalid validator configuration %v: %v", name, err)
 		}
@@ -22,32 +39,248 @@ func ValidatorFromMap(in map[string]interface{}) (FieldValidatorFunc, error) {
 	return ValidateAll(validators...), nil
 }
 
-func GetValidator(name string, args interface{}) (FieldValidatorFunc, error) {
-	switch name {
-	case `one-of`:
+// Creates a validator from the arguments given for it in a field's ValidatorConfig.
+type ValidatorFactory func(args interface{}) (FieldValidatorFunc, error)
+
+var registeredValidators = make(map[string]ValidatorFactory)
+var validatorsLock sync.RWMutex
+
+func init() {
+	RegisterValidator(`one-of`, func(args interface{}) (FieldValidatorFunc, error) {
 		if typeutil.IsArray(args) {
 			return ValidateIsOneOf(sliceutil.Sliceify(args)...), nil
 		} else {
 			return nil, fmt.Errorf("Must specify an array of values for validator 'one-of'")
 		}
+	})
+
+	RegisterValidator(`not-zero`, staticValidator(ValidateNonZero))
+	RegisterValidator(`not-empty`, staticValidator(ValidateNotEmpty))
+	RegisterValidator(`positive-integer`, staticValidator(ValidatePositiveInteger))
+	RegisterValidator(`positive-or-zero-integer`, staticValidator(ValidatePositiveOrZeroInteger))
+	RegisterValidator(`email`, staticValidator(ValidateEmail))
+	RegisterValidator(`uuid`, staticValidator(ValidateUUID))
+	RegisterValidator(`cidr`, staticValidator(ValidateCIDR))
+
+	RegisterValidator(`regex`, func(args interface{}) (FieldValidatorFunc, error) {
+		if pattern, err := regexp.Compile(typeutil.V(args).String()); err == nil {
+			return ValidateMatchesPattern(pattern), nil
+		} else {
+			return nil, err
+		}
+	})
 
-	case `not-zero`:
-		return ValidateNonZero, nil
+	RegisterValidator(`expression`, func(args interface{}) (FieldValidatorFunc, error) {
+		return ValidateExpression(typeutil.V(args).String())
+	})
 
-	case `not-empty`:
-		return ValidateNotEmpty, nil
+	RegisterValidator(`min-length`, func(args interface{}) (FieldValidatorFunc, error) {
+		if length, err := stringutil.ConvertToInteger(args); err == nil {
+			return ValidateMinLength(int(length)), nil
+		} else {
+			return nil, fmt.Errorf("Must specify an integer length for validator 'min-length'")
+		}
+	})
 
-	case `positive-integer`:
-		return ValidatePositiveInteger, nil
+	RegisterValidator(`max-length`, func(args interface{}) (FieldValidatorFunc, error) {
+		if length, err := stringutil.ConvertToInteger(args); err == nil {
+			return ValidateMaxLength(int(length)), nil
+		} else {
+			return nil, fmt.Errorf("Must specify an integer length for validator 'max-length'")
+		}
+	})
 
-	case `positive-or-zero-integer`:
-		return ValidatePositiveOrZeroInteger, nil
+	RegisterValidator(`range`, func(args interface{}) (FieldValidatorFunc, error) {
+		if min, max, err := validatorRangeArgs(args); err == nil {
+			bounds := make([]FieldValidatorFunc, 0)
 
-	default:
+			if min != nil {
+				if v, err := stringutil.ConvertToFloat(min); err == nil {
+					bounds = append(bounds, ValidateMinimum(v))
+				} else {
+					return nil, fmt.Errorf("invalid minimum: %v", err)
+				}
+			}
+
+			if max != nil {
+				if v, err := stringutil.ConvertToFloat(max); err == nil {
+					bounds = append(bounds, ValidateMaximum(v))
+				} else {
+					return nil, fmt.Errorf("invalid maximum: %v", err)
+				}
+			}
+
+			return ValidateAll(bounds...), nil
+		} else {
+			return nil, err
+		}
+	})
+
+	RegisterValidator(`url`, func(args interface{}) (FieldValidatorFunc, error) {
+		// "url: true" allows any scheme, otherwise a list of allowed schemes may be given
+		if _, ok := args.(bool); ok {
+			return ValidateURL(), nil
+		}
+
+		return ValidateURL(sliceutil.Stringify(sliceutil.Sliceify(args))...), nil
+	})
+
+	RegisterValidator(`ip`, func(args interface{}) (FieldValidatorFunc, error) {
+		switch v := typeutil.V(args).String(); v {
+		case `v4`, `4`:
+			return ValidateIP(4), nil
+		case `v6`, `6`:
+			return ValidateIP(6), nil
+		case `true`, `any`, ``:
+			return ValidateIP(0), nil
+		default:
+			return nil, fmt.Errorf("Unknown IP version %q", v)
+		}
+	})
+
+	RegisterValidator(`time-range`, func(args interface{}) (FieldValidatorFunc, error) {
+		if after, before, err := validatorRangeArgs(args); err == nil {
+			var afterFn, beforeFn func() time.Time
+
+			if after != nil {
+				if fn, err := validatorTimeBound(after); err == nil {
+					afterFn = fn
+				} else {
+					return nil, fmt.Errorf("invalid lower bound: %v", err)
+				}
+			}
+
+			if before != nil {
+				if fn, err := validatorTimeBound(before); err == nil {
+					beforeFn = fn
+				} else {
+					return nil, fmt.Errorf("invalid upper bound: %v", err)
+				}
+			}
+
+			// relative bounds (e.g.: "now", "-24h") are evaluated each time a value is validated
+			return func(value interface{}) error {
+				var a, b time.Time
+
+				if afterFn != nil {
+					a = afterFn()
+				}
+
+				if beforeFn != nil {
+					b = beforeFn()
+				}
+
+				return ValidateTimeRange(a, b)(value)
+			}, nil
+		} else {
+			return nil, err
+		}
+	})
+
+	RegisterValidator(`each`, func(args interface{}) (FieldValidatorFunc, error) {
+		if config, ok := args.(map[string]interface{}); ok {
+			if validator, err := ValidatorFromMap(config); err == nil {
+				return ValidateEach(validator), nil
+			} else {
+				return nil, err
+			}
+		} else {
+			return nil, fmt.Errorf("Must specify a map of validators for validator 'each'")
+		}
+	})
+}
+
+// Registers a validator that can be referenced by name in a field's ValidatorConfig (and so from
+// schema files).  Registering a name that already exists replaces the existing validator.
+func RegisterValidator(name string, factory ValidatorFactory) {
+	validatorsLock.Lock()
+	defer validatorsLock.Unlock()
+
+	registeredValidators[name] = factory
+}
+
+// Returns the names of all registered validators.
+func ValidatorNames() []string {
+	validatorsLock.RLock()
+	defer validatorsLock.RUnlock()
+
+	names := maputil.StringKeys(registeredValidators)
+	sort.Strings(names)
+
+	return names
+}
+
+func GetValidator(name string, args interface{}) (FieldValidatorFunc, error) {
+	validatorsLock.RLock()
+	factory, ok := registeredValidators[name]
+	validatorsLock.RUnlock()
+
+	if ok {
+		return factory(args)
+	} else {
 		return nil, fmt.Errorf("Unknown validator %q", name)
 	}
 }
 
+// returns a factory for validators that take no arguments
+func staticValidator(validator FieldValidatorFunc) ValidatorFactory {
+	return func(_ interface{}) (FieldValidatorFunc, error) {
+		return validator, nil
+	}
+}
+
+// ranges may be given as a two-element array or as a map with "min" and "max" keys
+func validatorRangeArgs(args interface{}) (interface{}, interface{}, error) {
+	if typeutil.IsMap(args) {
+		m := maputil.M(args)
+		return m.Get(`min`).Value, m.Get(`max`).Value, nil
+	} else if typeutil.IsArray(args) {
+		if bounds := sliceutil.Sliceify(args); len(bounds) == 2 {
+			return bounds[0], bounds[1], nil
+		}
+	}
+
+	return nil, nil, fmt.Errorf("Must specify a [min, max] array or a map with min and/or max keys")
+}
+
+// time bounds may be absolute times, "now", or durations relative to now (e.g.: "-24h")
+func validatorTimeBound(bound interface{}) (func() time.Time, error) {
+	switch v := typeutil.V(bound).String(); v {
+	case `now`:
+		return time.Now, nil
+	default:
+		if offset, err := time.ParseDuration(v); err == nil {
+			return func() time.Time {
+				return time.Now().Add(offset)
+			}, nil
+		} else if t, err := stringutil.ConvertToTime(bound); err == nil {
+			return func() time.Time {
+				return t
+			}, nil
+		} else {
+			return nil, err
+		}
+	}
+}
+
+// wraps errors returned by the given validator so that they identify the rule that failed
+func namedValidator(name string, validator FieldValidatorFunc) FieldValidatorFunc {
+	return func(value interface{}) error {
+		if err := validator(value); err != nil {
+			if _, ok := err.(*FieldError); ok {
+				return err
+			}
+
+			return &FieldError{
+				Rule:    name,
+				Message: err.Error(),
+			}
+		}
+
+		return nil
+	}
+}
+
 func ValidateAll(validators ...FieldValidatorFunc) FieldValidatorFunc {
 	return func(value interface{}) error {
 		for _, validator := range validators {
@@ -111,3 +344,248 @@ func ValidatePositiveOrZeroInteger(value interface{}) error {
 
 	return nil
 }
+
+func ValidateMatchesPattern(pattern *regexp.Regexp) FieldValidatorFunc {
+	return func(value interface{}) error {
+		if value == nil {
+			return nil
+		}
+
+		if !pattern.MatchString(fmt.Sprintf("%v", value)) {
+			return fmt.Errorf("value must match the pattern %v", pattern)
+		}
+
+		return nil
+	}
+}
+
+// Validates that strings (measured in characters), arrays, and maps are at least the given length.
+func ValidateMinLength(length int) FieldValidatorFunc {
+	return func(value interface{}) error {
+		if value == nil {
+			return nil
+		}
+
+		if l, err := validatorLength(value); err == nil {
+			if l < length {
+				return fmt.Errorf("expected length >= %d, got: %d", length, l)
+			}
+		} else {
+			return err
+		}
+
+		return nil
+	}
+}
+
+// Validates that strings (measured in characters), arrays, and maps are at most the given length.
+func ValidateMaxLength(length int) FieldValidatorFunc {
+	return func(value interface{}) error {
+		if value == nil {
+			return nil
+		}
+
+		if l, err := validatorLength(value); err == nil {
+			if l > length {
+				return fmt.Errorf("expected length <= %d, got: %d", length, l)
+			}
+		} else {
+			return err
+		}
+
+		return nil
+	}
+}
+
+func ValidateMinimum(min float64) FieldValidatorFunc {
+	return func(value interface{}) error {
+		if value == nil {
+			return nil
+		}
+
+		if v, err := stringutil.ConvertToFloat(value); err == nil {
+			if v < min {
+				return fmt.Errorf("expected value >= %v, got: %v", min, v)
+			}
+		} else {
+			return fmt.Errorf("expected a number, got: %v", value)
+		}
+
+		return nil
+	}
+}
+
+func ValidateMaximum(max float64) FieldValidatorFunc {
+	return func(value interface{}) error {
+		if value == nil {
+			return nil
+		}
+
+		if v, err := stringutil.ConvertToFloat(value); err == nil {
+			if v > max {
+				return fmt.Errorf("expected value <= %v, got: %v", max, v)
+			}
+		} else {
+			return fmt.Errorf("expected a number, got: %v", value)
+		}
+
+		return nil
+	}
+}
+
+func ValidateEmail(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	v := fmt.Sprintf("%v", value)
+
+	// ParseAddress also accepts display names (e.g.: "Bob <bob@example.com>"); only allow bare addresses
+	if address, err := mail.ParseAddress(v); err != nil || address.Address != v {
+		return fmt.Errorf("expected an email address, got: %v", value)
+	}
+
+	return nil
+}
+
+// Validates that values are absolute URLs, optionally restricted to the given schemes.
+func ValidateURL(schemes ...string) FieldValidatorFunc {
+	return func(value interface{}) error {
+		if value == nil {
+			return nil
+		}
+
+		if u, err := url.Parse(fmt.Sprintf("%v", value)); err == nil && u.Scheme != `` && (u.Host != `` || u.Opaque != ``) {
+			if len(schemes) > 0 && !sliceutil.ContainsString(schemes, u.Scheme) {
+				return fmt.Errorf("expected a URL with scheme %s, got: %v", strings.Join(schemes, ` or `), u.Scheme)
+			}
+		} else {
+			return fmt.Errorf("expected an absolute URL, got: %v", value)
+		}
+
+		return nil
+	}
+}
+
+func ValidateUUID(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	if !uuidPattern.MatchString(fmt.Sprintf("%v", value)) {
+		return fmt.Errorf("expected a UUID, got: %v", value)
+	}
+
+	return nil
+}
+
+// Validates that values are IP addresses of the given version (4 or 6), or either if version is 0.
+func ValidateIP(version int) FieldValidatorFunc {
+	return func(value interface{}) error {
+		if value == nil {
+			return nil
+		}
+
+		ip := net.ParseIP(fmt.Sprintf("%v", value))
+
+		switch {
+		case ip == nil:
+			return fmt.Errorf("expected an IP address, got: %v", value)
+		case version == 4 && ip.To4() == nil:
+			return fmt.Errorf("expected an IPv4 address, got: %v", value)
+		case version == 6 && ip.To4() != nil:
+			return fmt.Errorf("expected an IPv6 address, got: %v", value)
+		}
+
+		return nil
+	}
+}
+
+func ValidateCIDR(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	if _, _, err := net.ParseCIDR(fmt.Sprintf("%v", value)); err != nil {
+		return fmt.Errorf("expected a CIDR network address, got: %v", value)
+	}
+
+	return nil
+}
+
+// Validates that time values fall within the given bounds (inclusive).  A zero time leaves that
+// side of the range unbounded.
+func ValidateTimeRange(after time.Time, before time.Time) FieldValidatorFunc {
+	return func(value interface{}) error {
+		if value == nil {
+			return nil
+		}
+
+		var t time.Time
+
+		switch value.(type) {
+		case time.Time:
+			t = value.(time.Time)
+		case *time.Time:
+			t = *(value.(*time.Time))
+		default:
+			if v, err := stringutil.ConvertToTime(value); err == nil {
+				t = v
+			} else {
+				return fmt.Errorf("expected a time, got: %v", value)
+			}
+		}
+
+		if !after.IsZero() && t.Before(after) {
+			return fmt.Errorf("expected a time at or after %v, got: %v", after.Format(time.RFC3339), t.Format(time.RFC3339))
+		}
+
+		if !before.IsZero() && t.After(before) {
+			return fmt.Errorf("expected a time at or before %v, got: %v", before.Format(time.RFC3339), t.Format(time.RFC3339))
+		}
+
+		return nil
+	}
+}
+
+// Applies the given validator to each element of an array (or each value of a map).
+func ValidateEach(validator FieldValidatorFunc) FieldValidatorFunc {
+	return func(value interface{}) error {
+		if value == nil {
+			return nil
+		}
+
+		if typeutil.IsMap(value) {
+			m := reflect.ValueOf(value)
+
+			for _, key := range m.MapKeys() {
+				if err := validator(m.MapIndex(key).Interface()); err != nil {
+					return fmt.Errorf("element %v: %v", key.Interface(), err)
+				}
+			}
+
+			return nil
+		}
+
+		return sliceutil.Each(value, func(i int, v interface{}) error {
+			if err := validator(v); err != nil {
+				return fmt.Errorf("element %d: %v", i, err)
+			}
+
+			return nil
+		})
+	}
+}
+
+func validatorLength(value interface{}) (int, error) {
+	switch value.(type) {
+	case string:
+		return utf8.RuneCountInString(value.(string)), nil
+	default:
+		if l := typeutil.Len(value); l >= 0 {
+			return l, nil
+		}
+
+		return 0, fmt.Errorf("expected a value with a length, got: %T", value)
+	}
+}
@@ -0,0 +1,165 @@
+package dal
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatorsFromConfig(t *testing.T) {
+	assert := require.New(t)
+
+	for config, values := range map[string]map[interface{}]bool{
+		`{"regex": "^[a-z]+$"}`: {
+			`abc`: true,
+			`ABC`: false,
+		},
+		`{"min-length": 2, "max-length": 4}`: {
+			`a`:     false,
+			`ab`:    true,
+			`日本語`:   true,
+			`abcde`: false,
+			nil:     true,
+			42:      false,
+		},
+		`{"range": [1, 10]}`: {
+			1:      true,
+			10:     true,
+			0:      false,
+			10.5:   false,
+			`five`: false,
+		},
+		`{"range": {"min": 0.5}}`: {
+			0.5:     true,
+			1000000: true,
+			0.1:     false,
+		},
+		`{"email": true}`: {
+			`bob@example.com`:       true,
+			`Bob <bob@example.com>`: false,
+			`bob`:                   false,
+		},
+		`{"url": ["https"]}`: {
+			`https://example.com/path`: true,
+			`http://example.com/path`:  false,
+			`/path`:                    false,
+		},
+		`{"url": true}`: {
+			`ftp://example.com`: true,
+			`example.com`:       false,
+		},
+		`{"uuid": true}`: {
+			`0b0fd4f6-3b1c-4b6c-8e8a-6a4e0e7e1d2f`: true,
+			`0b0fd4f63b1c4b6c8e8a6a4e0e7e1d2f`:     false,
+		},
+		`{"ip": "v4"}`: {
+			`10.0.0.1`: true,
+			`::1`:      false,
+			`10.0.0`:   false,
+		},
+		`{"ip": "v6"}`: {
+			`::1`:      true,
+			`10.0.0.1`: false,
+		},
+		`{"cidr": true}`: {
+			`10.0.0.0/8`: true,
+			`10.0.0.1`:   false,
+		},
+		`{"time-range": {"min": "2018-01-01T00:00:00Z", "max": "now"}}`: {
+			`2018-06-01T00:00:00Z`:    true,
+			`2017-12-31T23:59:59Z`:    false,
+			time.Now().Add(time.Hour): false,
+		},
+		`{"each": {"one-of": ["red", "green"]}}`: {
+			`red`:  true,
+			`blue`: false,
+		},
+	} {
+		var validatorConfig map[string]interface{}
+
+		assert.NoError(json.Unmarshal([]byte(config), &validatorConfig))
+
+		validator, err := ValidatorFromMap(validatorConfig)
+		assert.NoError(err, config)
+
+		for value, ok := range values {
+			if ok {
+				assert.NoError(validator(value), fmt.Sprintf("%s: %v", config, value))
+			} else {
+				assert.Error(validator(value), fmt.Sprintf("%s: %v", config, value))
+			}
+		}
+	}
+
+	_, err := ValidatorFromMap(map[string]interface{}{
+		`nonexistent`: true,
+	})
+
+	assert.Error(err)
+}
+
+func TestValidateEach(t *testing.T) {
+	assert := require.New(t)
+
+	validator, err := ValidatorFromMap(map[string]interface{}{
+		`each`: map[string]interface{}{
+			`positive-integer`: true,
+		},
+	})
+
+	assert.NoError(err)
+	assert.NoError(validator([]int{1, 2, 3}))
+	assert.NoError(validator(map[string]interface{}{`a`: 1}))
+
+	err = validator([]interface{}{1, -2, 3})
+	assert.Error(err)
+	assert.Equal(`each`, err.(*FieldError).Rule)
+	assert.Contains(err.Error(), `element 1`)
+}
+
+func TestRegisterValidator(t *testing.T) {
+	assert := require.New(t)
+
+	RegisterValidator(`divisible-by`, func(args interface{}) (FieldValidatorFunc, error) {
+		if divisor, ok := args.(float64); ok && divisor != 0 {
+			return func(value interface{}) error {
+				if v, ok := value.(int); ok && v%int(divisor) == 0 {
+					return nil
+				}
+
+				return fmt.Errorf("value must be divisible by %v", divisor)
+			}, nil
+		}
+
+		return nil, fmt.Errorf("invalid divisor")
+	})
+
+	assert.Contains(ValidatorNames(), `divisible-by`)
+
+	var field Field
+
+	assert.NoError(json.Unmarshal([]byte(`{
+		"name": "count",
+		"type": "int",
+		"validators": {
+			"divisible-by": 3
+		}
+	}`), &field))
+
+	assert.NoError(field.Validate(9))
+
+	err := field.Validate(10)
+	assert.Error(err)
+	assert.Equal(`count`, err.(*FieldError).Field)
+	assert.Equal(`divisible-by`, err.(*FieldError).Rule)
+
+	assert.Error(json.Unmarshal([]byte(`{
+		"name": "count",
+		"validators": {
+			"divisible-by": 0
+		}
+	}`), &field))
+}
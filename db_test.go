@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis"
 	"github.com/ghetzel/go-stockutil/stringutil"
 	"github.com/ghetzel/go-stockutil/typeutil"
 	"github.com/sniperkit/pivot/backends"
@@ -64,6 +66,106 @@ func setupTestSqliteWithAdditionalBleveIndexer(run func()) {
 	}
 }
 
+func setupTestMemory(run func()) {
+	if b, err := makeBackend(`memory://`); err == nil {
+		backend = b
+		run()
+	} else {
+		fmt.Fprintf(os.Stderr, "Failed to create backend: %v\n", err)
+	}
+}
+
+func setupTestBolt(run func()) {
+	if root, err := ioutil.TempDir(``, `pivot-backend-bolt-`); err == nil {
+		defer os.RemoveAll(root)
+
+		if b, err := makeBackend(fmt.Sprintf("bolt://%s/test.db", root)); err == nil {
+			backend = b
+			run()
+			b.(*backends.BoltBackend).Close()
+		} else {
+			fmt.Fprintf(os.Stderr, "Failed to create backend: %v\n", err)
+		}
+	} else {
+		panic(err.Error())
+	}
+}
+
+func setupTestRedis(run func()) {
+	if server, err := miniredis.Run(); err == nil {
+		defer server.Close()
+
+		if b, err := makeBackend(fmt.Sprintf("redis://%s/0", server.Addr())); err == nil {
+			backend = b
+			run()
+		} else {
+			fmt.Fprintf(os.Stderr, "Failed to create backend: %v\n", err)
+		}
+	} else {
+		panic(err.Error())
+	}
+}
+
+func setupTestTabular(format string, run func()) {
+	if root, err := ioutil.TempDir(``, `pivot-backend-tabular-`); err == nil {
+		defer os.RemoveAll(root)
+
+		if b, err := makeBackend(fmt.Sprintf("tabular+%s://%s", format, root)); err == nil {
+			backend = b
+			run()
+		} else {
+			fmt.Fprintf(os.Stderr, "Failed to create backend: %v\n", err)
+		}
+	} else {
+		panic(err.Error())
+	}
+}
+
+func setupTestRemote(run func()) {
+	os.RemoveAll(`./tmp/db_test`)
+	os.MkdirAll(`./tmp/db_test`, 0755)
+
+	if server, err := newTestRemoteServer(`sqlite:///./tmp/db_test/test.db`); err == nil {
+		defer server.Close()
+
+		if b, err := makeBackend(`pivot+` + server.URL); err == nil {
+			backend = b
+			run()
+		} else {
+			fmt.Fprintf(os.Stderr, "Failed to create backend: %v\n", err)
+		}
+	} else {
+		panic(err.Error())
+	}
+}
+
+// the plugin is this test binary, which serves a memory backend when run by the script written here
+func setupTestPlugin(run func()) {
+	if root, err := ioutil.TempDir(``, `pivot-backend-plugin-`); err == nil {
+		defer os.RemoveAll(root)
+
+		if err := ioutil.WriteFile(
+			filepath.Join(root, backends.PluginExecutablePrefix+`test`),
+			[]byte(fmt.Sprintf("#!/bin/sh\nPIVOT_TEST_PLUGIN=1 exec %q\n", os.Args[0])),
+			0755,
+		); err != nil {
+			panic(err.Error())
+		}
+
+		backends.PluginDirectories = []string{root}
+
+		if b, err := makeBackend(`plugin://test`); err == nil {
+			backend = b
+			run()
+			b.(*backends.PluginBackend).Close()
+		} else {
+			fmt.Fprintf(os.Stderr, "Failed to create backend: %v\n", err)
+		}
+	} else {
+		panic(err.Error())
+	}
+}
+
 func setupTestMysql(run func()) {
 	if b, err := makeBackend(`mysql://test:test@db/test`); err == nil {
 		backend = b
@@ -158,6 +260,16 @@ func setupTestMongo(run func()) {
 func TestMain(m *testing.M) {
 	var i int
 
+	// serve as the plugin for setupTestPlugin
+	if os.Getenv(`PIVOT_TEST_PLUGIN`) != `` {
+		if err := backends.ServePlugin(backends.NewMemoryBackend); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		os.Exit(0)
+	}
+
 	run := func() {
 		i = m.Run()
 
@@ -166,6 +278,9 @@ func TestMain(m *testing.M) {
 		}
 	}
 
+	// the memory backend has no external dependencies, so it is always tested
+	setupTestMemory(run)
+
 	if typeutil.V(os.Getenv(`INTEGRATION`)).Bool() {
 		setupTestMysql(run)
 		setupTestTiedot(run)
@@ -176,6 +291,12 @@ func TestMain(m *testing.M) {
 		setupTestFilesystemDefault(run)
 		setupTestFilesystemYaml(run)
 		setupTestFilesystemJson(run)
+		setupTestBolt(run)
+		setupTestRedis(run)
+		setupTestTabular(`csv`, run)
+		setupTestTabular(`ndjson`, run)
+		setupTestRemote(run)
+		setupTestPlugin(run)
 	}
 }
 
@@ -865,6 +986,274 @@ func TestObjectType(t *testing.T) {
 	assert.EqualValues(1, record.GetNested(`properties.count`))
 }
 
+func TestExtendedTypes(t *testing.T) {
+	assert := require.New(t)
+
+	err := backend.CreateCollection(
+		dal.NewCollection(`TestExtendedTypes`).
+			AddFields(dal.Field{
+				Name:    `tags`,
+				Type:    dal.ArrayType,
+				Subtype: dal.StringType,
+			}, dal.Field{
+				Name:   `status`,
+				Type:   dal.EnumType,
+				Values: []string{`draft`, `published`},
+			}, dal.Field{
+				Name:      `price`,
+				Type:      dal.DecimalType,
+				Precision: 2,
+			}, dal.Field{
+				Name: `ref`,
+				Type: dal.UUIDType,
+			}, dal.Field{
+				Name: `data`,
+				Type: dal.BytesType,
+			}))
+
+	defer func() {
+		assert.Nil(backend.DeleteCollection(`TestExtendedTypes`))
+	}()
+
+	assert.Nil(err)
+
+	assert.Nil(backend.Insert(`TestExtendedTypes`, dal.NewRecordSet(
+		dal.NewRecord(testCrudIdSet[0]).SetFields(map[string]interface{}{
+			`tags`:   []string{`a`, `b`},
+			`status`: `published`,
+			`price`:  `1234567890123.455`,
+			`ref`:    `1B4E28BA-2FA1-11D2-883F-0016D3CCA427`,
+			`data`:   TestData,
+		}),
+	)))
+
+	record, err := backend.Retrieve(`TestExtendedTypes`, testCrudIdSet[0])
+	assert.NoError(err)
+	assert.Equal([]interface{}{`a`, `b`}, record.Get(`tags`))
+	assert.Equal(`published`, record.Get(`status`))
+	assert.Equal(`1234567890123.46`, record.Get(`price`))
+	assert.Equal(`1b4e28ba-2fa1-11d2-883f-0016d3cca427`, record.Get(`ref`))
+	assert.Equal(TestData, record.Get(`data`))
+
+	// strings given for bytes fields are stored as they are, including ones that look like base64
+	assert.Nil(backend.Update(`TestExtendedTypes`, dal.NewRecordSet(
+		dal.NewRecord(testCrudIdSet[0]).Set(`data`, `test`),
+	)))
+
+	record, err = backend.Retrieve(`TestExtendedTypes`, testCrudIdSet[0])
+	assert.NoError(err)
+	assert.Equal([]byte(`test`), record.Get(`data`))
+
+	err = backend.Insert(`TestExtendedTypes`, dal.NewRecordSet(
+		dal.NewRecord(testCrudIdSet[1]).Set(`status`, `deleted`),
+	))
+
+	assert.True(dal.IsValidationErr(err))
+}
+
+func TestPartialUpdate(t *testing.T) {
+	assert := require.New(t)
+
+	// filesystem updates replace the whole record
+	if _, ok := backend.(*backends.FilesystemBackend); ok {
+		t.Skip(`partial updates are not supported by the filesystem backend`)
+	}
+
+	collection := dal.NewCollection(`TestPartialUpdate`).
+		AddFields(dal.Field{
+			Name:     `name`,
+			Type:     dal.StringType,
+			Required: true,
+		}, dal.Field{
+			Name: `price`,
+			Type: dal.IntType,
+		}, dal.Field{
+			Name: `qty`,
+			Type: dal.IntType,
+		}, dal.Field{
+			Name: `start`,
+			Type: dal.IntType,
+		}, dal.Field{
+			Name: `end`,
+			Type: dal.IntType,
+		}, dal.Field{
+			Name:       `total`,
+			Type:       dal.IntType,
+			Expression: `price * qty`,
+		})
+
+	collection.Rules = []string{`end > start`}
+
+	err := backend.CreateCollection(collection)
+
+	defer func() {
+		assert.Nil(backend.DeleteCollection(`TestPartialUpdate`))
+	}()
+
+	assert.Nil(err)
+
+	assert.Nil(backend.Insert(`TestPartialUpdate`, dal.NewRecordSet(
+		dal.NewRecord(testCrudIdSet[0]).SetFields(map[string]interface{}{
+			`name`:  `first`,
+			`price`: 5,
+			`qty`:   2,
+			`start`: 1,
+			`end`:   2,
+		}),
+	)))
+
+	// fields that are not being updated are left as they are, and the computed field and the rule
+	// are evaluated against the stored record with the update merged into it
+	assert.Nil(backend.Update(`TestPartialUpdate`, dal.NewRecordSet(
+		dal.NewRecord(testCrudIdSet[0]).Set(`end`, 3),
+	)))
+
+	record, err := backend.Retrieve(`TestPartialUpdate`, testCrudIdSet[0])
+	assert.NoError(err)
+	assert.Equal(`first`, record.Get(`name`))
+	assert.EqualValues(10, record.Get(`total`))
+	assert.EqualValues(3, record.Get(`end`))
+
+	// computed fields are recalculated when the fields they refer to are updated
+	assert.Nil(backend.Update(`TestPartialUpdate`, dal.NewRecordSet(
+		dal.NewRecord(testCrudIdSet[0]).Set(`price`, 4).Set(`qty`, 4),
+	)))
+
+	record, err = backend.Retrieve(`TestPartialUpdate`, testCrudIdSet[0])
+	assert.NoError(err)
+	assert.Equal(`first`, record.Get(`name`))
+	assert.EqualValues(16, record.Get(`total`))
+
+	// ...including when only some of the fields they refer to are updated
+	assert.Nil(backend.Update(`TestPartialUpdate`, dal.NewRecordSet(
+		dal.NewRecord(testCrudIdSet[0]).Set(`qty`, 5),
+	)))
+
+	record, err = backend.Retrieve(`TestPartialUpdate`, testCrudIdSet[0])
+	assert.NoError(err)
+	assert.EqualValues(20, record.Get(`total`))
+
+	err = backend.Update(`TestPartialUpdate`, dal.NewRecordSet(
+		dal.NewRecord(testCrudIdSet[0]).Set(`end`, 0),
+	))
+
+	assert.True(dal.IsValidationErr(err))
+
+	record, err = backend.Retrieve(`TestPartialUpdate`, testCrudIdSet[0])
+	assert.NoError(err)
+	assert.EqualValues(3, record.Get(`end`))
+
+	// required fields cannot be cleared
+	err = backend.Update(`TestPartialUpdate`, dal.NewRecordSet(
+		dal.NewRecord(testCrudIdSet[0]).Set(`name`, nil),
+	))
+
+	assert.True(dal.IsValidationErr(err))
+}
+
+func TestHashedFieldRoundTrip(t *testing.T) {
+	assert := require.New(t)
+
+	hash, err := dal.Hash(`sha256`, nil)
+	assert.NoError(err)
+
+	err = backend.CreateCollection(
+		dal.NewCollection(`TestHashedFieldRoundTrip`).
+			AddFields(dal.Field{
+				Name:      `password`,
+				Type:      dal.StringType,
+				Formatter: hash,
+				FormatterConfig: map[string]interface{}{
+					`hash`: `sha256`,
+				},
+			}, dal.Field{
+				Name: `name`,
+				Type: dal.StringType,
+			}))
+
+	defer func() {
+		assert.Nil(backend.DeleteCollection(`TestHashedFieldRoundTrip`))
+	}()
+
+	assert.Nil(err)
+
+	assert.Nil(backend.Insert(`TestHashedFieldRoundTrip`, dal.NewRecordSet(
+		dal.NewRecord(testCrudIdSet[0]).Set(`password`, `secret`).Set(`name`, `first`),
+	)))
+
+	record, err := backend.Retrieve(`TestHashedFieldRoundTrip`, testCrudIdSet[0])
+	assert.NoError(err)
+	assert.Equal(`2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b`, record.Get(`password`))
+
+	// saving the retrieved record again keeps the stored hash
+	record.Set(`name`, `second`)
+	assert.Nil(backend.Update(`TestHashedFieldRoundTrip`, dal.NewRecordSet(record)))
+
+	record, err = backend.Retrieve(`TestHashedFieldRoundTrip`, testCrudIdSet[0])
+	assert.NoError(err)
+	assert.Equal(`second`, record.Get(`name`))
+	assert.Equal(`2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b`, record.Get(`password`))
+
+	// changed values are always hashed, even if they look like a hash already
+	assert.Nil(backend.Update(`TestHashedFieldRoundTrip`, dal.NewRecordSet(
+		dal.NewRecord(testCrudIdSet[0]).Set(`password`, `e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855`),
+	)))
+
+	record, err = backend.Retrieve(`TestHashedFieldRoundTrip`, testCrudIdSet[0])
+	assert.NoError(err)
+	assert.Equal(`cd372fb85148700fa88095e3492d3f9f5beb43e555e5ff26d95f5a6adc36f8e6`, record.Get(`password`))
+}
+
+func TestCurrentTimeOnSave(t *testing.T) {
+	assert := require.New(t)
+
+	err := backend.CreateCollection(
+		dal.NewCollection(`TestCurrentTimeOnSave`).
+			AddFields(dal.Field{
+				Name:      `updated_at`,
+				Type:      dal.TimeType,
+				Formatter: dal.CurrentTime,
+				FormatterConfig: map[string]interface{}{
+					`current-time`: true,
+				},
+			}, dal.Field{
+				Name: `name`,
+				Type: dal.StringType,
+			}))
+
+	defer func() {
+		assert.Nil(backend.DeleteCollection(`TestCurrentTimeOnSave`))
+	}()
+
+	assert.Nil(err)
+
+	assert.Nil(backend.Insert(`TestCurrentTimeOnSave`, dal.NewRecordSet(
+		dal.NewRecord(testCrudIdSet[0]).Set(`name`, `first`).Set(`updated_at`, time.Time{}),
+	)))
+
+	record, err := backend.Retrieve(`TestCurrentTimeOnSave`, testCrudIdSet[0])
+	assert.NoError(err)
+
+	first, ok := record.Get(`updated_at`).(time.Time)
+	assert.True(ok)
+	assert.False(first.IsZero())
+
+	// saving the retrieved record again sets a new timestamp, even though the value read back is
+	// unchanged (some backends only store whole seconds)
+	time.Sleep(1100 * time.Millisecond)
+
+	record.Set(`name`, `second`)
+	assert.Nil(backend.Update(`TestCurrentTimeOnSave`, dal.NewRecordSet(record)))
+
+	record, err = backend.Retrieve(`TestCurrentTimeOnSave`, testCrudIdSet[0])
+	assert.NoError(err)
+	assert.Equal(`second`, record.Get(`name`))
+
+	second, ok := record.Get(`updated_at`).(time.Time)
+	assert.True(ok)
+	assert.True(second.After(first))
+}
+
 func TestAggregators(t *testing.T) {
 	assert := require.New(t)
 	collection := dal.NewCollection(`TestAggregators`).
@@ -24,6 +24,7 @@ var QueryUnescapeValues = false
 var AllValue = `all`
 var SortAscending = `+`
 var SortDescending = `-`
+var HavingPrefix = `@`
 var DefaultIdentityField = `id`
 var rxCharFilter = regexp.MustCompile(`[\W\s\_]+`)
 
@@ -60,11 +61,101 @@ const (
 	Count
 )
 
+func ParseAggregation(in string) (Aggregation, error) {
+	switch strings.ToLower(in) {
+	case `first`:
+		return First, nil
+	case `last`:
+		return Last, nil
+	case `min`, `minimum`:
+		return Minimum, nil
+	case `max`, `maximum`:
+		return Maximum, nil
+	case `sum`:
+		return Sum, nil
+	case `avg`, `average`:
+		return Average, nil
+	case `count`:
+		return Count, nil
+	default:
+		return First, fmt.Errorf("Unsupported aggregation '%s'", in)
+	}
+}
+
+func (self Aggregation) String() string {
+	switch self {
+	case First:
+		return `first`
+	case Last:
+		return `last`
+	case Minimum:
+		return `min`
+	case Maximum:
+		return `max`
+	case Sum:
+		return `sum`
+	case Average:
+		return `avg`
+	case Count:
+		return `count`
+	default:
+		return ``
+	}
+}
+
 type Aggregate struct {
 	Aggregation Aggregation
 	Field       string
 }
 
+// Returns the name that identifies this aggregate in aggregate criteria and sort fields, which
+// distinguishes between several aggregates being performed on the same field (e.g.: "sum:amount").
+func (self Aggregate) Key() string {
+	return self.Aggregation.String() + ModifierDelimiter + self.Field
+}
+
+// Returns the aggregate that the given name refers to, which is either the key of one of the given
+// aggregates (e.g.: "sum:amount") or the name of an aggregated field, which refers to the first
+// aggregate performed on that field.
+func FindAggregate(aggregates []Aggregate, name string) (Aggregate, bool) {
+	for _, aggregate := range aggregates {
+		if aggregate.Key() == name {
+			return aggregate, true
+		}
+	}
+
+	for _, aggregate := range aggregates {
+		if aggregate.Field == name {
+			return aggregate, true
+		}
+	}
+
+	return Aggregate{}, false
+}
+
+// Returns the name of the field that the value of the given aggregate is returned as in grouped
+// results.  This is the name of the aggregated field, unless more than one aggregate is being
+// performed on that field, in which case it is the aggregate's key.
+func AggregateResultName(aggregates []Aggregate, aggregate Aggregate) string {
+	for _, other := range aggregates {
+		if other.Field == aggregate.Field && other.Aggregation != aggregate.Aggregation {
+			return aggregate.Key()
+		}
+	}
+
+	return aggregate.Field
+}
+
+// Returns the name of the field in grouped results that the given name refers to: either an
+// aggregate (see FindAggregate), or a field being grouped by.
+func ResultFieldName(aggregates []Aggregate, name string) string {
+	if aggregate, ok := FindAggregate(aggregates, name); ok {
+		return AggregateResultName(aggregates, aggregate)
+	}
+
+	return name
+}
+
 func (self *Criterion) String() string {
 	rv := ``
 
@@ -105,6 +196,7 @@ type Filter struct {
 	Offset        int
 	Limit         int
 	Criteria      []Criterion
+	Having        []Criterion
 	Sort          []string
 	Fields        []string
 	Options       map[string]interface{}
@@ -116,6 +208,7 @@ type Filter struct {
 func New() *Filter {
 	return &Filter{
 		Criteria:      make([]Criterion, 0),
+		Having:        make([]Criterion, 0),
 		Sort:          make([]string, 0),
 		Fields:        make([]string, 0),
 		Options:       make(map[string]interface{}),
@@ -131,6 +224,7 @@ func MakeFilter(specs ...string) Filter {
 	f := Filter{
 		Spec:          spec,
 		Criteria:      make([]Criterion, 0),
+		Having:        make([]Criterion, 0),
 		Sort:          make([]string, 0),
 		Fields:        make([]string, 0),
 		Options:       make(map[string]interface{}),
@@ -189,13 +283,19 @@ func All() *Filter {
 //
 // filter     ::= ([sort]field/value | [sort]type:field/value | [sort]type:field/comparator:value)+
 // sort       ::= ASCII plus (+), minus (-)
-// field      ::= ? US-ASCII field name ?;
+// field      ::= [having]? US-ASCII field name ?;
+// having     ::= ASCII at sign (@)
 // value      ::= ? UTF-8 field value ?;
 // type       ::= str | bool | int | float | date
 // comparator :=  is | not | gt | gte | lt | lte | prefix | suffix | regex
 //
+// Fields prefixed with the having prefix are added as Having criteria (see AddHaving).  The field of
+// a having criterion may refer to an aggregate by its key, so everything following the prefix is the
+// field name (e.g.: "@sum:amount/gt:1000"); to give the criterion a type, the type comes before the
+// prefix (e.g.: "int:@sum:amount/gt:1000").
 func Parse(spec string) (*Filter, error) {
 	var criterion Criterion
+	var having bool
 
 	spec = strings.TrimPrefix(spec, `/`)
 
@@ -239,7 +339,21 @@ func Parse(spec string) (*Filter, error) {
 				token = strings.TrimPrefix(token, SortDescending)
 				token = strings.TrimPrefix(token, SortAscending)
 
-				fType, fName := SplitModifierToken(token)
+				var fType, fName string
+
+				// the fields of having criteria may contain the modifier delimiter themselves
+				if strings.HasPrefix(token, HavingPrefix) {
+					fName = token
+				} else {
+					fType, fName = SplitModifierToken(token)
+				}
+
+				having = false
+
+				if strings.HasPrefix(fName, HavingPrefix) {
+					having = true
+					fName = strings.TrimPrefix(fName, HavingPrefix)
+				}
 
 				if fType == `` {
 					criterion = Criterion{
@@ -317,7 +431,11 @@ func Parse(spec string) (*Filter, error) {
 					}
 				}
 
-				rv.Criteria = append(rv.Criteria, criterion)
+				if having {
+					rv.Having = append(rv.Having, criterion)
+				} else {
+					rv.Criteria = append(rv.Criteria, criterion)
+				}
 			}
 		}
 	default:
@@ -340,6 +458,39 @@ func (self *Filter) AddCriteria(criteria ...Criterion) *Filter {
 	return self
 }
 
+// Adds criteria that are tested against the results of an aggregation (i.e.: after grouping), rather
+// than against the input rows.  The Field of each criterion refers to a field being grouped by or to
+// one of the aggregates being performed, either by its key (e.g.: "sum:amount") or by the name of the
+// aggregated field (which refers to the first aggregate on that field).  Sort fields refer to
+// aggregates the same way.
+func (self *Filter) AddHaving(criteria ...Criterion) *Filter {
+	self.Having = append(self.Having, criteria...)
+	return self
+}
+
+// Returns a new filter whose criteria are the Having criteria of this filter, suitable for testing
+// against grouped results.  If the aggregates being performed are given, criteria on them refer to
+// the fields their values are returned as (see AggregateResultName).
+func (self *Filter) HavingFilter(aggregates ...Aggregate) *Filter {
+	var f Filter
+
+	if len(self.Having) > 0 {
+		f = MakeFilter()
+
+		for _, criterion := range self.Having {
+			criterion.Field = ResultFieldName(aggregates, criterion.Field)
+			f.AddCriteria(criterion)
+		}
+	} else {
+		f = MakeFilter(AllValue)
+	}
+
+	f.IdentityField = self.IdentityField
+	f.Normalizer = self.Normalizer
+
+	return &f
+}
+
 func (self *Filter) SortBy(fields ...string) *Filter {
 	if len(fields) > 0 {
 		self.Sort = fields
@@ -425,17 +576,25 @@ func (self *Filter) IsMatchAll() bool {
 }
 
 func (self *Filter) String() string {
+	criteria := make([]string, 0)
+
 	if self.MatchAll {
-		return AllValue
+		if len(self.Having) == 0 {
+			return AllValue
+		}
 	} else {
-		criteria := make([]string, 0)
-
 		for _, criterion := range self.Criteria {
 			criteria = append(criteria, criterion.String())
 		}
+	}
 
-		return strings.Join(criteria, CriteriaSeparator)
+	for _, criterion := range self.Having {
+		c := criterion
+		c.Field = HavingPrefix + c.Field
+		criteria = append(criteria, c.String())
 	}
+
+	return strings.Join(criteria, CriteriaSeparator)
 }
 
 func (self *Filter) GetSort() []SortBy {
@@ -198,3 +198,140 @@ func TestFilterCopy(t *testing.T) {
 		},
 	}, f2.Criteria)
 }
+
+func TestFilterHaving(t *testing.T) {
+	assert := require.New(t)
+
+	f := MustParse(`status/active`)
+	assert.True(f.HavingFilter().IsMatchAll())
+
+	f.AddHaving(Criterion{
+		Field:    `amount`,
+		Operator: `gt`,
+		Values:   []interface{}{1000},
+	})
+
+	having := f.HavingFilter()
+	assert.False(having.IsMatchAll())
+	assert.Equal([]Criterion{
+		{
+			Field:    `amount`,
+			Operator: `gt`,
+			Values:   []interface{}{1000},
+		},
+	}, having.Criteria)
+
+	assert.True(having.MatchesRecord(dal.NewRecord(nil).Set(`amount`, 1500)))
+	assert.False(having.MatchesRecord(dal.NewRecord(nil).Set(`amount`, 500)))
+}
+
+func TestFilterHavingMultipleAggregates(t *testing.T) {
+	assert := require.New(t)
+
+	aggregates := []Aggregate{
+		{Aggregation: Sum, Field: `amount`},
+		{Aggregation: Count, Field: `amount`},
+		{Aggregation: Average, Field: `price`},
+	}
+
+	aggregate, ok := FindAggregate(aggregates, `count:amount`)
+	assert.True(ok)
+	assert.Equal(aggregates[1], aggregate)
+
+	// bare field names refer to the first aggregate on that field
+	aggregate, ok = FindAggregate(aggregates, `amount`)
+	assert.True(ok)
+	assert.Equal(aggregates[0], aggregate)
+
+	_, ok = FindAggregate(aggregates, `max:amount`)
+	assert.False(ok)
+
+	assert.Equal(`sum:amount`, ResultFieldName(aggregates, `amount`))
+	assert.Equal(`count:amount`, ResultFieldName(aggregates, `count:amount`))
+	assert.Equal(`price`, ResultFieldName(aggregates, `avg:price`))
+	assert.Equal(`state`, ResultFieldName(aggregates, `state`))
+
+	f := MustParse(`all`)
+	f.AddHaving(Criterion{
+		Field:    `sum:amount`,
+		Operator: `gt`,
+		Values:   []interface{}{1000},
+	})
+
+	having := f.HavingFilter(aggregates...)
+	assert.Equal(`sum:amount`, having.Criteria[0].Field)
+	assert.True(having.MatchesRecord(dal.NewRecord(nil).Set(`sum:amount`, 1500).Set(`count:amount`, 2)))
+	assert.False(having.MatchesRecord(dal.NewRecord(nil).Set(`sum:amount`, 500).Set(`count:amount`, 2000)))
+}
+
+func TestFilterParseHaving(t *testing.T) {
+	assert := require.New(t)
+
+	spec := `str:status/active/@sum:amount/gt:1000/int:@count:amount/lte:5/@state/prefix:N`
+
+	f, err := Parse(spec)
+	assert.NoError(err)
+
+	assert.Equal([]Criterion{
+		{
+			Type:   dal.StringType,
+			Field:  `status`,
+			Values: []interface{}{`active`},
+		},
+	}, f.Criteria)
+
+	assert.Equal([]Criterion{
+		{
+			Type:     dal.AutoType,
+			Field:    `sum:amount`,
+			Operator: `gt`,
+			Values:   []interface{}{`1000`},
+		}, {
+			Type:     dal.IntType,
+			Field:    `count:amount`,
+			Operator: `lte`,
+			Values:   []interface{}{`5`},
+		}, {
+			Type:     dal.AutoType,
+			Field:    `state`,
+			Operator: `prefix`,
+			Values:   []interface{}{`N`},
+		},
+	}, f.Having)
+
+	assert.Equal(`str:status/active/auto:@sum:amount/gt:1000/int:@count:amount/lte:5/auto:@state/prefix:N`, f.String())
+
+	again, err := Parse(f.String())
+	assert.NoError(err)
+	assert.Equal(f.Criteria, again.Criteria)
+	assert.Equal(f.Having, again.Having)
+
+	// having criteria on a filter that otherwise matches everything
+	f = MustParse(AllValue)
+	f.AddHaving(Criterion{
+		Field:    `sum:amount`,
+		Operator: `gt`,
+		Values:   []interface{}{1000},
+	})
+
+	assert.Equal(`@sum:amount/gt:1000`, f.String())
+
+	again, err = Parse(f.String())
+	assert.NoError(err)
+	assert.Empty(again.Criteria)
+	assert.Len(again.Having, 1)
+	assert.Equal(`sum:amount`, again.Having[0].Field)
+}
+
+func TestParseAggregation(t *testing.T) {
+	assert := require.New(t)
+
+	for _, agg := range []Aggregation{First, Last, Minimum, Maximum, Sum, Average, Count} {
+		v, err := ParseAggregation(agg.String())
+		assert.NoError(err)
+		assert.Equal(agg, v)
+	}
+
+	_, err := ParseAggregation(`median`)
+	assert.Error(err)
+}
@@ -3,14 +3,19 @@ package generators
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 
+	"github.com/ghetzel/go-stockutil/stringutil"
 	"github.com/sniperkit/pivot/filter"
 )
 
+var ElasticsearchHavingAggregationName = `having`
+
 // Elasticsearch Generator
 
 type Elasticsearch struct {
 	filter.Generator
+	BucketSize  int // the maximum number of buckets returned for each field being grouped by
 	collection  string
 	fields      []string
 	criteria    []map[string]interface{}
@@ -80,6 +85,17 @@ func (self *Elasticsearch) Finalize(flt *filter.Filter) error {
 		payload[`sort`] = []string{`_doc`}
 	}
 
+	if len(self.facetFields) > 0 || len(self.aggregateBy) > 0 {
+		if aggs, err := self.aggregationsToNative(flt); err == nil {
+			payload[`aggs`] = aggs
+			payload[`size`] = 0
+			delete(payload, `from`)
+			delete(payload, `sort`)
+		} else {
+			return err
+		}
+	}
+
 	if data, err := json.MarshalIndent(payload, ``, `    `); err == nil {
 		self.Push(data)
 	} else {
@@ -142,3 +158,184 @@ func (self *Elasticsearch) WithCriterion(criterion filter.Criterion) error {
 
 	return nil
 }
+
+func (self *Elasticsearch) aggregationsToNative(flt *filter.Filter) (map[string]interface{}, error) {
+	metrics := make(map[string]interface{})
+
+	for _, aggregate := range self.aggregateBy {
+		var esFn string
+
+		switch aggregate.Aggregation {
+		case filter.Minimum:
+			esFn = `min`
+		case filter.Maximum:
+			esFn = `max`
+		case filter.Sum:
+			esFn = `sum`
+		case filter.Average:
+			esFn = `avg`
+		case filter.Count:
+			esFn = `value_count`
+		default:
+			return nil, fmt.Errorf("Unsupported aggregation '%v'", aggregate.Aggregation)
+		}
+
+		metrics[filter.AggregateResultName(self.aggregateBy, aggregate)] = map[string]interface{}{
+			esFn: map[string]interface{}{
+				`field`: aggregate.Field,
+			},
+		}
+	}
+
+	if len(self.facetFields) == 0 {
+		if len(flt.Having) > 0 {
+			return nil, fmt.Errorf("Aggregate criteria require at least one field to group by")
+		}
+
+		return metrics, nil
+	}
+
+	// build the terms aggregations from the innermost field outward, so that each grouped field
+	// is nested beneath the field before it
+	var aggs map[string]interface{}
+
+	for i := len(self.facetFields) - 1; i >= 0; i-- {
+		field := self.facetFields[i]
+		innermost := (i == len(self.facetFields)-1)
+		terms := map[string]interface{}{
+			`field`: field,
+		}
+
+		if self.BucketSize > 0 {
+			terms[`size`] = self.BucketSize
+		}
+
+		subaggs := make(map[string]interface{})
+
+		if innermost {
+			for name, metric := range metrics {
+				subaggs[name] = metric
+			}
+
+			// each parent needs enough buckets to fill the requested page once the buckets of all
+			// parents are sorted together, but aggregate criteria are tested after buckets are
+			// limited, so those need every bucket
+			if flt.Limit > 0 && len(flt.Having) == 0 {
+				terms[`size`] = flt.Offset + flt.Limit
+			}
+
+			if len(flt.Having) > 0 {
+				if selector, err := self.havingToBucketSelector(flt); err == nil {
+					subaggs[ElasticsearchHavingAggregationName] = selector
+				} else {
+					return nil, err
+				}
+			}
+		} else {
+			for name, agg := range aggs {
+				subaggs[name] = agg
+			}
+		}
+
+		if order := self.bucketOrder(flt, field, innermost); len(order) > 0 {
+			terms[`order`] = order
+		}
+
+		level := map[string]interface{}{
+			`terms`: terms,
+		}
+
+		if len(subaggs) > 0 {
+			level[`aggs`] = subaggs
+		}
+
+		aggs = map[string]interface{}{
+			field: level,
+		}
+	}
+
+	return aggs, nil
+}
+
+func (self *Elasticsearch) bucketOrder(flt *filter.Filter, field string, innermost bool) []map[string]interface{} {
+	order := make([]map[string]interface{}, 0)
+
+	for _, sortBy := range flt.GetSort() {
+		direction := `asc`
+
+		if sortBy.Descending {
+			direction = `desc`
+		}
+
+		if sortBy.Field == field {
+			order = append(order, map[string]interface{}{
+				`_key`: direction,
+			})
+		} else if innermost && self.isAggregatedField(sortBy.Field) {
+			order = append(order, map[string]interface{}{
+				filter.ResultFieldName(self.aggregateBy, sortBy.Field): direction,
+			})
+		}
+	}
+
+	return order
+}
+
+func (self *Elasticsearch) havingToBucketSelector(flt *filter.Filter) (map[string]interface{}, error) {
+	paths := make(map[string]interface{})
+	conditions := make([]string, 0)
+
+	for i, criterion := range flt.Having {
+		if !self.isAggregatedField(criterion.Field) {
+			return nil, fmt.Errorf("Aggregate criteria can only be applied to aggregated fields, got '%s'", criterion.Field)
+		}
+
+		param := fmt.Sprintf("v%d", i)
+		paths[param] = filter.ResultFieldName(self.aggregateBy, criterion.Field)
+		tests := make([]string, 0)
+
+		for _, vI := range criterion.Values {
+			var op string
+
+			switch criterion.Operator {
+			case `is`, ``:
+				op = `==`
+			case `not`:
+				op = `!=`
+			case `gt`:
+				op = `>`
+			case `gte`:
+				op = `>=`
+			case `lt`:
+				op = `<`
+			case `lte`:
+				op = `<=`
+			default:
+				return nil, fmt.Errorf("Unimplemented operator '%s' for aggregate criteria", criterion.Operator)
+			}
+
+			if v, err := stringutil.ConvertToFloat(vI); err == nil {
+				tests = append(tests, fmt.Sprintf("params.%s %s %v", param, op, v))
+			} else {
+				return nil, fmt.Errorf("Aggregate criteria values must be numeric: %v", err)
+			}
+		}
+
+		if len(tests) > 0 {
+			conditions = append(conditions, `(`+strings.Join(tests, ` || `)+`)`)
+		}
+	}
+
+	return map[string]interface{}{
+		`bucket_selector`: map[string]interface{}{
+			`buckets_path`: paths,
+			`script`:       strings.Join(conditions, ` && `),
+		},
+	}, nil
+}
+
+// Returns whether the given name refers to one of the aggregates (see filter.FindAggregate).
+func (self *Elasticsearch) isAggregatedField(name string) bool {
+	_, ok := filter.FindAggregate(self.aggregateBy, name)
+	return ok
+}
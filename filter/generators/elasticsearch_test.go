@@ -0,0 +1,178 @@
+package generators
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sniperkit/pivot/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestElasticsearchGroupByHaving(t *testing.T) {
+	assert := require.New(t)
+
+	f := filter.All()
+	f.Sort = []string{`state`, `-amount`}
+	f.Limit = 10
+	f.AddHaving(filter.Criterion{
+		Field:    `amount`,
+		Operator: `gt`,
+		Values:   []interface{}{`1000`},
+	}, filter.Criterion{
+		Field:    `id`,
+		Operator: `lte`,
+		Values:   []interface{}{5, 10},
+	})
+
+	gen := NewElasticsearchGenerator()
+	gen.BucketSize = 100
+
+	gen.GroupByField(`state`)
+	gen.GroupByField(`city`)
+	gen.AggregateByField(filter.Sum, `amount`)
+	gen.AggregateByField(filter.Count, `id`)
+
+	data, err := filter.Render(gen, `orders`, f)
+	assert.Nil(err)
+
+	var payload map[string]interface{}
+	assert.Nil(json.Unmarshal(data, &payload))
+
+	assert.Equal(float64(0), payload[`size`])
+	assert.NotContains(payload, `sort`)
+
+	assert.Equal(map[string]interface{}{
+		`state`: map[string]interface{}{
+			`terms`: map[string]interface{}{
+				`field`: `state`,
+				`size`:  float64(100),
+				`order`: []interface{}{
+					map[string]interface{}{
+						`_key`: `asc`,
+					},
+				},
+			},
+			`aggs`: map[string]interface{}{
+				`city`: map[string]interface{}{
+					`terms`: map[string]interface{}{
+						`field`: `city`,
+						`size`:  float64(100),
+						`order`: []interface{}{
+							map[string]interface{}{
+								`amount`: `desc`,
+							},
+						},
+					},
+					`aggs`: map[string]interface{}{
+						`amount`: map[string]interface{}{
+							`sum`: map[string]interface{}{
+								`field`: `amount`,
+							},
+						},
+						`id`: map[string]interface{}{
+							`value_count`: map[string]interface{}{
+								`field`: `id`,
+							},
+						},
+						`having`: map[string]interface{}{
+							`bucket_selector`: map[string]interface{}{
+								`buckets_path`: map[string]interface{}{
+									`v0`: `amount`,
+									`v1`: `id`,
+								},
+								`script`: `(params.v0 > 1000) && (params.v1 <= 5 || params.v1 <= 10)`,
+							},
+						},
+					},
+				},
+			},
+		},
+	}, payload[`aggs`])
+}
+
+func TestElasticsearchGroupByHavingMultipleAggregates(t *testing.T) {
+	assert := require.New(t)
+
+	f := filter.All()
+	f.Sort = []string{`-count:amount`}
+	f.AddHaving(filter.Criterion{
+		Field:    `sum:amount`,
+		Operator: `gt`,
+		Values:   []interface{}{1000},
+	})
+
+	gen := NewElasticsearchGenerator()
+	gen.GroupByField(`state`)
+	gen.AggregateByField(filter.Sum, `amount`)
+	gen.AggregateByField(filter.Count, `amount`)
+
+	data, err := filter.Render(gen, `orders`, f)
+	assert.Nil(err)
+
+	var payload map[string]interface{}
+	assert.Nil(json.Unmarshal(data, &payload))
+
+	assert.Equal(map[string]interface{}{
+		`state`: map[string]interface{}{
+			`terms`: map[string]interface{}{
+				`field`: `state`,
+				`order`: []interface{}{
+					map[string]interface{}{
+						`count:amount`: `desc`,
+					},
+				},
+			},
+			`aggs`: map[string]interface{}{
+				`sum:amount`: map[string]interface{}{
+					`sum`: map[string]interface{}{
+						`field`: `amount`,
+					},
+				},
+				`count:amount`: map[string]interface{}{
+					`value_count`: map[string]interface{}{
+						`field`: `amount`,
+					},
+				},
+				`having`: map[string]interface{}{
+					`bucket_selector`: map[string]interface{}{
+						`buckets_path`: map[string]interface{}{
+							`v0`: `sum:amount`,
+						},
+						`script`: `(params.v0 > 1000)`,
+					},
+				},
+			},
+		},
+	}, payload[`aggs`])
+}
+
+func TestElasticsearchGroupByHavingErrors(t *testing.T) {
+	assert := require.New(t)
+
+	f := filter.All()
+	f.AddHaving(filter.Criterion{
+		Field:    `state`,
+		Operator: `gt`,
+		Values:   []interface{}{1},
+	})
+
+	gen := NewElasticsearchGenerator()
+	gen.GroupByField(`state`)
+	gen.AggregateByField(filter.Sum, `amount`)
+
+	_, err := filter.Render(gen, `orders`, f)
+	assert.Error(err)
+
+	f = filter.All()
+	f.AddHaving(filter.Criterion{
+		Field:    `amount`,
+		Operator: `gt`,
+		Values:   []interface{}{1},
+	})
+
+	gen = NewElasticsearchGenerator()
+	gen.AggregateByField(filter.Sum, `amount`)
+
+	_, err = filter.Render(gen, `orders`, f)
+	assert.Error(err)
+}
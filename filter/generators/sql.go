@@ -2,6 +2,7 @@ package generators
 
 import (
 	"bytes"
+	"database/sql/driver"
 	"encoding/json"
 	"fmt"
 	"reflect"
@@ -38,19 +39,26 @@ const (
 )
 
 type SqlTypeMapping struct {
-	StringType         string
-	StringTypeLength   int
-	IntegerType        string
-	FloatType          string
-	FloatTypeLength    int
-	FloatTypePrecision int
-	BooleanType        string
-	BooleanTypeLength  int
-	DateTimeType       string
-	ObjectType         string
-	RawType            string
-	SubtypeFormat      string
-	MultiSubtypeFormat string
+	StringType           string
+	StringTypeLength     int
+	IntegerType          string
+	FloatType            string
+	FloatTypeLength      int
+	FloatTypePrecision   int
+	BooleanType          string
+	BooleanTypeLength    int
+	DateTimeType         string
+	ObjectType           string
+	RawType              string
+	SubtypeFormat        string
+	MultiSubtypeFormat   string
+	ArrayType            string
+	DecimalType          string
+	DecimalTypeLength    int
+	DecimalTypePrecision int
+	UUIDType             string
+	EnumType             string
+	EnumCheckFormat      string
 }
 
 var NoTypeMapping = SqlTypeMapping{}
@@ -66,42 +74,48 @@ var CassandraTypeMapping = SqlTypeMapping{
 	RawType:            `BLOB`,
 	SubtypeFormat:      `%s<%v>`,
 	MultiSubtypeFormat: `%s<%v,%v>`,
+	ArrayType:          `LIST`,
+	DecimalType:        `DECIMAL`,
+	UUIDType:           `UUID`,
+	EnumType:           `VARCHAR`,
 }
 
 var MysqlTypeMapping = SqlTypeMapping{
-	StringType:         `VARCHAR`,
-	StringTypeLength:   255,
-	IntegerType:        `BIGINT`,
-	FloatType:          `DECIMAL`,
-	FloatTypeLength:    10,
-	FloatTypePrecision: 8,
-	BooleanType:        `BOOL`,
-	DateTimeType:       `DATETIME`,
-	ObjectType:         `BLOB`,
-	RawType:            `BLOB`,
+	StringType:           `VARCHAR`,
+	StringTypeLength:     255,
+	IntegerType:          `BIGINT`,
+	FloatType:            `DECIMAL`,
+	FloatTypeLength:      10,
+	FloatTypePrecision:   8,
+	BooleanType:          `BOOL`,
+	DateTimeType:         `DATETIME`,
+	ObjectType:           `JSON`,
+	RawType:              `BLOB`,
+	DecimalType:          `DECIMAL`,
+	DecimalTypeLength:    36,
+	DecimalTypePrecision: 12,
+	UUIDType:             `CHAR(36)`,
+	EnumType:             `ENUM(%s)`,
 }
 
 var PostgresTypeMapping = SqlTypeMapping{
-	StringType:   `TEXT`,
-	IntegerType:  `BIGINT`,
-	FloatType:    `NUMERIC`,
-	BooleanType:  `BOOLEAN`,
-	DateTimeType: `TIMESTAMP`,
-	ObjectType:   `BLOB`,
-	RawType:      `BLOB`,
-}
-
-var PostgresJsonTypeMapping = SqlTypeMapping{
-	StringType:   `TEXT`,
-	IntegerType:  `BIGINT`,
-	FloatType:    `NUMERIC`,
-	BooleanType:  `BOOLEAN`,
-	DateTimeType: `TIMESTAMP`,
-	// ObjectType:   `JSONB`, // TODO: implement the JSONB functionality in PostgreSQL 9.2+
-	ObjectType: `BLOB`,
-	RawType:    `BLOB`,
+	StringType:      `TEXT`,
+	IntegerType:     `BIGINT`,
+	FloatType:       `NUMERIC`,
+	BooleanType:     `BOOLEAN`,
+	DateTimeType:    `TIMESTAMP`,
+	ObjectType:      `JSONB`,
+	RawType:         `BYTEA`,
+	SubtypeFormat:   `%[2]s%[1]s`,
+	ArrayType:       `[]`,
+	DecimalType:     `NUMERIC`,
+	UUIDType:        `UUID`,
+	EnumType:        `TEXT`,
+	EnumCheckFormat: `CHECK (%s IN (%s))`,
 }
 
+var PostgresJsonTypeMapping = PostgresTypeMapping
+
 var SqliteTypeMapping = SqlTypeMapping{
 	StringType:        `TEXT`,
 	IntegerType:       `INTEGER`,
@@ -111,6 +125,10 @@ var SqliteTypeMapping = SqlTypeMapping{
 	DateTimeType:      `INTEGER`,
 	ObjectType:        `BLOB`,
 	RawType:           `BLOB`,
+	DecimalType:       `TEXT`,
+	UUIDType:          `TEXT`,
+	EnumType:          `TEXT`,
+	EnumCheckFormat:   `CHECK (%s IN (%s))`,
 }
 
 var DefaultSqlTypeMapping = MysqlTypeMapping
@@ -133,6 +151,12 @@ type Sql struct {
 	TypeMapping           SqlTypeMapping         // provides mapping information between DAL types and native SQL types
 	Type                  SqlStatementType       // what type of SQL statement is being generated
 	InputData             map[string]interface{} // key-value data for statement types that require input data (e.g.: inserts, updates)
+	TextSearchFields      []string               // fields whose contains: and prefix: criteria are tested using a full text search instead of LIKE
+	TextSearchQuery       SqlTextSearchQueryFunc // renders the full text search query for the criteria on TextSearchFields
+	TextSearchJoinFormat  string                 // format string for the clause that joins the results of a full text search to a SELECT statement, given the query placeholder
+	TextSearchWhereFormat string                 // format string for the criterion that limits other statements to the results of a full text search, given the query placeholder
+	TextSearchRankField   string                 // what SELECT statements using a full text search are sorted by when no other sort order is given
+	CriterionFunc         SqlCriterionFunc       // renders criteria that need database-specific syntax (e.g.: on native lists or objects), before the default rendering is used
 	collection            string
 	fields                []string
 	criteria              []string
@@ -140,8 +164,16 @@ type Sql struct {
 	values                []interface{}
 	groupBy               []string
 	aggregateBy           []filter.Aggregate
+	textSearchCriteria    []filter.Criterion
 }
 
+// Renders a criterion as an SQL condition, or returns false if it should be rendered as usual.  Each
+// value used in the condition is added with addValue, which returns the placeholder to use for it.
+type SqlCriterionFunc func(criterion filter.Criterion, addValue func(value interface{}) string) (string, bool, error)
+
+// Renders the criteria being tested using a full text search as a single query value.
+type SqlTextSearchQueryFunc func(criteria []filter.Criterion) (interface{}, error)
+
 func NewSqlGenerator() *Sql {
 	return &Sql{
 		Generator:            filter.Generator{},
@@ -168,6 +200,7 @@ func (self *Sql) Initialize(collectionName string) error {
 	self.criteria = make([]string, 0)
 	self.inputValues = make([]interface{}, 0)
 	self.values = make([]interface{}, 0)
+	self.textSearchCriteria = make([]filter.Criterion, 0)
 
 	return nil
 }
@@ -186,7 +219,12 @@ func (self *Sql) Finalize(f *filter.Filter) error {
 			}
 
 			if len(self.fields) == 0 && len(self.groupBy) == 0 && len(self.aggregateBy) == 0 {
-				self.Push([]byte(`*`))
+				// only return this table's columns if another is being joined to it
+				if len(self.textSearchCriteria) > 0 {
+					self.Push([]byte(self.collection + `.*`))
+				} else {
+					self.Push([]byte(`*`))
+				}
 			} else {
 				fieldNames := make([]string, 0)
 
@@ -209,7 +247,7 @@ func (self *Sql) Finalize(f *filter.Filter) error {
 
 				for _, aggpair := range self.aggregateBy {
 					fName := self.ToAggregatedFieldName(aggpair.Aggregation, aggpair.Field)
-					fName = fmt.Sprintf("%v AS "+self.FieldNameFormat, fName, aggpair.Field)
+					fName = fmt.Sprintf("%v AS "+self.FieldNameFormat, fName, filter.AggregateResultName(self.aggregateBy, aggpair))
 					fieldNames = append(fieldNames, fName)
 				}
 
@@ -220,9 +258,17 @@ func (self *Sql) Finalize(f *filter.Filter) error {
 		self.Push([]byte(` FROM `))
 		self.Push([]byte(self.collection))
 
+		if err := self.populateTextSearchJoin(); err != nil {
+			return err
+		}
+
 		self.populateWhereClause()
 		self.populateGroupBy()
 
+		if err := self.populateHaving(f); err != nil {
+			return err
+		}
+
 		if !self.Count {
 			self.populateOrderBy(f)
 			self.populateLimitOffset(f)
@@ -298,11 +344,20 @@ func (self *Sql) Finalize(f *filter.Filter) error {
 
 		self.Push([]byte(strings.Join(updatePairs, `, `)))
 
+		if err := self.populateTextSearchWhere(); err != nil {
+			return err
+		}
+
 		self.populateWhereClause()
 
 	case SqlDeleteStatement:
 		self.Push([]byte(`DELETE FROM `))
 		self.Push([]byte(self.collection))
+
+		if err := self.populateTextSearchWhere(); err != nil {
+			return err
+		}
+
 		self.populateWhereClause()
 
 	default:
@@ -340,6 +395,12 @@ func (self *Sql) GetValues() []interface{} {
 }
 
 func (self *Sql) WithCriterion(criterion filter.Criterion) error {
+	// criteria answered by the full text search are rendered along with it in Finalize()
+	if self.isTextSearchCriterion(criterion) {
+		self.textSearchCriteria = append(self.textSearchCriteria, criterion)
+		return nil
+	}
+
 	criterionStr := ``
 
 	if len(self.criteria) == 0 {
@@ -348,6 +409,15 @@ func (self *Sql) WithCriterion(criterion filter.Criterion) error {
 		criterionStr = `AND (`
 	}
 
+	if self.CriterionFunc != nil {
+		if condition, ok, err := self.CriterionFunc(criterion, self.addValue); err != nil {
+			return err
+		} else if ok {
+			self.criteria = append(self.criteria, criterionStr+condition+`)`)
+			return nil
+		}
+	}
+
 	outValues := make([]string, 0)
 
 	// whether to wrap is: and not: queries containing multiple values in an IN() group
@@ -429,7 +499,7 @@ func (self *Sql) WithCriterion(criterion filter.Criterion) error {
 		case `NULL`:
 			value = strings.ToUpper(value)
 		default:
-			value = self.GetPlaceholder(criterion.Field, len(self.criteria))
+			value = self.GetPlaceholder(criterion.Field, len(self.values)-1)
 		}
 
 		outVal := ``
@@ -565,9 +635,18 @@ func (self *Sql) ToAggregatedFieldName(agg filter.Aggregation, field string) str
 	}
 }
 
+// Returns the aggregated field that the given name refers to (see filter.FindAggregate).
+func (self *Sql) toAggregatedFieldNameFor(name string) (string, bool) {
+	if aggpair, ok := filter.FindAggregate(self.aggregateBy, name); ok {
+		return self.ToAggregatedFieldName(aggpair.Aggregation, aggpair.Field), true
+	}
+
+	return ``, false
+}
+
 func (self *Sql) ToNativeValue(t dal.Type, subtypes []dal.Type, in interface{}) string {
 	switch t {
-	case dal.StringType:
+	case dal.StringType, dal.EnumType, dal.UUIDType:
 		return fmt.Sprintf("'%v'", in)
 	case dal.BooleanType:
 		if v, ok := in.(bool); ok {
@@ -630,9 +709,45 @@ func (self *Sql) ToNativeType(in dal.Type, subtypes []dal.Type, length int) (str
 			)
 		}
 
-	case dal.RawType:
+	case dal.RawType, dal.BytesType:
 		out = self.TypeMapping.RawType
 
+	case dal.ArrayType:
+		if self.TypeMapping.ArrayType != `` && self.TypeMapping.SubtypeFormat != `` && len(subtypes) > 0 && IsNativeArraySubtype(subtypes[0]) {
+			if subtype, err := self.ToNativeType(subtypes[0], nil, 0); err == nil {
+				out = fmt.Sprintf(self.TypeMapping.SubtypeFormat, self.TypeMapping.ArrayType, subtype)
+			} else {
+				return ``, err
+			}
+		} else {
+			// lists are stored as objects on databases without a native list type
+			out = self.TypeMapping.ObjectType
+		}
+
+	case dal.DecimalType:
+		if out = self.TypeMapping.DecimalType; out == `` {
+			return self.ToNativeType(dal.StringType, subtypes, length)
+		}
+
+		if l := self.TypeMapping.DecimalTypeLength; length == 0 && l > 0 {
+			length = l
+		}
+
+		if p := self.TypeMapping.DecimalTypePrecision; p > 0 {
+			precision = p
+		}
+
+	case dal.UUIDType:
+		if out = self.TypeMapping.UUIDType; out == `` {
+			return self.ToNativeType(dal.StringType, subtypes, length)
+		}
+
+	case dal.EnumType:
+		// enum types that list their values can't be represented without knowing the values
+		if out = self.TypeMapping.EnumType; out == `` || strings.Contains(out, `%s`) {
+			return self.ToNativeType(dal.StringType, subtypes, length)
+		}
+
 	default:
 		out = strings.ToUpper(in.String())
 	}
@@ -648,6 +763,96 @@ func (self *Sql) ToNativeType(in dal.Type, subtypes []dal.Type, length int) (str
 	return strings.ToUpper(out), nil
 }
 
+// Returns whether lists of the given type can be stored using a native list type; lists of anything
+// else (including lists without a declared type) are stored as objects.
+func IsNativeArraySubtype(subtype dal.Type) bool {
+	switch subtype {
+	case ``, dal.AutoType, dal.ObjectType, dal.ArrayType, dal.RawType, dal.BytesType:
+		return false
+	default:
+		return true
+	}
+}
+
+// Returns the native type for the given field, which (unlike ToNativeType) takes the field's
+// precision and enumerated values into account.
+func (self *Sql) ToNativeFieldType(field dal.Field) (string, error) {
+	switch field.Type {
+	case dal.DecimalType:
+		if self.TypeMapping.DecimalType != `` && field.Precision > 0 {
+			length := field.Length
+
+			if length == 0 {
+				length = self.TypeMapping.DecimalTypeLength
+			}
+
+			if length > 0 {
+				return strings.ToUpper(fmt.Sprintf("%s(%d,%d)", self.TypeMapping.DecimalType, length, field.Precision)), nil
+			}
+		}
+
+	case dal.EnumType:
+		if f := self.TypeMapping.EnumType; len(field.Values) > 0 && strings.Contains(f, `%s`) {
+			return fmt.Sprintf(f, self.enumValueList(field.Values)), nil
+		}
+	}
+
+	return self.ToNativeType(field.Type, []dal.Type{field.Subtype}, field.Length)
+}
+
+// Returns a constraint clause that limits an enum field to its values on databases without a native
+// enum type, or an empty string if no constraint is needed.
+func (self *Sql) ToEnumConstraint(field dal.Field) string {
+	if field.Type == dal.EnumType && len(field.Values) > 0 {
+		if f := self.TypeMapping.EnumCheckFormat; f != `` && !strings.Contains(self.TypeMapping.EnumType, `%s`) {
+			return fmt.Sprintf(f, self.ToFieldName(field.Name), self.enumValueList(field.Values))
+		}
+	}
+
+	return ``
+}
+
+func (self *Sql) enumValueList(values []string) string {
+	quoted := make([]string, len(values))
+
+	for i, value := range values {
+		quoted[i] = `'` + strings.Replace(value, `'`, `''`, -1) + `'`
+	}
+
+	return strings.Join(quoted, `,`)
+}
+
+// Returns the values listed in a native enum type, e.g.: ENUM('a','b') -> ["a", "b"]
+func (self *Sql) SplitEnumValues(in string) []string {
+	values := make([]string, 0)
+
+	if start, end := strings.Index(in, `(`), strings.LastIndex(in, `)`); start >= 0 && end > start {
+		var current []rune
+		var quoted bool
+
+		runes := []rune(in[start+1 : end])
+
+		for i := 0; i < len(runes); i++ {
+			switch r := runes[i]; {
+			case r == '\'' && quoted && i+1 < len(runes) && runes[i+1] == '\'':
+				current = append(current, r)
+				i++
+			case r == '\'':
+				if quoted {
+					values = append(values, string(current))
+					current = nil
+				}
+
+				quoted = !quoted
+			case quoted:
+				current = append(current, r)
+			}
+		}
+	}
+
+	return values
+}
+
 func (self *Sql) SplitTypeLength(in string) (string, int, int) {
 	var length int
 	var precision int
@@ -696,8 +901,9 @@ func (self *Sql) ApplyNormalizer(fieldName string, in string) string {
 }
 
 func (self *Sql) PrepareInputValue(f string, value interface{}) (interface{}, error) {
-	// times get returned as-is
-	if _, ok := value.(time.Time); ok {
+	// times, byte slices, and values that know how to encode themselves get returned as-is
+	switch value.(type) {
+	case time.Time, []byte, driver.Valuer:
 		return value, nil
 	}
 
@@ -736,7 +942,198 @@ func (self *Sql) populateGroupBy() {
 	}
 }
 
+func (self *Sql) populateHaving(f *filter.Filter) error {
+	if len(f.Having) > 0 && (len(self.groupBy) > 0 || len(self.aggregateBy) > 0) {
+		clauses := make([]string, 0)
+
+		for _, criterion := range f.Having {
+			outFieldName := self.ToFieldName(criterion.Field)
+			outValues := make([]string, 0)
+
+			// criteria on a field being aggregated are tested against the aggregated value
+			if aggField, ok := self.toAggregatedFieldNameFor(criterion.Field); ok {
+				outFieldName = aggField
+			}
+
+			for _, vI := range criterion.Values {
+				var typedValue interface{}
+
+				if vS, ok := vI.(string); ok && criterion.Type != dal.StringType {
+					typedValue = stringutil.Autotype(vS)
+				} else {
+					typedValue = vI
+				}
+
+				if typedValue == nil || strings.ToUpper(fmt.Sprintf("%v", typedValue)) == `NULL` {
+					switch criterion.Operator {
+					case `is`, ``:
+						outValues = append(outValues, outFieldName+` IS NULL`)
+					case `not`:
+						outValues = append(outValues, outFieldName+` IS NOT NULL`)
+					default:
+						return fmt.Errorf("Operator '%s' cannot be used with NULL values", criterion.Operator)
+					}
+
+					continue
+				}
+
+				var sqlOp string
+
+				switch criterion.Operator {
+				case `is`, ``:
+					sqlOp = `=`
+				case `not`:
+					sqlOp = `<>`
+				case `gt`:
+					sqlOp = `>`
+				case `gte`:
+					sqlOp = `>=`
+				case `lt`:
+					sqlOp = `<`
+				case `lte`:
+					sqlOp = `<=`
+				default:
+					return fmt.Errorf("Unimplemented operator '%s' for aggregate criteria", criterion.Operator)
+				}
+
+				outValues = append(outValues, fmt.Sprintf(
+					"%s %s %s",
+					outFieldName,
+					sqlOp,
+					self.GetPlaceholder(criterion.Field, len(self.values)),
+				))
+
+				self.values = append(self.values, typedValue)
+			}
+
+			if len(outValues) > 0 {
+				clauses = append(clauses, `(`+strings.Join(outValues, ` OR `)+`)`)
+			}
+		}
+
+		if len(clauses) > 0 {
+			self.Push([]byte(` HAVING `))
+			self.Push([]byte(strings.Join(clauses, ` AND `)))
+		}
+	}
+
+	return nil
+}
+
+// Renders criteria as an SQLite FTS5 query: each criterion is limited to its field's column, its
+// values are quoted as phrases (prefix: phrases match any word starting with them), and a record
+// must match every criterion and at least one value in each.
+func SqliteTextSearchQuery(criteria []filter.Criterion) (interface{}, error) {
+	terms := make([]string, 0)
+
+	for _, criterion := range criteria {
+		phrases := make([]string, 0)
+
+		for _, value := range criterion.Values {
+			phrase := sqliteTextSearchQuote(fmt.Sprintf("%v", value))
+
+			switch criterion.Operator {
+			case `prefix`:
+				phrase += `*`
+			case `contains`:
+				break
+			default:
+				return nil, fmt.Errorf("Operator '%s' cannot be used in a full text search", criterion.Operator)
+			}
+
+			phrases = append(phrases, phrase)
+		}
+
+		if len(phrases) == 0 {
+			return nil, fmt.Errorf("No values given for full text search on field %q", criterion.Field)
+		}
+
+		terms = append(terms, fmt.Sprintf("%s : (%s)", sqliteTextSearchQuote(criterion.Field), strings.Join(phrases, ` OR `)))
+	}
+
+	return strings.Join(terms, ` AND `), nil
+}
+
+func sqliteTextSearchQuote(in string) string {
+	return `"` + strings.Replace(in, `"`, `""`, -1) + `"`
+}
+
+// adds a value to the statement, returning the placeholder for it
+func (self *Sql) addValue(value interface{}) string {
+	self.values = append(self.values, value)
+	return self.GetPlaceholder(``, len(self.values)-1)
+}
+
+func (self *Sql) isTextSearchCriterion(criterion filter.Criterion) bool {
+	if self.TextSearchQuery == nil || !sliceutil.ContainsString(self.TextSearchFields, criterion.Field) {
+		return false
+	}
+
+	switch criterion.Operator {
+	case `contains`, `prefix`:
+		return true
+	}
+
+	return false
+}
+
+// Joins the results of the full text search (if any) to a SELECT statement.  The search query's
+// placeholder comes before those in the WHERE clause, so its value is put first.
+func (self *Sql) populateTextSearchJoin() error {
+	if len(self.textSearchCriteria) == 0 {
+		return nil
+	}
+
+	if self.TextSearchJoinFormat == `` {
+		return fmt.Errorf("Full text search is not supported in SELECT statements")
+	}
+
+	if query, err := self.TextSearchQuery(self.textSearchCriteria); err == nil {
+		self.Push([]byte(` ` + fmt.Sprintf(self.TextSearchJoinFormat, self.GetPlaceholder(``, 0))))
+		self.values = append([]interface{}{query}, self.values...)
+		return nil
+	} else {
+		return err
+	}
+}
+
+// Adds a criterion limiting an UPDATE or DELETE statement to the results of the full text search (if any).
+func (self *Sql) populateTextSearchWhere() error {
+	if len(self.textSearchCriteria) == 0 {
+		return nil
+	}
+
+	if self.TextSearchWhereFormat == `` {
+		return fmt.Errorf("Full text search is not supported in this statement")
+	}
+
+	if query, err := self.TextSearchQuery(self.textSearchCriteria); err == nil {
+		criterionStr := `WHERE (`
+
+		if len(self.criteria) > 0 {
+			criterionStr = `AND (`
+		}
+
+		criterionStr += fmt.Sprintf(self.TextSearchWhereFormat, self.GetPlaceholder(``, len(self.criteria))) + `)`
+
+		self.criteria = append(self.criteria, criterionStr)
+		self.values = append(self.values, query)
+		return nil
+	} else {
+		return err
+	}
+}
+
 func (self *Sql) populateOrderBy(f *filter.Filter) {
+	// full text search results are sorted by relevance unless told otherwise
+	if len(sliceutil.CompactString(f.Sort)) == 0 && len(self.textSearchCriteria) > 0 && self.TextSearchRankField != `` {
+		if len(self.groupBy) == 0 && len(self.aggregateBy) == 0 {
+			self.Push([]byte(` ORDER BY ` + self.TextSearchRankField + ` ASC`))
+		}
+
+		return
+	}
+
 	if sortFields := sliceutil.CompactString(f.Sort); len(sortFields) > 0 {
 		self.Push([]byte(` ORDER BY `))
 		orderByFields := make([]string, len(sortFields))
@@ -744,6 +1141,11 @@ func (self *Sql) populateOrderBy(f *filter.Filter) {
 		for i, sortBy := range f.GetSort() {
 			v := self.ToFieldName(sortBy.Field)
 
+			// sorting on a field being aggregated sorts on the aggregated value
+			if aggField, ok := self.toAggregatedFieldNameFor(sortBy.Field); ok {
+				v = aggField
+			}
+
 			if !sortBy.Descending {
 				v += ` ASC`
 			} else {
@@ -1,11 +1,13 @@
 package generators
 
 import (
+	"fmt"
 	"sort"
 	"strings"
 	"testing"
 
 	"github.com/ghetzel/go-stockutil/maputil"
+	"github.com/sniperkit/pivot/dal"
 	"github.com/sniperkit/pivot/filter"
 	"github.com/stretchr/testify/require"
 )
@@ -52,6 +54,62 @@ func TestSqlSplitTypeLength(t *testing.T) {
 	assert.Equal(12, precision)
 }
 
+func TestSqlNativeFieldTypes(t *testing.T) {
+	assert := require.New(t)
+
+	enum := dal.Field{
+		Name:   `status`,
+		Type:   dal.EnumType,
+		Values: []string{`draft`, `it's done`},
+	}
+
+	decimal := dal.Field{
+		Name:      `price`,
+		Type:      dal.DecimalType,
+		Precision: 2,
+	}
+
+	list := dal.Field{
+		Name:    `tags`,
+		Type:    dal.ArrayType,
+		Subtype: dal.StringType,
+	}
+
+	uuid := dal.Field{
+		Name: `ref`,
+		Type: dal.UUIDType,
+	}
+
+	for mapping, expected := range map[*SqlTypeMapping][]string{
+		&MysqlTypeMapping:     {`ENUM('draft','it''s done')`, ``, `DECIMAL(36,2)`, `JSON`, `CHAR(36)`},
+		&PostgresTypeMapping:  {`TEXT`, `CHECK (status IN ('draft','it''s done'))`, `NUMERIC`, `TEXT[]`, `UUID`},
+		&SqliteTypeMapping:    {`TEXT`, `CHECK (status IN ('draft','it''s done'))`, `TEXT`, `BLOB`, `TEXT`},
+		&CassandraTypeMapping: {`VARCHAR`, ``, `DECIMAL`, `LIST<VARCHAR>`, `UUID`},
+	} {
+		gen := NewSqlGenerator()
+		gen.TypeMapping = *mapping
+
+		actual, err := gen.ToNativeFieldType(enum)
+		assert.NoError(err)
+		assert.Equal(expected[0], actual)
+		assert.Equal(expected[1], gen.ToEnumConstraint(enum))
+
+		actual, err = gen.ToNativeFieldType(decimal)
+		assert.NoError(err)
+		assert.Equal(expected[2], actual)
+
+		actual, err = gen.ToNativeFieldType(list)
+		assert.NoError(err)
+		assert.Equal(expected[3], actual)
+
+		actual, err = gen.ToNativeFieldType(uuid)
+		assert.NoError(err)
+		assert.Equal(expected[4], actual)
+	}
+
+	assert.Equal([]string{`draft`, `it's done`, `a,b`}, NewSqlGenerator().SplitEnumValues(`enum('draft','it''s done','a,b')`))
+}
+
 func TestSqlSelects(t *testing.T) {
 	assert := require.New(t)
 
@@ -939,6 +997,102 @@ func TestSqlSelectGroupBy(t *testing.T) {
 	)
 }
 
+func TestSqlSelectGroupByHaving(t *testing.T) {
+	assert := require.New(t)
+
+	f, err := filter.Parse(`status/active`)
+	assert.Nil(err)
+	f.Fields = []string{`state`}
+	f.Sort = []string{`-amount`, `state`}
+	f.Limit = 10
+	f.AddHaving(filter.Criterion{
+		Field:    `amount`,
+		Operator: `gt`,
+		Values:   []interface{}{`1000`},
+	}, filter.Criterion{
+		Field:    `id`,
+		Operator: `gte`,
+		Values:   []interface{}{5},
+	})
+
+	gen := NewSqlGenerator()
+	gen.PlaceholderFormat = `$%d`
+	gen.PlaceholderArgument = `index1`
+
+	gen.GroupByField(`state`)
+	gen.AggregateByField(filter.Sum, `amount`)
+	gen.AggregateByField(filter.Count, `id`)
+
+	sql, err := filter.Render(gen, `orders`, f)
+	assert.Nil(err)
+
+	assert.Equal(
+		`SELECT state, SUM(amount) AS amount, COUNT(id) AS id FROM orders `+
+			`WHERE (status = $1) `+
+			`GROUP BY state `+
+			`HAVING (SUM(amount) > $2) AND (COUNT(id) >= $3) `+
+			`ORDER BY SUM(amount) DESC, state ASC `+
+			`LIMIT 10`,
+		string(sql[:]),
+	)
+
+	assert.Equal([]interface{}{
+		`active`,
+		int64(1000),
+		5,
+	}, gen.GetValues())
+}
+
+func TestSqlSelectGroupByHavingMultipleAggregates(t *testing.T) {
+	assert := require.New(t)
+
+	f := filter.All()
+	f.Sort = []string{`-count:amount`}
+	f.AddHaving(filter.Criterion{
+		Field:    `sum:amount`,
+		Operator: `gt`,
+		Values:   []interface{}{1000},
+	})
+
+	gen := NewSqlGenerator()
+	gen.FieldNameFormat = "%q"
+	gen.GroupByField(`state`)
+	gen.AggregateByField(filter.Sum, `amount`)
+	gen.AggregateByField(filter.Count, `amount`)
+
+	sql, err := filter.Render(gen, `orders`, f)
+	assert.Nil(err)
+
+	// both aggregates are returned, named by their keys
+	assert.Equal(
+		`SELECT state, SUM("amount") AS "sum:amount", COUNT("amount") AS "count:amount" FROM orders `+
+			`GROUP BY "state" `+
+			`HAVING (SUM("amount") > ?) `+
+			`ORDER BY COUNT("amount") DESC`,
+		string(sql[:]),
+	)
+
+	assert.Equal([]interface{}{1000}, gen.GetValues())
+}
+
+func TestSqlSelectGroupByHavingErrors(t *testing.T) {
+	assert := require.New(t)
+
+	f := filter.All()
+	f.AddHaving(filter.Criterion{
+		Field:    `amount`,
+		Operator: `contains`,
+		Values:   []interface{}{`1`},
+	})
+
+	gen := NewSqlGenerator()
+	gen.GroupByField(`state`)
+	gen.AggregateByField(filter.Sum, `amount`)
+
+	_, err := filter.Render(gen, `orders`, f)
+	assert.Error(err)
+}
+
 func TestSqlBulkDelete(t *testing.T) {
 	assert := require.New(t)
 
@@ -988,3 +1142,123 @@ func TestSqlBulkDeleteWithNormalizers(t *testing.T) {
 		`Steve`,
 	}, gen.GetValues())
 }
+
+func TestSqlTextSearch(t *testing.T) {
+	assert := require.New(t)
+
+	f, err := filter.Parse(`title/contains:quick fox/body/prefix:jump|"leap/int:views/gt:10`)
+	assert.Nil(err)
+
+	gen := NewSqlGenerator()
+	gen.TextSearchFields = []string{`title`, `body`}
+	gen.TextSearchQuery = SqliteTextSearchQuery
+	gen.TextSearchJoinFormat = `INNER JOIN (SELECT rowid AS r, bm25(fts) AS rank FROM fts WHERE fts MATCH %s) AS s ON s.r = posts.rowid`
+	gen.TextSearchWhereFormat = `rowid IN (SELECT rowid FROM fts WHERE fts MATCH %s)`
+	gen.TextSearchRankField = `s.rank`
+
+	sql, err := filter.Render(gen, `posts`, f)
+	assert.Nil(err)
+
+	assert.Equal(
+		`SELECT posts.* FROM posts INNER JOIN (SELECT rowid AS r, bm25(fts) AS rank FROM fts WHERE fts MATCH ?) AS s ON s.r = posts.rowid WHERE (views > ?) ORDER BY s.rank ASC`,
+		string(sql[:]),
+	)
+
+	assert.Equal([]interface{}{
+		`"title" : ("quick fox") AND "body" : ("jump"* OR """leap"*)`,
+		int64(10),
+	}, gen.GetValues())
+
+	// an explicit sort order replaces sorting by rank
+	gen = NewSqlGenerator()
+	gen.TextSearchFields = []string{`title`}
+	gen.TextSearchQuery = SqliteTextSearchQuery
+	gen.TextSearchJoinFormat = `INNER JOIN (SELECT rowid AS r, bm25(fts) AS rank FROM fts WHERE fts MATCH %s) AS s ON s.r = posts.rowid`
+	gen.TextSearchRankField = `s.rank`
+
+	sql, err = filter.Render(gen, `posts`, filter.MustParse(`title/contains:fox/body/contains:dog`).SortBy(`-views`))
+	assert.Nil(err)
+
+	assert.Equal(
+		`SELECT posts.* FROM posts INNER JOIN (SELECT rowid AS r, bm25(fts) AS rank FROM fts WHERE fts MATCH ?) AS s ON s.r = posts.rowid WHERE (body LIKE ?) ORDER BY views DESC`,
+		string(sql[:]),
+	)
+
+	assert.Equal([]interface{}{
+		`"title" : ("fox")`,
+		`%%dog%%`,
+	}, gen.GetValues())
+
+	// other statements are limited to the search results with a criterion
+	gen = NewSqlGenerator()
+	gen.Type = SqlDeleteStatement
+	gen.TextSearchFields = []string{`title`}
+	gen.TextSearchQuery = SqliteTextSearchQuery
+	gen.TextSearchWhereFormat = `rowid IN (SELECT rowid FROM fts WHERE fts MATCH %s)`
+
+	sql, err = filter.Render(gen, `posts`, filter.MustParse(`int:views/lt:5/title/contains:fox`))
+	assert.Nil(err)
+
+	assert.Equal(
+		`DELETE FROM posts WHERE (views < ?) AND (rowid IN (SELECT rowid FROM fts WHERE fts MATCH ?))`,
+		string(sql[:]),
+	)
+
+	assert.Equal([]interface{}{
+		int64(5),
+		`"title" : ("fox")`,
+	}, gen.GetValues())
+}
+
+func TestSqlPostgresNativeTypes(t *testing.T) {
+	assert := require.New(t)
+
+	gen := NewSqlGenerator()
+	gen.TypeMapping = PostgresTypeMapping
+
+	for field, expected := range map[*dal.Field]string{
+		{Type: dal.ObjectType}:                         `JSONB`,
+		{Type: dal.RawType}:                            `BYTEA`,
+		{Type: dal.ArrayType, Subtype: dal.IntType}:    `BIGINT[]`,
+		{Type: dal.ArrayType, Subtype: dal.TimeType}:   `TIMESTAMP[]`,
+		{Type: dal.ArrayType, Subtype: dal.ObjectType}: `JSONB`,
+		{Type: dal.ArrayType}:                          `JSONB`,
+	} {
+		actual, err := gen.ToNativeFieldType(*field)
+		assert.NoError(err)
+		assert.Equal(expected, actual)
+	}
+}
+
+func TestSqlCriterionFunc(t *testing.T) {
+	assert := require.New(t)
+
+	f, err := filter.Parse(`name/a|b/tags/x|y/age/gt:7`)
+	assert.Nil(err)
+
+	gen := NewSqlGenerator()
+	gen.PlaceholderFormat = `$%d`
+	gen.PlaceholderArgument = `index1`
+	gen.CriterionFunc = func(criterion filter.Criterion, addValue func(value interface{}) string) (string, bool, error) {
+		if criterion.Field == `tags` {
+			return fmt.Sprintf("tags && %s", addValue(criterion.Values)), true, nil
+		}
+
+		return ``, false, nil
+	}
+
+	sql, err := filter.Render(gen, `foo`, f)
+	assert.Nil(err)
+
+	assert.Equal(
+		`SELECT * FROM foo WHERE (name IN($1, $2)) AND (tags && $3) AND (age > $4)`,
+		string(sql[:]),
+	)
+
+	assert.Equal([]interface{}{
+		`a`,
+		`b`,
+		[]interface{}{`x`, `y`},
+		int64(7),
+	}, gen.GetValues())
+}
@@ -0,0 +1,303 @@
+package pivot
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/sniperkit/pivot/dal"
+)
+
+var goIdentifierInvalidChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+var generateGoTemplate = template.Must(template.New(`generate`).Parse(`// Code generated by pivot generate; DO NOT EDIT.
+
+package {{ .Package }}
+
+import (
+{{- if .ImportTime }}
+	"time"
+
+{{ end }}
+	"github.com/sniperkit/pivot/backends"
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/mapper"
+)
+{{ range .Collections }}
+const (
+	{{ .Plural }}Collection = ` + "`{{ .Name }}`" + `
+{{- range .Fields }}
+	{{ .Const }} = ` + "`{{ .Name }}`" + `
+{{- end }}
+)
+{{ end }}
+{{- range .Collections }}
+type {{ .Singular }} struct {
+	dal.Model ` + "`" + `pivot:"{{ .Name }}"` + "`" + `
+{{- range .Fields }}
+	{{ .GoName }} {{ .GoType }} ` + "`" + `pivot:"{{ .Tag }}"` + "`" + `
+{{- end }}
+}
+
+// {{ .Plural }}Model provides typed access to the {{ .Name }} collection.
+type {{ .Plural }}Model struct {
+	*mapper.Model
+}
+
+func New{{ .Plural }}Model(db backends.Backend) (*{{ .Plural }}Model, error) {
+	if collection, err := dal.CollectionFromStruct(&{{ .Singular }}{}); err == nil {
+		return &{{ .Plural }}Model{
+			Model: mapper.NewModel(db, collection),
+		}, nil
+	} else {
+		return nil, err
+	}
+}
+
+func (self *{{ .Plural }}Model) Get{{ .Singular }}(id interface{}) (*{{ .Singular }}, error) {
+	instance := new({{ .Singular }})
+
+	if err := self.Get(id, instance); err == nil {
+		return instance, nil
+	} else {
+		return nil, err
+	}
+}
+
+func (self *{{ .Plural }}Model) Find{{ .Plural }}(flt interface{}) ([]{{ .Singular }}, error) {
+	results := make([]{{ .Singular }}, 0)
+
+	if err := self.Find(flt, &results); err == nil {
+		return results, nil
+	} else {
+		return nil, err
+	}
+}
+
+func (self *{{ .Plural }}Model) All{{ .Plural }}() ([]{{ .Singular }}, error) {
+	results := make([]{{ .Singular }}, 0)
+
+	if err := self.All(&results); err == nil {
+		return results, nil
+	} else {
+		return nil, err
+	}
+}
+{{ end }}`))
+
+type generateCollection struct {
+	Name     string
+	Singular string
+	Plural   string
+	Fields   []generateField
+}
+
+type generateField struct {
+	Name   string
+	Const  string
+	GoName string
+	GoType string
+	Tag    string
+}
+
+// Generates Go source code containing a struct type, field name constants, and a typed wrapper
+// around mapper.Model for each of the given collections.  The output is gofmt-formatted, and
+// collections are emitted in order of name so that the output is deterministic.
+func GenerateGoSource(packageName string, collections []*dal.Collection) ([]byte, error) {
+	data := struct {
+		Package     string
+		ImportTime  bool
+		Collections []generateCollection
+	}{
+		Package: packageName,
+	}
+
+	sorted := append([]*dal.Collection{}, collections...)
+
+	sort.SliceStable(sorted, func(i int, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	for _, collection := range sorted {
+		plural := goIdentifier(collection.Name)
+		singular := goSingular(plural)
+
+		if singular == plural {
+			plural = plural + `s`
+		}
+
+		gc := generateCollection{
+			Name:     collection.Name,
+			Singular: singular,
+			Plural:   plural,
+		}
+
+		identityField := collection.IdentityField
+		identityType := collection.IdentityFieldType
+
+		if identityField == `` {
+			identityField = dal.DefaultIdentityField
+		}
+
+		if identityType == `` {
+			identityType = dal.DefaultIdentityFieldType
+		}
+
+		gc.Fields = append(gc.Fields, generateField{
+			Name:   identityField,
+			Const:  singular + `IDField`,
+			GoName: `ID`,
+			GoType: goTypeFor(identityType),
+			Tag:    identityField + `,identity`,
+		})
+
+		for _, field := range collection.Fields {
+			if field.Name == identityField {
+				continue
+			}
+
+			goName := goIdentifier(field.Name)
+
+			if goName == `ID` || goName == `Model` {
+				goName = goName + `Field`
+			}
+
+			goType := goTypeFor(field.Type)
+
+			if goType == `time.Time` {
+				data.ImportTime = true
+			}
+
+			gc.Fields = append(gc.Fields, generateField{
+				Name:   field.Name,
+				Const:  singular + goName + `Field`,
+				GoName: goName,
+				GoType: goType,
+				Tag:    goStructTag(field),
+			})
+		}
+
+		data.Collections = append(data.Collections, gc)
+	}
+
+	output := bytes.NewBuffer(nil)
+
+	if err := generateGoTemplate.Execute(output, data); err != nil {
+		return nil, err
+	}
+
+	if src, err := format.Source(output.Bytes()); err == nil {
+		return src, nil
+	} else {
+		return nil, fmt.Errorf("generated invalid source: %v", err)
+	}
+}
+
+func goIdentifier(name string) string {
+	name = goIdentifierInvalidChars.ReplaceAllString(name, `_`)
+	name = stringutil.Camelize(name)
+
+	if name == `` || (name[0] >= '0' && name[0] <= '9') {
+		name = `X` + name
+	}
+
+	return name
+}
+
+func goSingular(plural string) string {
+	if strings.HasSuffix(plural, `ies`) {
+		return strings.TrimSuffix(plural, `ies`) + `y`
+	} else if strings.HasSuffix(plural, `s`) && !strings.HasSuffix(plural, `ss`) {
+		return strings.TrimSuffix(plural, `s`)
+	}
+
+	return plural
+}
+
+func goTypeFor(t dal.Type) string {
+	switch t {
+	case dal.StringType:
+		return `string`
+	case dal.BooleanType:
+		return `bool`
+	case dal.IntType:
+		return `int64`
+	case dal.FloatType:
+		return `float64`
+	case dal.TimeType:
+		return `time.Time`
+	case dal.ObjectType:
+		return `map[string]interface{}`
+	case dal.RawType, dal.BytesType:
+		return `[]byte`
+	case dal.EnumType, dal.DecimalType, dal.UUIDType:
+		return `string`
+	case dal.ArrayType:
+		return `[]interface{}`
+	default:
+		return `interface{}`
+	}
+}
+
+// generates the struct tag that dal.CollectionFromStruct will read back into an equivalent field
+func goStructTag(field dal.Field) string {
+	tag := []string{field.Name}
+
+	// types that can't be inferred from the Go type need to be stated explicitly
+	if t := field.Type; t != `` && (goTypeFor(t) == `interface{}` || (t.IsExtended() && t != dal.ArrayType)) {
+		tag = append(tag, `type=`+string(t))
+	}
+
+	if field.Required {
+		tag = append(tag, `required`)
+	}
+
+	if field.Unique {
+		tag = append(tag, `unique`)
+	}
+
+	if field.Key {
+		tag = append(tag, `key`)
+	}
+
+	if field.Length > 0 {
+		tag = append(tag, fmt.Sprintf("length=%d", field.Length))
+	}
+
+	if field.Precision > 0 {
+		tag = append(tag, fmt.Sprintf("precision=%d", field.Precision))
+	}
+
+	if v := field.DefaultValue; v != nil {
+		if dv := fmt.Sprintf("%v", v); goStructTagSafe(dv) {
+			tag = append(tag, `default=`+dv)
+		}
+	}
+
+	if field.Subtype != `` {
+		tag = append(tag, `subtype=`+string(field.Subtype))
+	}
+
+	if field.KeyType != `` {
+		tag = append(tag, `keytype=`+string(field.KeyType))
+	}
+
+	if values := strings.Join(field.Values, `|`); values != `` && goStructTagSafe(values) {
+		tag = append(tag, `values=`+values)
+	}
+
+	if goStructTagSafe(field.Description) && field.Description != `` {
+		tag = append(tag, `description=`+field.Description)
+	}
+
+	return strings.Join(tag, `,`)
+}
+
+func goStructTagSafe(value string) bool {
+	return !strings.ContainsAny(value, ",\"`\n")
+}
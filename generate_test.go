@@ -0,0 +1,79 @@
+package pivot
+
+import (
+	"go/parser"
+	"go/token"
+	"regexp"
+	"testing"
+
+	"github.com/sniperkit/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateGoSource(t *testing.T) {
+	assert := require.New(t)
+
+	widgets := &dal.Collection{
+		Name:              `widgets`,
+		IdentityFieldType: dal.StringType,
+		Fields: []dal.Field{
+			{
+				Name:        `type`,
+				Description: `The type of widget.`,
+				Type:        dal.StringType,
+				Length:      32,
+				Required:    true,
+			}, {
+				Name:         `enabled`,
+				Type:         dal.BooleanType,
+				DefaultValue: true,
+			}, {
+				Name: `created_at`,
+				Type: dal.TimeType,
+			}, {
+				Name: `properties`,
+				Type: dal.ObjectType,
+			},
+		},
+	}
+
+	categories := &dal.Collection{
+		Name: `categories`,
+		Fields: []dal.Field{
+			{
+				Name:   `name`,
+				Type:   dal.StringType,
+				Unique: true,
+			},
+		},
+	}
+
+	src, err := GenerateGoSource(`models`, []*dal.Collection{widgets, categories})
+	assert.Nil(err)
+
+	// output must not depend on the order collections are given in
+	src2, err := GenerateGoSource(`models`, []*dal.Collection{categories, widgets})
+	assert.Nil(err)
+	assert.Equal(string(src), string(src2))
+
+	_, err = parser.ParseFile(token.NewFileSet(), `models.go`, src, 0)
+	assert.Nil(err)
+
+	// collapse alignment whitespace so the assertions don't depend on gofmt's column alignment
+	code := regexp.MustCompile(`[ \t]+`).ReplaceAllString(string(src), ` `)
+
+	assert.Contains(code, "package models\n")
+	assert.Contains(code, "WidgetsCollection = `widgets`")
+	assert.Contains(code, "WidgetCreatedAtField = `created_at`")
+	assert.Contains(code, "CategoryNameField = `name`")
+	assert.Contains(code, "type Widget struct {")
+	assert.Contains(code, "ID string `pivot:\"id,identity\"`")
+	assert.Contains(code, "Type string `pivot:\"type,required,length=32,description=The type of widget.\"`")
+	assert.Contains(code, "Enabled bool `pivot:\"enabled,default=true\"`")
+	assert.Contains(code, "CreatedAt time.Time `pivot:\"created_at\"`")
+	assert.Contains(code, "Properties map[string]interface{} `pivot:\"properties\"`")
+	assert.Contains(code, "type Category struct {")
+	assert.Contains(code, "ID int64 `pivot:\"id,identity\"`")
+	assert.Contains(code, "func (self *WidgetsModel) FindWidgets(flt interface{}) ([]Widget, error) {")
+	assert.Contains(code, "func (self *CategoriesModel) GetCategory(id interface{}) (*Category, error) {")
+}
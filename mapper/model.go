@@ -43,6 +43,11 @@ type Mapper interface {
 
 type Model struct {
 	Mapper
+
+	// If the backend supports it, Migrate will attempt to resolve any differences between the
+	// desired and actual schemas instead of returning an error.
+	ApplyMigrations bool
+
 	db         backends.Backend
 	collection *dal.Collection
 }
@@ -110,7 +115,25 @@ func (self *Model) Migrate() error {
 		actualCollection = c
 	}
 
-	if diffs := self.collection.Diff(actualCollection); diffs != nil {
+	diffs := self.collection.Diff(actualCollection)
+
+	// if permitted, apply the changes and compare the result again
+	if diffs != nil && self.ApplyMigrations {
+		if migratable, ok := self.db.(backends.Migratable); ok {
+			if err := migratable.Migrate(diffs); err != nil {
+				return err
+			}
+
+			if c, err := self.db.GetCollection(self.collection.Name); err == nil {
+				actualCollection = c
+				diffs = self.collection.Diff(actualCollection)
+			} else {
+				return err
+			}
+		}
+	}
+
+	if diffs != nil {
 		msg := fmt.Sprintf("Actual schema for collection '%s' differs from desired schema:\n", self.collection.Name)
 
 		for _, err := range diffs {
@@ -160,7 +183,7 @@ func (self *Model) Exists(id interface{}) bool {
 // Updates and saves an existing instance of the model from the given struct or dal.Record.
 //
 func (self *Model) Update(from interface{}) error {
-	if record, err := self.collection.MakeRecord(from); err == nil {
+	if record, err := self.collection.MakeRecordForUpdate(from); err == nil {
 		return self.db.Update(self.collection.Name, dal.NewRecordSet(record))
 	} else {
 		return err
@@ -226,3 +226,183 @@ func TestModelList(t *testing.T) {
 		},
 	}, values)
 }
+
+func TestModelApplyMigrations(t *testing.T) {
+	assert := require.New(t)
+
+	tmpfile, err := ioutil.TempFile("", "TestModelApplyMigrations")
+	assert.Nil(err)
+	defer os.Remove(tmpfile.Name())
+
+	db, err := pivot.NewDatabase(`sqlite:///` + tmpfile.Name() + `?autoregister=true`)
+	assert.Nil(err)
+
+	type ModelMigrate struct {
+		ID    int
+		Name  string `pivot:"name"`
+		Email string `pivot:"email"`
+		Size  int    `pivot:"size"`
+	}
+
+	model1 := NewModel(db, &dal.Collection{
+		Name: `model_migrate`,
+		Fields: []dal.Field{
+			{
+				Name: `name`,
+				Type: dal.StringType,
+			},
+		},
+	})
+
+	assert.Nil(model1.Migrate())
+	assert.Nil(model1.Create(&ModelMigrate{
+		ID:   1,
+		Name: `test-1`,
+	}))
+
+	model2 := NewModel(db, &dal.Collection{
+		Name: `model_migrate`,
+		Fields: []dal.Field{
+			{
+				Name:     `name`,
+				Type:     dal.StringType,
+				Required: true,
+			}, {
+				Name:    `email`,
+				Type:    dal.StringType,
+				Indexed: true,
+			}, {
+				Name:         `size`,
+				Type:         dal.IntType,
+				Required:     true,
+				DefaultValue: 0,
+			},
+		},
+	})
+
+	// without opting in, differences are still an error
+	assert.Error(model2.Migrate())
+
+	model2.ApplyMigrations = true
+	assert.Nil(model2.Migrate())
+
+	// existing data survives the migration
+	v := new(ModelMigrate)
+	assert.Nil(model2.Get(1, v))
+	assert.Equal(1, v.ID)
+	assert.Equal(`test-1`, v.Name)
+	assert.Equal(``, v.Email)
+
+	assert.Nil(model2.Create(&ModelMigrate{
+		ID:    2,
+		Name:  `test-2`,
+		Email: `test@example.com`,
+		Size:  42,
+	}))
+
+	v = new(ModelMigrate)
+	assert.Nil(model2.Get(2, v))
+	assert.Equal(`test@example.com`, v.Email)
+	assert.Equal(42, v.Size)
+
+	// nothing left to migrate, including indexes (which aren't compared)
+	assert.Nil(model2.Migrate())
+
+	model2.ApplyMigrations = false
+	assert.Nil(model2.Migrate())
+
+	// existing rows would have no value for a new required field without a default
+	model3 := NewModel(db, &dal.Collection{
+		Name: `model_migrate`,
+		Fields: []dal.Field{
+			{
+				Name:     `name`,
+				Type:     dal.StringType,
+				Required: true,
+			}, {
+				Name:    `email`,
+				Type:    dal.StringType,
+				Indexed: true,
+			}, {
+				Name:         `size`,
+				Type:         dal.IntType,
+				Required:     true,
+				DefaultValue: 0,
+			}, {
+				Name:     `age`,
+				Type:     dal.IntType,
+				Required: true,
+			},
+		},
+	})
+
+	model3.ApplyMigrations = true
+	assert.Error(model3.Migrate())
+
+	v = new(ModelMigrate)
+	assert.Nil(model2.Get(1, v))
+	assert.Equal(`test-1`, v.Name)
+	assert.Nil(model2.Migrate())
+}
+
+func TestModelFromStruct(t *testing.T) {
+	assert := require.New(t)
+
+	tmpfile, err := ioutil.TempFile("", "TestModelFromStruct")
+	assert.Nil(err)
+	defer os.Remove(tmpfile.Name())
+
+	db, err := pivot.NewDatabase(`sqlite:///` + tmpfile.Name())
+	assert.Nil(err)
+
+	type ModelStruct struct {
+		dal.Model `pivot:"model_struct"`
+		ID        int
+		Name      string `pivot:"name,required,length=64"`
+		Enabled   bool   `pivot:"enabled,omitempty"`
+		Size      int    `pivot:"size,unique"`
+	}
+
+	handwritten := &dal.Collection{
+		Name:              `model_struct`,
+		IdentityField:     `id`,
+		IdentityFieldType: dal.IntType,
+		Fields: []dal.Field{
+			{
+				Name:     `name`,
+				Type:     dal.StringType,
+				Required: true,
+				Length:   64,
+			}, {
+				Name: `enabled`,
+				Type: dal.BooleanType,
+			}, {
+				Name:   `size`,
+				Type:   dal.IntType,
+				Unique: true,
+			},
+		},
+	}
+
+	collection, err := dal.CollectionFromStruct(&ModelStruct{})
+	assert.Nil(err)
+	assert.Nil(handwritten.Diff(collection))
+	assert.Nil(collection.Diff(handwritten))
+
+	model := NewModel(db, collection)
+	assert.Nil(model.Migrate())
+
+	assert.Nil(model.Create(&ModelStruct{
+		ID:   1,
+		Name: `test-1`,
+		Size: 42,
+	}))
+
+	v := new(ModelStruct)
+	assert.Nil(model.Get(1, v))
+	assert.Equal(`test-1`, v.Name)
+	assert.Equal(42, v.Size)
+
+	_, ok := model.NewInstance().(*ModelStruct)
+	assert.True(ok)
+}
@@ -0,0 +1,544 @@
+package pivot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/log"
+	"github.com/ghodss/yaml"
+	"github.com/sniperkit/pivot/backends"
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter"
+)
+
+// The name of the collection used to record which migrations have been applied.
+var MigrationHistoryCollection = `_pivot_migrations`
+
+var registeredMigrations = make([]*Migration, 0)
+
+type MigrationFunc func(db backends.Backend) error
+
+// A single schema change or data transform to perform as part of a migration.  Exactly one of
+// Schema, Drop, Insert, Update, or Delete should be specified.
+type MigrationStep struct {
+	// Create the collection, or migrate the existing one, so that it matches this definition.
+	Schema *dal.Collection `json:"schema,omitempty"`
+
+	// Remove the named collection.
+	Drop string `json:"drop,omitempty"`
+
+	// The collection the Insert, Update, and Delete operations apply to.
+	Collection string `json:"collection,omitempty"`
+
+	// Limits Update and Delete operations to records matching this filter.
+	Filter string `json:"filter,omitempty"`
+
+	// Records to insert into the collection.
+	Insert []map[string]interface{} `json:"insert,omitempty"`
+
+	// Values to set on all matching records.
+	Update map[string]interface{} `json:"update,omitempty"`
+
+	// Delete all matching records.
+	Delete bool `json:"delete,omitempty"`
+}
+
+func (self MigrationStep) String() string {
+	if self.Schema != nil {
+		return fmt.Sprintf("schema %q", self.Schema.Name)
+	} else if self.Drop != `` {
+		return fmt.Sprintf("drop %q", self.Drop)
+	} else if len(self.Insert) > 0 {
+		return fmt.Sprintf("insert %d records into %q", len(self.Insert), self.Collection)
+	} else if len(self.Update) > 0 {
+		return fmt.Sprintf("update %q where %v: set %v", self.Collection, self.filterSpec(), self.Update)
+	} else if self.Delete {
+		return fmt.Sprintf("delete from %q where %v", self.Collection, self.filterSpec())
+	} else {
+		return `no-op`
+	}
+}
+
+func (self MigrationStep) filterSpec() string {
+	if self.Filter == `` {
+		return filter.AllValue
+	}
+
+	return self.Filter
+}
+
+// A Migration is a named, versioned set of changes that can be applied to (and optionally
+// rolled back from) a database.  Migrations are applied in order of ascending version.
+type Migration struct {
+	Version  int             `json:"version"`
+	Name     string          `json:"name"`
+	Up       []MigrationStep `json:"up,omitempty"`
+	Down     []MigrationStep `json:"down,omitempty"`
+	UpFunc   MigrationFunc   `json:"-"`
+	DownFunc MigrationFunc   `json:"-"`
+}
+
+func (self *Migration) String() string {
+	if self.Name != `` {
+		return fmt.Sprintf("%d_%s", self.Version, self.Name)
+	} else {
+		return fmt.Sprintf("%d", self.Version)
+	}
+}
+
+type MigrationStatus struct {
+	Migration *Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Registers a migration defined in Go code so that it will be included in every Migrator.
+func RegisterMigration(migration *Migration) {
+	registeredMigrations = append(registeredMigrations, migration)
+}
+
+// Loads all migrations from the JSON and YAML files in the given directory.  Files should be named
+// <version>_<name>.(json|yml|yaml); if the file does not specify a version or name, it will be
+// taken from the filename.
+func LoadMigrationsFromDir(dir string) ([]*Migration, error) {
+	migrations := make([]*Migration, 0)
+
+	if entries, err := ioutil.ReadDir(dir); err == nil {
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+
+			switch path.Ext(entry.Name()) {
+			case `.json`, `.yml`, `.yaml`:
+				if migration, err := LoadMigrationFromFile(filepath.Join(dir, entry.Name())); err == nil {
+					migrations = append(migrations, migration)
+				} else {
+					return nil, fmt.Errorf("%s: %v", entry.Name(), err)
+				}
+			}
+		}
+
+		return migrations, nil
+	} else {
+		return nil, err
+	}
+}
+
+func LoadMigrationFromFile(filename string) (*Migration, error) {
+	if data, err := ioutil.ReadFile(filename); err == nil {
+		migration := new(Migration)
+
+		switch ext := path.Ext(filename); ext {
+		case `.json`:
+			if err := json.Unmarshal(data, migration); err != nil {
+				return nil, fmt.Errorf("decode error: %v", err)
+			}
+
+		case `.yml`, `.yaml`:
+			if err := yaml.Unmarshal(data, migration); err != nil {
+				return nil, fmt.Errorf("decode error: %v", err)
+			}
+
+		default:
+			return nil, fmt.Errorf("Unrecognized file extension %s", ext)
+		}
+
+		// fill in the version and name from the filename if they weren't given
+		base := strings.TrimSuffix(filepath.Base(filename), path.Ext(filename))
+		parts := strings.SplitN(base, `_`, 2)
+
+		if migration.Version == 0 {
+			if v, err := strconv.Atoi(parts[0]); err == nil {
+				migration.Version = v
+			} else {
+				return nil, fmt.Errorf("cannot determine migration version from filename %q", filepath.Base(filename))
+			}
+		}
+
+		if migration.Name == `` && len(parts) > 1 {
+			migration.Name = parts[1]
+		}
+
+		return migration, nil
+	} else {
+		return nil, err
+	}
+}
+
+// A Migrator applies and rolls back migrations against a database, recording the versions that
+// have been applied in the MigrationHistoryCollection.  Because schema changes are determined by
+// comparing the desired schema against what actually exists, backends that support it should be
+// connected with the "autoregister" option enabled.
+type Migrator struct {
+	// Describe the changes that would be made instead of making them.
+	DryRun bool
+
+	// Where dry run output is written to (defaults to os.Stdout)
+	Output io.Writer
+
+	db         backends.Backend
+	migrations []*Migration
+	history    *dal.Collection
+}
+
+func NewMigrator(db backends.Backend, migrations ...*Migration) *Migrator {
+	all := append(append([]*Migration{}, registeredMigrations...), migrations...)
+
+	sort.SliceStable(all, func(i int, j int) bool {
+		return all[i].Version < all[j].Version
+	})
+
+	return &Migrator{
+		Output:     os.Stdout,
+		db:         db,
+		migrations: all,
+		history: &dal.Collection{
+			Name:              MigrationHistoryCollection,
+			IdentityField:     `version`,
+			IdentityFieldType: dal.IntType,
+			Fields: []dal.Field{
+				{
+					Name: `name`,
+					Type: dal.StringType,
+				}, {
+					Name: `applied_at`,
+					Type: dal.TimeType,
+				},
+			},
+		},
+	}
+}
+
+// Returns all known migrations and whether they have been applied.
+func (self *Migrator) Status() ([]MigrationStatus, error) {
+	statuses := make([]MigrationStatus, 0)
+
+	if err := self.verify(); err != nil {
+		return nil, err
+	}
+
+	exists, err := self.historyExists()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, migration := range self.migrations {
+		status := MigrationStatus{
+			Migration: migration,
+		}
+
+		if exists {
+			if record, err := self.db.Retrieve(MigrationHistoryCollection, migration.Version); err == nil {
+				status.Applied = true
+
+				if v, ok := self.history.ConvertValue(`applied_at`, record.Get(`applied_at`)).(time.Time); ok {
+					status.AppliedAt = v
+				}
+			} else if !dal.IsNotExistError(err) {
+				return nil, err
+			}
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// Applies all pending migrations up to and including the given version.  If version is zero, all
+// pending migrations are applied.
+func (self *Migrator) Up(version int) error {
+	if statuses, err := self.Status(); err == nil {
+		for _, status := range statuses {
+			if status.Applied {
+				continue
+			}
+
+			if version > 0 && status.Migration.Version > version {
+				break
+			}
+
+			if err := self.apply(status.Migration, true); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+// Rolls back the given number of most recently applied migrations.
+func (self *Migrator) Down(count int) error {
+	if statuses, err := self.Status(); err == nil {
+		for i := len(statuses) - 1; i >= 0 && count > 0; i-- {
+			if !statuses[i].Applied {
+				continue
+			}
+
+			if err := self.apply(statuses[i].Migration, false); err != nil {
+				return err
+			}
+
+			count -= 1
+		}
+
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (self *Migrator) verify() error {
+	versions := make(map[int]bool)
+
+	for _, migration := range self.migrations {
+		if _, ok := versions[migration.Version]; ok {
+			return fmt.Errorf("Duplicate migration version %d", migration.Version)
+		} else {
+			versions[migration.Version] = true
+		}
+	}
+
+	return nil
+}
+
+func (self *Migrator) historyExists() (bool, error) {
+	self.db.RegisterCollection(self.history)
+
+	if _, err := self.db.GetCollection(MigrationHistoryCollection); err == nil {
+		self.db.RegisterCollection(self.history)
+		return true, nil
+	} else if dal.IsCollectionNotFoundErr(err) {
+		return false, nil
+	} else {
+		return false, err
+	}
+}
+
+func (self *Migrator) apply(migration *Migration, up bool) error {
+	var steps []MigrationStep
+	var fn MigrationFunc
+
+	if up {
+		steps = migration.Up
+		fn = migration.UpFunc
+		self.printf("migrating up: %v\n", migration)
+	} else {
+		steps = migration.Down
+		fn = migration.DownFunc
+		self.printf("migrating down: %v\n", migration)
+
+		if len(steps) == 0 && fn == nil {
+			return fmt.Errorf("Migration %v cannot be rolled back", migration)
+		}
+	}
+
+	for _, step := range steps {
+		if err := self.applyStep(step); err != nil {
+			return fmt.Errorf("Migration %v failed at step %v: %v", migration, step, err)
+		}
+	}
+
+	if fn != nil {
+		if self.DryRun {
+			self.printf("  run function\n")
+		} else if err := fn(self.db); err != nil {
+			return fmt.Errorf("Migration %v failed: %v", migration, err)
+		}
+	}
+
+	return self.record(migration, up)
+}
+
+func (self *Migrator) applyStep(step MigrationStep) error {
+	self.printf("  %v\n", step)
+
+	if step.Schema != nil {
+		return self.applySchema(step.Schema)
+	}
+
+	if self.DryRun {
+		return nil
+	}
+
+	if step.Drop != `` {
+		return self.db.DeleteCollection(step.Drop)
+	}
+
+	if step.Collection == `` {
+		return fmt.Errorf("must specify a collection")
+	}
+
+	collection, err := self.db.GetCollection(step.Collection)
+
+	if err != nil {
+		return err
+	}
+
+	f, err := filter.Parse(step.filterSpec())
+
+	if err != nil {
+		return err
+	}
+
+	if len(step.Insert) > 0 {
+		recordset := dal.NewRecordSet()
+
+		for _, values := range step.Insert {
+			fields := make(map[string]interface{})
+
+			for k, v := range values {
+				fields[k] = v
+			}
+
+			record := dal.NewRecord(fields[collection.IdentityField])
+			delete(fields, collection.IdentityField)
+			record.SetFields(fields)
+
+			if r, err := collection.MakeRecord(record); err == nil {
+				recordset.Push(r)
+			} else {
+				return err
+			}
+		}
+
+		return self.db.Insert(step.Collection, recordset)
+	}
+
+	if search := self.db.WithSearch(collection); search != nil {
+		if len(step.Update) > 0 {
+			recordset := dal.NewRecordSet()
+
+			if err := search.QueryFunc(collection, f, func(record *dal.Record, err error, _ backends.IndexPage) error {
+				if err == nil {
+					record.SetFields(step.Update)
+					recordset.Push(record)
+				}
+
+				return err
+			}); err != nil {
+				return err
+			}
+
+			if len(recordset.Records) > 0 {
+				return self.db.Update(step.Collection, recordset)
+			}
+
+			return nil
+		} else if step.Delete {
+			return search.DeleteQuery(collection, f)
+		}
+	} else {
+		return fmt.Errorf("collection %q is not searchable", step.Collection)
+	}
+
+	return nil
+}
+
+// creates the given collection if it does not exist, or migrates the existing collection to
+// match the definition.
+func (self *Migrator) applySchema(definition *dal.Collection) error {
+	if definition.IdentityField == `` {
+		definition.IdentityField = dal.DefaultIdentityField
+	}
+
+	if definition.IdentityFieldType == `` {
+		definition.IdentityFieldType = dal.DefaultIdentityFieldType
+	}
+
+	if actual, err := self.db.GetCollection(definition.Name); err == nil {
+		diff := definition.Diff(actual)
+
+		if len(diff) == 0 {
+			return nil
+		}
+
+		for _, delta := range diff {
+			self.printf("    %v\n", delta)
+		}
+
+		if migratable, ok := self.db.(backends.Migratable); ok {
+			if self.DryRun {
+				if planner, ok := self.db.(backends.MigrationPlanner); ok {
+					if stmts, err := planner.MigrationStatements(diff); err == nil {
+						for _, stmt := range stmts {
+							self.printf("    %s;\n", stmt)
+						}
+					} else {
+						return err
+					}
+				}
+
+				return nil
+			}
+
+			return migratable.Migrate(diff)
+		} else {
+			return fmt.Errorf("backend %T does not support schema migrations", self.db)
+		}
+	} else if dal.IsCollectionNotFoundErr(err) {
+		self.printf("    create collection %q\n", definition.Name)
+
+		if self.DryRun {
+			return nil
+		}
+
+		return self.db.CreateCollection(definition)
+	} else {
+		return err
+	}
+}
+
+func (self *Migrator) record(migration *Migration, up bool) error {
+	if self.DryRun {
+		if up {
+			self.printf("  record version %d\n", migration.Version)
+		} else {
+			self.printf("  remove version %d\n", migration.Version)
+		}
+
+		return nil
+	}
+
+	if exists, err := self.historyExists(); err == nil && !exists {
+		if err := self.db.CreateCollection(self.history); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if up {
+		log.Infof("Applied migration %v", migration)
+
+		return self.db.Insert(MigrationHistoryCollection, dal.NewRecordSet(
+			dal.NewRecord(migration.Version).SetFields(map[string]interface{}{
+				`name`:       migration.Name,
+				`applied_at`: time.Now(),
+			}),
+		))
+	} else {
+		log.Infof("Rolled back migration %v", migration)
+
+		return self.db.Delete(MigrationHistoryCollection, migration.Version)
+	}
+}
+
+func (self *Migrator) printf(format string, args ...interface{}) {
+	if self.DryRun && self.Output != nil {
+		fmt.Fprintf(self.Output, format, args...)
+	}
+}
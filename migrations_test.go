@@ -0,0 +1,141 @@
+package pivot
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sniperkit/pivot/backends"
+	"github.com/sniperkit/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrations(t *testing.T) {
+	assert := require.New(t)
+
+	tmpdir, err := ioutil.TempDir("", "TestMigrations")
+	assert.Nil(err)
+	defer os.RemoveAll(tmpdir)
+
+	db, err := NewDatabase(`sqlite:///` + filepath.Join(tmpdir, `test.db`) + `?autoregister=true`)
+	assert.Nil(err)
+
+	assert.Nil(ioutil.WriteFile(filepath.Join(tmpdir, `1_create_users.yml`), []byte(`
+up:
+- schema:
+    name:   migrate_users
+    fields:
+    - name: name
+      type: str
+- collection: migrate_users
+  insert:
+  - id:   1
+    name: alice
+- collection: migrate_users
+  insert:
+  - id:   2
+    name: bob
+down:
+- drop: migrate_users
+`), 0644))
+
+	assert.Nil(ioutil.WriteFile(filepath.Join(tmpdir, `2_add_role.yml`), []byte(`
+up:
+- schema:
+    name:   migrate_users
+    fields:
+    - name: name
+      type: str
+    - name: role
+      type: str
+- collection: migrate_users
+  update:
+    role: admin
+  filter: name/alice
+down:
+- collection: migrate_users
+  update:
+    role: ''
+`), 0644))
+
+	migrations, err := LoadMigrationsFromDir(tmpdir)
+	assert.Nil(err)
+	assert.Len(migrations, 2)
+	assert.Equal(1, migrations[0].Version)
+	assert.Equal(`create_users`, migrations[0].Name)
+
+	var upFuncCalled bool
+
+	migrations = append(migrations, &Migration{
+		Version: 3,
+		Name:    `from_code`,
+		UpFunc: func(db backends.Backend) error {
+			upFuncCalled = true
+			return nil
+		},
+	})
+
+	migrator := NewMigrator(db, migrations...)
+
+	statuses, err := migrator.Status()
+	assert.Nil(err)
+	assert.Len(statuses, 3)
+
+	for _, status := range statuses {
+		assert.False(status.Applied)
+	}
+
+	// apply the first migration only
+	assert.Nil(migrator.Up(1))
+
+	statuses, err = migrator.Status()
+	assert.Nil(err)
+	assert.True(statuses[0].Applied)
+	assert.False(statuses[0].AppliedAt.IsZero())
+	assert.False(statuses[1].Applied)
+
+	// dry run the rest: nothing should change
+	output := bytes.NewBuffer(nil)
+	migrator.DryRun = true
+	migrator.Output = output
+
+	assert.Nil(migrator.Up(0))
+	assert.Contains(output.String(), `migrating up: 2_add_role`)
+	assert.Contains(output.String(), `ADD COLUMN "role"`)
+	assert.False(upFuncCalled)
+
+	statuses, err = migrator.Status()
+	assert.Nil(err)
+	assert.False(statuses[1].Applied)
+
+	// apply the rest for real
+	migrator.DryRun = false
+	assert.Nil(migrator.Up(0))
+	assert.True(upFuncCalled)
+
+	record, err := db.Retrieve(`migrate_users`, 1)
+	assert.Nil(err)
+	assert.Equal(`admin`, record.Get(`role`))
+
+	record, err = db.Retrieve(`migrate_users`, 2)
+	assert.Nil(err)
+	assert.Nil(record.Get(`role`))
+
+	statuses, err = migrator.Status()
+	assert.Nil(err)
+
+	for _, status := range statuses {
+		assert.True(status.Applied)
+	}
+
+	// migration 3 has no down steps, so it cannot be rolled back
+	assert.Error(migrator.Down(1))
+
+	migrator = NewMigrator(db, migrations[:2]...)
+	assert.Nil(migrator.Down(2))
+
+	_, err = db.GetCollection(`migrate_users`)
+	assert.True(dal.IsCollectionNotFoundErr(err))
+}
@@ -0,0 +1,290 @@
+package pivot
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ghetzel/go-stockutil/stringutil"
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/util"
+)
+
+var OpenAPIVersion = `3.1.0`
+
+// Generates an OpenAPI 3 document describing the REST API exposed by Server for each of the given
+// collections.
+func GenerateOpenAPI(collections []*dal.Collection) map[string]interface{} {
+	schemas := map[string]interface{}{
+		`Error`: map[string]interface{}{
+			`type`: `object`,
+			`properties`: map[string]interface{}{
+				`error`: map[string]interface{}{
+					`type`: `string`,
+				},
+			},
+		},
+		`Collection`: map[string]interface{}{
+			`type`:        `object`,
+			`description`: `A collection schema definition.`,
+		},
+	}
+
+	paths := map[string]interface{}{
+		`/api/status`: map[string]interface{}{
+			`get`: openapiOperation(`getStatus`, `Retrieve the backend and indexer connection details.`, nil, nil, openapiJSON(map[string]interface{}{
+				`type`: `object`,
+			})),
+		},
+		`/api/schema`: map[string]interface{}{
+			`get`: openapiOperation(`listCollections`, `List the names of all collections.`, nil, nil, openapiJSON(map[string]interface{}{
+				`type`: `array`,
+				`items`: map[string]interface{}{
+					`type`: `string`,
+				},
+			})),
+			`post`: openapiOperation(`createCollections`, `Create one or more collections.`, nil, openapiJSON(openapiRef(`Collection`)), openapiJSON(openapiRef(`Collection`))),
+		},
+		`/api/openapi.json`: map[string]interface{}{
+			`get`: openapiOperation(`getOpenAPI`, `Retrieve this document.`, nil, nil, openapiJSON(map[string]interface{}{
+				`type`: `object`,
+			})),
+		},
+	}
+
+	sorted := append([]*dal.Collection{}, collections...)
+
+	sort.SliceStable(sorted, func(i int, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	for _, collection := range sorted {
+		name := collection.Name
+		recordName := name + `Record`
+		recordSetName := name + `RecordSet`
+		base := `/api/collections/` + name
+
+		schemas[name] = openapiCollectionSchema(collection)
+		schemas[recordName] = openapiRecordSchema(collection)
+		schemas[recordSetName] = map[string]interface{}{
+			`type`: `object`,
+			`properties`: map[string]interface{}{
+				`result_count`: map[string]interface{}{
+					`type`: `integer`,
+				},
+				`page`: map[string]interface{}{
+					`type`: `integer`,
+				},
+				`total_pages`: map[string]interface{}{
+					`type`: `integer`,
+				},
+				`records_per_page`: map[string]interface{}{
+					`type`: `integer`,
+				},
+				`records`: map[string]interface{}{
+					`type`:  `array`,
+					`items`: openapiRef(recordName),
+				},
+			},
+		}
+
+		opId := func(verb string) string {
+			return verb + stringutil.Camelize(name)
+		}
+
+		queryParams := []interface{}{
+			openapiParam(`limit`, `query`, `The maximum number of results to return.`, `integer`, false),
+			openapiParam(`offset`, `query`, `The number of results to skip.`, `integer`, false),
+			openapiParam(`sort`, `query`, `A comma-separated list of fields to sort by; prefix a field with "-" to sort descending.`, `string`, false),
+			openapiParam(`fields`, `query`, `A comma-separated list of fields to return.`, `string`, false),
+		}
+
+		paths[base] = map[string]interface{}{
+			`get`:  openapiOperation(opId(`get`), fmt.Sprintf("Retrieve the schema of the %s collection.", name), nil, nil, openapiJSON(openapiRef(`Collection`))),
+			`post`: openapiOperation(opId(`insert`), fmt.Sprintf("Insert records into the %s collection.", name), nil, openapiJSON(openapiRef(recordSetName)), nil),
+			`put`:  openapiOperation(opId(`update`), fmt.Sprintf("Update records in the %s collection.", name), nil, openapiJSON(openapiRef(recordSetName)), nil),
+		}
+
+		paths[base+`/query/`] = map[string]interface{}{
+			`get`: openapiOperation(opId(`query`), fmt.Sprintf("Query all records in the %s collection.", name), queryParams, nil, openapiJSON(openapiRef(recordSetName))),
+			`post`: openapiOperation(opId(`queryByMap`), fmt.Sprintf("Query records in the %s collection using a map of field criteria.", name), queryParams, openapiJSON(map[string]interface{}{
+				`type`: `object`,
+			}), openapiJSON(openapiRef(recordSetName))),
+		}
+
+		paths[base+`/where/{query}`] = map[string]interface{}{
+			`get`: openapiOperation(opId(`where`), fmt.Sprintf("Query records in the %s collection using a filter expression.", name), append([]interface{}{
+				openapiParam(`query`, `path`, `A filter expression.`, `string`, true),
+			}, queryParams...), nil, openapiJSON(openapiRef(recordSetName))),
+			`delete`: openapiOperation(opId(`deleteWhere`), fmt.Sprintf("Delete records in the %s collection matching a filter expression.", name), []interface{}{
+				openapiParam(`query`, `path`, `A filter expression.`, `string`, true),
+			}, nil, nil),
+		}
+
+		paths[base+`/aggregate/{fields}`] = map[string]interface{}{
+			`get`: openapiOperation(opId(`aggregate`), fmt.Sprintf("Aggregate values of fields in the %s collection.", name), []interface{}{
+				openapiParam(`fields`, `path`, `A comma-separated list of fields to aggregate.`, `string`, true),
+				openapiParam(`fn`, `query`, `A comma-separated list of aggregations (count, sum, min, max, avg).`, `string`, false),
+				openapiParam(`q`, `query`, `A filter expression.`, `string`, false),
+			}, nil, openapiJSON(map[string]interface{}{
+				`type`: `object`,
+			})),
+		}
+
+		paths[base+`/list/{fields}`] = map[string]interface{}{
+			`get`: openapiOperation(opId(`list`), fmt.Sprintf("List the distinct values of fields in the %s collection.", name), []interface{}{
+				openapiParam(`fields`, `path`, `A slash-separated list of fields.`, `string`, true),
+				openapiParam(`q`, `query`, `A filter expression.`, `string`, false),
+			}, nil, openapiJSON(map[string]interface{}{
+				`type`: `object`,
+				`additionalProperties`: map[string]interface{}{
+					`type`: `array`,
+				},
+			})),
+		}
+
+		paths[base+`/group/{fields}`] = map[string]interface{}{
+			`get`: openapiOperation(opId(`group`), fmt.Sprintf("Aggregate values of fields in the %s collection, grouped by the values of other fields.", name), append([]interface{}{
+				openapiParam(`fields`, `path`, `A slash-separated list of fields to group by.`, `string`, true),
+				openapiParam(`fn`, `query`, `A comma-separated list of aggregates, each an aggregation and a field (e.g.: "sum:amount").`, `string`, false),
+				openapiParam(`q`, `query`, `A filter expression, which may include criteria on the aggregated values.`, `string`, false),
+			}, queryParams...), nil, openapiJSON(map[string]interface{}{
+				`type`: `object`,
+			})),
+		}
+
+		paths[base+`/records`] = map[string]interface{}{
+			`post`: openapiOperation(opId(`createRecords`), fmt.Sprintf("Create records in the %s collection.", name), nil, openapiJSON(openapiRef(recordSetName)), openapiJSON(openapiRef(recordSetName))),
+			`put`:  openapiOperation(opId(`updateRecords`), fmt.Sprintf("Update records in the %s collection.", name), nil, openapiJSON(openapiRef(recordSetName)), nil),
+		}
+
+		idParam := openapiParam(`id`, `path`, `The record ID.`, `string`, true)
+
+		paths[base+`/records/{id}`] = map[string]interface{}{
+			`get`: openapiOperation(opId(`getRecord`), fmt.Sprintf("Retrieve a record from the %s collection.", name), []interface{}{
+				idParam,
+				openapiParam(`fields`, `query`, `A comma-separated list of fields to return.`, `string`, false),
+			}, nil, openapiJSON(openapiRef(recordName))),
+			`post`:   openapiOperation(opId(`saveRecord`), fmt.Sprintf("Create or update a record in the %s collection.", name), []interface{}{idParam}, openapiJSON(openapiRef(recordName)), openapiJSON(openapiRef(recordName))),
+			`delete`: openapiOperation(opId(`deleteRecord`), fmt.Sprintf("Delete a record from the %s collection.", name), []interface{}{idParam}, nil, nil),
+		}
+
+		paths[`/api/schema/`+name] = map[string]interface{}{
+			`get`:    openapiOperation(opId(`getSchema`), fmt.Sprintf("Retrieve the schema of the %s collection.", name), nil, nil, openapiJSON(openapiRef(`Collection`))),
+			`delete`: openapiOperation(opId(`deleteSchema`), fmt.Sprintf("Delete the %s collection.", name), nil, nil, nil),
+		}
+	}
+
+	return map[string]interface{}{
+		`openapi`: OpenAPIVersion,
+		`info`: map[string]interface{}{
+			`title`:       util.ApplicationName,
+			`description`: util.ApplicationSummary,
+			`version`:     util.ApplicationVersion,
+		},
+		`jsonSchemaDialect`: dal.JSONSchemaDialect,
+		`paths`:             paths,
+		`components`: map[string]interface{}{
+			`schemas`: schemas,
+		},
+	}
+}
+
+// the collection's JSON Schema, minus the keywords that are set at the document level
+func openapiCollectionSchema(collection *dal.Collection) map[string]interface{} {
+	schema := collection.JSONSchema()
+	delete(schema, `$schema`)
+	return schema
+}
+
+// records are serialized with the identity separate from the other fields
+func openapiRecordSchema(collection *dal.Collection) map[string]interface{} {
+	flat := openapiCollectionSchema(collection)
+	properties := flat[`properties`].(map[string]interface{})
+	id := properties[collection.IdentityField]
+	fields := make(map[string]interface{})
+
+	for k, v := range properties {
+		if k != collection.IdentityField {
+			fields[k] = v
+		}
+	}
+
+	fieldsSchema := map[string]interface{}{
+		`type`:       `object`,
+		`properties`: fields,
+	}
+
+	if required, ok := flat[`required`]; ok {
+		fieldsSchema[`required`] = required
+	}
+
+	return map[string]interface{}{
+		`type`: `object`,
+		`properties`: map[string]interface{}{
+			`id`:     id,
+			`fields`: fieldsSchema,
+		},
+	}
+}
+
+func openapiOperation(id string, summary string, params []interface{}, body interface{}, response interface{}) map[string]interface{} {
+	op := map[string]interface{}{
+		`operationId`: id,
+		`summary`:     summary,
+	}
+
+	if len(params) > 0 {
+		op[`parameters`] = params
+	}
+
+	if body != nil {
+		op[`requestBody`] = map[string]interface{}{
+			`required`: true,
+			`content`:  body,
+		}
+	}
+
+	success := map[string]interface{}{
+		`description`: `Success`,
+	}
+
+	if response != nil {
+		success[`content`] = response
+	}
+
+	op[`responses`] = map[string]interface{}{
+		`200`: success,
+		`default`: map[string]interface{}{
+			`description`: `Error`,
+			`content`:     openapiJSON(openapiRef(`Error`)),
+		},
+	}
+
+	return op
+}
+
+func openapiParam(name string, in string, description string, datatype string, required bool) map[string]interface{} {
+	return map[string]interface{}{
+		`name`:        name,
+		`in`:          in,
+		`description`: description,
+		`required`:    required,
+		`schema`: map[string]interface{}{
+			`type`: datatype,
+		},
+	}
+}
+
+func openapiJSON(schema interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		`application/json`: map[string]interface{}{
+			`schema`: schema,
+		},
+	}
+}
+
+func openapiRef(name string) map[string]interface{} {
+	return map[string]interface{}{
+		`$ref`: `#/components/schemas/` + name,
+	}
+}
@@ -0,0 +1,70 @@
+package pivot
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sniperkit/pivot/dal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateOpenAPI(t *testing.T) {
+	assert := require.New(t)
+
+	doc := GenerateOpenAPI([]*dal.Collection{
+		{
+			Name:              `widgets`,
+			IdentityField:     `id`,
+			IdentityFieldType: dal.StringType,
+			Fields: []dal.Field{
+				{
+					Name:     `type`,
+					Type:     dal.StringType,
+					Required: true,
+				},
+			},
+		},
+	})
+
+	_, err := json.Marshal(doc)
+	assert.Nil(err)
+
+	assert.Equal(OpenAPIVersion, doc[`openapi`])
+
+	paths := doc[`paths`].(map[string]interface{})
+
+	for _, path := range []string{
+		`/api/status`,
+		`/api/schema`,
+		`/api/openapi.json`,
+		`/api/collections/widgets`,
+		`/api/collections/widgets/query/`,
+		`/api/collections/widgets/where/{query}`,
+		`/api/collections/widgets/aggregate/{fields}`,
+		`/api/collections/widgets/list/{fields}`,
+		`/api/collections/widgets/group/{fields}`,
+		`/api/collections/widgets/records`,
+		`/api/collections/widgets/records/{id}`,
+		`/api/schema/widgets`,
+	} {
+		assert.Contains(paths, path)
+	}
+
+	record := paths[`/api/collections/widgets/records/{id}`].(map[string]interface{})
+	assert.Contains(record, `get`)
+	assert.Contains(record, `post`)
+	assert.Contains(record, `delete`)
+	assert.Equal(`getRecordWidgets`, record[`get`].(map[string]interface{})[`operationId`])
+
+	schemas := doc[`components`].(map[string]interface{})[`schemas`].(map[string]interface{})
+	assert.Contains(schemas, `widgets`)
+	assert.Contains(schemas, `widgetsRecord`)
+	assert.Contains(schemas, `widgetsRecordSet`)
+
+	recordSchema := schemas[`widgetsRecord`].(map[string]interface{})[`properties`].(map[string]interface{})
+	assert.Equal(map[string]interface{}{
+		`type`: `string`,
+	}, recordSchema[`id`])
+
+	assert.Equal([]string{`type`}, recordSchema[`fields`].(map[string]interface{})[`required`])
+}
@@ -4,10 +4,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"os"
 	"path"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/ghetzel/go-stockutil/log"
+	"github.com/ghetzel/go-stockutil/maputil"
 	"github.com/ghodss/yaml"
 	"github.com/sniperkit/pivot/backends"
 	"github.com/sniperkit/pivot/dal"
@@ -63,31 +66,118 @@ func NewDatabase(connection string) (backends.Backend, error) {
 	return NewDatabaseWithOptions(connection, backends.ConnectOptions{})
 }
 
+// Loads collection definitions from the given JSON or YAML file.  The file may contain either an
+// array of collection definitions or a JSON Schema document; in the latter case, any parts of the
+// schema that cannot be represented are logged as warnings.
 func LoadSchemataFromFile(filename string) ([]*dal.Collection, error) {
-	if file, err := os.Open(filename); err == nil {
+	if data, err := readSchemaFile(filename); err == nil {
+		if isJSONSchema(data) {
+			collections, warnings, err := LoadJSONSchemaFromFile(filename)
+
+			for _, warning := range warnings {
+				log.Warningf("%v: %v", filename, warning)
+			}
+
+			return collections, err
+		}
+
 		var collections []*dal.Collection
 
-		switch ext := path.Ext(filename); ext {
-		case `.json`:
-			if err := json.NewDecoder(file).Decode(&collections); err != nil {
-				return nil, fmt.Errorf("decode error: %v", err)
+		if err := json.Unmarshal(data, &collections); err != nil {
+			return nil, fmt.Errorf("decode error: %v", err)
+		}
+
+		return collections, nil
+	} else {
+		return nil, err
+	}
+}
+
+// Loads collection definitions from a JSON Schema document in the given JSON or YAML file.  The
+// top-level schema becomes a collection named after its title (or the filename), and each object
+// schema under "$defs" or "definitions" becomes a collection named after its key.  Constructs that
+// cannot be represented in a collection definition are returned as warnings.
+func LoadJSONSchemaFromFile(filename string) ([]*dal.Collection, []string, error) {
+	if data, err := readSchemaFile(filename); err == nil {
+		var schema map[string]interface{}
+
+		if err := json.Unmarshal(data, &schema); err != nil {
+			return nil, nil, fmt.Errorf("decode error: %v", err)
+		}
+
+		collections := make([]*dal.Collection, 0)
+		warnings := make([]string, 0)
+
+		if _, ok := schema[`properties`]; ok {
+			name := ``
+
+			if _, ok := schema[`title`]; !ok {
+				name = strings.TrimSuffix(path.Base(filename), path.Ext(filename))
 			}
 
-		case `.yml`, `.yaml`:
-			if data, err := ioutil.ReadAll(file); err == nil {
-				if err := yaml.Unmarshal(data, &collections); err != nil {
-					return nil, fmt.Errorf("decode error: %v", err)
-				}
+			if collection, w, err := dal.CollectionFromJSONSchema(name, schema); err == nil {
+				collections = append(collections, collection)
+				warnings = append(warnings, w...)
 			} else {
-				return nil, err
+				return nil, nil, err
 			}
+		}
+
+		for _, key := range []string{`$defs`, `definitions`} {
+			if defs, ok := schema[key].(map[string]interface{}); ok {
+				names := maputil.StringKeys(defs)
+				sort.Strings(names)
 
+				for _, name := range names {
+					if def, ok := defs[name].(map[string]interface{}); ok {
+						if collection, w, err := dal.CollectionFromJSONSchema(name, def); err == nil {
+							collections = append(collections, collection)
+							warnings = append(warnings, w...)
+						} else {
+							warnings = append(warnings, fmt.Sprintf("%s/%s: %v", key, name, err))
+						}
+					}
+				}
+			}
+		}
+
+		return collections, warnings, nil
+	} else {
+		return nil, nil, err
+	}
+}
+
+// reads a schema file, converting YAML to JSON so that both can be decoded the same way
+func readSchemaFile(filename string) ([]byte, error) {
+	if data, err := ioutil.ReadFile(filename); err == nil {
+		switch ext := path.Ext(filename); ext {
+		case `.json`:
+			return data, nil
+		case `.yml`, `.yaml`:
+			if data, err := yaml.YAMLToJSON(data); err == nil {
+				return data, nil
+			} else {
+				return nil, fmt.Errorf("decode error: %v", err)
+			}
 		default:
 			return nil, fmt.Errorf("Unrecognized file extension %s", ext)
 		}
-
-		return collections, nil
 	} else {
 		return nil, err
 	}
 }
+
+// JSON Schema documents are objects, whereas collection definitions are stored as an array
+func isJSONSchema(data []byte) bool {
+	var schema map[string]interface{}
+
+	if err := json.Unmarshal(data, &schema); err == nil {
+		for _, key := range []string{`$schema`, `properties`, `$defs`, `definitions`} {
+			if _, ok := schema[key]; ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
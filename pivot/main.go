@@ -2,9 +2,11 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/user"
 	"path/filepath"
+	"time"
 
 	"github.com/ghetzel/cli"
 	"github.com/ghetzel/go-stockutil/log"
@@ -40,7 +42,7 @@ func main() {
 		},
 		cli.StringSliceFlag{
 			Name:  `schema, s`,
-			Usage: `Path to one or more schema files to load`,
+			Usage: `Path to one or more schema files (collection definitions or JSON Schema) to load`,
 		},
 		cli.BoolTFlag{
 			Name:  `allow-netrc, N`,
@@ -234,11 +236,164 @@ func main() {
 				}
 			},
 		},
+		{
+			Name:  `migrate`,
+			Usage: `Apply, roll back, or inspect versioned schema migrations.`,
+			Subcommands: []cli.Command{
+				{
+					Name:      `up`,
+					Usage:     `Apply all pending migrations.`,
+					ArgsUsage: `[CONNECTION_STRING]`,
+					Flags: append([]cli.Flag{
+						cli.IntFlag{
+							Name:  `to, t`,
+							Usage: `Only apply migrations up to and including this version.`,
+						},
+					}, migrateFlags...),
+					Action: func(c *cli.Context) {
+						if err := migratorFromContext(c).Up(c.Int(`to`)); err != nil {
+							log.Fatalf("Migration failed: %v", err)
+						}
+					},
+				}, {
+					Name:      `down`,
+					Usage:     `Roll back the most recently applied migrations.`,
+					ArgsUsage: `[CONNECTION_STRING]`,
+					Flags: append([]cli.Flag{
+						cli.IntFlag{
+							Name:  `steps, s`,
+							Usage: `The number of migrations to roll back.`,
+							Value: 1,
+						},
+					}, migrateFlags...),
+					Action: func(c *cli.Context) {
+						if err := migratorFromContext(c).Down(c.Int(`steps`)); err != nil {
+							log.Fatalf("Rollback failed: %v", err)
+						}
+					},
+				}, {
+					Name:      `status`,
+					Usage:     `List all migrations and whether they have been applied.`,
+					ArgsUsage: `[CONNECTION_STRING]`,
+					Flags:     migrateFlags,
+					Action: func(c *cli.Context) {
+						if statuses, err := migratorFromContext(c).Status(); err == nil {
+							for _, status := range statuses {
+								if status.Applied {
+									fmt.Printf("%-8s %-40v %v\n", `applied`, status.Migration, status.AppliedAt.Format(time.RFC3339))
+								} else {
+									fmt.Printf("%-8s %-40v\n", `pending`, status.Migration)
+								}
+							}
+						} else {
+							log.Fatalf("Failed to retrieve migration status: %v", err)
+						}
+					},
+				},
+			},
+		},
+		{
+			Name:      `generate`,
+			Usage:     `Generate Go structs and typed models from schema files.`,
+			ArgsUsage: `[SCHEMA_FILE ...]`,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  `package, p`,
+					Usage: `The name of the Go package the generated code belongs to.`,
+					Value: `models`,
+				},
+				cli.StringFlag{
+					Name:  `output, o`,
+					Usage: `The file to write generated code to (defaults to standard output).`,
+				},
+			},
+			Action: func(c *cli.Context) {
+				collections := make([]*dal.Collection, 0)
+
+				for _, filename := range append(c.GlobalStringSlice(`schema`), c.Args()...) {
+					if schemata, err := pivot.LoadSchemataFromFile(filename); err == nil {
+						collections = append(collections, schemata...)
+					} else {
+						log.Fatalf("Failed to load schema file %s: %v", filename, err)
+					}
+				}
+
+				if len(collections) == 0 {
+					log.Fatalf("Must specify at least one schema file.")
+				}
+
+				if src, err := pivot.GenerateGoSource(c.String(`package`), collections); err == nil {
+					if output := c.String(`output`); output != `` {
+						if err := ioutil.WriteFile(output, src, 0644); err != nil {
+							log.Fatalf("Failed to write output: %v", err)
+						}
+					} else {
+						os.Stdout.Write(src)
+					}
+				} else {
+					log.Fatalf("Failed to generate code: %v", err)
+				}
+			},
+		},
 	}
 
 	app.Run(os.Args)
 }
 
+var migrateFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  `dir, d`,
+		Usage: `The directory containing migration files.`,
+		Value: `migrations`,
+	},
+	cli.BoolFlag{
+		Name:  `dry-run, n`,
+		Usage: `Print the changes that would be made without making them.`,
+	},
+}
+
+func migratorFromContext(c *cli.Context) *pivot.Migrator {
+	var backend string
+	var config pivot.Configuration
+
+	if cnf, err := pivot.LoadConfigFile(c.GlobalString(`config`)); err == nil {
+		config = cnf.ForEnv(os.Getenv(`PIVOT_ENV`))
+	} else if !os.IsNotExist(err) {
+		log.Fatalf("Configuration error: %v", err)
+	}
+
+	if v := c.Args().Get(0); v != `` {
+		backend = v
+	} else {
+		backend = config.Backend
+	}
+
+	if backend == `` {
+		log.Fatalf("Must specify a backend to connect to.")
+	}
+
+	// migrations need to see the schema as it actually exists in the database
+	backends.DefaultAutoregister = true
+
+	if db, err := pivot.NewDatabase(backend); err == nil {
+		var migrations []*pivot.Migration
+
+		if m, err := pivot.LoadMigrationsFromDir(c.String(`dir`)); err == nil {
+			migrations = m
+		} else if !os.IsNotExist(err) {
+			log.Fatalf("Failed to load migrations: %v", err)
+		}
+
+		migrator := pivot.NewMigrator(db, migrations...)
+		migrator.DryRun = c.Bool(`dry-run`)
+
+		return migrator
+	} else {
+		log.Fatalf("Failed to connect to backend: %v", err)
+		return nil
+	}
+}
+
 func populateNetrc(c *cli.Context) {
 	if netrc := c.String(`netrc`); c.Bool(`allow-netrc`) {
 		if netrc == `` {
@@ -147,6 +147,26 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 			httputil.RespondJSON(w, status)
 		})
 
+	router.Get(`/api/openapi.json`,
+		func(w http.ResponseWriter, req *http.Request) {
+			if names, err := self.backend.ListCollections(); err == nil {
+				collections := make([]*dal.Collection, 0)
+
+				for _, name := range names {
+					if collection, err := self.backend.GetCollection(name); err == nil {
+						collections = append(collections, collection)
+					} else {
+						httputil.RespondJSON(w, err)
+						return
+					}
+				}
+
+				httputil.RespondJSON(w, GenerateOpenAPI(collections))
+			} else {
+				httputil.RespondJSON(w, err)
+			}
+		})
+
 	router.Get(`/api/collections/:collection`,
 		func(w http.ResponseWriter, req *http.Request) {
 			name := vestigo.Param(req, `collection`)
@@ -341,6 +361,54 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 			}
 		})
 
+	router.Get(`/api/collections/:collection/group/*fields`,
+		func(w http.ResponseWriter, req *http.Request) {
+			name := vestigo.Param(req, `collection`)
+			fieldNames := strings.TrimPrefix(vestigo.Param(req, `_name`), `/`)
+			aggregates := make([]filter.Aggregate, 0)
+
+			// aggregates are given as a comma-separated list of keys (e.g.: "sum:amount,count:id")
+			for _, key := range strings.Split(httputil.Q(req, `fn`), `,`) {
+				if key == `` {
+					continue
+				}
+
+				fn, field := filter.SplitModifierToken(key)
+
+				if aggregation, err := filter.ParseAggregation(fn); err == nil {
+					aggregates = append(aggregates, filter.Aggregate{
+						Aggregation: aggregation,
+						Field:       field,
+					})
+				} else {
+					httputil.RespondJSON(w, err, http.StatusBadRequest)
+					return
+				}
+			}
+
+			if f, err := filterFromRequest(req, httputil.Q(req, `q`, `all`), 0); err == nil {
+				if collection, err := self.backend.GetCollection(name); err == nil {
+					collection = injectRequestParamsIntoCollection(req, collection)
+
+					if aggregator := self.backend.WithAggregator(collection); aggregator != nil {
+						if recordset, err := aggregator.GroupBy(collection, strings.Split(fieldNames, `/`), aggregates, f); err == nil {
+							httputil.RespondJSON(w, recordset)
+						} else {
+							httputil.RespondJSON(w, err)
+						}
+					} else {
+						httputil.RespondJSON(w, fmt.Errorf("Backend %T does not support aggregations.", self.backend), http.StatusBadRequest)
+					}
+				} else if dal.IsCollectionNotFoundErr(err) {
+					httputil.RespondJSON(w, err, http.StatusNotFound)
+				} else {
+					httputil.RespondJSON(w, err)
+				}
+			} else {
+				httputil.RespondJSON(w, err, http.StatusBadRequest)
+			}
+		})
+
 	router.Delete(`/api/collections/:collection/where/*urlquery`,
 		func(w http.ResponseWriter, req *http.Request) {
 			name := vestigo.Param(req, `collection`)
@@ -389,6 +457,12 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 			if err := httputil.ParseRequest(req, &record); err == nil {
 				recordset := dal.NewRecordSet(&record)
 				name := vestigo.Param(req, `collection`)
+
+				if err := self.decodeRecords(name, recordset); err != nil {
+					httputil.RespondJSON(w, err, http.StatusBadRequest)
+					return
+				}
+
 				var err error
 
 				if self.backend.Exists(name, record.ID) {
@@ -400,7 +474,7 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 				if err == nil {
 					httputil.RespondJSON(w, &record)
 				} else {
-					httputil.RespondJSON(w, err)
+					respondWriteError(w, err)
 				}
 			} else {
 				httputil.RespondJSON(w, err, http.StatusBadRequest)
@@ -414,10 +488,15 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 			if err := httputil.ParseRequest(req, &recordset); err == nil {
 				name := vestigo.Param(req, `collection`)
 
+				if err := self.decodeRecords(name, &recordset); err != nil {
+					httputil.RespondJSON(w, err, http.StatusBadRequest)
+					return
+				}
+
 				if err := self.backend.Insert(name, &recordset); err == nil {
 					httputil.RespondJSON(w, &recordset)
 				} else {
-					httputil.RespondJSON(w, err)
+					respondWriteError(w, err)
 				}
 			} else {
 				httputil.RespondJSON(w, err, http.StatusBadRequest)
@@ -431,10 +510,15 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 			if err := httputil.ParseRequest(req, &recordset); err == nil {
 				name := vestigo.Param(req, `collection`)
 
+				if err := self.decodeRecords(name, &recordset); err != nil {
+					httputil.RespondJSON(w, err, http.StatusBadRequest)
+					return
+				}
+
 				if err := self.backend.Update(name, &recordset); err == nil {
 					httputil.RespondJSON(w, nil)
 				} else {
-					httputil.RespondJSON(w, err)
+					respondWriteError(w, err)
 				}
 			} else {
 				httputil.RespondJSON(w, err, http.StatusBadRequest)
@@ -465,10 +549,15 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 			name := vestigo.Param(req, `collection`)
 
 			if err := json.NewDecoder(req.Body).Decode(&recordset); err == nil {
+				if err := self.decodeRecords(name, &recordset); err != nil {
+					httputil.RespondJSON(w, err, http.StatusBadRequest)
+					return
+				}
+
 				if err := self.backend.Insert(name, &recordset); err == nil {
 					httputil.RespondJSON(w, nil)
 				} else {
-					httputil.RespondJSON(w, err)
+					respondWriteError(w, err)
 				}
 			} else {
 				httputil.RespondJSON(w, err, http.StatusBadRequest)
@@ -481,10 +570,15 @@ func (self *Server) setupRoutes(router *vestigo.Router) error {
 			name := vestigo.Param(req, `collection`)
 
 			if err := json.NewDecoder(req.Body).Decode(&recordset); err == nil {
+				if err := self.decodeRecords(name, &recordset); err != nil {
+					httputil.RespondJSON(w, err, http.StatusBadRequest)
+					return
+				}
+
 				if err := self.backend.Update(name, &recordset); err == nil {
 					httputil.RespondJSON(w, nil)
 				} else {
-					httputil.RespondJSON(w, err)
+					respondWriteError(w, err)
 				}
 			} else {
 				httputil.RespondJSON(w, err, http.StatusBadRequest)
@@ -637,3 +731,33 @@ func filterFromRequest(req *http.Request, filterIn interface{}, defaultLimit int
 
 	return f, nil
 }
+
+// Byte slices are encoded in JSON as base64 strings, so the values of bytes fields in records read
+// from a request body are decoded before they are written.  Records for collections that don't
+// exist are left for the write itself to fail.
+func (self *Server) decodeRecords(name string, recordset *dal.RecordSet) error {
+	if collection, err := self.backend.GetCollection(name); err == nil {
+		for _, record := range recordset.Records {
+			if err := collection.DecodeJSONRecord(record); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// responds to a failed write; validation errors are rendered as a 422 listing each failing field
+func respondWriteError(w http.ResponseWriter, err error) {
+	if verr, ok := err.(*dal.ValidationError); ok {
+		httputil.RespondJSON(w, map[string]interface{}{
+			`success`:    false,
+			`error`:      verr.Error(),
+			`collection`: verr.Collection,
+			`errors`:     verr.Errors,
+			`fields`:     verr.Fields(),
+		}, http.StatusUnprocessableEntity)
+	} else {
+		httputil.RespondJSON(w, err)
+	}
+}
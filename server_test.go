@@ -0,0 +1,234 @@
+package pivot
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/husobee/vestigo"
+	"github.com/sniperkit/pivot/dal"
+	"github.com/sniperkit/pivot/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerValidationErrors(t *testing.T) {
+	assert := require.New(t)
+
+	tmpdir, err := ioutil.TempDir("", "TestServerValidationErrors")
+	assert.Nil(err)
+	defer os.RemoveAll(tmpdir)
+
+	db, err := NewDatabase(`sqlite:///` + filepath.Join(tmpdir, `test.db`))
+	assert.Nil(err)
+
+	assert.Nil(db.CreateCollection(
+		dal.NewCollection(`server_widgets`).AddFields(dal.Field{
+			Name:     `name`,
+			Type:     dal.StringType,
+			Required: true,
+		}, dal.Field{
+			Name:      `color`,
+			Type:      dal.StringType,
+			Validator: dal.ValidateIsOneOf(`red`, `green`, `blue`),
+		})))
+
+	server := NewServer(db.GetConnectionString().String())
+	server.backend = db
+	router := vestigo.NewRouter()
+	assert.Nil(server.setupRoutes(router))
+
+	body, err := json.Marshal(dal.NewRecordSet(
+		dal.NewRecord(1).Set(`color`, `orange`),
+	))
+	assert.Nil(err)
+
+	req := httptest.NewRequest(`POST`, `/api/collections/server_widgets/records`, bytes.NewReader(body))
+	req.Header.Set(`Content-Type`, `application/json`)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(http.StatusUnprocessableEntity, w.Code)
+
+	var response struct {
+		Collection string              `json:"collection"`
+		Errors     []dal.FieldError    `json:"errors"`
+		Fields     map[string][]string `json:"fields"`
+	}
+
+	assert.Nil(json.NewDecoder(w.Body).Decode(&response))
+	assert.Equal(`server_widgets`, response.Collection)
+	assert.Len(response.Errors, 2)
+	assert.Equal(`color`, response.Errors[0].Field)
+	assert.Equal(`validator`, response.Errors[0].Rule)
+	assert.Equal(`name`, response.Errors[1].Field)
+	assert.Equal(`required`, response.Errors[1].Rule)
+	assert.Equal([]string{`is required`}, response.Fields[`name`])
+
+	// nothing should have been written
+	assert.False(db.Exists(`server_widgets`, 1))
+}
+
+// serves the API for the given backend on a local listener
+func newTestRemoteServer(connectionString string) (*httptest.Server, error) {
+	if db, err := NewDatabase(connectionString); err == nil {
+		server := NewServer(connectionString)
+		server.backend = db
+		router := vestigo.NewRouter()
+
+		if err := server.setupRoutes(router); err != nil {
+			return nil, err
+		}
+
+		return httptest.NewServer(router), nil
+	} else {
+		return nil, err
+	}
+}
+
+func TestRemoteBackend(t *testing.T) {
+	assert := require.New(t)
+
+	tmpdir, err := ioutil.TempDir("", "TestRemoteBackend")
+	assert.Nil(err)
+	defer os.RemoveAll(tmpdir)
+
+	server, err := newTestRemoteServer(`sqlite:///` + filepath.Join(tmpdir, `test.db`))
+	assert.Nil(err)
+	defer server.Close()
+
+	db, err := NewDatabase(`pivot+` + server.URL)
+	assert.Nil(err)
+
+	assert.Nil(db.CreateCollection(
+		dal.NewCollection(`remote_widgets`).AddFields(dal.Field{
+			Name:     `name`,
+			Type:     dal.StringType,
+			Required: true,
+		}, dal.Field{
+			Name: `color`,
+			Type: dal.StringType,
+		}, dal.Field{
+			Name: `count`,
+			Type: dal.IntType,
+		})))
+
+	names, err := db.ListCollections()
+	assert.Nil(err)
+	assert.Contains(names, `remote_widgets`)
+
+	assert.Nil(db.Insert(`remote_widgets`, dal.NewRecordSet(
+		dal.NewRecord(1).Set(`name`, `one`).Set(`color`, `red`).Set(`count`, 1),
+		dal.NewRecord(2).Set(`name`, `two`).Set(`color`, `blue`).Set(`count`, 2),
+		dal.NewRecord(3).Set(`name`, `three`).Set(`color`, `red`).Set(`count`, 3),
+	)))
+
+	record, err := db.Retrieve(`remote_widgets`, 2)
+	assert.Nil(err)
+	assert.EqualValues(2, record.ID)
+	assert.Equal(`two`, record.Get(`name`))
+	assert.Equal(int64(2), record.Get(`count`))
+
+	assert.True(db.Exists(`remote_widgets`, 3))
+	assert.False(db.Exists(`remote_widgets`, 4))
+
+	_, err = db.Retrieve(`remote_widgets`, 4)
+	assert.True(dal.IsNotExistError(err))
+
+	// validation errors are returned as they would be locally
+	err = db.Insert(`remote_widgets`, dal.NewRecordSet(dal.NewRecord(5)))
+	assert.IsType(&dal.ValidationError{}, err)
+	assert.Equal(`name`, err.(*dal.ValidationError).Errors[0].Field)
+
+	assert.Nil(db.Update(`remote_widgets`, dal.NewRecordSet(
+		dal.NewRecord(2).Set(`name`, `TWO`).Set(`color`, `blue`).Set(`count`, 2),
+	)))
+
+	record, err = db.Retrieve(`remote_widgets`, 2)
+	assert.Nil(err)
+	assert.Equal(`TWO`, record.Get(`name`))
+
+	collection, err := db.GetCollection(`remote_widgets`)
+	assert.Nil(err)
+
+	search := db.WithSearch(collection)
+	assert.NotNil(search)
+
+	recordset, err := search.Query(collection, filter.MustParse(`int:count/gte:2`).SortBy(`-count`))
+	assert.Nil(err)
+	assert.EqualValues(2, recordset.ResultCount)
+	assert.Len(recordset.Records, 2)
+	assert.EqualValues(3, recordset.Records[0].ID)
+	assert.Equal(`three`, recordset.Records[0].Get(`name`))
+	assert.EqualValues(2, recordset.Records[1].ID)
+
+	values, err := search.ListValues(collection, []string{`color`}, filter.All())
+	assert.Nil(err)
+	assert.ElementsMatch([]interface{}{`red`, `blue`}, values[`color`])
+
+	aggregator := db.WithAggregator(collection)
+	assert.NotNil(aggregator)
+
+	count, err := aggregator.Count(collection)
+	assert.Nil(err)
+	assert.EqualValues(3, count)
+
+	count, err = aggregator.Count(collection, filter.MustParse(`color/red`))
+	assert.Nil(err)
+	assert.EqualValues(2, count)
+
+	sum, err := aggregator.Sum(collection, `count`)
+	assert.Nil(err)
+	assert.Equal(float64(6), sum)
+
+	max, err := aggregator.Maximum(collection, `count`, filter.MustParse(`color/red`))
+	assert.Nil(err)
+	assert.Equal(float64(3), max)
+
+	f := filter.All().SortBy(`-sum:count`)
+	f.AddHaving(filter.Criterion{
+		Field:    `count:count`,
+		Operator: `gte`,
+		Values:   []interface{}{1},
+	})
+
+	groups, err := aggregator.GroupBy(collection, []string{`color`}, []filter.Aggregate{
+		{Aggregation: filter.Sum, Field: `count`},
+		{Aggregation: filter.Count, Field: `count`},
+	}, f)
+
+	assert.Nil(err)
+	assert.Len(groups.Records, 2)
+	assert.Equal(`red`, groups.Records[0].Get(`color`))
+	assert.EqualValues(4, groups.Records[0].Get(`sum:count`))
+	assert.EqualValues(2, groups.Records[0].Get(`count:count`))
+	assert.Equal(`blue`, groups.Records[1].Get(`color`))
+	assert.EqualValues(2, groups.Records[1].Get(`sum:count`))
+
+	f.Having[0].Values = []interface{}{2}
+
+	groups, err = aggregator.GroupBy(collection, []string{`color`}, []filter.Aggregate{
+		{Aggregation: filter.Sum, Field: `count`},
+		{Aggregation: filter.Count, Field: `count`},
+	}, f)
+
+	assert.Nil(err)
+	assert.Len(groups.Records, 1)
+	assert.Equal(`red`, groups.Records[0].Get(`color`))
+
+	assert.Nil(db.Delete(`remote_widgets`, 1))
+	assert.False(db.Exists(`remote_widgets`, 1))
+
+	assert.Nil(search.DeleteQuery(collection, filter.MustParse(`color/blue`)))
+	assert.False(db.Exists(`remote_widgets`, 2))
+	assert.True(db.Exists(`remote_widgets`, 3))
+
+	assert.Nil(db.DeleteCollection(`remote_widgets`))
+
+	_, err = db.GetCollection(`remote_widgets`)
+	assert.NotNil(err)
+}